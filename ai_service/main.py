@@ -1,7 +1,7 @@
 # ai_service/main.py
 from fastapi import FastAPI, HTTPException
 from pydantic import BaseModel
-from typing import Optional
+from typing import Any, Dict, Optional
 import datetime
 import logging
 
@@ -23,6 +23,9 @@ class SecurityAlertInput(BaseModel):
     username: Optional[str] = None
     file_hash: Optional[str] = None
     status: Optional[str] = None # Current status from Go, likely 'new'
+    occurrence_count: Optional[int] = None # Times the alert was seen, re-sent when it escalates
+    attributes: Optional[Dict[str, str]] = None # Vendor fields without a field of their own, e.g. CEF extensions
+    raw_payload: Optional[Any] = None # The payload the alert was submitted as: a JSON document, or the CEF/syslog line as a string
 
 # Define the output model for the analyzed alert
 # This includes the original fields plus new AI-generated fields
@@ -67,6 +70,14 @@ async def analyze_alert(alert_input: SecurityAlertInput):
         risk_score = 0.75
         recommended_action = "Block source IP, review firewall logs."
 
+    # Repeated alerts are more likely to be real: +0.05 per order of magnitude of occurrences
+    occurrences = alert_input.occurrence_count or 1
+    boost = 0.0
+    while occurrences >= 10:
+        boost += 0.05
+        occurrences //= 10
+    risk_score = round(min(1.0, risk_score + boost), 3)
+
     # Add more sophisticated rules or load a model here later
     # For example, using scikit-learn:
     # from sklearn.ensemble import RandomForestClassifier
@@ -89,6 +100,9 @@ async def analyze_alert(alert_input: SecurityAlertInput):
         username=alert_input.username,
         file_hash=alert_input.file_hash,
         status=alert_input.status,
+        occurrence_count=alert_input.occurrence_count,
+        attributes=alert_input.attributes,
+        raw_payload=alert_input.raw_payload,
         predicted_severity=predicted_severity,
         risk_score=risk_score,
         recommended_action=recommended_action
@@ -0,0 +1,72 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "os"
+    "strconv"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/config"
+    "github.com/Kelvinkhyd/GuardianAI/internal/database"
+)
+
+// migrate manages the database schema.
+//
+// Usage: go run ./cmd/migrate up|down [N]|status
+//
+//	up      applies every pending migration
+//	down N  reverts the last N applied migrations (default 1)
+//	status  lists every migration and whether it has been applied
+func main() {
+    if len(os.Args) < 2 {
+        log.Fatalf("Usage: %s up|down [N]|status", os.Args[0])
+    }
+
+    cfg := config.LoadConfig()
+    dbConn, err := database.NewDBConnection(cfg.DatabaseURL)
+    if err != nil {
+        log.Fatalf("Failed to connect to database: %v", err)
+    }
+    defer dbConn.Close()
+
+    ctx := context.Background()
+    switch os.Args[1] {
+    case "up":
+        applied, err := dbConn.MigrateUp(ctx)
+        if err != nil {
+            log.Fatalf("Migration failed after %d migrations: %v", applied, err)
+        }
+        log.Printf("Applied %d migrations.", applied)
+
+    case "down":
+        steps := 1
+        if len(os.Args) > 2 {
+            steps, err = strconv.Atoi(os.Args[2])
+            if err != nil || steps < 1 {
+                log.Fatalf("Invalid number of migrations to revert: %q", os.Args[2])
+            }
+        }
+        reverted, err := dbConn.MigrateDown(ctx, steps)
+        if err != nil {
+            log.Fatalf("Revert failed after %d migrations: %v", reverted, err)
+        }
+        log.Printf("Reverted %d migrations.", reverted)
+
+    case "status":
+        statuses, err := dbConn.MigrationStatus(ctx)
+        if err != nil {
+            log.Fatalf("Failed to read migration status: %v", err)
+        }
+        for _, s := range statuses {
+            state := "pending"
+            if s.Applied {
+                state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05 MST")
+            }
+            fmt.Printf("%04d  %-40s %s\n", s.Version, s.Name, state)
+        }
+
+    default:
+        log.Fatalf("Unknown command %q, expected up, down or status", os.Args[1])
+    }
+}
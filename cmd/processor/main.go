@@ -1,24 +1,19 @@
 package main
 
 import (
-    "bytes"
     "context"
     "encoding/json"
-    "fmt"        // <--- ADDED THIS IMPORT
-    "io/ioutil"
     "log"
     "net/http"
     "os"
     "os/signal"
     "syscall"
-    "time"
-
-    kafkalib "github.com/segmentio/kafka-go"
 
+    "github.com/Kelvinkhyd/GuardianAI/internal/breaker"
     "github.com/Kelvinkhyd/GuardianAI/internal/config"
     "github.com/Kelvinkhyd/GuardianAI/internal/database"
     "github.com/Kelvinkhyd/GuardianAI/internal/kafka"
-    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+    "github.com/Kelvinkhyd/GuardianAI/internal/processor"
     "github.com/Kelvinkhyd/GuardianAI/internal/repository"
 )
 
@@ -32,20 +27,70 @@ func main() {
     }
     defer dbConn.Close()
 
+    // Bring the schema up to date
+    if err := dbConn.Migrate(context.Background(), cfg.AutoMigrate); err != nil {
+        log.Fatalf("Processor failed to migrate database: %v", err)
+    }
+
     alertRepo := repository.NewPgAlertRepository(dbConn.DB)
+    incidentRepo := repository.NewPgIncidentRepository(dbConn.DB)
 
     // Initialize Kafka Consumer
-    consumerGroupID := "guardianai-alert-processor-group"
-    kafkaConsumer := kafka.NewConsumer(cfg.KafkaBrokers, cfg.KafkaTopic, consumerGroupID)
+    kafkaConsumer := kafka.NewConsumer(cfg.KafkaBrokers, cfg.KafkaTopic, processor.ConsumerGroupID, processor.RetryPolicy(cfg), cfg.ProcessorWorkers)
     defer kafkaConsumer.Close()
 
-    // Define the AI Service URL (accessible within the main function scope)
-    // Use Docker service name for inter-container communication: guardianai_ai_service
-    aiServiceURL := "http://localhost:8000/analyze-alert"
-    log.Printf("AI Service URL: %s", aiServiceURL)
+    // Set up alert analysis: the AI service behind a circuit breaker, falling back
+    // to the local rules engine when it is unavailable
+    alertProcessor := processor.NewFromConfig(cfg, alertRepo)
+
+    // Group analysed alerts into incidents
+    correlator, err := processor.CorrelatorFromConfig(cfg, incidentRepo)
+    if err != nil {
+        log.Fatalf("Processor failed to start: %v", err)
+    }
+    if correlator != nil {
+        alertProcessor.SetCorrelator(correlator)
+    }
+
+    // Evaluate Sigma detection rules, if configured
+    sigmaEngine, err := processor.SigmaFromConfig(cfg)
+    if err != nil {
+        log.Fatalf("Processor failed to start: %v", err)
+    }
+    if sigmaEngine != nil {
+        alertProcessor.SetSigma(sigmaEngine, cfg.SigmaReloadInterval)
+    }
+    tenantSigma, err := processor.TenantSigmaFromConfig(cfg)
+    if err != nil {
+        log.Fatalf("Processor failed to start: %v", err)
+    }
+    for tenant, engine := range tenantSigma {
+        alertProcessor.SetTenantSigma(tenant, engine, cfg.SigmaReloadInterval)
+    }
+
+    // Match alerts against threat intel feeds, if configured
+    threatIntel, err := processor.ThreatIntelFromConfig(cfg)
+    if err != nil {
+        log.Fatalf("Processor failed to start: %v", err)
+    }
+    if threatIntel != nil {
+        alertProcessor.SetThreatIntel(threatIntel, cfg.ThreatIntelReloadInterval)
+    }
+
+    // Locate source and target addresses, if GeoIP databases are configured
+    geoIP, err := processor.GeoIPFromConfig(cfg)
+    if err != nil {
+        log.Fatalf("Processor failed to start: %v", err)
+    }
+    if geoIP != nil {
+        defer geoIP.Close()
+        alertProcessor.SetGeoIP(geoIP)
+    }
 
+    // Expose health and breaker state
+    go serveHealth(cfg.ProcessorHealthAddr, alertProcessor.Breaker())
 
-    log.Println("GuardianAI Alert Processor starting...")
+    log.Printf("GuardianAI Alert Processor starting with %d workers...", cfg.ProcessorWorkers)
 
     // Context for graceful shutdown
     ctx, cancel := context.WithCancel(context.Background())
@@ -62,67 +107,29 @@ func main() {
     }()
 
     // Start consuming messages
-    kafkaConsumer.ConsumeMessages(ctx, func(message kafkalib.Message) error {
-        var alert models.SecurityAlert // This will be the original alert from Kafka
-        err := json.Unmarshal(message.Value, &alert)
-        if err != nil {
-            log.Printf("ERROR Processor: Failed to unmarshal alert JSON from Kafka: %v", err)
-            return nil // Do not reprocess this bad message, skip it
-        }
-
-        log.Printf("Processor: Received alert ID: %s, Source: %s from Kafka for analysis.", alert.ID, alert.Source)
+    alertProcessor.Run(ctx, kafkaConsumer)
 
-        // --- Step 1: Send alert to AI Service for analysis ---
-        alertJSON, err := json.Marshal(alert)
-        if err != nil {
-            log.Printf("ERROR Processor: Failed to marshal alert for AI service: %v", err)
-            return err // Re-queue if marshaling failed (shouldn't happen often)
-        }
-
-        req, err := http.NewRequestWithContext(context.Background(), "POST", aiServiceURL, bytes.NewBuffer(alertJSON))
-        if err != nil {
-            log.Printf("ERROR Processor: Failed to create AI service request: %v", err)
-            return err // Re-queue
-        }
-        req.Header.Set("Content-Type", "application/json")
-
-        client := &http.Client{Timeout: 10 * time.Second} // Set a timeout for AI service call
-        resp, err := client.Do(req)
-        if err != nil {
-            log.Printf("ERROR Processor: Failed to call AI service for alert %s: %v", alert.ID, err)
-            return err // Re-queue if AI service is unreachable or responds with error
-        }
-        defer resp.Body.Close()
-
-        if resp.StatusCode != http.StatusOK {
-            bodyBytes, _ := ioutil.ReadAll(resp.Body)
-            log.Printf("ERROR Processor: AI service returned non-OK status %d for alert %s: %s", resp.StatusCode, alert.ID, string(bodyBytes))
-            return fmt.Errorf("AI service error: status %d", resp.StatusCode) // <--- fmt.Errorf requires fmt import
-        }
-
-        var analyzedAlert models.SecurityAlert // Use models.SecurityAlert as it now has AI fields
-        err = json.NewDecoder(resp.Body).Decode(&analyzedAlert)
-        if err != nil {
-            log.Printf("ERROR Processor: Failed to unmarshal AI service response for alert %s: %v", alert.ID, err)
-            return err // Re-queue
-        }
-
-        log.Printf("Processor: AI analysis complete for alert ID: %s. Predicted Severity: %s, Risk Score: %.2f",
-            analyzedAlert.ID, analyzedAlert.PredictedSeverity, analyzedAlert.RiskScore)
-
-        // --- Step 2: Update alert in database with AI results ---
-        // The AI service returns the full alert with AI fields populated.
-        // We set status to 'analyzed' after AI processing.
-        analyzedAlert.Status = "analyzed"
-        err = alertRepo.UpdateAlertWithAIResults(context.Background(), &analyzedAlert) // Pass the full analyzedAlert
-        if err != nil {
-            log.Printf("ERROR Processor: Failed to update alert %s with AI results in DB: %v", alert.ID, err)
-            return err // Re-queue if DB update failed
+    log.Println("GuardianAI Alert Processor stopped.")
+}
+
+// serveHealth serves GET /healthz with the state of the AI service circuit breaker.
+func serveHealth(addr string, aiBreaker *breaker.Breaker) {
+    mux := http.NewServeMux()
+    mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+        snap := aiBreaker.Snapshot()
+        status := "ok"
+        if snap.State != breaker.Closed {
+            status = "degraded"
         }
-        log.Printf("Processor: Alert ID: %s updated in DB with AI results and status 'analyzed'.", alert.ID)
-
-        return nil // Message processed successfully
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(map[string]interface{}{
+            "status":             status,
+            "ai_service_breaker": snap,
+        })
     })
 
-    log.Println("GuardianAI Alert Processor stopped.")
+    log.Printf("Processor health endpoint listening on %s", addr)
+    if err := http.ListenAndServe(addr, mux); err != nil {
+        log.Printf("ERROR Processor: Health endpoint stopped: %v", err)
+    }
 }
\ No newline at end of file
@@ -0,0 +1,45 @@
+package main
+
+import (
+    "context"
+    "flag"
+    "log"
+    "os"
+    "os/signal"
+    "syscall"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/config"
+    "github.com/Kelvinkhyd/GuardianAI/internal/kafka"
+)
+
+// redrive moves messages from the processor's dead-letter topic back to the topic
+// they came from, e.g. once the AI service is healthy again or a bug has been fixed.
+//
+// Usage: go run ./cmd/redrive [-max N] [-idle 10s] [-dry-run]
+func main() {
+    max := flag.Int("max", 0, "maximum number of messages to re-drive (0 = all)")
+    idle := flag.Duration("idle", 10*time.Second, "stop once no message arrived for this long")
+    dryRun := flag.Bool("dry-run", false, "only log the messages that would be re-driven")
+    groupID := flag.String("group", "guardianai-dlq-redrive", "consumer group used to read the dead-letter topic")
+    flag.Parse()
+
+    cfg := config.LoadConfig()
+
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+
+    sigChan := make(chan os.Signal, 1)
+    signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+    go func() {
+        <-sigChan
+        cancel()
+    }()
+
+    log.Printf("Re-driving messages from %s back to their original topic...", cfg.KafkaDLQTopic)
+    moved, err := kafka.Redrive(ctx, cfg.KafkaBrokers, cfg.KafkaDLQTopic, *groupID, cfg.KafkaTopic, *max, *idle, *dryRun)
+    if err != nil {
+        log.Fatalf("Re-drive failed after %d messages: %v", moved, err)
+    }
+    log.Printf("Re-drove %d messages.", moved)
+}
@@ -0,0 +1,86 @@
+package analyzer
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// ErrUnavailable is wrapped by analyzer errors that mean the analyzer could not be
+// reached or is overloaded, as opposed to errors about the alert itself. Fallback
+// only switches to its secondary analyzer for these.
+var ErrUnavailable = errors.New("analyzer unavailable")
+
+// Analyzer produces AI/ML insights for a security alert.
+type Analyzer interface {
+    // Name identifies the analyzer; it is recorded in the alert's ai_model_version.
+    Name() string
+    // Analyze returns a copy of alert with PredictedSeverity, RiskScore,
+    // RecommendedAction and AIModelVersion filled in.
+    Analyze(ctx context.Context, alert models.SecurityAlert) (*models.SecurityAlert, error)
+}
+
+// modelVersion formats the ai_model_version recorded for a result, so it is always
+// visible which analyzer produced it, e.g. "ai-service:v1.0.0_dummy_model".
+func modelVersion(analyzerName, version string) string {
+    if version == "" {
+        return analyzerName
+    }
+    return fmt.Sprintf("%s:%s", analyzerName, version)
+}
+
+// Fallback uses a primary analyzer and switches to a secondary one whenever the
+// primary is unavailable, e.g. the in-process rules engine when the AI service is down.
+type Fallback struct {
+    primary   Analyzer
+    secondary Analyzer
+}
+
+// NewFallback creates an analyzer that prefers primary and falls back to secondary.
+func NewFallback(primary, secondary Analyzer) *Fallback {
+    return &Fallback{primary: primary, secondary: secondary}
+}
+
+// Name returns the name of the primary analyzer.
+func (f *Fallback) Name() string {
+    return f.primary.Name()
+}
+
+// Analyze runs the primary analyzer, and the secondary one if the primary is unavailable.
+func (f *Fallback) Analyze(ctx context.Context, alert models.SecurityAlert) (*models.SecurityAlert, error) {
+    result, err := f.primary.Analyze(ctx, alert)
+    if err == nil || !errors.Is(err, ErrUnavailable) || ctx.Err() != nil {
+        return result, err
+    }
+    log.Printf("Analyzer %s unavailable for alert %s, falling back to %s: %v", f.primary.Name(), alert.ID, f.secondary.Name(), err)
+    return f.secondary.Analyze(ctx, alert)
+}
+
+// TenantRouter sends the alerts of some tenants to their own analyzer, e.g. an AI
+// service with a model trained on that tenant's data, and all others to a default one.
+type TenantRouter struct {
+    def     Analyzer
+    tenants map[string]Analyzer
+}
+
+// NewTenantRouter creates an analyzer that routes alerts by tenant, using def for
+// tenants without an entry in tenants.
+func NewTenantRouter(def Analyzer, tenants map[string]Analyzer) *TenantRouter {
+    return &TenantRouter{def: def, tenants: tenants}
+}
+
+// Name returns the name of the default analyzer.
+func (t *TenantRouter) Name() string {
+    return t.def.Name()
+}
+
+// Analyze runs the analyzer of the alert's tenant.
+func (t *TenantRouter) Analyze(ctx context.Context, alert models.SecurityAlert) (*models.SecurityAlert, error) {
+    if a, ok := t.tenants[alert.TenantID]; ok {
+        return a.Analyze(ctx, alert)
+    }
+    return t.def.Analyze(ctx, alert)
+}
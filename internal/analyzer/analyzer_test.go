@@ -0,0 +1,80 @@
+package analyzer
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "testing"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// stubAnalyzer returns a fixed error, or the alert with its name as the model version.
+type stubAnalyzer struct {
+    name  string
+    err   error
+    calls int
+}
+
+func (s *stubAnalyzer) Name() string { return s.name }
+
+func (s *stubAnalyzer) Analyze(ctx context.Context, alert models.SecurityAlert) (*models.SecurityAlert, error) {
+    s.calls++
+    if s.err != nil {
+        return nil, s.err
+    }
+    alert.AIModelVersion = s.name
+    return &alert, nil
+}
+
+func TestFallback(t *testing.T) {
+    tests := []struct {
+        name         string
+        primaryErr   error
+        cancelled    bool
+        wantAnalyzer string // "" if an error is expected
+    }{
+        {"primary succeeds", nil, false, "primary"},
+        {"primary unavailable", ErrUnavailable, false, "secondary"},
+        {"primary unavailable, wrapped", fmt.Errorf("AI service error: status 503: %w", ErrUnavailable), false, "secondary"},
+        {"alert rejected", errors.New("AI service error: status 422: invalid alert"), false, ""},
+        {"caller gave up", fmt.Errorf("failed to call AI service: %w", ErrUnavailable), true, ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            primary := &stubAnalyzer{name: "primary", err: tt.primaryErr}
+            secondary := &stubAnalyzer{name: "secondary"}
+            f := NewFallback(primary, secondary)
+
+            ctx, cancel := context.WithCancel(context.Background())
+            defer cancel()
+            if tt.cancelled {
+                cancel()
+            }
+            got, err := f.Analyze(ctx, models.SecurityAlert{ID: "alert-1"})
+
+            if tt.wantAnalyzer == "" {
+                if err == nil || !errors.Is(err, tt.primaryErr) {
+                    t.Fatalf("Analyze error = %v, want the primary's error %v", err, tt.primaryErr)
+                }
+                if secondary.calls != 0 {
+                    t.Fatalf("secondary called %d times, want 0", secondary.calls)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("Analyze: %v", err)
+            }
+            if got.AIModelVersion != tt.wantAnalyzer {
+                t.Errorf("analysed by %s, want %s", got.AIModelVersion, tt.wantAnalyzer)
+            }
+            if primary.calls != 1 {
+                t.Errorf("primary called %d times, want 1", primary.calls)
+            }
+        })
+    }
+
+    if name := NewFallback(&stubAnalyzer{name: "primary"}, &stubAnalyzer{name: "secondary"}).Name(); name != "primary" {
+        t.Errorf("Name() = %q, want the primary's name", name)
+    }
+}
@@ -0,0 +1,120 @@
+package analyzer
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/breaker"
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// HTTPAnalyzer calls the Python AI service (ai_service/main.py) over HTTP.
+// Calls go through a circuit breaker: once the service has failed repeatedly,
+// calls fail fast with ErrUnavailable instead of each waiting for the timeout.
+type HTTPAnalyzer struct {
+    url     string
+    timeout time.Duration
+    client  *http.Client // Shared across calls so connections are reused
+    breaker *breaker.Breaker
+}
+
+// NewHTTPAnalyzer creates an analyzer for the AI service endpoint at url.
+// Each call is bounded by timeout (or the caller's deadline, if sooner).
+// br may be nil to call the service without a circuit breaker.
+func NewHTTPAnalyzer(url string, timeout time.Duration, br *breaker.Breaker) *HTTPAnalyzer {
+    transport := &http.Transport{
+        Proxy:               http.ProxyFromEnvironment,
+        DialContext:         (&net.Dialer{Timeout: 2 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+        MaxIdleConns:        100,
+        MaxIdleConnsPerHost: 32, // One idle connection per processor worker, with headroom
+        IdleConnTimeout:     90 * time.Second,
+    }
+    return &HTTPAnalyzer{
+        url:     url,
+        timeout: timeout,
+        client:  &http.Client{Transport: transport},
+        breaker: br,
+    }
+}
+
+// Name implements Analyzer.
+func (a *HTTPAnalyzer) Name() string {
+    return "ai-service"
+}
+
+// Analyze sends the alert to the AI service and returns its analysis.
+// Network failures, timeouts, 5xx and 429 responses, and calls rejected by the
+// open circuit breaker are reported as ErrUnavailable.
+func (a *HTTPAnalyzer) Analyze(ctx context.Context, alert models.SecurityAlert) (*models.SecurityAlert, error) {
+    if a.breaker == nil {
+        return a.call(ctx, alert)
+    }
+
+    done, err := a.breaker.Allow()
+    if err != nil {
+        return nil, fmt.Errorf("AI service call rejected: %v: %w", err, ErrUnavailable)
+    }
+    result, err := a.call(ctx, alert)
+    if err != nil && ctx.Err() != nil {
+        // Our own caller gave up (e.g. shutdown); that says nothing about the service.
+        done(breaker.Ignored)
+        return nil, err
+    }
+    if err != nil && errors.Is(err, ErrUnavailable) {
+        done(breaker.Failure)
+    } else {
+        done(breaker.Success) // A 4xx about this alert doesn't count against the service
+    }
+    return result, err
+}
+
+// Breaker returns the circuit breaker guarding the AI service, or nil.
+func (a *HTTPAnalyzer) Breaker() *breaker.Breaker {
+    return a.breaker
+}
+
+// call performs one request to the AI service within the timeout budget.
+func (a *HTTPAnalyzer) call(ctx context.Context, alert models.SecurityAlert) (*models.SecurityAlert, error) {
+    ctx, cancel := context.WithTimeout(ctx, a.timeout)
+    defer cancel()
+
+    alertJSON, err := json.Marshal(alert)
+    if err != nil {
+        return nil, fmt.Errorf("failed to marshal alert for AI service: %w", err)
+    }
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewBuffer(alertJSON))
+    if err != nil {
+        return nil, fmt.Errorf("failed to create AI service request: %w", err)
+    }
+    req.Header.Set("Content-Type", "application/json")
+
+    resp, err := a.client.Do(req)
+    if err != nil {
+        return nil, fmt.Errorf("failed to call AI service: %v: %w", err, ErrUnavailable)
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+        err := fmt.Errorf("AI service error: status %d: %s", resp.StatusCode, string(bodyBytes))
+        if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+            return nil, fmt.Errorf("%v: %w", err, ErrUnavailable)
+        }
+        return nil, err
+    }
+
+    var analyzed models.SecurityAlert // The AI service returns the full alert with AI fields populated
+    if err := json.NewDecoder(resp.Body).Decode(&analyzed); err != nil {
+        return nil, fmt.Errorf("failed to decode AI service response: %w", err)
+    }
+    analyzed.AIModelVersion = modelVersion(a.Name(), analyzed.AIModelVersion)
+    return &analyzed, nil
+}
@@ -0,0 +1,113 @@
+package analyzer
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/breaker"
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// aiService is a stand-in for ai_service/main.py that answers with status, or with
+// the analysed alert if status is 200.
+func aiService(t *testing.T, status int) *httptest.Server {
+    t.Helper()
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        var alert models.SecurityAlert
+        if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        if status != http.StatusOK {
+            http.Error(w, "nope", status)
+            return
+        }
+        alert.PredictedSeverity = "critical"
+        alert.RiskScore = 0.9
+        alert.AIModelVersion = "v1.0.0_dummy_model"
+        json.NewEncoder(w).Encode(alert)
+    }))
+    t.Cleanup(srv.Close)
+    return srv
+}
+
+func TestHTTPAnalyzerStatus(t *testing.T) {
+    tests := []struct {
+        status          int
+        wantUnavailable bool
+    }{
+        {http.StatusBadRequest, false},
+        {http.StatusNotFound, false},
+        {http.StatusUnprocessableEntity, false},
+        {http.StatusTooManyRequests, true},
+        {http.StatusInternalServerError, true},
+        {http.StatusBadGateway, true},
+        {http.StatusServiceUnavailable, true},
+    }
+    for _, tt := range tests {
+        t.Run(http.StatusText(tt.status), func(t *testing.T) {
+            srv := aiService(t, tt.status)
+            br := breaker.New(breaker.Settings{Name: "ai-service", FailureThreshold: 1, OpenTimeout: time.Minute})
+            a := NewHTTPAnalyzer(srv.URL, time.Second, br)
+
+            _, err := a.Analyze(context.Background(), models.SecurityAlert{ID: "alert-1"})
+            if err == nil {
+                t.Fatal("Analyze succeeded, want an error")
+            }
+            if got := errors.Is(err, ErrUnavailable); got != tt.wantUnavailable {
+                t.Errorf("errors.Is(%v, ErrUnavailable) = %v, want %v", err, got, tt.wantUnavailable)
+            }
+            // Only an unavailable service counts against the breaker.
+            wantState := breaker.Closed
+            if tt.wantUnavailable {
+                wantState = breaker.Open
+            }
+            if got := br.State(); got != wantState {
+                t.Errorf("breaker is %s, want %s", got, wantState)
+            }
+        })
+    }
+}
+
+func TestHTTPAnalyzerSuccess(t *testing.T) {
+    srv := aiService(t, http.StatusOK)
+    a := NewHTTPAnalyzer(srv.URL, time.Second, nil)
+
+    got, err := a.Analyze(context.Background(), models.SecurityAlert{ID: "alert-1", Severity: "high"})
+    if err != nil {
+        t.Fatalf("Analyze: %v", err)
+    }
+    if got.ID != "alert-1" || got.PredictedSeverity != "critical" || got.RiskScore != 0.9 {
+        t.Errorf("got %+v, want the service's analysis of alert-1", got)
+    }
+    if got.AIModelVersion != "ai-service:v1.0.0_dummy_model" {
+        t.Errorf("AIModelVersion = %q, want it prefixed with the analyzer name", got.AIModelVersion)
+    }
+}
+
+func TestHTTPAnalyzerUnreachable(t *testing.T) {
+    // Nothing listens on the address of a closed server.
+    srv := httptest.NewServer(http.NotFoundHandler())
+    srv.Close()
+    a := NewHTTPAnalyzer(srv.URL, time.Second, nil)
+    if _, err := a.Analyze(context.Background(), models.SecurityAlert{ID: "alert-1"}); !errors.Is(err, ErrUnavailable) {
+        t.Errorf("Analyze error = %v, want ErrUnavailable", err)
+    }
+
+    // Neither does a service that answers too late.
+    release := make(chan struct{})
+    slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        <-release
+    }))
+    defer slow.Close()
+    defer close(release) // Before Close, which waits for the handler
+    a = NewHTTPAnalyzer(slow.URL, 50*time.Millisecond, nil)
+    if _, err := a.Analyze(context.Background(), models.SecurityAlert{ID: "alert-1"}); !errors.Is(err, ErrUnavailable) {
+        t.Errorf("Analyze error = %v, want ErrUnavailable after the timeout", err)
+    }
+}
@@ -0,0 +1,62 @@
+package analyzer
+
+import (
+    "context"
+    "math"
+    "strings"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// rulesVersion is bumped whenever the rules below change.
+const rulesVersion = "v1.1.0"
+
+// RuleAnalyzer is an in-process analyzer that reproduces the heuristics of the
+// Python AI service, so alerts keep getting analysed while the service is down.
+type RuleAnalyzer struct{}
+
+// NewRuleAnalyzer creates the local rules-based analyzer.
+func NewRuleAnalyzer() *RuleAnalyzer {
+    return &RuleAnalyzer{}
+}
+
+// Name implements Analyzer.
+func (a *RuleAnalyzer) Name() string {
+    return "go-rules"
+}
+
+// Analyze applies the rules to a copy of alert. It never fails.
+func (a *RuleAnalyzer) Analyze(ctx context.Context, alert models.SecurityAlert) (*models.SecurityAlert, error) {
+    category := strings.ToLower(alert.Category)
+    title := strings.ToLower(alert.Title)
+
+    // Defaults, as in ai_service/main.py
+    alert.PredictedSeverity = alert.Severity
+    alert.RiskScore = 0.5
+    alert.RecommendedAction = "Investigate immediately."
+
+    switch {
+    case strings.Contains(category, "login") && strings.ToLower(alert.Severity) == "high":
+        alert.PredictedSeverity = "critical"
+        alert.RiskScore = 0.9
+        alert.RecommendedAction = "Isolate user account and review audit logs."
+    case strings.Contains(category, "malware") || strings.Contains(title, "virus"):
+        alert.PredictedSeverity = "critical"
+        alert.RiskScore = 0.95
+        alert.RecommendedAction = "Quarantine host, analyze malware signature."
+    case strings.Contains(category, "network anomaly"):
+        alert.PredictedSeverity = "high"
+        alert.RiskScore = 0.75
+        alert.RecommendedAction = "Block source IP, review firewall logs."
+    }
+
+    // Repeated alerts are more likely to be real: +0.05 per order of magnitude of occurrences
+    boost := 0.0
+    for n := alert.OccurrenceCount; n >= 10; n /= 10 {
+        boost += 0.05
+    }
+    alert.RiskScore = math.Round(math.Min(1, alert.RiskScore+boost)*1000) / 1000
+
+    alert.AIModelVersion = modelVersion(a.Name(), rulesVersion)
+    return &alert, nil
+}
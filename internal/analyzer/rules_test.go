@@ -0,0 +1,72 @@
+package analyzer
+
+import (
+    "context"
+    "testing"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// The cases mirror the rules of ai_service/main.py; both must change together.
+func TestRuleAnalyzer(t *testing.T) {
+    const (
+        investigate = "Investigate immediately."
+        isolate     = "Isolate user account and review audit logs."
+        quarantine  = "Quarantine host, analyze malware signature."
+        block       = "Block source IP, review firewall logs."
+    )
+    tests := []struct {
+        name        string
+        category    string
+        title       string
+        severity    string
+        occurrences int
+        wantSev     string
+        wantScore   float64
+        wantAction  string
+    }{
+        {"high login", "Failed Login", "Brute force", "high", 0, "critical", 0.9, isolate},
+        {"case insensitive", "LOGIN", "Brute force", "HIGH", 0, "critical", 0.9, isolate},
+        {"medium login", "login", "Brute force", "medium", 0, "medium", 0.5, investigate},
+        {"login wins over malware", "login malware", "Virus", "high", 0, "critical", 0.9, isolate},
+        {"malware category", "Malware", "Suspicious binary", "low", 0, "critical", 0.95, quarantine},
+        {"virus title", "endpoint", "Virus detected on host", "low", 0, "critical", 0.95, quarantine},
+        {"network anomaly", "Network Anomaly", "Port scan", "medium", 0, "high", 0.75, block},
+        {"network without anomaly", "network", "Port scan", "medium", 0, "medium", 0.5, investigate},
+        {"default keeps severity", "policy", "USB device", "low", 0, "low", 0.5, investigate},
+
+        // +0.05 per order of magnitude of occurrences
+        {"single occurrence", "policy", "USB device", "low", 1, "low", 0.5, investigate},
+        {"below ten", "policy", "USB device", "low", 9, "low", 0.5, investigate},
+        {"ten", "policy", "USB device", "low", 10, "low", 0.55, investigate},
+        {"hundred", "policy", "USB device", "low", 100, "low", 0.6, investigate},
+        {"many", "Network Anomaly", "Port scan", "medium", 12345, "high", 0.95, block},
+        {"capped at one", "malware", "Worm", "high", 1000, "critical", 1, quarantine},
+    }
+    a := NewRuleAnalyzer()
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            alert := models.SecurityAlert{
+                ID:              "alert-1",
+                Category:        tt.category,
+                Title:           tt.title,
+                Severity:        tt.severity,
+                OccurrenceCount: tt.occurrences,
+            }
+            got, err := a.Analyze(context.Background(), alert)
+            if err != nil {
+                t.Fatalf("Analyze: %v", err)
+            }
+            if got.PredictedSeverity != tt.wantSev || got.RiskScore != tt.wantScore || got.RecommendedAction != tt.wantAction {
+                t.Errorf("got (%q, %v, %q), want (%q, %v, %q)",
+                    got.PredictedSeverity, got.RiskScore, got.RecommendedAction, tt.wantSev, tt.wantScore, tt.wantAction)
+            }
+            if got.Severity != tt.severity || got.ID != alert.ID {
+                t.Errorf("input fields changed: severity %q, ID %q", got.Severity, got.ID)
+            }
+            if got.AIModelVersion != "go-rules:"+rulesVersion {
+                t.Errorf("AIModelVersion = %q, want go-rules:%s", got.AIModelVersion, rulesVersion)
+            }
+        })
+    }
+}
@@ -3,132 +3,188 @@ package api
 import (
     "context"
     "encoding/json"
+    "errors"
     "fmt"
+    "io"
     "log"
+    "mime"
     "net/http"
-    "strconv" // For parsing limit/offset
+    "strings"
     "time"
 
     "github.com/gorilla/mux"
 
-    "github.com/Kelvinkhyd/GuardianAI/internal/kafka" // Import kafka package
+    "github.com/Kelvinkhyd/GuardianAI/internal/auth"
+    "github.com/Kelvinkhyd/GuardianAI/internal/ingest"
     "github.com/Kelvinkhyd/GuardianAI/internal/models"
+    "github.com/Kelvinkhyd/GuardianAI/internal/normalize"
     "github.com/Kelvinkhyd/GuardianAI/internal/repository" // Import repository
 )
 
 // Handler holds dependencies for our API handlers.
+// New alerts are stored through the ingest service, which queues them in the
+// transactional outbox, so the handlers never talk to Kafka directly.
 type Handler struct {
     AlertRepo repository.AlertRepository
-    KafkaProducer *kafka.Producer // Add Kafka producer
+    Ingest    *ingest.Service
 }
 
-// NewHandler creates a new Handler instance.
-func NewHandler(ar repository.AlertRepository, kp *kafka.Producer) *Handler {
-    return &Handler{AlertRepo: ar, KafkaProducer: kp}
+// NewHandler creates a new Handler instance. Alerts posted to /alerts are stored
+// through in, which also deduplicates them if configured.
+func NewHandler(ar repository.AlertRepository, in *ingest.Service) *Handler {
+    return &Handler{AlertRepo: ar, Ingest: in}
 }
 
-// HandleAlerts receives incoming security alerts via HTTP POST and stores them, then publishes to Kafka.
+// HandleAlerts receives incoming security alerts via HTTP POST and stores them for processing.
+// The body is a JSON alert, or a single CEF or LEEF event with Content-Type text/cef
+// or text/leef, as sent by SIEM forwarders. OCSF Detection Findings and ECS documents
+// are accepted with Content-Type application/ocsf+json or application/ecs+json, or
+// with ?format=ocsf or ?format=ecs.
+// The body is kept as the alert's raw_payload, and JSON fields a SecurityAlert has no
+// place for end up in its attributes instead of being dropped.
 func (h *Handler) HandleAlerts(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodPost {
         http.Error(w, "Only POST requests are accepted", http.StatusMethodNotAllowed)
         return
     }
 
-    var alert models.SecurityAlert
-    err := json.NewDecoder(r.Body).Decode(&alert)
+    alert, err := decodeAlert(w, r)
     if err != nil {
         http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
         return
     }
 
-    if alert.ID == "" {
-        alert.ID = fmt.Sprintf("alert-%d", time.Now().UnixNano())
-    }
-    alert.Status = "new"
-    // It's good practice to ensure the timestamp is set if not provided or to current time.
-    // If the client provides a timestamp, use it. Otherwise, set it to now.
-    if alert.Timestamp.IsZero() {
-        alert.Timestamp = time.Now()
-    }
-
     ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
     defer cancel()
+    tenant := auth.TenantFrom(r.Context())
 
-    // 1. Save to Database
-    err = h.AlertRepo.CreateAlert(ctx, &alert)
+    // Save to Database. Repeats of a recent alert are folded into it instead of being
+    // stored and analysed again.
+    res, err := h.Ingest.Submit(ctx, tenant, alert)
+    if errors.Is(err, repository.ErrAlertExists) {
+        http.Error(w, fmt.Sprintf("Alert %s already exists", alert.ID), http.StatusConflict)
+        return
+    }
     if err != nil {
         log.Printf("ERROR: Failed to save alert to DB: %v", err)
         http.Error(w, "Failed to process alert: "+err.Error(), http.StatusInternalServerError)
         return
     }
+    h.writeAlertAccepted(w, res, alert.Source)
+}
 
-    // 2. Publish to Kafka
-    alertJSON, err := json.Marshal(alert)
-    if err != nil {
-        log.Printf("ERROR: Failed to marshal alert for Kafka: %v", err)
-        // Still return 202 because DB save succeeded, but log the Kafka issue.
-        w.Header().Set("Content-Type", "application/json")
-        w.WriteHeader(http.StatusAccepted)
-        json.NewEncoder(w).Encode(map[string]string{"message": "Alert received and saved to DB, but failed to publish to Kafka", "alert_id": alert.ID})
-        return
+// decodeAlert reads the alert in the body of a POST /alerts request, in the format
+// named by the format query parameter or else by its Content-Type.
+func decodeAlert(w http.ResponseWriter, r *http.Request) (*models.SecurityAlert, error) {
+    r.Body = http.MaxBytesReader(w, r.Body, maxNDJSONLine) // The body is kept as the raw payload
+    mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+    format := normalize.FormatForMediaType(mediaType)
+    if q := r.URL.Query().Get("format"); q != "" {
+        f, err := normalize.ParseFormat(q)
+        if err != nil {
+            return nil, err
+        }
+        format, mediaType = f, ""
+    }
+    if mediaType != ingest.MediaTypeCEF && mediaType != ingest.MediaTypeLEEF {
+        return normalize.Decode(format, r.Body)
     }
 
-    // Use alert ID as key for Kafka message to ensure order for a specific alert (if partitions are by key)
-    err = h.KafkaProducer.PublishMessage(ctx, []byte(alert.ID), alertJSON)
+    body, err := io.ReadAll(r.Body)
     if err != nil {
-        log.Printf("ERROR: Failed to publish alert %s to Kafka: %v", alert.ID, err)
-        // Similar to above, DB save succeeded, so return Accepted.
-        w.Header().Set("Content-Type", "application/json")
-        w.WriteHeader(http.StatusAccepted)
-        json.NewEncoder(w).Encode(map[string]string{"message": "Alert received and saved to DB, but failed to publish to Kafka", "alert_id": alert.ID})
-        return
+        return nil, err
+    }
+    event := strings.TrimSpace(string(body))
+    if strings.Contains(event, "\n") {
+        return nil, fmt.Errorf("expected a single event, send several to the syslog listener")
     }
+    if mediaType == ingest.MediaTypeCEF {
+        return ingest.ParseCEF(event)
+    }
+    return ingest.ParseLEEF(event)
+}
 
-    log.Printf("Received, saved, and published alert to Kafka: ID=%s, Source=%s", alert.ID, alert.Source)
+// writeAlertAccepted reports the outcome of HandleAlerts. New alerts are answered with
+// 202 Accepted; repeats folded into an existing alert with 200 OK and that alert's ID.
+func (h *Handler) writeAlertAccepted(w http.ResponseWriter, res *repository.FoldResult, source string) {
+    status := http.StatusAccepted
+    resp := map[string]interface{}{"alert_id": res.AlertID, "occurrence_count": res.OccurrenceCount}
+    switch {
+    case !res.Folded:
+        log.Printf("Received and saved alert, queued for processing: ID=%s, Source=%s", res.AlertID, source)
+        resp["message"] = "Alert received, saved, and queued for processing"
+    case res.Escalated:
+        log.Printf("Duplicate alert folded into %s (%d occurrences), re-queued for processing", res.AlertID, res.OccurrenceCount)
+        status = http.StatusOK
+        resp["message"] = "Duplicate alert folded into existing alert, which was re-queued for processing"
+        resp["duplicate"] = true
+    default:
+        status = http.StatusOK
+        resp["message"] = "Duplicate alert folded into existing alert"
+        resp["duplicate"] = true
+    }
 
     w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(http.StatusAccepted)
-    json.NewEncoder(w).Encode(map[string]string{"message": "Alert received, saved, and published for processing", "alert_id": alert.ID})
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(resp)
 }
 
+// alertListResponse is the envelope returned by GET /alerts.
+type alertListResponse struct {
+    Items      []models.SecurityAlert `json:"items"`
+    NextCursor string                 `json:"next_cursor,omitempty"` // Pass back as ?cursor= to get the next page
+    HasMore    bool                   `json:"has_more"`
+}
 
 // GetAlerts retrieves a list of security alerts from the database.
-// Supports pagination via query parameters: /alerts?limit=10&offset=0
+// Supports keyset pagination via query parameters: /alerts?limit=10&cursor=<next_cursor>
+// and filtering/sorting, e.g. /alerts?severity=high&source_ip=10.0.0.0/8&sort=risk_score
+// (see parseAlertFilter for the full list of parameters).
 func (h *Handler) GetAlerts(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodGet {
         http.Error(w, "Only GET requests are accepted", http.StatusMethodNotAllowed)
         return
     }
 
-    limitStr := r.URL.Query().Get("limit")
-    offsetStr := r.URL.Query().Get("offset")
-
-    limit, err := strconv.Atoi(limitStr)
-    if err != nil || limit <= 0 {
-        limit = 10 // Default limit
-    }
-    offset, err := strconv.Atoi(offsetStr)
-    if err != nil || offset < 0 {
-        offset = 0 // Default offset
+    filter, err := parseAlertFilter(r.URL.Query())
+    if err != nil {
+        http.Error(w, "Invalid query: "+err.Error(), http.StatusBadRequest)
+        return
     }
 
     ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
     defer cancel()
+    tenant := auth.TenantFrom(r.Context())
+
+    // Fetch one extra row to find out whether another page exists.
+    pageSize := filter.Limit
+    filter.Limit = pageSize + 1
 
-    alerts, err := h.AlertRepo.GetAllAlerts(ctx, limit, offset)
+    alerts, err := h.AlertRepo.GetAllAlerts(ctx, tenant, filter)
     if err != nil {
         log.Printf("ERROR: Failed to retrieve alerts from DB: %v", err)
         http.Error(w, "Failed to retrieve alerts: "+err.Error(), http.StatusInternalServerError)
         return
     }
 
+    resp := alertListResponse{Items: alerts}
+    if len(alerts) > pageSize {
+        resp.Items = alerts[:pageSize]
+        resp.HasMore = true
+        resp.NextCursor = encodeCursor(repository.CursorAfter(resp.Items[pageSize-1], filter))
+    }
+    if resp.Items == nil {
+        resp.Items = []models.SecurityAlert{} // Always encode an array, never null
+    }
+
     w.Header().Set("Content-Type", "application/json")
     w.WriteHeader(http.StatusOK)
-    json.NewEncoder(w).Encode(alerts)
+    json.NewEncoder(w).Encode(resp)
 }
 
 // GetAlertByID retrieves a single security alert by its ID.
-// Usage: /alerts/{id}
+// Usage: /alerts/{id}, or /alerts/{id}?format=ocsf to get it as an OCSF Detection
+// Finding and /alerts/{id}?format=ecs as an ECS document.
 func (h *Handler) GetAlertByID(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodGet {
         // Mux already handles this with .Methods("GET"), but it's good practice.
@@ -136,6 +192,12 @@ func (h *Handler) GetAlertByID(w http.ResponseWriter, r *http.Request) {
         return
     }
 
+    format, err := normalize.ParseFormat(r.URL.Query().Get("format"))
+    if err != nil {
+        http.Error(w, "Invalid query: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+
     // Use mux.Vars to extract the 'id' variable from the path
     vars := mux.Vars(r)
     alertID := vars["id"] // "id" matches the {id} in the route definition
@@ -147,8 +209,65 @@ func (h *Handler) GetAlertByID(w http.ResponseWriter, r *http.Request) {
 
     ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
     defer cancel()
+    tenant := auth.TenantFrom(r.Context())
+
+    alert, err := h.AlertRepo.GetAlertByID(ctx, tenant, alertID)
+    if err != nil {
+        log.Printf("ERROR: Failed to retrieve alert by ID %s: %v", alertID, err)
+        http.Error(w, "Failed to retrieve alert: "+err.Error(), http.StatusInternalServerError)
+        return
+    }
+    if alert == nil {
+        http.Error(w, "Alert not found", http.StatusNotFound)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusOK)
+    json.NewEncoder(w).Encode(normalize.Encode(format, alert))
+}
+
+// updateAlertRequest is the body accepted by PATCH /alerts/{id}.
+type updateAlertRequest struct {
+    Status models.AlertStatus `json:"status"`
+}
+
+// UpdateAlert moves an alert to a new lifecycle status.
+// Usage: PATCH /alerts/{id} with body {"status": "triaged"}
+// Illegal transitions (e.g. new -> resolved) are rejected with 409 Conflict. Alerts
+// only become "analyzed" through the processor, together with the AI results, so
+// that status cannot be requested.
+func (h *Handler) UpdateAlert(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPatch {
+        http.Error(w, "Only PATCH requests are accepted", http.StatusMethodNotAllowed)
+        return
+    }
+
+    alertID := mux.Vars(r)["id"]
+    if alertID == "" {
+        http.Error(w, "Alert ID is missing from the URL path", http.StatusBadRequest)
+        return
+    }
+
+    var req updateAlertRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    if !req.Status.IsValid() {
+        http.Error(w, fmt.Sprintf("Unknown status %q", req.Status), http.StatusBadRequest)
+        return
+    }
+    if req.Status == models.StatusAnalyzed {
+        http.Error(w, "Status \"analyzed\" is set by the processor when the analysis is stored", http.StatusBadRequest)
+        return
+    }
+
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    tenant := auth.TenantFrom(r.Context())
 
-    alert, err := h.AlertRepo.GetAlertByID(ctx, alertID)
+    alert, err := h.AlertRepo.GetAlertByID(ctx, tenant, alertID)
     if err != nil {
         log.Printf("ERROR: Failed to retrieve alert by ID %s: %v", alertID, err)
         http.Error(w, "Failed to retrieve alert: "+err.Error(), http.StatusInternalServerError)
@@ -159,6 +278,28 @@ func (h *Handler) GetAlertByID(w http.ResponseWriter, r *http.Request) {
         return
     }
 
+    if !alert.Status.CanTransitionTo(req.Status) {
+        http.Error(w, fmt.Sprintf("Cannot move alert from %q to %q", alert.Status, req.Status), http.StatusConflict)
+        return
+    }
+
+    err = h.AlertRepo.TransitionAlertStatus(ctx, tenant, alertID, alert.Status, req.Status)
+    if err != nil {
+        switch {
+        case errors.Is(err, repository.ErrAlertNotFound):
+            http.Error(w, "Alert not found", http.StatusNotFound)
+        case errors.Is(err, repository.ErrStatusConflict):
+            http.Error(w, "Alert status was changed by someone else, reload and retry", http.StatusConflict)
+        default:
+            log.Printf("ERROR: Failed to update status of alert %s: %v", alertID, err)
+            http.Error(w, "Failed to update alert: "+err.Error(), http.StatusInternalServerError)
+        }
+        return
+    }
+
+    log.Printf("Alert %s moved from %s to %s", alertID, alert.Status, req.Status)
+    alert.Status = req.Status
+
     w.Header().Set("Content-Type", "application/json")
     w.WriteHeader(http.StatusOK)
     json.NewEncoder(w).Encode(alert)
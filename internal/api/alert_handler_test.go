@@ -0,0 +1,100 @@
+package api
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "github.com/gorilla/mux"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/auth"
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+    "github.com/Kelvinkhyd/GuardianAI/internal/repository"
+)
+
+// patchAlert sends a PATCH for alert id with body on behalf of a caller of tenant.
+func patchAlert(h *Handler, tenant, id, body string) *httptest.ResponseRecorder {
+    req := httptest.NewRequest(http.MethodPatch, "/alerts/"+id, strings.NewReader(body))
+    req = mux.SetURLVars(req, map[string]string{"id": id})
+    req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Subject: "tester", Tenant: tenant, Roles: []auth.Role{auth.RoleAnalyst}}))
+    rec := httptest.NewRecorder()
+    h.UpdateAlert(rec, req)
+    return rec
+}
+
+// concurrentRepo moves an alert on to triaged right after the handler has read it,
+// as if another analyst had updated it in the meantime.
+type concurrentRepo struct {
+    repository.AlertRepository
+}
+
+func (r concurrentRepo) GetAlertByID(ctx context.Context, tenant, id string) (*models.SecurityAlert, error) {
+    alert, err := r.AlertRepository.GetAlertByID(ctx, tenant, id)
+    if err != nil || alert == nil {
+        return alert, err
+    }
+    return alert, r.AlertRepository.TransitionAlertStatus(ctx, tenant, id, alert.Status, models.StatusTriaged)
+}
+
+func TestUpdateAlert(t *testing.T) {
+    ctx := context.Background()
+    repo := repository.NewMemoryRepository()
+    for _, alert := range []models.SecurityAlert{
+        {ID: "a1", Source: "ids", Title: "Port scan", Severity: "high", Status: models.StatusAnalyzed},
+        {ID: "a2", Source: "ids", Title: "Brute force", Severity: "low", Status: models.StatusNew},
+    } {
+        if err := repo.CreateAlert(ctx, "acme", &alert); err != nil {
+            t.Fatal(err)
+        }
+    }
+    h := NewHandler(repo, nil)
+
+    cases := []struct {
+        name   string
+        tenant string
+        id     string
+        body   string
+        want   int
+    }{
+        {"bad JSON", "acme", "a1", `{"status":`, http.StatusBadRequest},
+        {"unknown status", "acme", "a1", `{"status": "closed"}`, http.StatusBadRequest},
+        {"analyzed is set by the processor", "acme", "a2", `{"status": "analyzed"}`, http.StatusBadRequest},
+        {"missing alert", "acme", "nope", `{"status": "triaged"}`, http.StatusNotFound},
+        {"other tenant", "globex", "a1", `{"status": "triaged"}`, http.StatusNotFound},
+        {"illegal transition", "acme", "a1", `{"status": "resolved"}`, http.StatusConflict},
+        {"not yet analyzed", "acme", "a2", `{"status": "triaged"}`, http.StatusConflict},
+    }
+    for _, c := range cases {
+        if rec := patchAlert(h, c.tenant, c.id, c.body); rec.Code != c.want {
+            t.Errorf("%s: got status %d, want %d: %s", c.name, rec.Code, c.want, rec.Body)
+        }
+    }
+
+    rec := patchAlert(h, "acme", "a1", `{"status": "triaged"}`)
+    if rec.Code != http.StatusOK {
+        t.Fatalf("got status %d, want 200: %s", rec.Code, rec.Body)
+    }
+    var got models.SecurityAlert
+    if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+        t.Fatalf("reply is not JSON: %v: %s", err, rec.Body)
+    }
+    if got.ID != "a1" || got.Status != models.StatusTriaged {
+        t.Errorf("got alert %s in status %s, want a1 in triaged", got.ID, got.Status)
+    }
+    stored, err := repo.GetAlertByID(ctx, "acme", "a1")
+    if err != nil || stored.Status != models.StatusTriaged {
+        t.Errorf("stored alert: got %+v, %v, want status triaged", stored, err)
+    }
+
+    // Another update landing between the read and the write is reported, not overwritten
+    if err := repo.TransitionAlertStatus(ctx, "acme", "a2", models.StatusNew, models.StatusAnalyzed); err != nil {
+        t.Fatal(err)
+    }
+    racing := NewHandler(concurrentRepo{repo}, nil)
+    if rec := patchAlert(racing, "acme", "a2", `{"status": "triaged"}`); rec.Code != http.StatusConflict {
+        t.Errorf("concurrent update: got status %d, want 409: %s", rec.Code, rec.Body)
+    }
+}
@@ -0,0 +1,235 @@
+package api
+
+import (
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net"
+    "net/netip"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+    "github.com/Kelvinkhyd/GuardianAI/internal/repository"
+)
+
+const (
+    defaultListLimit = 10
+    maxListLimit     = 1000
+)
+
+// parseAlertFilter builds a repository.AlertFilter from the query string of GET /alerts.
+//
+// Supported parameters:
+//
+//	status, severity, predicted_severity, category, source, hostname, username  exact match
+//	fingerprint   deduplication fingerprint, finds alerts that are repeats of each other
+//	sigma_rule    ID of a Sigma rule the alert matched
+//	source_country, target_country   ISO country code from the GeoIP enrichment
+//	source_asn, target_asn   autonomous system number from the GeoIP enrichment
+//	source_ip, target_ip   single address or CIDR block (e.g. 10.0.0.0/8)
+//	attr.<key>    value of an attribute, e.g. attr.deviceVendor=Acme
+//	raw.<path>    scalar value in the raw payload at a dot-separated path of object
+//	              keys and array indexes, e.g. raw.vendor.rule_id=42 or raw.tags.0=phishing
+//	min_risk_score, max_risk_score   inclusive range, 0.0 - 1.0
+//	since, until   RFC 3339 window on the alert timestamp (until is exclusive)
+//	sort   created_at (default), risk_score or timestamp
+//	order  desc (default) or asc
+//	limit   page size
+//	cursor  opaque next_cursor returned by a previous page (keyset pagination)
+//	offset  legacy offset pagination, ignored when cursor is given
+func parseAlertFilter(q url.Values) (repository.AlertFilter, error) {
+    f := repository.AlertFilter{
+        Status:            models.AlertStatus(q.Get("status")),
+        Severity:          q.Get("severity"),
+        PredictedSeverity: q.Get("predicted_severity"),
+        Category:          q.Get("category"),
+        Source:            q.Get("source"),
+        Hostname:          q.Get("hostname"),
+        Username:          q.Get("username"),
+        Fingerprint:       q.Get("fingerprint"),
+        SigmaRule:         q.Get("sigma_rule"),
+        SourceCountry:     strings.ToUpper(q.Get("source_country")),
+        TargetCountry:     strings.ToUpper(q.Get("target_country")),
+        SourceIP:          q.Get("source_ip"),
+        TargetIP:          q.Get("target_ip"),
+        SortBy:            repository.AlertSortField(q.Get("sort")),
+    }
+
+    if f.Status != "" && !f.Status.IsValid() {
+        return f, fmt.Errorf("unknown status %q", f.Status)
+    }
+    for name, values := range q {
+        if key, ok := strings.CutPrefix(name, "attr."); ok {
+            if key == "" {
+                return f, fmt.Errorf("missing attribute name in %q", name)
+            }
+            if f.Attributes == nil {
+                f.Attributes = make(map[string]string)
+            }
+            f.Attributes[key] = values[0]
+        } else if path, ok := strings.CutPrefix(name, "raw."); ok {
+            if err := validateRawPath(path); err != nil {
+                return f, fmt.Errorf("invalid raw payload path %q: %w", path, err)
+            }
+            if f.RawPayload == nil {
+                f.RawPayload = make(map[string]string)
+            }
+            f.RawPayload[path] = values[0]
+        }
+    }
+    for name, value := range map[string]string{"source_ip": f.SourceIP, "target_ip": f.TargetIP} {
+        if err := validateIPOrCIDR(value); err != nil {
+            return f, fmt.Errorf("invalid %s: %w", name, err)
+        }
+    }
+
+    var err error
+    if f.SourceASN, err = parseOptionalASN(q, "source_asn"); err != nil {
+        return f, err
+    }
+    if f.TargetASN, err = parseOptionalASN(q, "target_asn"); err != nil {
+        return f, err
+    }
+    if f.MinRiskScore, err = parseOptionalFloat(q, "min_risk_score"); err != nil {
+        return f, err
+    }
+    if f.MaxRiskScore, err = parseOptionalFloat(q, "max_risk_score"); err != nil {
+        return f, err
+    }
+    if f.Since, err = parseOptionalTime(q, "since"); err != nil {
+        return f, err
+    }
+    if f.Until, err = parseOptionalTime(q, "until"); err != nil {
+        return f, err
+    }
+
+    if f.SortBy == "" {
+        f.SortBy = repository.SortByCreatedAt
+    } else if !f.SortBy.IsValid() {
+        return f, fmt.Errorf("unsupported sort field %q", f.SortBy)
+    }
+    switch strings.ToLower(q.Get("order")) {
+    case "", "desc":
+    case "asc":
+        f.SortAscending = true
+    default:
+        return f, fmt.Errorf("order must be 'asc' or 'desc'")
+    }
+
+    f.Limit, err = strconv.Atoi(q.Get("limit"))
+    if err != nil || f.Limit <= 0 {
+        f.Limit = defaultListLimit // Default limit
+    }
+    if f.Limit > maxListLimit {
+        f.Limit = maxListLimit
+    }
+    f.Offset, err = strconv.Atoi(q.Get("offset"))
+    if err != nil || f.Offset < 0 {
+        f.Offset = 0 // Default offset
+    }
+
+    if raw := q.Get("cursor"); raw != "" {
+        cursor, err := decodeCursor(raw)
+        if err != nil {
+            return f, err
+        }
+        // A cursor only makes sense for the ordering it was produced with.
+        if cursor.SortBy != f.SortBy || cursor.Ascending != f.SortAscending {
+            return f, fmt.Errorf("cursor does not match the requested sort order")
+        }
+        f.After = cursor
+        f.Offset = 0
+    }
+
+    return f, nil
+}
+
+// encodeCursor turns a cursor into the opaque token handed out as next_cursor.
+func encodeCursor(c repository.AlertCursor) string {
+    raw, _ := json.Marshal(c) // Marshalling a plain struct cannot fail
+    return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeCursor reverses encodeCursor.
+func decodeCursor(token string) (*repository.AlertCursor, error) {
+    raw, err := base64.RawURLEncoding.DecodeString(token)
+    if err != nil {
+        return nil, fmt.Errorf("malformed cursor")
+    }
+    var c repository.AlertCursor
+    if err := json.Unmarshal(raw, &c); err != nil || c.ID == "" {
+        return nil, fmt.Errorf("malformed cursor")
+    }
+    return &c, nil
+}
+
+// validateIPOrCIDR accepts an empty string, a single IP address or a CIDR block.
+// Blocks may have host bits set, the repository masks them.
+func validateIPOrCIDR(value string) error {
+    if value == "" {
+        return nil
+    }
+    if strings.Contains(value, "/") {
+        _, err := netip.ParsePrefix(value)
+        return err
+    }
+    if net.ParseIP(value) == nil {
+        return fmt.Errorf("%q is not an IP address", value)
+    }
+    return nil
+}
+
+// validateRawPath accepts a dot-separated path of object keys and array indexes.
+// Postgres would read a signed number such as -1 as an index counted from the end
+// of an array, which the in-memory repository does not support, so those are refused.
+func validateRawPath(path string) error {
+    for _, segment := range strings.Split(path, ".") {
+        if segment == "" {
+            return errors.New("empty path segment")
+        }
+        if _, err := strconv.Atoi(strings.TrimSpace(segment)); err == nil && strings.Trim(segment, "0123456789") != "" {
+            return fmt.Errorf("array index %q must be a plain non-negative number", segment)
+        }
+    }
+    return nil
+}
+
+func parseOptionalFloat(q url.Values, name string) (*float64, error) {
+    raw := q.Get(name)
+    if raw == "" {
+        return nil, nil
+    }
+    v, err := strconv.ParseFloat(raw, 64)
+    if err != nil {
+        return nil, fmt.Errorf("invalid %s: %w", name, err)
+    }
+    return &v, nil
+}
+
+func parseOptionalASN(q url.Values, name string) (uint32, error) {
+    raw := strings.TrimPrefix(strings.ToUpper(q.Get(name)), "AS")
+    if raw == "" {
+        return 0, nil
+    }
+    n, err := strconv.ParseUint(raw, 10, 32)
+    if err != nil || n == 0 {
+        return 0, fmt.Errorf("invalid %s: must be an AS number such as 15169 or AS15169", name)
+    }
+    return uint32(n), nil
+}
+
+func parseOptionalTime(q url.Values, name string) (time.Time, error) {
+    raw := q.Get(name)
+    if raw == "" {
+        return time.Time{}, nil
+    }
+    t, err := time.Parse(time.RFC3339, raw)
+    if err != nil {
+        return time.Time{}, fmt.Errorf("invalid %s (expected RFC 3339): %w", name, err)
+    }
+    return t, nil
+}
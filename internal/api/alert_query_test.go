@@ -0,0 +1,38 @@
+package api
+
+import (
+    "net/url"
+    "testing"
+)
+
+func TestParseAlertFilterRawPath(t *testing.T) {
+    tests := []struct {
+        path  string
+        valid bool
+    }{
+        {"vendor.rule_id", true},
+        {"vendor.tags.0", true},
+        {"vendor.tags.10", true},
+        {"-", true},   // An object key
+        {"a-1", true}, // Likewise
+        {"", false},
+        {"vendor.", false},
+        {".vendor", false},
+        {"vendor..tags", false},
+        {"vendor.tags.-1", false}, // Postgres counts negative indexes from the end
+        {"vendor.tags.+1", false},
+        {"vendor.tags. 1", false},
+    }
+    for _, tt := range tests {
+        f, err := parseAlertFilter(url.Values{"raw." + tt.path: {"x"}})
+        if tt.valid {
+            if err != nil {
+                t.Errorf("raw.%s: %v", tt.path, err)
+            } else if f.RawPayload[tt.path] != "x" {
+                t.Errorf("raw.%s: RawPayload = %v", tt.path, f.RawPayload)
+            }
+        } else if err == nil {
+            t.Errorf("raw.%s: accepted, want an error", tt.path)
+        }
+    }
+}
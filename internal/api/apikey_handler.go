@@ -0,0 +1,182 @@
+package api
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "net/http"
+    "time"
+
+    "github.com/gorilla/mux"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/auth"
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+    "github.com/Kelvinkhyd/GuardianAI/internal/repository"
+)
+
+// APIKeyHandler serves the admin endpoints managing API keys.
+type APIKeyHandler struct {
+    KeyRepo repository.APIKeyRepository
+}
+
+// NewAPIKeyHandler creates a new APIKeyHandler instance.
+func NewAPIKeyHandler(kr repository.APIKeyRepository) *APIKeyHandler {
+    return &APIKeyHandler{KeyRepo: kr}
+}
+
+// canManageTenant reports whether an admin of callerTenant may manage the keys of
+// tenant. Admins of the default tenant run the deployment and manage every tenant's
+// keys; other admins only those of their own tenant.
+func canManageTenant(callerTenant, tenant string) bool {
+    return callerTenant == models.DefaultTenant || callerTenant == tenant
+}
+
+// createAPIKeyRequest is the body accepted by POST /admin/api-keys.
+type createAPIKeyRequest struct {
+    Name      string      `json:"name"`
+    TenantID  string      `json:"tenant_id"` // Defaults to the caller's tenant
+    Roles     []auth.Role `json:"roles"`
+    ExpiresAt *time.Time  `json:"expires_at"`
+}
+
+// createAPIKeyResponse is the stored key plus the key itself, which is only ever
+// returned here.
+type createAPIKeyResponse struct {
+    *models.APIKey
+    Key string `json:"key"`
+}
+
+// CreateAPIKey issues a new API key.
+// Usage: POST /admin/api-keys with body {"name": "edr-sensor-1", "tenant_id": "finance", "roles": ["ingest"], "expires_at": "2026-01-01T00:00:00Z"}
+// tenant_id and expires_at are optional. The response contains the key, which cannot be retrieved again.
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+    var req createAPIKeyRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    if req.Name == "" {
+        http.Error(w, "name is required", http.StatusBadRequest)
+        return
+    }
+    if len(req.Roles) == 0 {
+        http.Error(w, "at least one role is required", http.StatusBadRequest)
+        return
+    }
+    for _, role := range req.Roles {
+        if !role.IsValid() {
+            http.Error(w, fmt.Sprintf("Unknown role %q", role), http.StatusBadRequest)
+            return
+        }
+    }
+    if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+        http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
+        return
+    }
+    callerTenant := auth.TenantFrom(r.Context())
+    if req.TenantID == "" {
+        req.TenantID = callerTenant
+    }
+    if !models.ValidTenantID(req.TenantID) {
+        http.Error(w, fmt.Sprintf("Invalid tenant_id %q", req.TenantID), http.StatusBadRequest)
+        return
+    }
+    if !canManageTenant(callerTenant, req.TenantID) {
+        http.Error(w, "Forbidden: cannot create API keys for another tenant", http.StatusForbidden)
+        return
+    }
+
+    createdBy := ""
+    if p := auth.PrincipalFrom(r.Context()); p != nil {
+        createdBy = p.String()
+    }
+    key, plain, err := auth.NewAPIKey(req.Name, req.TenantID, req.Roles, createdBy, req.ExpiresAt)
+    if err != nil {
+        log.Printf("ERROR: %v", err)
+        http.Error(w, "Failed to create API key", http.StatusInternalServerError)
+        return
+    }
+
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    if err := h.KeyRepo.CreateAPIKey(ctx, key); err != nil {
+        log.Printf("ERROR: Failed to save API key: %v", err)
+        http.Error(w, "Failed to create API key: "+err.Error(), http.StatusInternalServerError)
+        return
+    }
+    log.Printf("API key %s (%s) for tenant %s with roles %v created by %s", key.ID, key.Name, key.TenantID, key.Roles, createdBy)
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusCreated)
+    json.NewEncoder(w).Encode(createAPIKeyResponse{APIKey: key, Key: plain})
+}
+
+// GetAPIKeys lists the API keys the caller may manage, revoked ones included. Keys
+// themselves are never returned.
+// Usage: GET /admin/api-keys
+func (h *APIKeyHandler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+
+    keys, err := h.KeyRepo.GetAllAPIKeys(ctx)
+    if err != nil {
+        log.Printf("ERROR: Failed to retrieve API keys: %v", err)
+        http.Error(w, "Failed to retrieve API keys: "+err.Error(), http.StatusInternalServerError)
+        return
+    }
+    callerTenant := auth.TenantFrom(r.Context())
+    visible := []models.APIKey{} // Always encode an array, never null
+    for _, key := range keys {
+        if canManageTenant(callerTenant, key.TenantID) {
+            visible = append(visible, key)
+        }
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusOK)
+    json.NewEncoder(w).Encode(map[string]interface{}{"items": visible})
+}
+
+// RevokeAPIKey disables an API key for good.
+// Usage: DELETE /admin/api-keys/{id}
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+    id := mux.Vars(r)["id"]
+    if id == "" {
+        http.Error(w, "API key ID is missing from the URL path", http.StatusBadRequest)
+        return
+    }
+
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+
+    // Keys of tenants the caller cannot manage are reported as missing
+    key, err := h.KeyRepo.GetAPIKeyByID(ctx, id)
+    if err != nil {
+        log.Printf("ERROR: Failed to retrieve API key %s: %v", id, err)
+        http.Error(w, "Failed to revoke API key: "+err.Error(), http.StatusInternalServerError)
+        return
+    }
+    if key == nil || !canManageTenant(auth.TenantFrom(r.Context()), key.TenantID) {
+        http.Error(w, "API key not found", http.StatusNotFound)
+        return
+    }
+
+    if err := h.KeyRepo.RevokeAPIKey(ctx, id); err != nil {
+        if errors.Is(err, repository.ErrAPIKeyNotFound) {
+            http.Error(w, "API key not found", http.StatusNotFound)
+            return
+        }
+        log.Printf("ERROR: Failed to revoke API key %s: %v", id, err)
+        http.Error(w, "Failed to revoke API key: "+err.Error(), http.StatusInternalServerError)
+        return
+    }
+
+    revokedBy := "anonymous"
+    if p := auth.PrincipalFrom(r.Context()); p != nil {
+        revokedBy = p.String()
+    }
+    log.Printf("API key %s revoked by %s", id, revokedBy)
+    w.WriteHeader(http.StatusNoContent)
+}
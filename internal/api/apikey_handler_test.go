@@ -0,0 +1,188 @@
+package api
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "github.com/gorilla/mux"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/auth"
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+    "github.com/Kelvinkhyd/GuardianAI/internal/repository"
+)
+
+const testAdminKey = "bootstrap-secret"
+
+// newAuthRouter wires the admin and alert triage routes with the same role checks
+// as main.go, authenticating against repo and the bootstrap key testAdminKey.
+func newAuthRouter(repo *repository.MemoryRepository) *mux.Router {
+    authn := auth.NewAuthenticator(repo, testAdminKey, nil)
+    alerts := NewHandler(repo, nil)
+    keys := NewAPIKeyHandler(repo)
+
+    router := mux.NewRouter()
+    router.HandleFunc("/alerts/{id}", authn.Require(auth.RoleAnalyst, alerts.UpdateAlert)).Methods("PATCH")
+    router.HandleFunc("/admin/api-keys", authn.Require(auth.RoleAdmin, keys.CreateAPIKey)).Methods("POST")
+    router.HandleFunc("/admin/api-keys", authn.Require(auth.RoleAdmin, keys.GetAPIKeys)).Methods("GET")
+    router.HandleFunc("/admin/api-keys/{id}", authn.Require(auth.RoleAdmin, keys.RevokeAPIKey)).Methods("DELETE")
+    return router
+}
+
+// call sends one request through router with token as the bearer credential.
+func call(router http.Handler, method, target, token, body string) *httptest.ResponseRecorder {
+    req := httptest.NewRequest(method, target, strings.NewReader(body))
+    if token != "" {
+        req.Header.Set("Authorization", "Bearer "+token)
+    }
+    rec := httptest.NewRecorder()
+    router.ServeHTTP(rec, req)
+    return rec
+}
+
+// createKey issues a key through POST /admin/api-keys and returns the reply.
+func createKey(t *testing.T, router http.Handler, token, body string) createAPIKeyResponse {
+    t.Helper()
+    rec := call(router, http.MethodPost, "/admin/api-keys", token, body)
+    if rec.Code != http.StatusCreated {
+        t.Fatalf("creating %s: got status %d, want 201: %s", body, rec.Code, rec.Body)
+    }
+    resp := createAPIKeyResponse{APIKey: &models.APIKey{}}
+    if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+        t.Fatalf("reply is not JSON: %v: %s", err, rec.Body)
+    }
+    if resp.Key == "" || resp.ID == "" {
+        t.Fatalf("creating %s: got %s, want the key and its ID", body, rec.Body)
+    }
+    return resp
+}
+
+// listKeys returns the IDs GET /admin/api-keys shows to token.
+func listKeys(t *testing.T, router http.Handler, token string) []string {
+    t.Helper()
+    rec := call(router, http.MethodGet, "/admin/api-keys", token, "")
+    if rec.Code != http.StatusOK {
+        t.Fatalf("listing keys: got status %d, want 200: %s", rec.Code, rec.Body)
+    }
+    if strings.Contains(rec.Body.String(), `"key"`) || strings.Contains(rec.Body.String(), `"hash"`) {
+        t.Errorf("listing keys: reply exposes key material: %s", rec.Body)
+    }
+    var resp struct {
+        Items []models.APIKey `json:"items"`
+    }
+    if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+        t.Fatalf("reply is not JSON: %v: %s", err, rec.Body)
+    }
+    var ids []string
+    for _, key := range resp.Items {
+        ids = append(ids, key.ID)
+    }
+    return ids
+}
+
+func TestAPIKeyEndpoints(t *testing.T) {
+    repo := repository.NewMemoryRepository()
+    router := newAuthRouter(repo)
+
+    invalid := []struct{ name, body string }{
+        {"bad JSON", `{"name":`},
+        {"no name", `{"roles": ["ingest"]}`},
+        {"no roles", `{"name": "sensor"}`},
+        {"unknown role", `{"name": "sensor", "roles": ["root"]}`},
+        {"expired", `{"name": "sensor", "roles": ["ingest"], "expires_at": "2001-01-01T00:00:00Z"}`},
+        {"bad tenant", `{"name": "sensor", "tenant_id": "Not A Tenant!", "roles": ["ingest"]}`},
+    }
+    for _, c := range invalid {
+        if rec := call(router, http.MethodPost, "/admin/api-keys", testAdminKey, c.body); rec.Code != http.StatusBadRequest {
+            t.Errorf("%s: got status %d, want 400: %s", c.name, rec.Code, rec.Body)
+        }
+    }
+
+    acmeAdmin := createKey(t, router, testAdminKey, `{"name": "acme-admin", "tenant_id": "acme", "roles": ["admin"]}`)
+    globexSensor := createKey(t, router, testAdminKey, `{"name": "globex-sensor", "tenant_id": "globex", "roles": ["ingest"]}`)
+    if acmeAdmin.TenantID != "acme" || acmeAdmin.CreatedBy == "" {
+        t.Errorf("got key %+v, want tenant acme and its creator", *acmeAdmin.APIKey)
+    }
+
+    // Tenant admins only manage their own tenant's keys
+    if rec := call(router, http.MethodPost, "/admin/api-keys", acmeAdmin.Key, `{"name": "x", "tenant_id": "globex", "roles": ["ingest"]}`); rec.Code != http.StatusForbidden {
+        t.Errorf("key for another tenant: got status %d, want 403", rec.Code)
+    }
+    acmeSensor := createKey(t, router, acmeAdmin.Key, `{"name": "acme-sensor", "roles": ["ingest"]}`)
+    if acmeSensor.TenantID != "acme" {
+        t.Errorf("got tenant %q, want the creator's tenant acme", acmeSensor.TenantID)
+    }
+
+    if got := listKeys(t, router, testAdminKey); len(got) != 3 {
+        t.Errorf("bootstrap admin: got keys %v, want all 3", got)
+    }
+    got := listKeys(t, router, acmeAdmin.Key)
+    if len(got) != 2 || strings.Contains(strings.Join(got, " "), globexSensor.ID) {
+        t.Errorf("acme admin: got keys %v, want only acme's 2", got)
+    }
+
+    // Keys of other tenants are reported as missing
+    for _, c := range []struct{ name, token, id string }{
+        {"other tenant", acmeAdmin.Key, globexSensor.ID},
+        {"missing key", testAdminKey, "gk_missing"},
+    } {
+        if rec := call(router, http.MethodDelete, "/admin/api-keys/"+c.id, c.token, ""); rec.Code != http.StatusNotFound {
+            t.Errorf("revoking %s: got status %d, want 404", c.name, rec.Code)
+        }
+    }
+    if rec := call(router, http.MethodDelete, "/admin/api-keys/"+acmeSensor.ID, acmeAdmin.Key, ""); rec.Code != http.StatusNoContent {
+        t.Fatalf("revoking: got status %d, want 204: %s", rec.Code, rec.Body)
+    }
+    stored, err := repo.GetAPIKeyByID(context.Background(), acmeSensor.ID)
+    if err != nil || stored == nil || stored.RevokedAt == nil {
+        t.Errorf("revoked key: got %+v, %v, want it marked revoked", stored, err)
+    }
+}
+
+func TestRouteRoles(t *testing.T) {
+    ctx := context.Background()
+    repo := repository.NewMemoryRepository()
+    router := newAuthRouter(repo)
+
+    alert := models.SecurityAlert{ID: "a1", Source: "ids", Title: "Port scan", Severity: "high", Status: models.StatusAnalyzed}
+    if err := repo.CreateAlert(ctx, "acme", &alert); err != nil {
+        t.Fatal(err)
+    }
+    sensor := createKey(t, router, testAdminKey, `{"name": "sensor", "tenant_id": "acme", "roles": ["ingest"]}`)
+    analyst := createKey(t, router, testAdminKey, `{"name": "analyst", "tenant_id": "acme", "roles": ["analyst"]}`)
+    triage := `{"status": "triaged"}`
+
+    cases := []struct {
+        name   string
+        method string
+        target string
+        token  string
+        body   string
+        want   int
+    }{
+        {"no credentials", http.MethodPatch, "/alerts/a1", "", triage, http.StatusUnauthorized},
+        {"ingest key triaging", http.MethodPatch, "/alerts/a1", sensor.Key, triage, http.StatusForbidden},
+        {"ingest key listing keys", http.MethodGet, "/admin/api-keys", sensor.Key, "", http.StatusForbidden},
+        {"analyst key creating keys", http.MethodPost, "/admin/api-keys", analyst.Key, `{"name": "x", "roles": ["admin"]}`, http.StatusForbidden},
+        {"analyst key triaging", http.MethodPatch, "/alerts/a1", analyst.Key, triage, http.StatusOK},
+    }
+    for _, c := range cases {
+        if rec := call(router, c.method, c.target, c.token, c.body); rec.Code != c.want {
+            t.Errorf("%s: got status %d, want %d: %s", c.name, rec.Code, c.want, rec.Body)
+        }
+    }
+
+    // A revoked key is rejected before its role is looked at
+    if rec := call(router, http.MethodDelete, "/admin/api-keys/"+analyst.ID, testAdminKey, ""); rec.Code != http.StatusNoContent {
+        t.Fatalf("revoking: got status %d, want 204", rec.Code)
+    }
+    if rec := call(router, http.MethodPatch, "/alerts/a1", analyst.Key, `{"status": "investigating"}`); rec.Code != http.StatusUnauthorized {
+        t.Errorf("revoked key: got status %d, want 401", rec.Code)
+    }
+    if rec := call(router, http.MethodGet, "/admin/api-keys", sensor.Key+"x", ""); rec.Code != http.StatusUnauthorized {
+        t.Errorf("tampered key: got status %d, want 401", rec.Code)
+    }
+}
@@ -0,0 +1,216 @@
+package api
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "log"
+    "mime"
+    "net/http"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/auth"
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+    "github.com/Kelvinkhyd/GuardianAI/internal/normalize"
+)
+
+const (
+    maxBatchBodyBytes = 32 << 20 // 32MB per request
+    maxBatchItems     = 10000
+    maxNDJSONLine     = 1 << 20 // 1MB per alert
+)
+
+// Per-item outcomes reported by POST /alerts/batch.
+const (
+    batchItemAccepted  = "accepted"  // Stored (and queued for analysis)
+    batchItemFolded    = "folded"    // A repeat of a recent alert, counted as another occurrence of alert_id
+    batchItemDuplicate = "duplicate" // An alert with this ID already exists, nothing was stored
+    batchItemInvalid   = "invalid"   // The item could not be decoded
+    batchItemFailed    = "failed"    // The item was valid but could not be stored
+)
+
+// batchItemResult reports what happened to one element of a batch.
+type batchItemResult struct {
+    Index   int    `json:"index"` // Position of the item in the request (0-based)
+    AlertID string `json:"alert_id,omitempty"`
+    Status  string `json:"status"`
+    Error   string `json:"error,omitempty"`
+    // OccurrenceCount is the number of occurrences of a folded item's alert, including it.
+    OccurrenceCount int `json:"occurrence_count,omitempty"`
+}
+
+// batchResponse is the body returned by POST /alerts/batch.
+type batchResponse struct {
+    Accepted int               `json:"accepted"`
+    Rejected int               `json:"rejected"`
+    Results  []batchItemResult `json:"results"`
+}
+
+// HandleAlertsBatch ingests many alerts in one request.
+// Usage: POST /alerts/batch with either a JSON array of alerts or NDJSON
+// (one alert per line, Content-Type: application/x-ndjson).
+// Items go through the same deduplication as POST /alerts, so repeats of a recent alert,
+// also within the batch, are folded into it. The valid items are stored in a single
+// transaction: either all of them or none.
+// The response lists the outcome of every item so partial failures are visible;
+// it is 202 when every item was accepted or folded and 207 otherwise.
+func (h *Handler) HandleAlertsBatch(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Only POST requests are accepted", http.StatusMethodNotAllowed)
+        return
+    }
+
+    items, err := readBatchItems(http.MaxBytesReader(w, r.Body, maxBatchBodyBytes), r.Header.Get("Content-Type"))
+    if err != nil {
+        http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    if len(items) == 0 {
+        http.Error(w, "Batch contains no alerts", http.StatusBadRequest)
+        return
+    }
+    if len(items) > maxBatchItems {
+        http.Error(w, fmt.Sprintf("Batch contains %d alerts, the maximum is %d", len(items), maxBatchItems), http.StatusRequestEntityTooLarge)
+        return
+    }
+
+    results := make([]batchItemResult, len(items))
+    alerts := make([]models.SecurityAlert, 0, len(items))
+    positions := make([]int, 0, len(items))   // Position in the request of each of alerts
+    seen := make(map[string]bool, len(items)) // IDs sent by the client
+    for i, raw := range items {
+        results[i] = batchItemResult{Index: i}
+
+        decoded, err := normalize.DecodeNative(raw) // Keeps unknown fields and the item as its raw payload
+        if err != nil {
+            results[i].Status = batchItemInvalid
+            results[i].Error = err.Error()
+            continue
+        }
+        if id := decoded.ID; id != "" {
+            results[i].AlertID = id
+            if seen[id] {
+                results[i].Status = batchItemDuplicate
+                results[i].Error = "alert ID appears more than once in this batch"
+                continue
+            }
+            seen[id] = true
+        }
+        alerts = append(alerts, *decoded)
+        positions = append(positions, i)
+    }
+
+    ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+    defer cancel()
+    tenant := auth.TenantFrom(r.Context())
+
+    // Save to Database. New alerts are queued in the outbox in the same transaction
+    // and published to Kafka in batches by the outbox relay.
+    if len(alerts) > 0 {
+        stored, err := h.Ingest.SubmitAll(ctx, tenant, alerts)
+        if err != nil {
+            log.Printf("ERROR: Failed to save alert batch to DB: %v", err)
+        }
+        for j, alert := range alerts {
+            res := &results[positions[j]]
+            res.AlertID = alert.ID
+            switch {
+            case err != nil:
+                res.Status = batchItemFailed
+                res.Error = "failed to store alert"
+            case stored[j] == nil:
+                res.Status = batchItemDuplicate
+                res.Error = "an alert with this ID already exists"
+            case stored[j].Folded:
+                res.Status = batchItemFolded
+                res.AlertID = stored[j].AlertID
+                res.OccurrenceCount = stored[j].OccurrenceCount
+            default:
+                res.Status = batchItemAccepted
+            }
+        }
+    }
+
+    resp := batchResponse{Results: results}
+    for _, res := range results {
+        if res.Status == batchItemAccepted || res.Status == batchItemFolded {
+            resp.Accepted++
+        } else {
+            resp.Rejected++
+        }
+    }
+    log.Printf("Batch ingestion: %d alerts accepted, %d rejected", resp.Accepted, resp.Rejected)
+
+    status := http.StatusAccepted
+    if resp.Rejected > 0 {
+        status = http.StatusMultiStatus
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(resp)
+}
+
+// readBatchItems splits a batch body into one raw JSON document per alert.
+// A JSON array is used if the body starts with '[', or NDJSON otherwise.
+func readBatchItems(body io.Reader, contentType string) ([]json.RawMessage, error) {
+    br := bufio.NewReader(body)
+
+    mediaType, _, _ := mime.ParseMediaType(contentType)
+    isNDJSON := mediaType == "application/x-ndjson" || mediaType == "application/jsonl"
+    if !isNDJSON {
+        first, err := peekNonSpace(br)
+        if err != nil {
+            return nil, err
+        }
+        isNDJSON = first != '['
+    }
+
+    if !isNDJSON {
+        var items []json.RawMessage
+        if err := json.NewDecoder(br).Decode(&items); err != nil {
+            return nil, err
+        }
+        return items, nil
+    }
+
+    var items []json.RawMessage
+    scanner := bufio.NewScanner(br)
+    scanner.Buffer(make([]byte, 64*1024), maxNDJSONLine)
+    for scanner.Scan() {
+        line := bytes.TrimSpace(scanner.Bytes())
+        if len(line) == 0 {
+            continue // Tolerate blank lines, e.g. a trailing newline
+        }
+        // Copy the line: the scanner reuses its buffer.
+        items = append(items, json.RawMessage(append([]byte(nil), line...)))
+        if len(items) > maxBatchItems {
+            break // The caller reports the size error
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, err
+    }
+    return items, nil
+}
+
+// peekNonSpace returns the first non-whitespace byte of the reader without consuming it.
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+    for {
+        b, err := br.Peek(1)
+        if err == io.EOF {
+            return 0, nil // Empty body
+        }
+        if err != nil {
+            return 0, err
+        }
+        switch b[0] {
+        case ' ', '\t', '\r', '\n':
+            br.ReadByte()
+        default:
+            return b[0], nil
+        }
+    }
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/dedup"
+    "github.com/Kelvinkhyd/GuardianAI/internal/ingest"
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+    "github.com/Kelvinkhyd/GuardianAI/internal/repository"
+)
+
+func TestAlertsBatchFoldsRepeats(t *testing.T) {
+    repo := repository.NewMemoryRepository()
+    policy, err := dedup.NewPolicy(dedup.DefaultFields, time.Hour, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    h := NewHandler(repo, ingest.NewService(repo, policy))
+
+    body := strings.Join([]string{
+        `{"id": "a1", "source": "ids", "title": "Port scan", "severity": "high", "category": "network"}`,
+        `{"source": "ids", "title": "port scan ", "severity": "high", "category": "network"}`,
+        `{"id": "a1", "source": "ids", "title": "Other", "severity": "low", "category": "network"}`,
+        `not json`,
+        `{"source": "ids", "title": "Brute force", "severity": "low", "category": "auth"}`,
+    }, "\n")
+    req := httptest.NewRequest(http.MethodPost, "/alerts/batch", strings.NewReader(body))
+    req.Header.Set("Content-Type", "application/x-ndjson")
+    rec := httptest.NewRecorder()
+    h.HandleAlertsBatch(rec, req)
+
+    var resp batchResponse
+    if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+        t.Fatalf("reply is not JSON: %v: %s", err, rec.Body)
+    }
+    if rec.Code != http.StatusMultiStatus || resp.Accepted != 3 || resp.Rejected != 2 {
+        t.Fatalf("got %d with %d accepted and %d rejected, want 207, 3 and 2: %s", rec.Code, resp.Accepted, resp.Rejected, rec.Body)
+    }
+    var statuses []string
+    for _, res := range resp.Results {
+        statuses = append(statuses, res.Status)
+    }
+    want := []string{batchItemAccepted, batchItemFolded, batchItemDuplicate, batchItemInvalid, batchItemAccepted}
+    if strings.Join(statuses, " ") != strings.Join(want, " ") {
+        t.Errorf("got statuses %v, want %v", statuses, want)
+    }
+    if res := resp.Results[1]; res.AlertID != "a1" || res.OccurrenceCount != 2 {
+        t.Errorf("folded item: got %+v, want alert a1 with 2 occurrences", res)
+    }
+
+    alerts, err := repo.GetAllAlerts(req.Context(), models.DefaultTenant, repository.AlertFilter{})
+    if err != nil || len(alerts) != 2 {
+        t.Fatalf("got %d alerts, %v, want 2", len(alerts), err)
+    }
+}
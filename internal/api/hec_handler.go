@@ -0,0 +1,353 @@
+package api
+
+import (
+    "bufio"
+    "compress/gzip"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "log"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/auth"
+    "github.com/Kelvinkhyd/GuardianAI/internal/ingest"
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// Status codes of the Splunk HTTP Event Collector protocol, sent in the "code" field
+// of every reply. Agents look at these rather than at the HTTP status.
+const (
+    hecCodeSuccess        = 0
+    hecCodeNoData         = 5
+    hecCodeInvalidFormat  = 6
+    hecCodeServerError    = 8
+    hecCodeChannelMissing = 10
+    hecCodeInvalidChannel = 11
+    hecCodeEventRequired  = 12
+    hecCodeEventBlank     = 13
+    hecCodeAckDisabled    = 14
+    hecCodeHealthy        = 17
+)
+
+const (
+    hecMaxChannelLength = 128
+    hecChannelIdle      = 10 * time.Minute // Channels unused for this long are forgotten, with their acks
+    hecMaxPendingAcks   = 10000            // Per channel; beyond this the oldest unqueried acks are dropped
+)
+
+// hecResponse is the body of HEC replies.
+type hecResponse struct {
+    Text               string `json:"text"`
+    Code               int    `json:"code"`
+    AckID              *int64 `json:"ackId,omitempty"`
+    InvalidEventNumber *int   `json:"invalid-event-number,omitempty"`
+}
+
+// HECHandler implements the Splunk HTTP Event Collector endpoints, so agents that ship
+// to Splunk can send alerts to GuardianAI unchanged. The HEC token is an API key with
+// the ingest role, sent as "Authorization: Splunk <key>".
+type HECHandler struct {
+    Ingest  *ingest.Service
+    Mapping *ingest.HECMapping
+    acks    *hecAcks // nil if acknowledgements are disabled
+}
+
+// NewHECHandler creates a HECHandler storing alerts through in, translated by
+// mapping. With ackEnabled, requests on a data channel get an ackId that can be
+// checked at /services/collector/ack.
+func NewHECHandler(in *ingest.Service, mapping *ingest.HECMapping, ackEnabled bool) *HECHandler {
+    h := &HECHandler{Ingest: in, Mapping: mapping}
+    if ackEnabled {
+        h.acks = &hecAcks{channels: make(map[string]*hecChannel)}
+    }
+    return h
+}
+
+// HandleEvent ingests one or more JSON event envelopes, concatenated as HEC sends them.
+// Usage: POST /services/collector/event
+func (h *HECHandler) HandleEvent(w http.ResponseWriter, r *http.Request) {
+    channel, ok := h.channel(w, r)
+    if !ok {
+        return
+    }
+    body, err := hecBody(w, r)
+    if err != nil {
+        writeHEC(w, http.StatusBadRequest, hecResponse{Text: "Invalid data format", Code: hecCodeInvalidFormat})
+        return
+    }
+    defer body.Close()
+
+    var events []ingest.HECEvent
+    dec := json.NewDecoder(body)
+    dec.UseNumber()
+    for {
+        var e ingest.HECEvent
+        err := dec.Decode(&e)
+        if err == io.EOF {
+            break
+        }
+        if err != nil || len(events) >= maxBatchItems {
+            n := len(events)
+            writeHEC(w, http.StatusBadRequest, hecResponse{Text: "Invalid data format", Code: hecCodeInvalidFormat, InvalidEventNumber: &n})
+            return
+        }
+        events = append(events, e)
+    }
+    h.store(w, r, channel, events)
+}
+
+// HandleRaw ingests raw text, one event per line. The metadata of all events comes
+// from the host, source, sourcetype, index and time query parameters.
+// Usage: POST /services/collector/raw?sourcetype=suricata
+func (h *HECHandler) HandleRaw(w http.ResponseWriter, r *http.Request) {
+    channel, ok := h.channel(w, r)
+    if !ok {
+        return
+    }
+    body, err := hecBody(w, r)
+    if err != nil {
+        writeHEC(w, http.StatusBadRequest, hecResponse{Text: "Invalid data format", Code: hecCodeInvalidFormat})
+        return
+    }
+    defer body.Close()
+
+    q := r.URL.Query()
+    var events []ingest.HECEvent
+    scanner := bufio.NewScanner(body)
+    scanner.Buffer(make([]byte, 64*1024), maxNDJSONLine)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" {
+            continue
+        }
+        if len(events) >= maxBatchItems {
+            n := len(events)
+            writeHEC(w, http.StatusBadRequest, hecResponse{Text: "Invalid data format", Code: hecCodeInvalidFormat, InvalidEventNumber: &n})
+            return
+        }
+        e := ingest.HECEvent{Event: line, Host: q.Get("host"), Source: q.Get("source"), SourceType: q.Get("sourcetype"), Index: q.Get("index")}
+        if t := q.Get("time"); t != "" {
+            e.Time = t
+        }
+        events = append(events, e)
+    }
+    if err := scanner.Err(); err != nil {
+        writeHEC(w, http.StatusBadRequest, hecResponse{Text: "Invalid data format", Code: hecCodeInvalidFormat})
+        return
+    }
+    h.store(w, r, channel, events)
+}
+
+// store translates the events and stores them as alerts of the caller's tenant. The
+// whole request is rejected if any event cannot be translated, before anything is stored,
+// and the events are stored atomically, so an agent retrying a failed request does not
+// duplicate part of it.
+func (h *HECHandler) store(w http.ResponseWriter, r *http.Request, channel string, events []ingest.HECEvent) {
+    if len(events) == 0 {
+        writeHEC(w, http.StatusBadRequest, hecResponse{Text: "No data", Code: hecCodeNoData})
+        return
+    }
+    alerts := make([]models.SecurityAlert, len(events))
+    for i := range events {
+        alert, err := h.Mapping.Alert(&events[i])
+        if err != nil {
+            n := i
+            resp := hecResponse{Text: "Invalid data format", Code: hecCodeInvalidFormat, InvalidEventNumber: &n}
+            switch {
+            case errors.Is(err, ingest.ErrHECEventRequired):
+                resp.Text, resp.Code = "Event field is required", hecCodeEventRequired
+            case errors.Is(err, ingest.ErrHECEventBlank):
+                resp.Text, resp.Code = "Event field cannot be blank", hecCodeEventBlank
+            default:
+                log.Printf("Rejected HEC event %d: %v", i, err)
+            }
+            writeHEC(w, http.StatusBadRequest, resp)
+            return
+        }
+        alerts[i] = *alert
+    }
+
+    ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+    defer cancel()
+    tenant := auth.TenantFrom(r.Context())
+
+    // Same path as POST /alerts: stored, deduplicated and queued for analysis
+    results, err := h.Ingest.SubmitAll(ctx, tenant, alerts)
+    if err != nil {
+        log.Printf("ERROR: Failed to save HEC events to DB: %v", err)
+        writeHEC(w, http.StatusInternalServerError, hecResponse{Text: "Internal server error", Code: hecCodeServerError})
+        return
+    }
+    skipped := 0
+    for _, res := range results {
+        if res == nil {
+            skipped++
+        }
+    }
+    log.Printf("HEC ingestion: %d events saved and queued for processing, %d skipped as already stored", len(alerts)-skipped, skipped)
+
+    resp := hecResponse{Text: "Success", Code: hecCodeSuccess}
+    if h.acks != nil && channel != "" {
+        id := h.acks.issue(tenant+"/"+channel, time.Now())
+        resp.AckID = &id
+    }
+    writeHEC(w, http.StatusOK, resp)
+}
+
+// HandleAck reports which acknowledgement IDs of a channel have been indexed. Events
+// are stored before the request that carried them is answered, so every issued ID is
+// acknowledged; as in Splunk, an ID is reported true only once.
+// Usage: POST /services/collector/ack?channel=<guid> with {"acks": [1, 2]}
+func (h *HECHandler) HandleAck(w http.ResponseWriter, r *http.Request) {
+    if h.acks == nil {
+        writeHEC(w, http.StatusBadRequest, hecResponse{Text: "ACK is disabled", Code: hecCodeAckDisabled})
+        return
+    }
+    channel, ok := h.channel(w, r)
+    if !ok {
+        return
+    }
+    if channel == "" {
+        writeHEC(w, http.StatusBadRequest, hecResponse{Text: "Data channel is missing", Code: hecCodeChannelMissing})
+        return
+    }
+    var req struct {
+        Acks []int64 `json:"acks"`
+    }
+    if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+        writeHEC(w, http.StatusBadRequest, hecResponse{Text: "Invalid data format", Code: hecCodeInvalidFormat})
+        return
+    }
+
+    status := h.acks.query(auth.TenantFrom(r.Context())+"/"+channel, req.Acks, time.Now())
+    resp := map[string]map[string]bool{"acks": make(map[string]bool, len(status))}
+    for id, done := range status {
+        resp["acks"][strconv.FormatInt(id, 10)] = done
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusOK)
+    json.NewEncoder(w).Encode(resp)
+}
+
+// HandleHealth answers the health checks of HEC agents.
+// Usage: GET /services/collector/health
+func (h *HECHandler) HandleHealth(w http.ResponseWriter, r *http.Request) {
+    writeHEC(w, http.StatusOK, hecResponse{Text: "HEC is healthy", Code: hecCodeHealthy})
+}
+
+// channel returns the data channel of the request, from the X-Splunk-Request-Channel
+// header or the channel query parameter, or "" if it names none. Invalid channels
+// are answered with an error and ok false.
+func (h *HECHandler) channel(w http.ResponseWriter, r *http.Request) (string, bool) {
+    channel := r.Header.Get("X-Splunk-Request-Channel")
+    if channel == "" {
+        channel = r.URL.Query().Get("channel")
+    }
+    if len(channel) > hecMaxChannelLength || strings.ContainsAny(channel, "/ \t") {
+        writeHEC(w, http.StatusBadRequest, hecResponse{Text: "Invalid data channel", Code: hecCodeInvalidChannel})
+        return "", false
+    }
+    return channel, true
+}
+
+// hecBody returns the size-limited request body, decompressed if the agent gzipped it.
+func hecBody(w http.ResponseWriter, r *http.Request) (io.ReadCloser, error) {
+    body := http.MaxBytesReader(w, r.Body, maxBatchBodyBytes)
+    if !strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+        return body, nil
+    }
+    gz, err := gzip.NewReader(body)
+    if err != nil {
+        return nil, fmt.Errorf("invalid gzip body: %w", err)
+    }
+    // Bound the decompressed size as well
+    return struct {
+        io.Reader
+        io.Closer
+    }{io.LimitReader(gz, maxBatchBodyBytes), body}, nil
+}
+
+func writeHEC(w http.ResponseWriter, status int, resp hecResponse) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(resp)
+}
+
+// hecAcks hands out acknowledgement IDs per data channel and remembers them until
+// they are queried.
+type hecAcks struct {
+    mu        sync.Mutex
+    channels  map[string]*hecChannel // "tenant/channel" -> state
+    lastPrune time.Time
+}
+
+type hecChannel struct {
+    nextID   int64
+    oldest   int64              // Lowest ID that may still be pending
+    pending  map[int64]struct{} // Issued and not yet reported
+    lastUsed time.Time
+}
+
+// issue returns the next acknowledgement ID of the channel.
+func (a *hecAcks) issue(channel string, now time.Time) int64 {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    a.pruneLocked(now)
+
+    c := a.channels[channel]
+    if c == nil {
+        c = &hecChannel{pending: make(map[int64]struct{})}
+        a.channels[channel] = c
+    }
+    id := c.nextID
+    c.nextID++
+    c.pending[id] = struct{}{}
+    for len(c.pending) > hecMaxPendingAcks {
+        delete(c.pending, c.oldest)
+        c.oldest++
+    }
+    c.lastUsed = now
+    return id
+}
+
+// query reports for each ID whether it was issued on the channel and not reported
+// before, and forgets the reported ones.
+func (a *hecAcks) query(channel string, ids []int64, now time.Time) map[int64]bool {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+
+    status := make(map[int64]bool, len(ids))
+    c := a.channels[channel]
+    for _, id := range ids {
+        if c == nil {
+            status[id] = false
+            continue
+        }
+        _, status[id] = c.pending[id]
+        delete(c.pending, id)
+    }
+    if c != nil {
+        c.lastUsed = now
+    }
+    return status
+}
+
+// pruneLocked forgets channels that have been idle for hecChannelIdle. It scans the
+// channels at most once a minute.
+func (a *hecAcks) pruneLocked(now time.Time) {
+    if now.Sub(a.lastPrune) < time.Minute {
+        return
+    }
+    a.lastPrune = now
+    for name, c := range a.channels {
+        if now.Sub(c.lastUsed) > hecChannelIdle {
+            delete(a.channels, name)
+        }
+    }
+}
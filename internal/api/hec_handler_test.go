@@ -0,0 +1,205 @@
+package api
+
+import (
+    "bytes"
+    "compress/gzip"
+    "context"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "reflect"
+    "sort"
+    "testing"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/auth"
+    "github.com/Kelvinkhyd/GuardianAI/internal/ingest"
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+    "github.com/Kelvinkhyd/GuardianAI/internal/repository"
+)
+
+const hecTestTenant = "acme"
+
+// hecRequest sends body to h as a request of hecTestTenant and returns the status
+// code and the decoded reply.
+func hecRequest(t *testing.T, h http.HandlerFunc, target string, body []byte, header map[string]string) (int, map[string]interface{}) {
+    t.Helper()
+    req := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+    for k, v := range header {
+        req.Header.Set(k, v)
+    }
+    req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Subject: "hec", Tenant: hecTestTenant, Roles: []auth.Role{auth.RoleIngest}}))
+    rec := httptest.NewRecorder()
+    h(rec, req)
+    var resp map[string]interface{}
+    if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+        t.Fatalf("reply is not JSON: %v: %s", err, rec.Body)
+    }
+    return rec.Code, resp
+}
+
+// storedAlerts returns the alerts of hecTestTenant, oldest first.
+func storedAlerts(t *testing.T, repo repository.AlertRepository) []models.SecurityAlert {
+    t.Helper()
+    alerts, err := repo.GetAllAlerts(context.Background(), hecTestTenant, repository.AlertFilter{SortBy: repository.SortByTimestamp, SortAscending: true})
+    if err != nil {
+        t.Fatal(err)
+    }
+    return alerts
+}
+
+func newTestHECHandler(repo repository.AlertRepository, ackEnabled bool) *HECHandler {
+    return NewHECHandler(ingest.NewService(repo, nil), ingest.DefaultHECMapping(), ackEnabled)
+}
+
+func TestHECEvent(t *testing.T) {
+    repo := repository.NewMemoryRepository()
+    h := newTestHECHandler(repo, false)
+
+    // Envelopes are concatenated, not wrapped in an array
+    body := `{"time": 1772366400, "host": "web-01", "sourcetype": "suricata", "event": {"signature": "ET SCAN", "severity": "High", "src_ip": "203.0.113.7"}}
+        {"time": "1772366460.5", "event": "Failed password for root", "fields": {"env": "prod"}}`
+    code, resp := hecRequest(t, h.HandleEvent, "/services/collector/event", []byte(body), nil)
+    if code != http.StatusOK || resp["code"] != 0.0 || resp["ackId"] != nil {
+        t.Fatalf("got %d %v, want 200 with code 0 and no ackId", code, resp)
+    }
+
+    alerts := storedAlerts(t, repo)
+    if len(alerts) != 2 {
+        t.Fatalf("got %d alerts, want 2", len(alerts))
+    }
+    if a := alerts[0]; a.Title != "ET SCAN" || a.Source != "suricata" || a.Severity != "high" || a.SourceIP != "203.0.113.7" || a.Hostname != "web-01" {
+        t.Errorf("unexpected alert from a JSON event: %+v", a)
+    }
+    if a := alerts[1]; a.Title != "Failed password for root" || a.Attributes["env"] != "prod" || a.Status != models.StatusNew || len(a.RawPayload) == 0 {
+        t.Errorf("unexpected alert from a text event: %+v", a)
+    }
+}
+
+func TestHECInvalidEventNumber(t *testing.T) {
+    repo := repository.NewMemoryRepository()
+    h := newTestHECHandler(repo, false)
+
+    for _, tc := range []struct {
+        name string
+        body string
+        code float64
+        n    float64
+    }{
+        {"missing event", `{"event": "a"} {"host": "x"}`, hecCodeEventRequired, 1},
+        {"blank event", `{"event": "a"} {"event": "b"} {"event": "  "}`, hecCodeEventBlank, 2},
+        {"malformed envelope", `{"event": "a"} {"event": `, hecCodeInvalidFormat, 1},
+    } {
+        code, resp := hecRequest(t, h.HandleEvent, "/services/collector/event", []byte(tc.body), nil)
+        if code != http.StatusBadRequest || resp["code"] != tc.code || resp["invalid-event-number"] != tc.n {
+            t.Errorf("%s: got %d %v, want 400 with code %v for event %v", tc.name, code, resp, tc.code, tc.n)
+        }
+    }
+    if alerts := storedAlerts(t, repo); len(alerts) != 0 {
+        t.Errorf("rejected requests stored %d alerts", len(alerts))
+    }
+
+    code, resp := hecRequest(t, h.HandleEvent, "/services/collector/event", nil, nil)
+    if code != http.StatusBadRequest || resp["code"] != float64(hecCodeNoData) {
+        t.Errorf("empty body: got %d %v, want code %d", code, resp, hecCodeNoData)
+    }
+}
+
+func TestHECRawGzip(t *testing.T) {
+    repo := repository.NewMemoryRepository()
+    h := newTestHECHandler(repo, false)
+
+    var buf bytes.Buffer
+    gz := gzip.NewWriter(&buf)
+    gz.Write([]byte("first line\n\nCEF:0|Acme|IDS|1.0|100|Port scan|7|src=10.0.0.1\n"))
+    gz.Close()
+    code, resp := hecRequest(t, h.HandleRaw, "/services/collector/raw?sourcetype=ids&host=sensor-1&time=1772366400", buf.Bytes(), map[string]string{"Content-Encoding": "gzip"})
+    if code != http.StatusOK || resp["code"] != 0.0 {
+        t.Fatalf("got %d %v, want 200 with code 0", code, resp)
+    }
+
+    alerts := storedAlerts(t, repo)
+    if len(alerts) != 2 {
+        t.Fatalf("got %d alerts, want 2 (blank lines are skipped)", len(alerts))
+    }
+    for _, a := range alerts {
+        if a.Hostname != "sensor-1" || a.Timestamp.Unix() != 1772366400 {
+            t.Errorf("raw event without the query metadata: %+v", a)
+        }
+    }
+    titles := []string{alerts[0].Title, alerts[1].Title}
+    sort.Strings(titles) // Both have the same timestamp
+    if !reflect.DeepEqual(titles, []string{"Port scan", "first line"}) {
+        t.Errorf("got titles %q, want the CEF event name and the text line", titles)
+    }
+
+    code, resp = hecRequest(t, h.HandleRaw, "/services/collector/raw", []byte("not gzip"), map[string]string{"Content-Encoding": "gzip"})
+    if code != http.StatusBadRequest || resp["code"] != float64(hecCodeInvalidFormat) {
+        t.Errorf("invalid gzip body: got %d %v, want code %d", code, resp, hecCodeInvalidFormat)
+    }
+}
+
+func TestHECAcks(t *testing.T) {
+    h := newTestHECHandler(repository.NewMemoryRepository(), true)
+    channel := map[string]string{"X-Splunk-Request-Channel": "0aeeac95-ac74-4aa9-b30d-6c4c0ac581ba"}
+
+    for want := 0.0; want < 2; want++ {
+        code, resp := hecRequest(t, h.HandleEvent, "/services/collector/event", []byte(`{"event": "x"}`), channel)
+        if code != http.StatusOK || resp["ackId"] != want {
+            t.Fatalf("got %d %v, want ackId %v", code, resp, want)
+        }
+    }
+    // Without a channel there is nothing to acknowledge on
+    if _, resp := hecRequest(t, h.HandleEvent, "/services/collector/event", []byte(`{"event": "x"}`), nil); resp["ackId"] != nil {
+        t.Errorf("request without a channel got ackId %v", resp["ackId"])
+    }
+
+    code, resp := hecRequest(t, h.HandleAck, "/services/collector/ack", []byte(`{"acks": [0, 1, 7]}`), channel)
+    want := map[string]interface{}{"0": true, "1": true, "7": false}
+    if code != http.StatusOK || !reflect.DeepEqual(resp["acks"], want) {
+        t.Errorf("ack query: got %d %v, want %v", code, resp, want)
+    }
+    // Reported once, like Splunk
+    if _, resp := hecRequest(t, h.HandleAck, "/services/collector/ack", []byte(`{"acks": [0]}`), channel); !reflect.DeepEqual(resp["acks"], map[string]interface{}{"0": false}) {
+        t.Errorf("second ack query: got %v, want 0 no longer pending", resp)
+    }
+    // Acks belong to the channel they were issued on
+    other := map[string]string{"X-Splunk-Request-Channel": "other"}
+    if _, resp := hecRequest(t, h.HandleAck, "/services/collector/ack", []byte(`{"acks": [1]}`), other); !reflect.DeepEqual(resp["acks"], map[string]interface{}{"1": false}) {
+        t.Errorf("ack query on another channel: got %v", resp)
+    }
+
+    if code, resp := hecRequest(t, h.HandleAck, "/services/collector/ack", []byte(`{"acks": [0]}`), nil); code != http.StatusBadRequest || resp["code"] != float64(hecCodeChannelMissing) {
+        t.Errorf("ack query without a channel: got %d %v", code, resp)
+    }
+    if code, resp := hecRequest(t, h.HandleEvent, "/services/collector/event?channel=a/b", []byte(`{"event": "x"}`), nil); code != http.StatusBadRequest || resp["code"] != float64(hecCodeInvalidChannel) {
+        t.Errorf("invalid channel: got %d %v", code, resp)
+    }
+
+    disabled := newTestHECHandler(repository.NewMemoryRepository(), false)
+    if code, resp := hecRequest(t, disabled.HandleAck, "/services/collector/ack", []byte(`{"acks": [0]}`), channel); code != http.StatusBadRequest || resp["code"] != float64(hecCodeAckDisabled) {
+        t.Errorf("ack query with acks disabled: got %d %v", code, resp)
+    }
+}
+
+// failingRepository fails every batch insert.
+type failingRepository struct {
+    *repository.MemoryRepository
+}
+
+func (r failingRepository) CreateAlerts(ctx context.Context, tenant string, alerts []models.SecurityAlert) ([]string, error) {
+    return nil, errors.New("connection reset")
+}
+
+func TestHECStoreFailure(t *testing.T) {
+    repo := failingRepository{repository.NewMemoryRepository()}
+    h := newTestHECHandler(repo, true)
+
+    code, resp := hecRequest(t, h.HandleEvent, "/services/collector/event", []byte(`{"event": "a"} {"event": "b"}`), map[string]string{"X-Splunk-Request-Channel": "c"})
+    if code != http.StatusInternalServerError || resp["code"] != float64(hecCodeServerError) || resp["ackId"] != nil {
+        t.Errorf("got %d %v, want 500 with code %d and no ackId", code, resp, hecCodeServerError)
+    }
+    if alerts := storedAlerts(t, repo); len(alerts) != 0 {
+        t.Errorf("a failed request stored %d alerts, the agent's retry would duplicate them", len(alerts))
+    }
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "net/http"
+    "net/url"
+    "strconv"
+    "time"
+
+    "github.com/gorilla/mux"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/auth"
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+    "github.com/Kelvinkhyd/GuardianAI/internal/repository"
+)
+
+// IncidentHandler serves the incidents built by the correlation engine in the processor.
+type IncidentHandler struct {
+    IncidentRepo repository.IncidentRepository
+}
+
+// NewIncidentHandler creates a new IncidentHandler instance.
+func NewIncidentHandler(ir repository.IncidentRepository) *IncidentHandler {
+    return &IncidentHandler{IncidentRepo: ir}
+}
+
+// incidentListResponse is the envelope returned by GET /incidents.
+type incidentListResponse struct {
+    Items   []models.Incident `json:"items"`
+    HasMore bool              `json:"has_more"`
+}
+
+// parseIncidentFilter builds a repository.IncidentFilter from the query string of GET /incidents.
+//
+// Supported parameters:
+//
+//	status          open, investigating or resolved
+//	min_risk_score  inclusive lower bound, 0.0 - 1.0
+//	limit, offset   pagination
+func parseIncidentFilter(q url.Values) (repository.IncidentFilter, error) {
+    f := repository.IncidentFilter{Status: models.IncidentStatus(q.Get("status"))}
+    if f.Status != "" && !f.Status.IsValid() {
+        return f, fmt.Errorf("unknown status %q", f.Status)
+    }
+
+    var err error
+    if f.MinRiskScore, err = parseOptionalFloat(q, "min_risk_score"); err != nil {
+        return f, err
+    }
+
+    f.Limit, err = strconv.Atoi(q.Get("limit"))
+    if err != nil || f.Limit <= 0 {
+        f.Limit = defaultListLimit
+    }
+    if f.Limit > maxListLimit {
+        f.Limit = maxListLimit
+    }
+    f.Offset, err = strconv.Atoi(q.Get("offset"))
+    if err != nil || f.Offset < 0 {
+        f.Offset = 0
+    }
+    return f, nil
+}
+
+// GetIncidents lists incidents, most recently active first, without their member alerts.
+// Usage: /incidents?status=open&min_risk_score=0.8&limit=20&offset=0
+func (h *IncidentHandler) GetIncidents(w http.ResponseWriter, r *http.Request) {
+    filter, err := parseIncidentFilter(r.URL.Query())
+    if err != nil {
+        http.Error(w, "Invalid query: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    tenant := auth.TenantFrom(r.Context())
+
+    // Fetch one extra row to find out whether another page exists.
+    pageSize := filter.Limit
+    filter.Limit = pageSize + 1
+
+    incidents, err := h.IncidentRepo.GetAllIncidents(ctx, tenant, filter)
+    if err != nil {
+        log.Printf("ERROR: Failed to retrieve incidents: %v", err)
+        http.Error(w, "Failed to retrieve incidents: "+err.Error(), http.StatusInternalServerError)
+        return
+    }
+
+    resp := incidentListResponse{Items: incidents}
+    if len(incidents) > pageSize {
+        resp.Items = incidents[:pageSize]
+        resp.HasMore = true
+    }
+    if resp.Items == nil {
+        resp.Items = []models.Incident{} // Always encode an array, never null
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusOK)
+    json.NewEncoder(w).Encode(resp)
+}
+
+// GetIncidentByID returns one incident with its entities, member alerts and timeline.
+// Usage: /incidents/{id}
+func (h *IncidentHandler) GetIncidentByID(w http.ResponseWriter, r *http.Request) {
+    incidentID := mux.Vars(r)["id"]
+    if incidentID == "" {
+        http.Error(w, "Incident ID is missing from the URL path", http.StatusBadRequest)
+        return
+    }
+
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    tenant := auth.TenantFrom(r.Context())
+
+    incident, err := h.IncidentRepo.GetIncidentByID(ctx, tenant, incidentID)
+    if err != nil {
+        log.Printf("ERROR: Failed to retrieve incident by ID %s: %v", incidentID, err)
+        http.Error(w, "Failed to retrieve incident: "+err.Error(), http.StatusInternalServerError)
+        return
+    }
+    if incident == nil {
+        http.Error(w, "Incident not found", http.StatusNotFound)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusOK)
+    json.NewEncoder(w).Encode(incident)
+}
+
+// updateIncidentRequest is the body accepted by PATCH /incidents/{id}.
+type updateIncidentRequest struct {
+    Status models.IncidentStatus `json:"status"`
+}
+
+// UpdateIncident moves an incident to a new status.
+// Usage: PATCH /incidents/{id} with body {"status": "investigating"}
+// Resolved incidents stop receiving alerts; illegal transitions are rejected with 409 Conflict.
+func (h *IncidentHandler) UpdateIncident(w http.ResponseWriter, r *http.Request) {
+    incidentID := mux.Vars(r)["id"]
+    if incidentID == "" {
+        http.Error(w, "Incident ID is missing from the URL path", http.StatusBadRequest)
+        return
+    }
+
+    var req updateIncidentRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    if !req.Status.IsValid() {
+        http.Error(w, fmt.Sprintf("Unknown status %q", req.Status), http.StatusBadRequest)
+        return
+    }
+
+    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+    defer cancel()
+    tenant := auth.TenantFrom(r.Context())
+
+    incident, err := h.IncidentRepo.GetIncidentByID(ctx, tenant, incidentID)
+    if err != nil {
+        log.Printf("ERROR: Failed to retrieve incident by ID %s: %v", incidentID, err)
+        http.Error(w, "Failed to retrieve incident: "+err.Error(), http.StatusInternalServerError)
+        return
+    }
+    if incident == nil {
+        http.Error(w, "Incident not found", http.StatusNotFound)
+        return
+    }
+    if !incident.Status.CanTransitionTo(req.Status) {
+        http.Error(w, fmt.Sprintf("Cannot move incident from %q to %q", incident.Status, req.Status), http.StatusConflict)
+        return
+    }
+
+    if err := h.IncidentRepo.UpdateIncidentStatus(ctx, tenant, incidentID, req.Status); err != nil {
+        switch {
+        case errors.Is(err, repository.ErrIncidentNotFound):
+            http.Error(w, "Incident not found", http.StatusNotFound)
+        case errors.Is(err, repository.ErrIncidentTransition):
+            http.Error(w, "Incident status was changed by someone else, reload and retry", http.StatusConflict)
+        default:
+            log.Printf("ERROR: Failed to update status of incident %s: %v", incidentID, err)
+            http.Error(w, "Failed to update incident: "+err.Error(), http.StatusInternalServerError)
+        }
+        return
+    }
+    log.Printf("Incident %s moved from %s to %s", incidentID, incident.Status, req.Status)
+
+    // Reload to include the new timeline entry
+    incident, err = h.IncidentRepo.GetIncidentByID(ctx, tenant, incidentID)
+    if err != nil || incident == nil {
+        log.Printf("ERROR: Failed to reload incident %s: %v", incidentID, err)
+        http.Error(w, "Failed to retrieve incident", http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusOK)
+    json.NewEncoder(w).Encode(incident)
+}
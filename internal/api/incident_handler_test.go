@@ -0,0 +1,145 @@
+package api
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/gorilla/mux"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/auth"
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+    "github.com/Kelvinkhyd/GuardianAI/internal/repository"
+)
+
+// serveIncident runs one request against handle on behalf of a caller of tenant.
+// id, if set, is passed as the {id} route variable.
+func serveIncident(handle http.HandlerFunc, method, target, id, tenant, body string) *httptest.ResponseRecorder {
+    req := httptest.NewRequest(method, target, strings.NewReader(body))
+    if id != "" {
+        req = mux.SetURLVars(req, map[string]string{"id": id})
+    }
+    req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Subject: "tester", Tenant: tenant, Roles: []auth.Role{auth.RoleAnalyst}}))
+    rec := httptest.NewRecorder()
+    handle(rec, req)
+    return rec
+}
+
+// newIncidentRepo returns a repository with the incidents incident-a1 and incident-a2
+// of tenant acme and incident-b1 of tenant globex, each holding one alert.
+func newIncidentRepo(t *testing.T) *repository.MemoryRepository {
+    t.Helper()
+    ctx := context.Background()
+    repo := repository.NewMemoryRepository()
+    now := time.Now().UTC()
+    for _, c := range []struct{ tenant, id, host string }{
+        {"acme", "a1", "host-1"},
+        {"acme", "a2", "host-2"},
+        {"globex", "b1", "host-1"},
+    } {
+        alert := models.SecurityAlert{ID: c.id, Source: "edr", Title: "Alert " + c.id, Severity: "high", Timestamp: now, Status: models.StatusAnalyzed}
+        if err := repo.CreateAlert(ctx, c.tenant, &alert); err != nil {
+            t.Fatal(err)
+        }
+        es := []models.IncidentEntity{{Kind: models.EntityHostname, Value: c.host, LastSeen: now}}
+        if _, _, err := repo.AttachAlert(ctx, alert, es, now.Add(-time.Hour), "incident-"+c.id); err != nil {
+            t.Fatal(err)
+        }
+    }
+    return repo
+}
+
+func TestGetIncidents(t *testing.T) {
+    h := NewIncidentHandler(newIncidentRepo(t))
+
+    for tenant, want := range map[string]int{"acme": 2, "globex": 1, "initech": 0} {
+        rec := serveIncident(h.GetIncidents, http.MethodGet, "/incidents", "", tenant, "")
+        var resp incidentListResponse
+        if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+            t.Fatalf("reply is not JSON: %v: %s", err, rec.Body)
+        }
+        if rec.Code != http.StatusOK || len(resp.Items) != want {
+            t.Errorf("%s: got status %d with %d incidents, want 200 and %d", tenant, rec.Code, len(resp.Items), want)
+        }
+        for _, incident := range resp.Items {
+            if incident.TenantID != tenant {
+                t.Errorf("%s: got incident %s of tenant %s", tenant, incident.ID, incident.TenantID)
+            }
+        }
+    }
+
+    if rec := serveIncident(h.GetIncidents, http.MethodGet, "/incidents?status=closed", "", "acme", ""); rec.Code != http.StatusBadRequest {
+        t.Errorf("unknown status filter: got status %d, want 400", rec.Code)
+    }
+}
+
+func TestGetIncidentByID(t *testing.T) {
+    h := NewIncidentHandler(newIncidentRepo(t))
+
+    rec := serveIncident(h.GetIncidentByID, http.MethodGet, "/incidents/incident-a1", "incident-a1", "acme", "")
+    var got models.Incident
+    if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+        t.Fatalf("reply is not JSON: %v: %s", err, rec.Body)
+    }
+    if rec.Code != http.StatusOK || got.ID != "incident-a1" || len(got.Alerts) != 1 || got.Alerts[0].ID != "a1" {
+        t.Errorf("got status %d with %+v, want 200 and incident-a1 holding a1", rec.Code, got)
+    }
+
+    for _, c := range []struct{ name, id, tenant string }{
+        {"missing incident", "incident-zz", "acme"},
+        {"other tenant", "incident-b1", "acme"},
+    } {
+        if rec := serveIncident(h.GetIncidentByID, http.MethodGet, "/incidents/"+c.id, c.id, c.tenant, ""); rec.Code != http.StatusNotFound {
+            t.Errorf("%s: got status %d, want 404", c.name, rec.Code)
+        }
+    }
+}
+
+func TestUpdateIncident(t *testing.T) {
+    repo := newIncidentRepo(t)
+    h := NewIncidentHandler(repo)
+
+    // Steps run in order, each starting from the state the previous one left
+    steps := []struct {
+        name   string
+        tenant string
+        id     string
+        body   string
+        want   int
+    }{
+        {"bad JSON", "acme", "incident-a1", `{"status":`, http.StatusBadRequest},
+        {"unknown status", "acme", "incident-a1", `{"status": "closed"}`, http.StatusBadRequest},
+        {"missing incident", "acme", "incident-zz", `{"status": "investigating"}`, http.StatusNotFound},
+        {"other tenant", "globex", "incident-a1", `{"status": "investigating"}`, http.StatusNotFound},
+        {"open to investigating", "acme", "incident-a1", `{"status": "investigating"}`, http.StatusOK},
+        {"back to open", "acme", "incident-a1", `{"status": "open"}`, http.StatusConflict},
+        {"investigating to resolved", "acme", "incident-a1", `{"status": "resolved"}`, http.StatusOK},
+        {"reopen resolved", "acme", "incident-a1", `{"status": "investigating"}`, http.StatusConflict},
+        {"open to resolved", "acme", "incident-a2", `{"status": "resolved"}`, http.StatusOK},
+    }
+    for _, s := range steps {
+        if rec := serveIncident(h.UpdateIncident, http.MethodPatch, "/incidents/"+s.id, s.id, s.tenant, s.body); rec.Code != s.want {
+            t.Errorf("%s: got status %d, want %d: %s", s.name, rec.Code, s.want, rec.Body)
+        }
+    }
+
+    // The change is stored and recorded in the timeline
+    got, err := repo.GetIncidentByID(context.Background(), "acme", "incident-a2")
+    if err != nil || got == nil || got.Status != models.IncidentResolved {
+        t.Fatalf("incident-a2: got %+v, %v, want resolved", got, err)
+    }
+    last := got.Timeline[len(got.Timeline)-1]
+    if last.Type != models.IncidentEventStatusChanged {
+        t.Errorf("incident-a2: got last timeline entry %+v, want a status change", last)
+    }
+
+    // The other tenant's incident was not touched
+    other, err := repo.GetIncidentByID(context.Background(), "globex", "incident-b1")
+    if err != nil || other == nil || other.Status != models.IncidentOpen {
+        t.Errorf("incident-b1: got %+v, %v, want it still open", other, err)
+    }
+}
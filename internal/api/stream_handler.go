@@ -0,0 +1,199 @@
+package api
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "log"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/gorilla/mux"
+    "github.com/gorilla/websocket"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/auth"
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+    "github.com/Kelvinkhyd/GuardianAI/internal/stream"
+)
+
+const (
+    // heartbeatInterval is how often an idle stream sends a keep-alive, so proxies do
+    // not close it and dead WebSocket clients are noticed.
+    heartbeatInterval = 15 * time.Second
+    wsWriteTimeout    = 10 * time.Second
+)
+
+// StreamHandler pushes new and re-analysed alerts to clients as they happen, over
+// Server-Sent Events or WebSocket.
+type StreamHandler struct {
+    Hub      *stream.Hub
+    upgrader websocket.Upgrader
+}
+
+// NewStreamHandler creates a new StreamHandler. WebSocket connections are accepted
+// from the origins in allowedOrigins ("*" allows any); if it is empty, only from
+// pages served by this host.
+func NewStreamHandler(hub *stream.Hub, allowedOrigins []string) *StreamHandler {
+    h := &StreamHandler{Hub: hub}
+    if len(allowedOrigins) > 0 {
+        allowed := make(map[string]bool, len(allowedOrigins))
+        for _, o := range allowedOrigins {
+            allowed[o] = true
+        }
+        h.upgrader.CheckOrigin = func(r *http.Request) bool {
+            origin := r.Header.Get("Origin")
+            return origin == "" || allowed["*"] || allowed[origin]
+        }
+    }
+    return h
+}
+
+// IsWebSocketRequest matches requests asking for a WebSocket upgrade, so both
+// transports can be served on the same path.
+func IsWebSocketRequest(r *http.Request, rm *mux.RouteMatch) bool {
+    return websocket.IsWebSocketUpgrade(r)
+}
+
+// subscribe opens a subscription for the caller's tenant, with the filter and resume
+// position of the request.
+// The position comes from the Last-Event-ID header, sent by browsers when an
+// EventSource reconnects, or the last_event_id query parameter.
+func (h *StreamHandler) subscribe(r *http.Request) (*stream.Subscription, error) {
+    filter, err := parseAlertFilter(r.URL.Query())
+    if err != nil {
+        return nil, err
+    }
+    after := int64(-1)
+    raw := r.Header.Get("Last-Event-ID")
+    if raw == "" {
+        raw = r.URL.Query().Get("last_event_id")
+    }
+    if raw != "" {
+        if after, err = strconv.ParseInt(raw, 10, 64); err != nil || after < 0 {
+            return nil, fmt.Errorf("invalid last event ID %q", raw)
+        }
+    }
+    return h.Hub.Subscribe(auth.TenantFrom(r.Context()), filter, after), nil
+}
+
+// next waits up to heartbeatInterval for the next events. It returns no events and
+// no error if the wait timed out.
+func next(ctx context.Context, sub *stream.Subscription) ([]models.AlertEvent, error) {
+    wait, cancel := context.WithTimeout(ctx, heartbeatInterval)
+    defer cancel()
+    events, err := sub.Next(wait)
+    if err != nil && ctx.Err() == nil && wait.Err() != nil {
+        return nil, nil // Heartbeat due
+    }
+    return events, err
+}
+
+// StreamAlerts streams alert events as Server-Sent Events.
+// Usage: GET /alerts/stream?severity=high, with the filter parameters of GET /alerts.
+// Every event carries the event ID, its type (alert.created or alert.analyzed) and the
+// alert as JSON data. Reconnecting clients resume after the Last-Event-ID they send.
+func (h *StreamHandler) StreamAlerts(w http.ResponseWriter, r *http.Request) {
+    sub, err := h.subscribe(r)
+    if err != nil {
+        http.Error(w, "Invalid query: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    rc := http.NewResponseController(w)
+    rc.SetWriteDeadline(time.Time{}) // The stream outlives any server write timeout
+    w.Header().Set("Content-Type", "text/event-stream")
+    w.Header().Set("Cache-Control", "no-cache")
+    w.Header().Set("X-Accel-Buffering", "no") // Keep nginx from buffering the stream
+    w.WriteHeader(http.StatusOK)
+    fmt.Fprint(w, "retry: 3000\n\n")
+    if err := rc.Flush(); err != nil {
+        log.Printf("ERROR: Alert stream not supported by the connection: %v", err)
+        return
+    }
+
+    ctx := r.Context()
+    for {
+        events, err := next(ctx, sub)
+        if err != nil {
+            if ctx.Err() == nil {
+                log.Printf("ERROR: Alert stream failed: %v", err)
+            }
+            return // The client reconnects and resumes from its Last-Event-ID
+        }
+        if len(events) == 0 {
+            fmt.Fprint(w, ": ping\n\n")
+        }
+        for _, e := range events {
+            data, err := json.Marshal(e.Alert)
+            if err != nil {
+                log.Printf("ERROR: Failed to encode alert event %d: %v", e.ID, err)
+                continue
+            }
+            fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+        }
+        if err := rc.Flush(); err != nil {
+            return // Client went away
+        }
+    }
+}
+
+// StreamAlertsWS streams alert events over a WebSocket.
+// Usage: GET /alerts/stream?severity=high with an Upgrade: websocket header.
+// Every message is a JSON object {"id", "type", "alert", "created_at"}. Browsers
+// cannot set Last-Event-ID on a WebSocket, so clients resume with ?last_event_id=.
+func (h *StreamHandler) StreamAlertsWS(w http.ResponseWriter, r *http.Request) {
+    sub, err := h.subscribe(r)
+    if err != nil {
+        http.Error(w, "Invalid query: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    conn, err := h.upgrader.Upgrade(w, r, nil)
+    if err != nil {
+        return // Upgrade has already replied with an error
+    }
+    defer conn.Close()
+
+    // The request context is not cancelled when a hijacked connection closes, so
+    // reading is what tells us the client has gone. Clients are not expected to send
+    // anything but control frames.
+    ctx, cancel := context.WithCancel(r.Context())
+    defer cancel()
+    conn.SetReadLimit(4096)
+    conn.SetReadDeadline(time.Now().Add(2 * heartbeatInterval))
+    conn.SetPongHandler(func(string) error {
+        return conn.SetReadDeadline(time.Now().Add(2 * heartbeatInterval))
+    })
+    go func() {
+        defer cancel()
+        for {
+            if _, _, err := conn.ReadMessage(); err != nil {
+                return
+            }
+        }
+    }()
+
+    for {
+        events, err := next(ctx, sub)
+        if err != nil {
+            if ctx.Err() == nil {
+                log.Printf("ERROR: Alert stream failed: %v", err)
+                conn.WriteControl(websocket.CloseMessage,
+                    websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "alert stream failed"), time.Now().Add(wsWriteTimeout))
+            }
+            return
+        }
+        if len(events) == 0 {
+            if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+                return
+            }
+            continue
+        }
+        conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+        for _, e := range events {
+            if err := conn.WriteJSON(e); err != nil {
+                return // Client went away
+            }
+        }
+    }
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// apiKeyPrefix starts every key, so keys are easy to recognise, e.g. by secret scanners.
+// A key reads gai_<id>_<secret>: the ID is stored in clear to look the key up, the
+// whole key only as a SHA-256 hash. Keys carry 256 random bits, so a fast hash is
+// enough; there is nothing to brute-force as with passwords.
+const apiKeyPrefix = "gai_"
+
+// NewAPIKey creates a key of the tenant with a random ID and secret. It returns the
+// record to store and the key to hand to the client, which cannot be recovered later.
+func NewAPIKey(name, tenant string, roles []Role, createdBy string, expiresAt *time.Time) (*models.APIKey, string, error) {
+    id := make([]byte, 6)
+    secret := make([]byte, 32)
+    if _, err := rand.Read(id); err != nil {
+        return nil, "", fmt.Errorf("failed to generate API key: %w", err)
+    }
+    if _, err := rand.Read(secret); err != nil {
+        return nil, "", fmt.Errorf("failed to generate API key: %w", err)
+    }
+
+    key := &models.APIKey{
+        ID:        hex.EncodeToString(id),
+        Name:      name,
+        TenantID:  tenant,
+        CreatedBy: createdBy,
+        ExpiresAt: expiresAt,
+    }
+    for _, r := range roles {
+        key.Roles = append(key.Roles, string(r))
+    }
+    plain := apiKeyPrefix + key.ID + "_" + base64.RawURLEncoding.EncodeToString(secret)
+    key.Hash = HashAPIKey(plain)
+    return key, plain, nil
+}
+
+// HashAPIKey returns the hash stored for a key.
+func HashAPIKey(key string) []byte {
+    sum := sha256.Sum256([]byte(key))
+    return sum[:]
+}
+
+// IsAPIKey reports whether a bearer token looks like an API key rather than a JWT.
+func IsAPIKey(token string) bool {
+    return strings.HasPrefix(token, apiKeyPrefix)
+}
+
+// apiKeyID extracts the ID part of a key.
+func apiKeyID(key string) (string, bool) {
+    rest, ok := strings.CutPrefix(key, apiKeyPrefix)
+    if !ok {
+        return "", false
+    }
+    id, secret, ok := strings.Cut(rest, "_")
+    if !ok || id == "" || secret == "" {
+        return "", false
+    }
+    return id, true
+}
@@ -0,0 +1,86 @@
+// Package auth authenticates API requests with API keys (sensors and scripts) or JWTs
+// (analysts signing in through an identity provider) and checks the role each route
+// requires. Every caller also belongs to a tenant and only sees that tenant's alerts.
+package auth
+
+import (
+    "context"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// Role is a permission level granted to a caller.
+type Role string
+
+// Roles. Admin implies every other role.
+const (
+    RoleIngest  Role = "ingest"  // Submit alerts
+    RoleAnalyst Role = "analyst" // Read, stream and triage alerts and incidents
+    RoleAdmin   Role = "admin"   // Everything, including API key management
+)
+
+// IsValid reports whether r is one of the known roles.
+func (r Role) IsValid() bool {
+    switch r {
+    case RoleIngest, RoleAnalyst, RoleAdmin:
+        return true
+    }
+    return false
+}
+
+// Authentication methods recorded in Principal.Method.
+const (
+    MethodAPIKey = "api_key"
+    MethodJWT    = "jwt"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+    Subject string // API key ID or JWT subject
+    Name    string // API key name or the user name from the JWT
+    Method  string // MethodAPIKey or MethodJWT
+    Tenant  string // Tenant whose alerts the principal may see, never empty
+    Roles   []Role
+}
+
+// HasRole reports whether the principal was granted role, directly or through admin.
+func (p *Principal) HasRole(role Role) bool {
+    for _, r := range p.Roles {
+        if r == role || r == RoleAdmin {
+            return true
+        }
+    }
+    return false
+}
+
+// String identifies the principal in logs.
+func (p *Principal) String() string {
+    s := p.Method + ":" + p.Subject
+    if p.Name != "" && p.Name != p.Subject {
+        s += " (" + p.Name + ")"
+    }
+    return s + "@" + p.Tenant
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+    return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal stored by the middleware, or nil if the
+// request was not authenticated (e.g. authentication is disabled).
+func PrincipalFrom(ctx context.Context) *Principal {
+    p, _ := ctx.Value(principalKey{}).(*Principal)
+    return p
+}
+
+// TenantFrom returns the tenant of the request's principal. Requests that were not
+// authenticated, because authentication is disabled, belong to the default tenant.
+func TenantFrom(ctx context.Context) string {
+    if p := PrincipalFrom(ctx); p != nil && p.Tenant != "" {
+        return p.Tenant
+    }
+    return models.DefaultTenant
+}
@@ -0,0 +1,221 @@
+package auth
+
+import (
+    "context"
+    "crypto/ecdsa"
+    "crypto/elliptic"
+    "crypto/rand"
+    "crypto/rsa"
+    "encoding/base64"
+    "encoding/json"
+    "math/big"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "github.com/golang-jwt/jwt/v5"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+    "github.com/Kelvinkhyd/GuardianAI/internal/repository"
+)
+
+// serve runs one request through h and returns the status code.
+func serve(h http.HandlerFunc, target string, header http.Header) int {
+    req := httptest.NewRequest("GET", target, nil)
+    for k, v := range header {
+        req.Header[k] = v
+    }
+    rec := httptest.NewRecorder()
+    h(rec, req)
+    return rec.Code
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+    ctx := context.Background()
+    repo := repository.NewMemoryRepository()
+    authn := NewAuthenticator(repo, "bootstrap-secret", nil)
+
+    ok := func(w http.ResponseWriter, r *http.Request) {
+        if PrincipalFrom(r.Context()) == nil {
+            t.Error("handler ran without a principal")
+        }
+    }
+    ingest := authn.Require(RoleIngest, ok)
+    analyst := authn.Require(RoleAnalyst, ok)
+    stream := authn.RequireStream(RoleAnalyst, ok)
+
+    sensor, sensorToken, err := NewAPIKey("sensor-1", "acme", []Role{RoleIngest}, "test", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := repo.CreateAPIKey(ctx, sensor); err != nil {
+        t.Fatal(err)
+    }
+    past := time.Now().Add(-time.Hour)
+    expired, expiredToken, _ := NewAPIKey("old", "acme", []Role{RoleIngest}, "test", &past)
+    if err := repo.CreateAPIKey(ctx, expired); err != nil {
+        t.Fatal(err)
+    }
+    bearer := func(token string) http.Header { return http.Header{"Authorization": {"Bearer " + token}} }
+
+    cases := []struct {
+        name   string
+        h      http.HandlerFunc
+        target string
+        header http.Header
+        want   int
+    }{
+        {"no credentials", ingest, "/", nil, http.StatusUnauthorized},
+        {"sensor key", ingest, "/", bearer(sensorToken), http.StatusOK},
+        {"sensor key in X-API-Key", ingest, "/", http.Header{"X-Api-Key": {sensorToken}}, http.StatusOK},
+        {"sensor key lacks role", analyst, "/", bearer(sensorToken), http.StatusForbidden},
+        {"tampered secret", ingest, "/", bearer(sensorToken[:len(sensorToken)-2] + "xx"), http.StatusUnauthorized},
+        {"expired key", ingest, "/", bearer(expiredToken), http.StatusUnauthorized},
+        {"bootstrap admin", analyst, "/", bearer("bootstrap-secret"), http.StatusOK},
+        {"query token on stream", stream, "/?access_token=bootstrap-secret", nil, http.StatusOK},
+        {"query token elsewhere", analyst, "/?access_token=bootstrap-secret", nil, http.StatusUnauthorized},
+        {"basic auth", ingest, "/", http.Header{"Authorization": {"Basic Zm9vOmJhcg=="}}, http.StatusUnauthorized},
+    }
+    for _, c := range cases {
+        if got := serve(c.h, c.target, c.header); got != c.want {
+            t.Errorf("%s: got status %d, want %d", c.name, got, c.want)
+        }
+    }
+
+    // Use is recorded, and revoked keys stop working
+    stored, _ := repo.GetAPIKeyByID(ctx, sensor.ID)
+    if stored.LastUsedAt == nil {
+        t.Error("expected the key's last use to be recorded")
+    }
+    if err := repo.RevokeAPIKey(ctx, sensor.ID); err != nil {
+        t.Fatal(err)
+    }
+    if got := serve(ingest, "/", bearer(sensorToken)); got != http.StatusUnauthorized {
+        t.Errorf("revoked key: got status %d, want 401", got)
+    }
+
+    // A nil Authenticator leaves routes open
+    var disabled *Authenticator
+    called := false
+    disabled.Require(RoleAdmin, func(http.ResponseWriter, *http.Request) { called = true })(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+    if !called {
+        t.Error("expected a nil Authenticator to pass requests through")
+    }
+}
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+// writeJWKS writes the public keys of signers to a JWKS file.
+func writeJWKS(t *testing.T, path string, signers map[string]interface{}) {
+    t.Helper()
+    var keys []map[string]string
+    for kid, s := range signers {
+        switch k := s.(type) {
+        case *rsa.PrivateKey:
+            keys = append(keys, map[string]string{"kty": "RSA", "kid": kid, "use": "sig",
+                "n": b64(k.N.Bytes()), "e": b64(big.NewInt(int64(k.E)).Bytes())})
+        case *ecdsa.PrivateKey:
+            x, y := make([]byte, 32), make([]byte, 32)
+            k.X.FillBytes(x)
+            k.Y.FillBytes(y)
+            keys = append(keys, map[string]string{"kty": "EC", "kid": kid, "crv": "P-256", "x": b64(x), "y": b64(y)})
+        }
+    }
+    data, _ := json.Marshal(map[string]interface{}{"keys": keys})
+    if err := os.WriteFile(path, data, 0o644); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestJWTVerifier(t *testing.T) {
+    rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+    if err != nil {
+        t.Fatal(err)
+    }
+    ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    rotated, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+    path := filepath.Join(t.TempDir(), "jwks.json")
+    writeJWKS(t, path, map[string]interface{}{"rsa-1": rsaKey, "ec-1": ecKey})
+    v, err := NewJWTVerifier(path, "https://idp.example", "guardianai", "realm_access.roles", "")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    sign := func(method jwt.SigningMethod, kid string, key interface{}, edit func(jwt.MapClaims)) string {
+        claims := jwt.MapClaims{
+            "sub":                "u-42",
+            "preferred_username": "alice",
+            "iss":                "https://idp.example",
+            "aud":                []string{"guardianai", "other"},
+            "exp":                time.Now().Add(time.Hour).Unix(),
+            "realm_access":       map[string]interface{}{"roles": []string{"Analyst", "offline_access"}},
+            "tenant":             "acme",
+        }
+        if edit != nil {
+            edit(claims)
+        }
+        tok := jwt.NewWithClaims(method, claims)
+        tok.Header["kid"] = kid
+        s, err := tok.SignedString(key)
+        if err != nil {
+            t.Fatal(err)
+        }
+        return s
+    }
+
+    for _, token := range []string{
+        sign(jwt.SigningMethodRS256, "rsa-1", rsaKey, nil),
+        sign(jwt.SigningMethodES256, "ec-1", ecKey, nil),
+    } {
+        p, err := v.Verify(token)
+        if err != nil {
+            t.Fatalf("Verify: %v", err)
+        }
+        if p.Subject != "u-42" || p.Name != "alice" || p.Method != MethodJWT {
+            t.Errorf("unexpected principal %+v", p)
+        }
+        if !p.HasRole(RoleAnalyst) || p.HasRole(RoleAdmin) || len(p.Roles) != 1 {
+            t.Errorf("expected only the analyst role, got %v", p.Roles)
+        }
+        if p.Tenant != "acme" {
+            t.Errorf("expected tenant acme, got %q", p.Tenant)
+        }
+    }
+
+    // Tokens without a tenant claim belong to the default tenant
+    p, err := v.Verify(sign(jwt.SigningMethodES256, "ec-1", ecKey, func(c jwt.MapClaims) { delete(c, "tenant") }))
+    if err != nil || p.Tenant != models.DefaultTenant {
+        t.Errorf("token without tenant: got %+v, %v, want the default tenant", p, err)
+    }
+
+    rejected := map[string]string{
+        "expired":      sign(jwt.SigningMethodES256, "ec-1", ecKey, func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }),
+        "no expiry":    sign(jwt.SigningMethodES256, "ec-1", ecKey, func(c jwt.MapClaims) { delete(c, "exp") }),
+        "wrong issuer": sign(jwt.SigningMethodES256, "ec-1", ecKey, func(c jwt.MapClaims) { c["iss"] = "https://evil.example" }),
+        "wrong aud":    sign(jwt.SigningMethodES256, "ec-1", ecKey, func(c jwt.MapClaims) { c["aud"] = "other" }),
+        "no subject":   sign(jwt.SigningMethodES256, "ec-1", ecKey, func(c jwt.MapClaims) { delete(c, "sub") }),
+        "wrong key":    sign(jwt.SigningMethodES256, "ec-1", rotated, nil),
+        "hmac":         sign(jwt.SigningMethodHS256, "ec-1", []byte("secret"), nil),
+        "unknown kid":  sign(jwt.SigningMethodES256, "ec-2", rotated, nil),
+        "bad tenant":   sign(jwt.SigningMethodES256, "ec-1", ecKey, func(c jwt.MapClaims) { c["tenant"] = "../Other" }),
+    }
+    for name, token := range rejected {
+        if _, err := v.Verify(token); err == nil {
+            t.Errorf("%s: expected the token to be rejected", name)
+        }
+    }
+
+    // A rotated key is picked up once the file changes
+    writeJWKS(t, path, map[string]interface{}{"ec-2": rotated})
+    later := time.Now().Add(time.Minute)
+    if err := os.Chtimes(path, later, later); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := v.Verify(rejected["unknown kid"]); err != nil {
+        t.Errorf("expected the rotated key to be loaded: %v", err)
+    }
+}
@@ -0,0 +1,157 @@
+package auth
+
+import (
+    "crypto"
+    "crypto/ecdsa"
+    "crypto/ed25519"
+    "crypto/elliptic"
+    "crypto/rsa"
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "math/big"
+    "os"
+)
+
+// KeySet holds the signature verification keys of a JWKS document by key ID.
+type KeySet struct {
+    keys map[string]crypto.PublicKey
+}
+
+// jwk is the subset of RFC 7517 needed for RSA, EC and Ed25519 public keys.
+type jwk struct {
+    Kty string `json:"kty"`
+    Kid string `json:"kid"`
+    Use string `json:"use"`
+    Crv string `json:"crv"`
+    N   string `json:"n"`
+    E   string `json:"e"`
+    X   string `json:"x"`
+    Y   string `json:"y"`
+}
+
+// ParseJWKS reads a JWKS document. Keys that are not meant for signatures or of an
+// unsupported type are skipped; at least one usable key is required.
+func ParseJWKS(data []byte) (*KeySet, error) {
+    var doc struct {
+        Keys []jwk `json:"keys"`
+    }
+    if err := json.Unmarshal(data, &doc); err != nil {
+        return nil, fmt.Errorf("invalid JWKS: %w", err)
+    }
+
+    set := &KeySet{keys: make(map[string]crypto.PublicKey)}
+    for i, k := range doc.Keys {
+        if k.Use != "" && k.Use != "sig" {
+            continue
+        }
+        key, err := k.publicKey()
+        if err != nil {
+            return nil, fmt.Errorf("invalid JWKS key %d (kid %q): %w", i, k.Kid, err)
+        }
+        if key == nil {
+            continue // Unsupported key type, e.g. a symmetric key
+        }
+        if _, dup := set.keys[k.Kid]; dup {
+            return nil, fmt.Errorf("invalid JWKS: duplicate kid %q", k.Kid)
+        }
+        set.keys[k.Kid] = key
+    }
+    if len(set.keys) == 0 {
+        return nil, fmt.Errorf("JWKS contains no usable signature keys")
+    }
+    return set, nil
+}
+
+// LoadJWKS reads a JWKS document from a file.
+func LoadJWKS(path string) (*KeySet, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read JWKS: %w", err)
+    }
+    return ParseJWKS(data)
+}
+
+// Key returns the key with the given ID. Tokens without a key ID are accepted if the
+// set holds a single key.
+func (s *KeySet) Key(kid string) (crypto.PublicKey, bool) {
+    if key, ok := s.keys[kid]; ok {
+        return key, true
+    }
+    if kid == "" && len(s.keys) == 1 {
+        for _, key := range s.keys {
+            return key, true
+        }
+    }
+    return nil, false
+}
+
+// Len returns the number of keys in the set.
+func (s *KeySet) Len() int {
+    return len(s.keys)
+}
+
+// publicKey decodes the key, or returns nil, nil for unsupported key types.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+    switch k.Kty {
+    case "RSA":
+        n, err := decodeBigInt(k.N)
+        if err != nil {
+            return nil, fmt.Errorf("n: %w", err)
+        }
+        e, err := decodeBigInt(k.E)
+        if err != nil {
+            return nil, fmt.Errorf("e: %w", err)
+        }
+        if n.BitLen() < 2048 {
+            return nil, fmt.Errorf("RSA keys must have at least 2048 bits")
+        }
+        if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+            return nil, fmt.Errorf("unsupported RSA exponent")
+        }
+        return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+    case "EC":
+        var curve elliptic.Curve
+        switch k.Crv {
+        case "P-256":
+            curve = elliptic.P256()
+        case "P-384":
+            curve = elliptic.P384()
+        case "P-521":
+            curve = elliptic.P521()
+        default:
+            return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+        }
+        x, err := decodeBigInt(k.X)
+        if err != nil {
+            return nil, fmt.Errorf("x: %w", err)
+        }
+        y, err := decodeBigInt(k.Y)
+        if err != nil {
+            return nil, fmt.Errorf("y: %w", err)
+        }
+        key := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+        if _, err := key.ECDH(); err != nil { // Rejects points that are not on the curve
+            return nil, fmt.Errorf("invalid EC point: %w", err)
+        }
+        return key, nil
+    case "OKP":
+        if k.Crv != "Ed25519" {
+            return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+        }
+        x, err := base64.RawURLEncoding.DecodeString(k.X)
+        if err != nil || len(x) != ed25519.PublicKeySize {
+            return nil, fmt.Errorf("invalid Ed25519 key")
+        }
+        return ed25519.PublicKey(x), nil
+    }
+    return nil, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+    b, err := base64.RawURLEncoding.DecodeString(s)
+    if err != nil || len(b) == 0 {
+        return nil, fmt.Errorf("invalid base64url value")
+    }
+    return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,182 @@
+package auth
+
+import (
+    "fmt"
+    "log"
+    "os"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/golang-jwt/jwt/v5"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// jwtLeeway absorbs clock skew between the identity provider and this server.
+const jwtLeeway = time.Minute
+
+// jwtMethods are the accepted signature algorithms. Symmetric (HS*) algorithms are not
+// accepted: the JWKS only holds public keys.
+var jwtMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// JWTVerifier checks JWTs issued to analysts against the keys of a local JWKS file.
+// When a token names a key ID that is not known, the file is read again if it has
+// changed, so rotated keys are picked up without a restart.
+type JWTVerifier struct {
+    path        string
+    issuer      string // Required "iss", empty to accept any
+    audience    string // Required in "aud", empty to accept any
+    rolesClaim  string // Claim holding the roles, "." separates nested objects
+    tenantClaim string // Claim holding the tenant, "." separates nested objects
+
+    mu      sync.Mutex
+    keys    *KeySet
+    modTime time.Time
+}
+
+// NewJWTVerifier loads the JWKS file at path. rolesClaim defaults to "roles" and
+// tenantClaim to "tenant"; a nested claim such as Keycloak's realm_access.roles is
+// written with dots. Tokens without a tenant claim belong to the default tenant.
+func NewJWTVerifier(path, issuer, audience, rolesClaim, tenantClaim string) (*JWTVerifier, error) {
+    if rolesClaim == "" {
+        rolesClaim = "roles"
+    }
+    if tenantClaim == "" {
+        tenantClaim = "tenant"
+    }
+    v := &JWTVerifier{path: path, issuer: issuer, audience: audience, rolesClaim: rolesClaim, tenantClaim: tenantClaim}
+    if _, err := v.reload(); err != nil {
+        return nil, err
+    }
+    return v, nil
+}
+
+// reload reads the JWKS file if it changed since it was last loaded. It reports
+// whether a new key set was loaded.
+func (v *JWTVerifier) reload() (bool, error) {
+    info, err := os.Stat(v.path)
+    if err != nil {
+        return false, fmt.Errorf("failed to read JWKS: %w", err)
+    }
+    v.mu.Lock()
+    defer v.mu.Unlock()
+    if v.keys != nil && info.ModTime().Equal(v.modTime) {
+        return false, nil
+    }
+    keys, err := LoadJWKS(v.path)
+    if err != nil {
+        return false, err
+    }
+    v.keys, v.modTime = keys, info.ModTime()
+    log.Printf("Loaded %d JWT verification keys from %s", keys.Len(), v.path)
+    return true, nil
+}
+
+// key returns the verification key for kid, reloading the JWKS file once if needed.
+func (v *JWTVerifier) key(kid string) (interface{}, error) {
+    v.mu.Lock()
+    key, ok := v.keys.Key(kid)
+    v.mu.Unlock()
+    if ok {
+        return key, nil
+    }
+    if reloaded, err := v.reload(); err != nil {
+        log.Printf("ERROR: Failed to reload JWKS: %v", err)
+    } else if reloaded {
+        v.mu.Lock()
+        key, ok = v.keys.Key(kid)
+        v.mu.Unlock()
+        if ok {
+            return key, nil
+        }
+    }
+    return nil, fmt.Errorf("unknown key ID %q", kid)
+}
+
+// Verify checks the token's signature and its exp, nbf, iss and aud claims, and
+// returns the principal it identifies. Tokens without an expiry or with a malformed
+// tenant are rejected.
+func (v *JWTVerifier) Verify(token string) (*Principal, error) {
+    opts := []jwt.ParserOption{
+        jwt.WithValidMethods(jwtMethods),
+        jwt.WithExpirationRequired(),
+        jwt.WithLeeway(jwtLeeway),
+    }
+    if v.issuer != "" {
+        opts = append(opts, jwt.WithIssuer(v.issuer))
+    }
+    if v.audience != "" {
+        opts = append(opts, jwt.WithAudience(v.audience))
+    }
+
+    claims := jwt.MapClaims{}
+    _, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+        kid, _ := t.Header["kid"].(string)
+        return v.key(kid)
+    }, opts...)
+    if err != nil {
+        return nil, fmt.Errorf("invalid token: %w", err)
+    }
+
+    p := &Principal{Method: MethodJWT}
+    p.Subject, _ = claims["sub"].(string)
+    if p.Subject == "" {
+        return nil, fmt.Errorf("invalid token: no subject")
+    }
+    p.Name, _ = claims["preferred_username"].(string)
+    if p.Name == "" {
+        p.Name, _ = claims["email"].(string)
+    }
+    p.Roles = claimRoles(claims, v.rolesClaim)
+
+    p.Tenant = models.DefaultTenant
+    switch tenant := claimValue(claims, v.tenantClaim).(type) {
+    case nil:
+    case string:
+        if !models.ValidTenantID(tenant) {
+            return nil, fmt.Errorf("invalid token: invalid tenant %q", tenant)
+        }
+        p.Tenant = tenant
+    default:
+        return nil, fmt.Errorf("invalid token: tenant claim is not a string")
+    }
+    return p, nil
+}
+
+// claimValue looks up a claim by its path, "." separating nested objects. It returns
+// nil if the claim is missing.
+func claimValue(claims jwt.MapClaims, path string) interface{} {
+    var value interface{} = map[string]interface{}(claims)
+    for _, part := range strings.Split(path, ".") {
+        obj, ok := value.(map[string]interface{})
+        if !ok {
+            return nil
+        }
+        value = obj[part]
+    }
+    return value
+}
+
+// claimRoles reads the known roles from a claim holding a list of strings or a single
+// space or comma separated string. Unknown roles are ignored.
+func claimRoles(claims jwt.MapClaims, path string) []Role {
+    var names []string
+    switch v := claimValue(claims, path).(type) {
+    case string:
+        names = strings.FieldsFunc(v, func(r rune) bool { return r == ' ' || r == ',' })
+    case []interface{}:
+        for _, item := range v {
+            if s, ok := item.(string); ok {
+                names = append(names, s)
+            }
+        }
+    }
+    var roles []Role
+    for _, name := range names {
+        if r := Role(strings.ToLower(name)); r.IsValid() {
+            roles = append(roles, r)
+        }
+    }
+    return roles
+}
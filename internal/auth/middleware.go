@@ -0,0 +1,167 @@
+package auth
+
+import (
+    "context"
+    "crypto/subtle"
+    "errors"
+    "fmt"
+    "log"
+    "net/http"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+    "github.com/Kelvinkhyd/GuardianAI/internal/repository"
+)
+
+// touchInterval limits how often the last use of an API key is written back.
+const touchInterval = time.Minute
+
+// Errors returned by Authenticate besides invalid credentials.
+var (
+    errNoCredentials = errors.New("no credentials")
+    errLookupFailed  = errors.New("failed to look up API key") // The credentials could not be checked
+)
+
+// Authenticator checks the credentials of API requests: API keys stored in the
+// repository, an optional bootstrap admin key from the configuration, and JWTs if a
+// verifier is configured. A nil *Authenticator disables authentication, every route
+// is then open.
+type Authenticator struct {
+    keys         repository.APIKeyRepository
+    adminKeyHash []byte       // Empty if no bootstrap key is configured
+    jwt          *JWTVerifier // nil disables JWT authentication
+
+    mu      sync.Mutex
+    touched map[string]time.Time // API key ID -> last use written back
+}
+
+// NewAuthenticator creates an Authenticator. adminKey is a static key with the admin
+// role, meant to create the first API keys; leave it empty once those exist. jwt may
+// be nil if analysts do not sign in with JWTs.
+func NewAuthenticator(keys repository.APIKeyRepository, adminKey string, jwt *JWTVerifier) *Authenticator {
+    a := &Authenticator{keys: keys, jwt: jwt, touched: make(map[string]time.Time)}
+    if adminKey != "" {
+        a.adminKeyHash = HashAPIKey(adminKey)
+    }
+    return a
+}
+
+// Authenticate identifies the caller from an "Authorization: Bearer" or "X-API-Key"
+// header. "Authorization: Splunk" is accepted like Bearer, so Splunk HEC agents can
+// send an API key as their HEC token. If allowQuery is set, the access_token query
+// parameter is accepted as well.
+func (a *Authenticator) Authenticate(r *http.Request, allowQuery bool) (*Principal, error) {
+    token := ""
+    if h := r.Header.Get("Authorization"); h != "" {
+        scheme, value, _ := strings.Cut(h, " ")
+        if !strings.EqualFold(scheme, "Bearer") && !strings.EqualFold(scheme, "Splunk") {
+            return nil, fmt.Errorf("unsupported authorization scheme %q", scheme)
+        }
+        token = strings.TrimSpace(value)
+    } else if h := r.Header.Get("X-API-Key"); h != "" {
+        token = h
+    } else if allowQuery {
+        token = r.URL.Query().Get("access_token")
+    }
+    if token == "" {
+        return nil, errNoCredentials
+    }
+
+    if len(a.adminKeyHash) > 0 && subtle.ConstantTimeCompare(HashAPIKey(token), a.adminKeyHash) == 1 {
+        return &Principal{Subject: "bootstrap", Name: "bootstrap admin key", Method: MethodAPIKey, Tenant: models.DefaultTenant, Roles: []Role{RoleAdmin}}, nil
+    }
+    if IsAPIKey(token) {
+        return a.authenticateAPIKey(r.Context(), token)
+    }
+    if a.jwt == nil {
+        return nil, fmt.Errorf("invalid API key")
+    }
+    return a.jwt.Verify(token)
+}
+
+// authenticateAPIKey looks the key up by its ID and compares the hashes.
+func (a *Authenticator) authenticateAPIKey(ctx context.Context, token string) (*Principal, error) {
+    id, ok := apiKeyID(token)
+    if !ok {
+        return nil, fmt.Errorf("invalid API key")
+    }
+    key, err := a.keys.GetAPIKeyByID(ctx, id)
+    if err != nil {
+        return nil, fmt.Errorf("%w: %v", errLookupFailed, err)
+    }
+    if key == nil || subtle.ConstantTimeCompare(HashAPIKey(token), key.Hash) != 1 {
+        return nil, fmt.Errorf("invalid API key")
+    }
+    now := time.Now()
+    if !key.IsActive(now) {
+        return nil, fmt.Errorf("API key %s is revoked or expired", key.ID)
+    }
+    a.touch(ctx, key.ID, now)
+
+    p := &Principal{Subject: key.ID, Name: key.Name, Method: MethodAPIKey, Tenant: key.TenantID}
+    if p.Tenant == "" {
+        p.Tenant = models.DefaultTenant
+    }
+    for _, r := range key.Roles {
+        p.Roles = append(p.Roles, Role(r))
+    }
+    return p, nil
+}
+
+// touch records the use of a key, at most once per touchInterval per key.
+func (a *Authenticator) touch(ctx context.Context, id string, now time.Time) {
+    a.mu.Lock()
+    if now.Sub(a.touched[id]) < touchInterval {
+        a.mu.Unlock()
+        return
+    }
+    a.touched[id] = now
+    a.mu.Unlock()
+
+    if err := a.keys.TouchAPIKey(ctx, id, now); err != nil {
+        log.Printf("ERROR: Failed to record use of API key %s: %v", id, err)
+    }
+}
+
+// Require wraps a handler so it only runs for callers holding role. Requests without
+// valid credentials are answered with 401 Unauthorized, callers lacking the role with
+// 403 Forbidden. The principal is available to the handler through PrincipalFrom.
+func (a *Authenticator) Require(role Role, next http.HandlerFunc) http.HandlerFunc {
+    return a.require(role, false, next)
+}
+
+// RequireStream is Require for streaming routes. Browsers cannot set headers on an
+// EventSource or WebSocket, so the credentials may also be passed as ?access_token=.
+func (a *Authenticator) RequireStream(role Role, next http.HandlerFunc) http.HandlerFunc {
+    return a.require(role, true, next)
+}
+
+func (a *Authenticator) require(role Role, allowQuery bool, next http.HandlerFunc) http.HandlerFunc {
+    if a == nil {
+        return next // Authentication disabled
+    }
+    return func(w http.ResponseWriter, r *http.Request) {
+        p, err := a.Authenticate(r, allowQuery)
+        if errors.Is(err, errLookupFailed) {
+            log.Printf("ERROR: %v", err)
+            http.Error(w, "Failed to check credentials", http.StatusInternalServerError)
+            return
+        }
+        if err != nil {
+            if !errors.Is(err, errNoCredentials) {
+                log.Printf("Rejected credentials for %s %s from %s: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+            }
+            w.Header().Set("WWW-Authenticate", `Bearer realm="guardianai"`)
+            http.Error(w, "Authentication required", http.StatusUnauthorized)
+            return
+        }
+        if !p.HasRole(role) {
+            log.Printf("Denied %s %s to %s: requires role %s", r.Method, r.URL.Path, p, role)
+            http.Error(w, fmt.Sprintf("Forbidden: requires role %q", role), http.StatusForbidden)
+            return
+        }
+        next(w, r.WithContext(WithPrincipal(r.Context(), p)))
+    }
+}
@@ -0,0 +1,289 @@
+package breaker
+
+import (
+    "context"
+    "errors"
+    "log"
+    "sync"
+    "time"
+)
+
+// ErrOpen is returned by Allow while the breaker rejects calls.
+var ErrOpen = errors.New("circuit breaker is open")
+
+// State is the state of a circuit breaker.
+type State int
+
+// Circuit breaker states.
+const (
+    // Closed lets every call through and counts consecutive failures.
+    Closed State = iota
+    // Open rejects every call until OpenTimeout has passed.
+    Open
+    // HalfOpen lets a limited number of trial calls through to probe for recovery.
+    HalfOpen
+)
+
+// String returns the lower-case name of the state.
+func (s State) String() string {
+    switch s {
+    case Closed:
+        return "closed"
+    case Open:
+        return "open"
+    case HalfOpen:
+        return "half-open"
+    }
+    return "unknown"
+}
+
+// MarshalText lets states appear by name in JSON.
+func (s State) MarshalText() ([]byte, error) {
+    return []byte(s.String()), nil
+}
+
+// Outcome is the result of a call let through by Allow, as reported to done.
+type Outcome int
+
+// Call outcomes.
+const (
+    // Success counts towards closing a half-open breaker and resets the failures.
+    Success Outcome = iota
+    // Failure counts towards opening the breaker.
+    Failure
+    // Ignored says nothing about the dependency, e.g. because the caller gave up.
+    // It only frees the trial call slot taken while half-open.
+    Ignored
+)
+
+// Settings configures a Breaker.
+type Settings struct {
+    Name string // Used in log messages
+    // FailureThreshold is the number of consecutive failures that opens the breaker.
+    FailureThreshold int
+    // OpenTimeout is how long the breaker stays open before probing again.
+    OpenTimeout time.Duration
+    // HalfOpenMaxCalls is the number of trial calls allowed while half-open. If all of
+    // them succeed the breaker closes; any failure opens it again.
+    HalfOpenMaxCalls int
+    // OnStateChange, if set, is called after every state change (outside the lock).
+    OnStateChange func(name string, from, to State)
+}
+
+// Snapshot describes the breaker at one point in time.
+type Snapshot struct {
+    Name                string    `json:"name"`
+    State               State     `json:"state"`
+    ConsecutiveFailures int       `json:"consecutive_failures"`
+    OpenedAt            time.Time `json:"opened_at,omitempty"`
+    LastStateChange     time.Time `json:"last_state_change"`
+}
+
+// Breaker is a circuit breaker with closed, open and half-open states.
+// It is safe for concurrent use.
+type Breaker struct {
+    settings Settings
+
+    mu                sync.Mutex
+    state             State
+    failures          int // Consecutive failures while closed
+    openedAt          time.Time
+    lastChange        time.Time
+    halfOpenCalls     int // Trial calls started while half-open
+    halfOpenSuccesses int
+    generation        uint64        // Incremented on every state change, see Allow
+    changed           chan struct{} // Closed and replaced on every state change, see Wait
+}
+
+// New creates a closed breaker.
+func New(settings Settings) *Breaker {
+    if settings.FailureThreshold < 1 {
+        settings.FailureThreshold = 1
+    }
+    if settings.HalfOpenMaxCalls < 1 {
+        settings.HalfOpenMaxCalls = 1
+    }
+    return &Breaker{
+        settings:   settings,
+        state:      Closed,
+        lastChange: time.Now(),
+        changed:    make(chan struct{}),
+    }
+}
+
+// Allow asks whether a call may proceed. If it may, the caller must report the
+// outcome by calling done exactly once. While the breaker is open, or half-open
+// with all trial calls taken, Allow returns ErrOpen.
+// Outcomes reported after the breaker changed state since Allow are ignored: a slow
+// call let through while closed says nothing about the half-open trial.
+func (b *Breaker) Allow() (done func(Outcome), err error) {
+    b.mu.Lock()
+    b.refreshLocked()
+    switch b.state {
+    case Open:
+        b.mu.Unlock()
+        return nil, ErrOpen
+    case HalfOpen:
+        if b.halfOpenCalls >= b.settings.HalfOpenMaxCalls {
+            b.mu.Unlock()
+            return nil, ErrOpen
+        }
+        b.halfOpenCalls++
+    }
+    generation := b.generation
+    b.mu.Unlock()
+
+    var once sync.Once
+    return func(outcome Outcome) {
+        once.Do(func() { b.record(generation, outcome) })
+    }, nil
+}
+
+// record updates the breaker with the outcome of a call let through by Allow in the
+// given generation.
+func (b *Breaker) record(generation uint64, outcome Outcome) {
+    b.mu.Lock()
+    if generation != b.generation {
+        b.mu.Unlock()
+        return
+    }
+    var from, to State
+    changed := false
+    switch b.state {
+    case Closed:
+        switch outcome {
+        case Success:
+            b.failures = 0
+        case Failure:
+            b.failures++
+            if b.failures >= b.settings.FailureThreshold {
+                from, to, changed = b.state, Open, true
+                b.setStateLocked(Open)
+            }
+        }
+    case HalfOpen:
+        switch outcome {
+        case Ignored:
+            // Free the slot for another trial call and wake up a waiter to make it
+            b.halfOpenCalls--
+            b.wakeLocked()
+        case Failure:
+            from, to, changed = b.state, Open, true
+            b.setStateLocked(Open)
+        case Success:
+            b.halfOpenSuccesses++
+            if b.halfOpenSuccesses >= b.settings.HalfOpenMaxCalls {
+                from, to, changed = b.state, Closed, true
+                b.setStateLocked(Closed)
+            }
+        }
+    }
+    b.mu.Unlock()
+
+    if changed {
+        b.notify(from, to)
+    }
+}
+
+// refreshLocked moves an open breaker to half-open once its timeout has passed.
+func (b *Breaker) refreshLocked() {
+    if b.state == Open && time.Since(b.openedAt) >= b.settings.OpenTimeout {
+        from := b.state
+        b.setStateLocked(HalfOpen)
+        // Notify asynchronously: we hold the lock and the callback may call back into us.
+        go b.notify(from, HalfOpen)
+    }
+}
+
+// setStateLocked switches state, resets the counters and wakes up waiters.
+func (b *Breaker) setStateLocked(s State) {
+    b.state = s
+    b.lastChange = time.Now()
+    b.failures = 0
+    b.halfOpenCalls = 0
+    b.halfOpenSuccesses = 0
+    if s == Open {
+        b.openedAt = b.lastChange
+    }
+    b.generation++
+    b.wakeLocked()
+}
+
+// wakeLocked wakes up every Wait call, which then checks the state again.
+func (b *Breaker) wakeLocked() {
+    close(b.changed)
+    b.changed = make(chan struct{})
+}
+
+func (b *Breaker) notify(from, to State) {
+    log.Printf("Circuit breaker %s: %s -> %s", b.settings.Name, from, to)
+    if b.settings.OnStateChange != nil {
+        b.settings.OnStateChange(b.settings.Name, from, to)
+    }
+}
+
+// State returns the current state.
+func (b *Breaker) State() State {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.refreshLocked()
+    return b.state
+}
+
+// Snapshot returns the current state and counters.
+func (b *Breaker) Snapshot() Snapshot {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.refreshLocked()
+    snap := Snapshot{
+        Name:                b.settings.Name,
+        State:               b.state,
+        ConsecutiveFailures: b.failures,
+        LastStateChange:     b.lastChange,
+    }
+    if b.state != Closed {
+        snap.OpenedAt = b.openedAt
+    }
+    return snap
+}
+
+// Wait blocks while the breaker would reject calls, i.e. while it is open or
+// half-open with every trial call taken. It returns early with ctx's error if ctx
+// is cancelled. Consumers use it to pause instead of spinning on a dead dependency.
+func (b *Breaker) Wait(ctx context.Context) error {
+    for {
+        b.mu.Lock()
+        b.refreshLocked()
+        blocked := b.state == Open || (b.state == HalfOpen && b.halfOpenCalls >= b.settings.HalfOpenMaxCalls)
+        changed := b.changed
+        var untilHalfOpen time.Duration
+        if b.state == Open {
+            untilHalfOpen = b.settings.OpenTimeout - time.Since(b.openedAt)
+        }
+        b.mu.Unlock()
+
+        if !blocked {
+            return nil
+        }
+
+        var timer *time.Timer
+        var timeout <-chan time.Time
+        if untilHalfOpen > 0 {
+            timer = time.NewTimer(untilHalfOpen)
+            timeout = timer.C
+        }
+        select {
+        case <-ctx.Done():
+            err := ctx.Err()
+            if timer != nil {
+                timer.Stop()
+            }
+            return err
+        case <-changed:
+        case <-timeout:
+        }
+        if timer != nil {
+            timer.Stop()
+        }
+    }
+}
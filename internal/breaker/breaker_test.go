@@ -0,0 +1,190 @@
+package breaker
+
+import (
+    "context"
+    "errors"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+func newTestBreaker(threshold, halfOpenMaxCalls int) *Breaker {
+    return New(Settings{Name: "test", FailureThreshold: threshold, OpenTimeout: 20 * time.Millisecond, HalfOpenMaxCalls: halfOpenMaxCalls})
+}
+
+// call lets one call through b and reports outcome.
+func call(t *testing.T, b *Breaker, outcome Outcome) {
+    t.Helper()
+    done, err := b.Allow()
+    if err != nil {
+        t.Fatalf("expected the call to be allowed in state %s, got %v", b.State(), err)
+    }
+    done(outcome)
+}
+
+// halfOpen trips b and waits until it probes again.
+func halfOpen(t *testing.T, b *Breaker) {
+    t.Helper()
+    for b.State() == Closed {
+        call(t, b, Failure)
+    }
+    time.Sleep(30 * time.Millisecond)
+    if b.State() != HalfOpen {
+        t.Fatalf("expected half-open after the open timeout, got %s", b.State())
+    }
+}
+
+func TestThreshold(t *testing.T) {
+    b := newTestBreaker(3, 1)
+    call(t, b, Failure)
+    call(t, b, Failure)
+    call(t, b, Success) // Resets the consecutive failures
+    call(t, b, Failure)
+    call(t, b, Failure)
+    call(t, b, Ignored)
+    if b.State() != Closed || b.Snapshot().ConsecutiveFailures != 2 {
+        t.Fatalf("expected closed with 2 failures, got %+v", b.Snapshot())
+    }
+    call(t, b, Failure)
+    if b.State() != Open {
+        t.Fatalf("expected open after 3 consecutive failures, got %s", b.State())
+    }
+    if _, err := b.Allow(); !errors.Is(err, ErrOpen) {
+        t.Errorf("expected ErrOpen, got %v", err)
+    }
+}
+
+func TestOpenTimeout(t *testing.T) {
+    var changes atomic.Int32 // The half-open change is notified asynchronously
+    b := New(Settings{FailureThreshold: 1, OpenTimeout: 20 * time.Millisecond,
+        OnStateChange: func(name string, from, to State) { changes.Add(1) }})
+    call(t, b, Failure)
+    if b.State() != Open || b.Snapshot().OpenedAt.IsZero() {
+        t.Fatalf("expected open, got %+v", b.Snapshot())
+    }
+    halfOpen(t, b)
+    call(t, b, Success)
+    if b.State() != Closed {
+        t.Fatalf("expected closed after a successful trial call, got %s", b.State())
+    }
+    time.Sleep(10 * time.Millisecond)
+    if n := changes.Load(); n != 3 {
+        t.Errorf("expected 3 state changes, got %d", n)
+    }
+}
+
+func TestHalfOpenMaxCalls(t *testing.T) {
+    b := newTestBreaker(1, 2)
+    halfOpen(t, b)
+    first, err := b.Allow()
+    if err != nil {
+        t.Fatal(err)
+    }
+    second, err := b.Allow()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := b.Allow(); !errors.Is(err, ErrOpen) {
+        t.Fatalf("expected ErrOpen with every trial call taken, got %v", err)
+    }
+
+    // An ignored call frees its slot without counting as a success
+    second(Ignored)
+    third, err := b.Allow()
+    if err != nil {
+        t.Fatalf("expected the freed slot to be available, got %v", err)
+    }
+    first(Success)
+    if b.State() != HalfOpen {
+        t.Fatalf("expected half-open after 1 of 2 successes, got %s", b.State())
+    }
+    third(Success)
+    if b.State() != Closed {
+        t.Fatalf("expected closed after 2 successes, got %s", b.State())
+    }
+
+    halfOpen(t, b)
+    call(t, b, Failure)
+    if b.State() != Open {
+        t.Errorf("expected a failed trial call to open the breaker, got %s", b.State())
+    }
+}
+
+func TestStaleOutcomes(t *testing.T) {
+    b := newTestBreaker(1, 1)
+    slow, err := b.Allow() // Let through while closed
+    if err != nil {
+        t.Fatal(err)
+    }
+    halfOpen(t, b)
+    slow(Success)
+    if b.State() != HalfOpen {
+        t.Fatalf("expected a call from before the trip to be ignored, got %s", b.State())
+    }
+    if _, err := b.Allow(); err != nil {
+        t.Fatalf("expected the trial call slot to be free, got %v", err)
+    }
+
+    // Results of calls from an earlier half-open period don't count either
+    b = newTestBreaker(1, 1)
+    halfOpen(t, b)
+    trial, _ := b.Allow()
+    b.mu.Lock()
+    b.setStateLocked(Open) // As if the trial had timed out and another failed
+    b.mu.Unlock()
+    time.Sleep(30 * time.Millisecond)
+    trial(Success)
+    if b.State() != HalfOpen {
+        t.Errorf("expected a stale trial call to be ignored, got %s", b.State())
+    }
+
+    // done reports once
+    b = newTestBreaker(2, 1)
+    done, _ := b.Allow()
+    done(Failure)
+    done(Failure)
+    if b.State() != Closed {
+        t.Errorf("expected a second report to be ignored, got %s", b.State())
+    }
+}
+
+func TestWait(t *testing.T) {
+    b := newTestBreaker(1, 1)
+    if err := b.Wait(context.Background()); err != nil {
+        t.Fatalf("expected a closed breaker not to block, got %v", err)
+    }
+
+    call(t, b, Failure)
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+    defer cancel()
+    if err := b.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+        t.Fatalf("expected the deadline while open, got %v", err)
+    }
+
+    start := time.Now()
+    if err := b.Wait(context.Background()); err != nil || b.State() != HalfOpen {
+        t.Fatalf("expected Wait to return once half-open, got %v in state %s", err, b.State())
+    }
+    if time.Since(start) > time.Second {
+        t.Fatal("Wait returned too late")
+    }
+
+    // Blocks while the trial call is out, and returns once it is given back
+    done, _ := b.Allow()
+    waited := make(chan error)
+    go func() { waited <- b.Wait(context.Background()) }()
+    select {
+    case err := <-waited:
+        t.Fatalf("expected Wait to block while the trial call is out, got %v", err)
+    case <-time.After(10 * time.Millisecond):
+    }
+    done(Ignored)
+    select {
+    case err := <-waited:
+        if err != nil {
+            t.Fatal(err)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("expected Wait to return once the trial call slot was freed")
+    }
+}
@@ -3,14 +3,121 @@ package config
 import (
     "log"
     "os"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/correlation"
+    "github.com/Kelvinkhyd/GuardianAI/internal/dedup"
 )
 
 // Config holds application-wide configuration settings.
 type Config struct {
     DatabaseURL  string
+    AutoMigrate  bool // Apply pending schema migrations at startup
     ServerPort   string
     KafkaBrokers []string // Add Kafka brokers
     KafkaTopic   string   // Add Kafka topic name
+
+    // RepositoryBackend selects where the API server stores alerts: "postgres", or
+    // "memory" for demos and tests (nothing survives a restart)
+    RepositoryBackend string
+    // MessageBus selects how alerts reach the processor: "kafka", or "memory" to run
+    // the processor inside the API server on an in-process bus
+    MessageBus string
+
+    // Deduplication of incoming alerts (API server): repeats within DedupWindow are
+    // folded into the first alert and only republished at the DedupEscalateAt counts
+    DedupEnabled    bool
+    DedupFields     []string // Alert fields the fingerprint is computed from
+    DedupWindow     time.Duration
+    DedupEscalateAt []int
+
+    // Correlation of analysed alerts into incidents (processor): an alert joins an
+    // open incident that saw one of its CorrelationEntities within CorrelationWindow
+    CorrelationEnabled  bool
+    CorrelationWindow   time.Duration
+    CorrelationEntities []string
+
+    // Sigma rules evaluated by the processor. Disabled while SigmaRulesDir is empty.
+    SigmaRulesDir       string
+    SigmaFieldMapping   string        // Optional YAML file mapping Sigma field names to alert fields
+    SigmaReloadInterval time.Duration // How often the rules directory is checked for changes, 0 disables reloading
+    // Extra Sigma rules per tenant (tenant -> rules directory), evaluated in addition to
+    // the rules in SigmaRulesDir for that tenant's alerts only
+    TenantSigmaRulesDirs map[string]string
+
+    // Threat intel feeds (text, CSV, STIX 2.1) matched by the processor. Disabled while
+    // ThreatIntelDir is empty.
+    ThreatIntelDir            string
+    ThreatIntelReloadInterval time.Duration // How often the feeds are reloaded, 0 disables reloading
+    ThreatIntelRiskBoost      float64       // Added to the risk score of alerts matching an indicator
+
+    // Offline MaxMind-format (MMDB) databases used by the processor to locate source
+    // and target addresses. Either may be empty; both empty disables GeoIP enrichment.
+    GeoIPCityDB string // City or Country database
+    GeoIPASNDB  string // ASN database
+
+    // Authentication of API requests (API server): API keys stored in the database,
+    // AuthAdminKey as a bootstrap admin key, and JWTs verified against AuthJWKSFile.
+    AuthEnabled        bool
+    AuthAdminKey       string
+    AuthJWKSFile       string // Empty disables JWT authentication
+    AuthJWTIssuer      string // Required "iss" claim, empty to accept any
+    AuthJWTAudience    string // Required "aud" claim, empty to accept any
+    AuthJWTRolesClaim  string // Claim holding the roles, e.g. realm_access.roles
+    AuthJWTTenantClaim string // Claim holding the tenant; tokens without it belong to the default tenant
+
+    // Syslog listeners (API server) storing RFC 5424 and RFC 3164 messages as alerts of
+    // SyslogTenant. Each listener is disabled while its address is empty.
+    SyslogUDPAddr     string
+    SyslogTCPAddr     string
+    SyslogTLSAddr     string
+    SyslogTLSCert     string // Server certificate and key, required for the TLS listener
+    SyslogTLSKey      string
+    SyslogTLSClientCA string // Optional CA that client certificates must be signed by
+    SyslogMappingFile string // Optional YAML file adjusting how messages map to alert fields
+    SyslogTenant      string
+
+    // Splunk HTTP Event Collector endpoints (/services/collector/..., API server)
+    HECEnabled      bool
+    HECFieldMapping string // Optional YAML file mapping event fields to alert fields
+    HECAckEnabled   bool   // Hand out ackIds to requests on a data channel
+
+    // Alert stream (GET /alerts/stream, API server). New events are signalled by the
+    // repository; StreamPollInterval is the fallback if a notification is lost.
+    StreamPollInterval   time.Duration
+    StreamAllowedOrigins []string // Origins allowed to open WebSocket streams, "*" for any; empty means same origin only
+
+    // Transactional outbox relay (API server)
+    OutboxPollInterval time.Duration
+    OutboxBatchSize    int
+
+    // Processor retry policy: a failing message is retried KafkaMaxRetries times with
+    // exponential backoff, then moved to KafkaDLQTopic.
+    KafkaMaxRetries      int
+    KafkaRetryBackoff    time.Duration
+    KafkaRetryMaxBackoff time.Duration
+    KafkaDLQTopic        string
+
+    // Number of alerts the processor handles concurrently
+    ProcessorWorkers int
+
+    // Alert analysis: the AI service, with the in-process rules engine as fallback
+    AIServiceURL     string
+    AIServiceTimeout time.Duration
+    AnalyzerFallback bool // Use the local rules engine while the AI service is unavailable
+    // AI service endpoints of tenants with their own model (tenant -> URL); other
+    // tenants' alerts go to AIServiceURL
+    TenantAIServiceURLs map[string]string
+
+    // Circuit breaker around the AI service
+    AIBreakerFailureThreshold int           // Consecutive failures that open the breaker
+    AIBreakerOpenTimeout      time.Duration // Time the breaker stays open before probing
+    AIBreakerHalfOpenCalls    int           // Successful probes needed to close it again
+
+    // Address of the processor's health endpoint (exposes the breaker state)
+    ProcessorHealthAddr string
 }
 
 // LoadConfig reads configuration from environment variables.
@@ -41,10 +148,241 @@ func LoadConfig() *Config {
         log.Println("KAFKA_TOPIC environment variable not set, using default 'security_alerts'.")
     }
 
+    kafkaDLQTopic := os.Getenv("KAFKA_DLQ_TOPIC")
+    if kafkaDLQTopic == "" {
+        kafkaDLQTopic = kafkaTopic + ".dlq"
+    }
+
+    // Use Docker service name for inter-container communication: http://guardianai_ai_service:8000/analyze-alert
+    aiServiceURL := os.Getenv("AI_SERVICE_URL")
+    if aiServiceURL == "" {
+        aiServiceURL = "http://localhost:8000/analyze-alert"
+    }
+
+    processorHealthAddr := os.Getenv("PROCESSOR_HEALTH_ADDR")
+    if processorHealthAddr == "" {
+        processorHealthAddr = ":8081"
+    }
+
+    // Hot reloading of the Sigma rules can be switched off with SIGMA_RELOAD=false
+    sigmaReloadInterval := getEnvDuration("SIGMA_RELOAD_INTERVAL", 5*time.Second)
+    if !getEnvBool("SIGMA_RELOAD", true) {
+        sigmaReloadInterval = 0
+    }
+    threatIntelReloadInterval := getEnvDuration("THREAT_INTEL_RELOAD_INTERVAL", 10*time.Minute)
+    if !getEnvBool("THREAT_INTEL_RELOAD", true) {
+        threatIntelReloadInterval = 0
+    }
+
     return &Config{
         DatabaseURL:  dbURL,
+        AutoMigrate:  getEnvBool("DB_AUTO_MIGRATE", true),
         ServerPort:   serverPort,
         KafkaBrokers: kafkaBrokers,
         KafkaTopic:   kafkaTopic,
+
+        RepositoryBackend: getEnvChoice("REPOSITORY_BACKEND", "postgres", "memory"),
+        MessageBus:        getEnvChoice("MESSAGE_BUS", "kafka", "memory"),
+
+        DedupEnabled:    getEnvBool("DEDUP_ENABLED", true),
+        DedupFields:     getEnvList("DEDUP_FIELDS", dedup.DefaultFields),
+        DedupWindow:     getEnvDuration("DEDUP_WINDOW", 15*time.Minute),
+        DedupEscalateAt: getEnvIntList("DEDUP_ESCALATE_AT", []int{10, 100, 1000}),
+
+        CorrelationEnabled:  getEnvBool("CORRELATION_ENABLED", true),
+        CorrelationWindow:   getEnvDuration("CORRELATION_WINDOW", time.Hour),
+        CorrelationEntities: getEnvList("CORRELATION_ENTITIES", correlation.DefaultEntities),
+
+        SigmaRulesDir:        os.Getenv("SIGMA_RULES_DIR"),
+        SigmaFieldMapping:    os.Getenv("SIGMA_FIELD_MAPPING"),
+        SigmaReloadInterval:  sigmaReloadInterval,
+        TenantSigmaRulesDirs: getEnvMap("TENANT_SIGMA_RULES_DIRS"),
+
+        ThreatIntelDir:            os.Getenv("THREAT_INTEL_DIR"),
+        ThreatIntelReloadInterval: threatIntelReloadInterval,
+        ThreatIntelRiskBoost:      getEnvFloat("THREAT_INTEL_RISK_BOOST", 0.2, 0, 1),
+
+        GeoIPCityDB: os.Getenv("GEOIP_CITY_DB"),
+        GeoIPASNDB:  os.Getenv("GEOIP_ASN_DB"),
+
+        AuthEnabled:        getEnvBool("AUTH_ENABLED", true),
+        AuthAdminKey:       os.Getenv("AUTH_ADMIN_KEY"),
+        AuthJWKSFile:       os.Getenv("AUTH_JWKS_FILE"),
+        AuthJWTIssuer:      os.Getenv("AUTH_JWT_ISSUER"),
+        AuthJWTAudience:    os.Getenv("AUTH_JWT_AUDIENCE"),
+        AuthJWTRolesClaim:  getEnvString("AUTH_JWT_ROLES_CLAIM", "roles"),
+        AuthJWTTenantClaim: getEnvString("AUTH_JWT_TENANT_CLAIM", "tenant"),
+
+        SyslogUDPAddr:     os.Getenv("SYSLOG_UDP_ADDR"),
+        SyslogTCPAddr:     os.Getenv("SYSLOG_TCP_ADDR"),
+        SyslogTLSAddr:     os.Getenv("SYSLOG_TLS_ADDR"),
+        SyslogTLSCert:     os.Getenv("SYSLOG_TLS_CERT"),
+        SyslogTLSKey:      os.Getenv("SYSLOG_TLS_KEY"),
+        SyslogTLSClientCA: os.Getenv("SYSLOG_TLS_CLIENT_CA"),
+        SyslogMappingFile: os.Getenv("SYSLOG_MAPPING_FILE"),
+        SyslogTenant:      getEnvString("SYSLOG_TENANT", "default"),
+
+        HECEnabled:      getEnvBool("HEC_ENABLED", true),
+        HECFieldMapping: os.Getenv("HEC_FIELD_MAPPING"),
+        HECAckEnabled:   getEnvBool("HEC_ACK_ENABLED", true),
+
+        StreamPollInterval:   getEnvDuration("STREAM_POLL_INTERVAL", 5*time.Second),
+        StreamAllowedOrigins: getEnvList("STREAM_ALLOWED_ORIGINS", nil),
+
+        OutboxPollInterval: getEnvDuration("OUTBOX_POLL_INTERVAL", time.Second),
+        OutboxBatchSize:    getEnvInt("OUTBOX_BATCH_SIZE", 100, 1),
+
+        KafkaMaxRetries:      getEnvInt("KAFKA_MAX_RETRIES", 5, 0),
+        KafkaRetryBackoff:    getEnvDuration("KAFKA_RETRY_BACKOFF", time.Second),
+        KafkaRetryMaxBackoff: getEnvDuration("KAFKA_RETRY_MAX_BACKOFF", 30*time.Second),
+        KafkaDLQTopic:        kafkaDLQTopic,
+
+        ProcessorWorkers: getEnvInt("PROCESSOR_WORKERS", 4, 1),
+
+        AIServiceURL:        aiServiceURL,
+        AIServiceTimeout:    getEnvDuration("AI_SERVICE_TIMEOUT", 5*time.Second),
+        AnalyzerFallback:    getEnvBool("ANALYZER_FALLBACK", true),
+        TenantAIServiceURLs: getEnvMap("TENANT_AI_SERVICE_URLS"),
+
+        AIBreakerFailureThreshold: getEnvInt("AI_BREAKER_FAILURE_THRESHOLD", 5, 1),
+        AIBreakerOpenTimeout:      getEnvDuration("AI_BREAKER_OPEN_TIMEOUT", 30*time.Second),
+        AIBreakerHalfOpenCalls:    getEnvInt("AI_BREAKER_HALF_OPEN_CALLS", 1, 1),
+
+        ProcessorHealthAddr: processorHealthAddr,
+    }
+}
+
+// getEnvString reads a string from the environment, falling back to def if the
+// variable is unset.
+func getEnvString(key, def string) string {
+    if raw := os.Getenv(key); raw != "" {
+        return raw
+    }
+    return def
+}
+
+// getEnvDuration reads a duration such as "500ms" or "2m" from the environment,
+// falling back to def if the variable is unset or invalid.
+func getEnvDuration(key string, def time.Duration) time.Duration {
+    raw := os.Getenv(key)
+    if raw == "" {
+        return def
+    }
+    d, err := time.ParseDuration(raw)
+    if err != nil || d <= 0 {
+        log.Printf("Invalid %s=%q, using default %s.", key, raw, def)
+        return def
+    }
+    return d
+}
+
+// getEnvInt reads an integer of at least min from the environment, falling back
+// to def if the variable is unset or invalid.
+func getEnvInt(key string, def, min int) int {
+    raw := os.Getenv(key)
+    if raw == "" {
+        return def
+    }
+    n, err := strconv.Atoi(raw)
+    if err != nil || n < min {
+        log.Printf("Invalid %s=%q, using default %d.", key, raw, def)
+        return def
+    }
+    return n
+}
+
+// getEnvFloat reads a number between min and max from the environment, falling back
+// to def if the variable is unset or invalid.
+func getEnvFloat(key string, def, min, max float64) float64 {
+    raw := os.Getenv(key)
+    if raw == "" {
+        return def
+    }
+    f, err := strconv.ParseFloat(raw, 64)
+    if err != nil || f < min || f > max {
+        log.Printf("Invalid %s=%q, using default %g.", key, raw, def)
+        return def
+    }
+    return f
+}
+
+// getEnvBool reads a boolean ("true", "false", "1", "0", ...) from the environment,
+// falling back to def if the variable is unset or invalid.
+func getEnvBool(key string, def bool) bool {
+    raw := os.Getenv(key)
+    if raw == "" {
+        return def
+    }
+    b, err := strconv.ParseBool(raw)
+    if err != nil {
+        log.Printf("Invalid %s=%q, using default %t.", key, raw, def)
+        return def
+    }
+    return b
+}
+
+// getEnvChoice reads one of a fixed set of values from the environment, falling
+// back to def if the variable is unset or not one of def and choices.
+func getEnvChoice(key, def string, choices ...string) string {
+    raw := os.Getenv(key)
+    if raw == "" || raw == def {
+        return def
+    }
+    for _, c := range choices {
+        if raw == c {
+            return raw
+        }
+    }
+    log.Printf("Invalid %s=%q, using default '%s'.", key, raw, def)
+    return def
+}
+
+// getEnvList reads a comma-separated list from the environment, falling back to def
+// if the variable is unset.
+func getEnvList(key string, def []string) []string {
+    raw := os.Getenv(key)
+    if raw == "" {
+        return def
+    }
+    var list []string
+    for _, item := range strings.Split(raw, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            list = append(list, item)
+        }
+    }
+    return list
+}
+
+// getEnvMap reads comma-separated key=value pairs from the environment, e.g.
+// "acme=http://ai-acme:8000/analyze-alert,globex=...". Malformed pairs are skipped.
+func getEnvMap(key string) map[string]string {
+    m := make(map[string]string)
+    for _, item := range getEnvList(key, nil) {
+        k, v, ok := strings.Cut(item, "=")
+        k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+        if !ok || k == "" || v == "" {
+            log.Printf("Ignoring invalid entry %q in %s, expected key=value.", item, key)
+            continue
+        }
+        m[k] = v
+    }
+    return m
+}
+
+// getEnvIntList reads a comma-separated list of positive integers from the environment,
+// falling back to def if the variable is unset or invalid.
+func getEnvIntList(key string, def []int) []int {
+    var list []int
+    for _, item := range getEnvList(key, nil) {
+        n, err := strconv.Atoi(item)
+        if err != nil || n < 1 {
+            log.Printf("Invalid %s=%q, using default %v.", key, os.Getenv(key), def)
+            return def
+        }
+        list = append(list, n)
+    }
+    if list == nil {
+        return def
     }
+    return list
 }
\ No newline at end of file
@@ -0,0 +1,98 @@
+package correlation
+
+import (
+    "context"
+    "fmt"
+    "strings"
+    "sync/atomic"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+    "github.com/Kelvinkhyd/GuardianAI/internal/repository"
+)
+
+// DefaultEntities are the entity kinds alerts are correlated on by default.
+var DefaultEntities = []string{models.EntityHostname, models.EntityUsername, models.EntitySourceIP, models.EntityFileHash}
+
+// entityValues maps the entity kinds to the alert fields they read.
+var entityValues = map[string]func(a models.SecurityAlert) string{
+    models.EntityHostname: func(a models.SecurityAlert) string { return a.Hostname },
+    models.EntityUsername: func(a models.SecurityAlert) string { return a.Username },
+    models.EntitySourceIP: func(a models.SecurityAlert) string { return a.SourceIP },
+    models.EntityFileHash: func(a models.SecurityAlert) string { return a.FileHash },
+}
+
+// placeholderValues are values sources send for "unknown". Correlating on them would
+// merge unrelated alerts into one huge incident.
+var placeholderValues = map[string]bool{"-": true, "n/a": true, "unknown": true, "none": true}
+
+// idCounter makes incident IDs created in the same nanosecond unique.
+var idCounter uint64
+
+// Engine groups analysed alerts into incidents: an alert joins the most recently
+// active open incident that saw one of its entities within the window, and opens a
+// new incident otherwise.
+type Engine struct {
+    repo   repository.IncidentRepository
+    window time.Duration
+    kinds  []string
+}
+
+// NewEngine creates an engine correlating on the given entity kinds (see DefaultEntities).
+func NewEngine(repo repository.IncidentRepository, window time.Duration, kinds []string) (*Engine, error) {
+    if window <= 0 {
+        return nil, fmt.Errorf("correlation window must be positive, got %s", window)
+    }
+    if len(kinds) == 0 {
+        return nil, fmt.Errorf("no correlation entities configured")
+    }
+    e := &Engine{repo: repo, window: window}
+    for _, k := range kinds {
+        k = strings.ToLower(strings.TrimSpace(k))
+        if _, ok := entityValues[k]; !ok {
+            return nil, fmt.Errorf("unknown correlation entity %q", k)
+        }
+        e.kinds = append(e.kinds, k)
+    }
+    return e, nil
+}
+
+// Entities extracts the alert's entities. Values are lower-cased so "HOST-1" and
+// "host-1" are the same host; empty and placeholder values are skipped.
+func (e *Engine) Entities(alert models.SecurityAlert) []models.IncidentEntity {
+    seen := lastSeen(alert)
+    var entities []models.IncidentEntity
+    for _, kind := range e.kinds {
+        value := strings.ToLower(strings.TrimSpace(entityValues[kind](alert)))
+        if value == "" || placeholderValues[value] {
+            continue
+        }
+        entities = append(entities, models.IncidentEntity{Kind: kind, Value: value, LastSeen: seen})
+    }
+    return entities
+}
+
+// lastSeen returns when the alert last occurred; folded repeats move it past the timestamp.
+func lastSeen(alert models.SecurityAlert) time.Time {
+    if alert.LastSeen.After(alert.Timestamp) {
+        return alert.LastSeen
+    }
+    return alert.Timestamp
+}
+
+// Correlate attaches a stored alert to an incident and returns it. The window is
+// measured from the alert's own time rather than the clock, so replayed or delayed
+// alerts are correlated the same way as live ones.
+func (e *Engine) Correlate(ctx context.Context, alert models.SecurityAlert) (*models.Incident, bool, error) {
+    since := alert.Timestamp.Add(-e.window)
+    incident, created, err := e.repo.AttachAlert(ctx, alert, e.Entities(alert), since, newIncidentID())
+    if err != nil {
+        return nil, false, fmt.Errorf("failed to correlate alert %s: %w", alert.ID, err)
+    }
+    return incident, created, nil
+}
+
+// newIncidentID returns a unique ID in the style of the alert IDs generated by the API.
+func newIncidentID() string {
+    return fmt.Sprintf("incident-%d-%d", time.Now().UnixNano(), atomic.AddUint64(&idCounter, 1))
+}
@@ -0,0 +1,74 @@
+package correlation
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+    "github.com/Kelvinkhyd/GuardianAI/internal/repository"
+)
+
+func TestEntities(t *testing.T) {
+    e, err := NewEngine(repository.NewMemoryRepository(), time.Hour, []string{"Hostname", " username ", "source_ip"})
+    if err != nil {
+        t.Fatalf("NewEngine: %v", err)
+    }
+    now := time.Now()
+    got := e.Entities(models.SecurityAlert{Hostname: "WS-01", Username: "-", SourceIP: "10.0.0.5", FileHash: "abc", Timestamp: now})
+    want := []models.IncidentEntity{
+        {Kind: models.EntityHostname, Value: "ws-01", LastSeen: now},
+        {Kind: models.EntitySourceIP, Value: "10.0.0.5", LastSeen: now},
+    }
+    if len(got) != len(want) {
+        t.Fatalf("Entities: got %+v, want %+v", got, want)
+    }
+    for i := range want {
+        if got[i] != want[i] {
+            t.Errorf("entity %d: got %+v, want %+v", i, got[i], want[i])
+        }
+    }
+
+    if _, err := NewEngine(repository.NewMemoryRepository(), time.Hour, []string{"target_ip"}); err == nil {
+        t.Error("NewEngine with an unknown entity: expected an error")
+    }
+}
+
+func TestCorrelate(t *testing.T) {
+    ctx := context.Background()
+    repo := repository.NewMemoryRepository()
+    e, err := NewEngine(repo, time.Hour, DefaultEntities)
+    if err != nil {
+        t.Fatalf("NewEngine: %v", err)
+    }
+
+    base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+    alerts := []models.SecurityAlert{
+        {ID: "a1", Timestamp: base, Hostname: "ws-01", Username: "alice", RiskScore: 0.5},
+        {ID: "a2", Timestamp: base.Add(time.Minute), Hostname: "WS-01", RiskScore: 0.5}, // Same host, other case
+        {ID: "a3", Timestamp: base.Add(2 * time.Minute), Hostname: "ws-02", Username: "bob"},
+    }
+    var incidents []string
+    for i := range alerts {
+        alerts[i].Title, alerts[i].Status = "alert "+alerts[i].ID, models.StatusAnalyzed
+        if err := repo.CreateAlert(ctx, models.DefaultTenant, &alerts[i]); err != nil {
+            t.Fatalf("CreateAlert: %v", err)
+        }
+        incident, _, err := e.Correlate(ctx, alerts[i])
+        if err != nil {
+            t.Fatalf("Correlate(%s): %v", alerts[i].ID, err)
+        }
+        incidents = append(incidents, incident.ID)
+    }
+
+    if incidents[0] != incidents[1] || incidents[0] == incidents[2] {
+        t.Errorf("incidents: got %v, want a1 and a2 together and a3 apart", incidents)
+    }
+    got, err := repo.GetIncidentByID(ctx, models.DefaultTenant, incidents[0])
+    if err != nil || got == nil {
+        t.Fatalf("GetIncidentByID: %v, %v", got, err)
+    }
+    if got.AlertCount != 2 || got.RiskScore != 0.75 {
+        t.Errorf("incident: got %d alerts, risk score %v, want 2, 0.75", got.AlertCount, got.RiskScore)
+    }
+}
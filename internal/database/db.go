@@ -1,7 +1,9 @@
 package database
 
 import (
+    "context"
     "database/sql"
+    "fmt"
     "log"
 
     _ "github.com/lib/pq" // PostgreSQL driver
@@ -31,4 +33,21 @@ func NewDBConnection(databaseURL string) (*DB, error) {
 
     log.Println("Successfully connected to the database!")
     return &DB{db}, nil
+}
+
+// Migrate applies pending schema migrations if autoMigrate is set. It is called by
+// every binary at startup; the advisory lock in MigrateUp makes that safe.
+func (db *DB) Migrate(ctx context.Context, autoMigrate bool) error {
+    if !autoMigrate {
+        log.Println("Automatic migrations disabled, run 'go run ./cmd/migrate up' to update the schema.")
+        return nil
+    }
+    applied, err := db.MigrateUp(ctx)
+    if err != nil {
+        return fmt.Errorf("failed to apply migrations: %w", err)
+    }
+    if applied > 0 {
+        log.Printf("Applied %d database migrations.", applied)
+    }
+    return nil
 }
\ No newline at end of file
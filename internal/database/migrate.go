@@ -0,0 +1,247 @@
+package database
+
+import (
+    "context"
+    "database/sql"
+    "embed"
+    "fmt"
+    "io/fs"
+    "log"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// migrationFiles holds the versioned schema migrations. Each migration is a pair of
+// files named <version>_<name>.up.sql and <version>_<name>.down.sql.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockKey is the Postgres advisory lock held while migrating, so the API
+// server and the processor starting at the same time cannot both apply migrations.
+const migrationLockKey int64 = 0x6775617264 // "guard"
+
+// Migration is one versioned schema change.
+type Migration struct {
+    Version int64
+    Name    string
+    Up      string
+    Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+    Migration
+    Applied   bool
+    AppliedAt time.Time
+}
+
+// loadMigrations reads the embedded migrations, sorted by version.
+func loadMigrations() ([]Migration, error) {
+    entries, err := fs.ReadDir(migrationFiles, "migrations")
+    if err != nil {
+        return nil, fmt.Errorf("failed to read embedded migrations: %w", err)
+    }
+
+    byVersion := make(map[int64]*Migration)
+    for _, e := range entries {
+        name := e.Name()
+        var direction string
+        switch {
+        case strings.HasSuffix(name, ".up.sql"):
+            direction = "up"
+        case strings.HasSuffix(name, ".down.sql"):
+            direction = "down"
+        default:
+            continue
+        }
+
+        base := strings.TrimSuffix(name, "."+direction+".sql")
+        parts := strings.SplitN(base, "_", 2)
+        version, err := strconv.ParseInt(parts[0], 10, 64)
+        if err != nil || len(parts) != 2 {
+            return nil, fmt.Errorf("migration file %q must be named <version>_<name>.%s.sql", name, direction)
+        }
+        body, err := migrationFiles.ReadFile("migrations/" + name)
+        if err != nil {
+            return nil, fmt.Errorf("failed to read migration %q: %w", name, err)
+        }
+
+        m, ok := byVersion[version]
+        if !ok {
+            m = &Migration{Version: version, Name: parts[1]}
+            byVersion[version] = m
+        } else if m.Name != parts[1] {
+            return nil, fmt.Errorf("migration version %d is used by both %q and %q", version, m.Name, parts[1])
+        }
+        if direction == "up" {
+            m.Up = string(body)
+        } else {
+            m.Down = string(body)
+        }
+    }
+
+    migrations := make([]Migration, 0, len(byVersion))
+    for _, m := range byVersion {
+        if m.Up == "" {
+            return nil, fmt.Errorf("migration %d_%s has no .up.sql file", m.Version, m.Name)
+        }
+        migrations = append(migrations, *m)
+    }
+    sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+    return migrations, nil
+}
+
+// withMigrationLock runs fn on a dedicated connection holding the migration advisory lock.
+// Advisory locks belong to a session, so everything must run on that same connection.
+func (db *DB) withMigrationLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+    conn, err := db.Conn(ctx)
+    if err != nil {
+        return fmt.Errorf("failed to get connection for migrations: %w", err)
+    }
+    defer conn.Close()
+
+    if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+        return fmt.Errorf("failed to acquire migration lock: %w", err)
+    }
+    defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+
+    _, err = conn.ExecContext(ctx, `
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version BIGINT PRIMARY KEY,
+            name VARCHAR(255) NOT NULL,
+            applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+        )`)
+    if err != nil {
+        return fmt.Errorf("failed to create schema_migrations table: %w", err)
+    }
+
+    return fn(conn)
+}
+
+// appliedMigrations returns the applied versions and when they were applied.
+func appliedMigrations(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+    rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+    }
+    defer rows.Close()
+
+    applied := make(map[int64]time.Time)
+    for rows.Next() {
+        var version int64
+        var at time.Time
+        if err := rows.Scan(&version, &at); err != nil {
+            return nil, fmt.Errorf("failed to scan schema_migrations row: %w", err)
+        }
+        applied[version] = at
+    }
+    return applied, rows.Err()
+}
+
+// runMigration executes one migration step and records it, in a single transaction.
+func runMigration(ctx context.Context, conn *sql.Conn, m Migration, up bool) error {
+    tx, err := conn.BeginTx(ctx, nil)
+    if err != nil {
+        return fmt.Errorf("failed to begin migration transaction: %w", err)
+    }
+    defer tx.Rollback() // No-op after a successful commit
+
+    script, record, args := m.Up, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, []interface{}{m.Version, m.Name}
+    if !up {
+        script, record, args = m.Down, `DELETE FROM schema_migrations WHERE version = $1`, []interface{}{m.Version}
+    }
+
+    if _, err := tx.ExecContext(ctx, script); err != nil {
+        return fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+    }
+    if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+        return fmt.Errorf("failed to record migration %d_%s: %w", m.Version, m.Name, err)
+    }
+    return tx.Commit()
+}
+
+// MigrateUp applies every pending migration in version order and returns how many were applied.
+func (db *DB) MigrateUp(ctx context.Context) (int, error) {
+    migrations, err := loadMigrations()
+    if err != nil {
+        return 0, err
+    }
+
+    count := 0
+    err = db.withMigrationLock(ctx, func(conn *sql.Conn) error {
+        applied, err := appliedMigrations(ctx, conn)
+        if err != nil {
+            return err
+        }
+        for _, m := range migrations {
+            if _, ok := applied[m.Version]; ok {
+                continue
+            }
+            log.Printf("Applying migration %d_%s...", m.Version, m.Name)
+            if err := runMigration(ctx, conn, m, true); err != nil {
+                return err
+            }
+            count++
+        }
+        return nil
+    })
+    return count, err
+}
+
+// MigrateDown reverts the most recently applied migrations, at most steps of them,
+// and returns how many were reverted.
+func (db *DB) MigrateDown(ctx context.Context, steps int) (int, error) {
+    migrations, err := loadMigrations()
+    if err != nil {
+        return 0, err
+    }
+
+    count := 0
+    err = db.withMigrationLock(ctx, func(conn *sql.Conn) error {
+        applied, err := appliedMigrations(ctx, conn)
+        if err != nil {
+            return err
+        }
+        for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+            m := migrations[i]
+            if _, ok := applied[m.Version]; !ok {
+                continue
+            }
+            if m.Down == "" {
+                return fmt.Errorf("migration %d_%s cannot be reverted: no .down.sql file", m.Version, m.Name)
+            }
+            log.Printf("Reverting migration %d_%s...", m.Version, m.Name)
+            if err := runMigration(ctx, conn, m, false); err != nil {
+                return err
+            }
+            count++
+        }
+        return nil
+    })
+    return count, err
+}
+
+// MigrationStatus lists every known migration and whether it has been applied.
+func (db *DB) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+    migrations, err := loadMigrations()
+    if err != nil {
+        return nil, err
+    }
+
+    var statuses []MigrationStatus
+    err = db.withMigrationLock(ctx, func(conn *sql.Conn) error {
+        applied, err := appliedMigrations(ctx, conn)
+        if err != nil {
+            return err
+        }
+        for _, m := range migrations {
+            at, ok := applied[m.Version]
+            statuses = append(statuses, MigrationStatus{Migration: m, Applied: ok, AppliedAt: at})
+        }
+        return nil
+    })
+    return statuses, err
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+    "fmt"
+    "io/fs"
+    "strings"
+    "testing"
+)
+
+// statements returns script without blank lines and -- comments.
+func statements(script string) string {
+    var lines []string
+    for _, line := range strings.Split(script, "\n") {
+        line = strings.TrimSpace(line)
+        if line != "" && !strings.HasPrefix(line, "--") {
+            lines = append(lines, line)
+        }
+    }
+    return strings.Join(lines, "\n")
+}
+
+func TestLoadMigrations(t *testing.T) {
+    migrations, err := loadMigrations()
+    if err != nil {
+        t.Fatalf("loadMigrations: %v", err)
+    }
+    if len(migrations) == 0 {
+        t.Fatal("no migrations embedded")
+    }
+
+    // Versions start at 1 and have no gaps or repeats
+    for i, m := range migrations {
+        if m.Version != int64(i+1) {
+            t.Errorf("migration %d_%s: got version %d at position %d, want %d", m.Version, m.Name, m.Version, i, i+1)
+        }
+        if statements(m.Up) == "" {
+            t.Errorf("migration %d_%s: up script is empty", m.Version, m.Name)
+        }
+        if statements(m.Down) == "" {
+            t.Errorf("migration %d_%s: down script is empty or missing", m.Version, m.Name)
+        }
+    }
+
+    // Every file belongs to exactly one migration, named with a zero-padded version
+    want := make(map[string]bool)
+    for _, m := range migrations {
+        for _, direction := range []string{"up", "down"} {
+            want[fmt.Sprintf("%04d_%s.%s.sql", m.Version, m.Name, direction)] = true
+        }
+    }
+    entries, err := fs.ReadDir(migrationFiles, "migrations")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(entries) != 2*len(migrations) {
+        t.Errorf("got %d files for %d migrations, want an up and a down file each", len(entries), len(migrations))
+    }
+    for _, e := range entries {
+        if !want[e.Name()] {
+            t.Errorf("file %s does not match a loaded migration", e.Name())
+        }
+    }
+}
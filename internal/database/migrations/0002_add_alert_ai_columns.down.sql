@@ -0,0 +1,4 @@
+ALTER TABLE alerts DROP COLUMN IF EXISTS ai_model_version;
+ALTER TABLE alerts DROP COLUMN IF EXISTS recommended_action;
+ALTER TABLE alerts DROP COLUMN IF EXISTS risk_score;
+ALTER TABLE alerts DROP COLUMN IF EXISTS predicted_severity;
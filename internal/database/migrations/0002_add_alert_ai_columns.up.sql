@@ -0,0 +1,5 @@
+-- Columns for AI/ML integration, filled in by the processor
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS predicted_severity VARCHAR(50);
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS risk_score NUMERIC(5,3); -- e.g., 0.000 to 1.000
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS recommended_action TEXT;
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS ai_model_version VARCHAR(100);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_alerts_risk_score_id;
+DROP INDEX IF EXISTS idx_alerts_timestamp_id;
+DROP INDEX IF EXISTS idx_alerts_created_at_id;
@@ -0,0 +1,4 @@
+-- Indexes backing the sort orders and keyset pagination of GET /alerts
+CREATE INDEX IF NOT EXISTS idx_alerts_created_at_id ON alerts (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_alerts_timestamp_id ON alerts (timestamp DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_alerts_risk_score_id ON alerts ((COALESCE(risk_score, 0)) DESC, id DESC);
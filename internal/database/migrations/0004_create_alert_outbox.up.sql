@@ -0,0 +1,14 @@
+-- Transactional outbox: rows are written in the same transaction as the alert and
+-- relayed to Kafka by the API server, so a stored alert always reaches the processor.
+CREATE TABLE IF NOT EXISTS alert_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    alert_id VARCHAR(255) NOT NULL,
+    message_key BYTEA,
+    payload JSONB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMP WITH TIME ZONE
+);
+CREATE INDEX IF NOT EXISTS idx_alert_outbox_pending ON alert_outbox (next_attempt_at, id) WHERE sent_at IS NULL;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_alerts_fingerprint_last_seen;
+ALTER TABLE alerts DROP COLUMN IF EXISTS last_seen;
+ALTER TABLE alerts DROP COLUMN IF EXISTS occurrence_count;
+ALTER TABLE alerts DROP COLUMN IF EXISTS fingerprint;
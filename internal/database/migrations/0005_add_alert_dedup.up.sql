@@ -0,0 +1,8 @@
+-- Deduplication: repeats of an alert (same fingerprint) within the configured window
+-- are folded into the first one, which counts them.
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS fingerprint VARCHAR(64);
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS occurrence_count INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS last_seen TIMESTAMP WITH TIME ZONE DEFAULT NOW();
+UPDATE alerts SET last_seen = timestamp WHERE last_seen IS NULL OR last_seen < timestamp;
+ALTER TABLE alerts ALTER COLUMN last_seen SET NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_alerts_fingerprint_last_seen ON alerts (fingerprint, last_seen DESC) WHERE fingerprint IS NOT NULL;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS incident_events;
+DROP TABLE IF EXISTS incident_entities;
+DROP TABLE IF EXISTS incident_alerts;
+DROP TABLE IF EXISTS incidents;
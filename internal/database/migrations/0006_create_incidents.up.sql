@@ -0,0 +1,46 @@
+-- Incidents group related alerts. The correlation engine in the processor attaches
+-- each analysed alert to an open incident sharing one of its entities.
+CREATE TABLE IF NOT EXISTS incidents (
+    id VARCHAR(255) PRIMARY KEY,
+    title VARCHAR(512) NOT NULL,
+    status VARCHAR(50) NOT NULL DEFAULT 'open',
+    severity VARCHAR(50) NOT NULL DEFAULT '',
+    risk_score NUMERIC(5,3) NOT NULL DEFAULT 0,
+    alert_count INTEGER NOT NULL DEFAULT 0,
+    first_seen TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_seen TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_incidents_last_seen ON incidents (last_seen DESC, id DESC);
+
+-- Member alerts. An alert belongs to at most one incident.
+CREATE TABLE IF NOT EXISTS incident_alerts (
+    incident_id VARCHAR(255) NOT NULL REFERENCES incidents (id) ON DELETE CASCADE,
+    alert_id VARCHAR(255) NOT NULL REFERENCES alerts (id) ON DELETE CASCADE,
+    added_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (incident_id, alert_id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_incident_alerts_alert ON incident_alerts (alert_id);
+
+-- Entities (hosts, users, addresses, files) seen in an incident's alerts, used to
+-- find the incident for a new alert.
+CREATE TABLE IF NOT EXISTS incident_entities (
+    incident_id VARCHAR(255) NOT NULL REFERENCES incidents (id) ON DELETE CASCADE,
+    kind VARCHAR(50) NOT NULL,
+    value VARCHAR(512) NOT NULL,
+    last_seen TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (incident_id, kind, value)
+);
+CREATE INDEX IF NOT EXISTS idx_incident_entities_lookup ON incident_entities (kind, value, last_seen DESC);
+
+-- Incident timeline
+CREATE TABLE IF NOT EXISTS incident_events (
+    id BIGSERIAL PRIMARY KEY,
+    incident_id VARCHAR(255) NOT NULL REFERENCES incidents (id) ON DELETE CASCADE,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    type VARCHAR(50) NOT NULL,
+    alert_id VARCHAR(255),
+    message TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_incident_events_incident ON incident_events (incident_id, id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_alerts_sigma_matches;
+ALTER TABLE alerts DROP COLUMN IF EXISTS sigma_matches;
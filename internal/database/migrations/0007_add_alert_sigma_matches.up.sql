@@ -0,0 +1,3 @@
+-- Sigma rules matched by the processor, as a JSON array of {rule_id, title, level}.
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS sigma_matches JSONB NOT NULL DEFAULT '[]';
+CREATE INDEX IF NOT EXISTS idx_alerts_sigma_matches ON alerts USING GIN (sigma_matches jsonb_path_ops);
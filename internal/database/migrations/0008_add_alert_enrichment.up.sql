@@ -0,0 +1,2 @@
+-- Context added by the processor's enrichment stage (threat intel matches, ...).
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS enrichment JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,3 @@
+-- Lets GET /alerts filter on enrichment fields (e.g. source country or ASN) with
+-- JSONB containment queries.
+CREATE INDEX IF NOT EXISTS idx_alerts_enrichment ON alerts USING GIN (enrichment jsonb_path_ops);
@@ -0,0 +1,11 @@
+-- Log of alert changes streamed to clients by GET /alerts/stream. Rows are written in
+-- the same transaction as the change, followed by a NOTIFY on the alert_events
+-- channel, and are kept for a day so disconnected clients can resume.
+CREATE TABLE IF NOT EXISTS alert_events (
+    id BIGSERIAL PRIMARY KEY,
+    type VARCHAR(50) NOT NULL,
+    alert_id VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_alert_events_created_at ON alert_events (created_at);
@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS idx_incidents_tenant_last_seen;
+DROP INDEX IF EXISTS idx_alerts_tenant_fingerprint_last_seen;
+DROP INDEX IF EXISTS idx_alerts_tenant_risk_score_id;
+DROP INDEX IF EXISTS idx_alerts_tenant_timestamp_id;
+DROP INDEX IF EXISTS idx_alerts_tenant_created_at_id;
+CREATE INDEX IF NOT EXISTS idx_incidents_last_seen ON incidents (last_seen DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_alerts_fingerprint_last_seen ON alerts (fingerprint, last_seen DESC) WHERE fingerprint IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_alerts_risk_score_id ON alerts ((COALESCE(risk_score, 0)) DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_alerts_timestamp_id ON alerts (timestamp DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_alerts_created_at_id ON alerts (created_at DESC, id DESC);
+ALTER TABLE api_keys DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE incidents DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE alert_outbox DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE alerts DROP COLUMN IF EXISTS tenant_id;
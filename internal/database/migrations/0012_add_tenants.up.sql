@@ -0,0 +1,18 @@
+-- Multi-tenancy: every alert, incident and API key belongs to a tenant, and every
+-- query is scoped to the caller's tenant. Existing rows move to the 'default' tenant.
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE alert_outbox ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE incidents ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+
+-- The list and dedup indexes now lead with the tenant, like the queries using them.
+DROP INDEX IF EXISTS idx_alerts_created_at_id;
+DROP INDEX IF EXISTS idx_alerts_timestamp_id;
+DROP INDEX IF EXISTS idx_alerts_risk_score_id;
+DROP INDEX IF EXISTS idx_alerts_fingerprint_last_seen;
+DROP INDEX IF EXISTS idx_incidents_last_seen;
+CREATE INDEX IF NOT EXISTS idx_alerts_tenant_created_at_id ON alerts (tenant_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_alerts_tenant_timestamp_id ON alerts (tenant_id, timestamp DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_alerts_tenant_risk_score_id ON alerts (tenant_id, (COALESCE(risk_score, 0)) DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_alerts_tenant_fingerprint_last_seen ON alerts (tenant_id, fingerprint, last_seen DESC) WHERE fingerprint IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_incidents_tenant_last_seen ON incidents (tenant_id, last_seen DESC, id DESC);
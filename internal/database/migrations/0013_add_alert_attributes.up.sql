@@ -0,0 +1,3 @@
+-- Vendor fields that have no column of their own, e.g. unmapped CEF and LEEF
+-- extensions, as a JSON object of strings.
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
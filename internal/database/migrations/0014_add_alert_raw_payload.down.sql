@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_alerts_attributes;
+ALTER TABLE alerts DROP COLUMN IF EXISTS raw_payload;
@@ -0,0 +1,7 @@
+-- The payload an alert was submitted as, before it was mapped to alert fields:
+-- the JSON body, or the CEF, LEEF or syslog line as a JSON string. NULL for alerts
+-- stored before this column existed.
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS raw_payload JSONB;
+
+-- Lets GET /alerts filter on attributes (attr.<key>=) with JSONB containment queries.
+CREATE INDEX IF NOT EXISTS idx_alerts_attributes ON alerts USING GIN (attributes jsonb_path_ops);
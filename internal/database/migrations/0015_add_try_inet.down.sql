@@ -0,0 +1 @@
+DROP FUNCTION IF EXISTS try_inet(text);
@@ -0,0 +1,10 @@
+-- source_ip and target_ip hold whatever the alert source sent, which is not always an
+-- address. try_inet returns NULL for such values instead of raising, so one bad row
+-- cannot make every CIDR query of GET /alerts fail.
+CREATE OR REPLACE FUNCTION try_inet(value text) RETURNS inet AS $$
+BEGIN
+    RETURN value::inet;
+EXCEPTION WHEN invalid_text_representation THEN
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
@@ -0,0 +1,12 @@
+-- Fails if two tenants have an alert with the same ID.
+ALTER TABLE alert_events DROP COLUMN IF EXISTS tenant_id;
+
+DROP INDEX IF EXISTS idx_incident_alerts_tenant_alert;
+ALTER TABLE incident_alerts DROP CONSTRAINT IF EXISTS incident_alerts_alert_fkey;
+ALTER TABLE incident_alerts DROP COLUMN IF EXISTS tenant_id;
+
+ALTER TABLE alerts DROP CONSTRAINT IF EXISTS alerts_pkey;
+ALTER TABLE alerts ADD PRIMARY KEY (id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_incident_alerts_alert ON incident_alerts (alert_id);
+ALTER TABLE incident_alerts ADD CONSTRAINT incident_alerts_alert_id_fkey
+    FOREIGN KEY (alert_id) REFERENCES alerts (id) ON DELETE CASCADE;
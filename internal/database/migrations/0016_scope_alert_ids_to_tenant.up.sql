@@ -0,0 +1,18 @@
+-- Alert IDs may be chosen by the client, so they are only unique within a tenant. The
+-- key and the tables referring to alerts now include the tenant, which keeps tenants
+-- from learning about, or colliding with, each other's alert IDs.
+ALTER TABLE incident_alerts DROP CONSTRAINT IF EXISTS incident_alerts_alert_id_fkey;
+ALTER TABLE alerts DROP CONSTRAINT IF EXISTS alerts_pkey;
+ALTER TABLE alerts ADD PRIMARY KEY (tenant_id, id);
+
+ALTER TABLE incident_alerts ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64);
+UPDATE incident_alerts ia SET tenant_id = i.tenant_id FROM incidents i WHERE i.id = ia.incident_id AND ia.tenant_id IS NULL;
+ALTER TABLE incident_alerts ALTER COLUMN tenant_id SET NOT NULL;
+ALTER TABLE incident_alerts ADD CONSTRAINT incident_alerts_alert_fkey
+    FOREIGN KEY (tenant_id, alert_id) REFERENCES alerts (tenant_id, id) ON DELETE CASCADE;
+DROP INDEX IF EXISTS idx_incident_alerts_alert;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_incident_alerts_tenant_alert ON incident_alerts (tenant_id, alert_id);
+
+ALTER TABLE alert_events ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64);
+UPDATE alert_events SET tenant_id = COALESCE(payload->>'tenant_id', 'default') WHERE tenant_id IS NULL;
+ALTER TABLE alert_events ALTER COLUMN tenant_id SET NOT NULL;
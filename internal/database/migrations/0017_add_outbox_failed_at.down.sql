@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_alert_outbox_pending;
+CREATE INDEX IF NOT EXISTS idx_alert_outbox_pending ON alert_outbox (next_attempt_at, id) WHERE sent_at IS NULL;
+
+ALTER TABLE alert_outbox DROP COLUMN IF EXISTS failed_at;
@@ -0,0 +1,7 @@
+-- Messages that still fail to publish after the relay's attempt limit are parked with
+-- failed_at set, so they no longer hold up the messages claimed alongside them. They
+-- are kept with their last_error; to try one again, clear failed_at and attempts.
+ALTER TABLE alert_outbox ADD COLUMN IF NOT EXISTS failed_at TIMESTAMP WITH TIME ZONE;
+
+DROP INDEX IF EXISTS idx_alert_outbox_pending;
+CREATE INDEX IF NOT EXISTS idx_alert_outbox_pending ON alert_outbox (next_attempt_at, id) WHERE sent_at IS NULL AND failed_at IS NULL;
@@ -0,0 +1,81 @@
+package dedup
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// DefaultFields are the alert fields that identify repeats of the same alert by default.
+var DefaultFields = []string{"source", "category", "title", "hostname", "source_ip", "file_hash"}
+
+// fieldValues maps the names accepted in the field list to the alert fields they read.
+var fieldValues = map[string]func(a models.SecurityAlert) string{
+    "source":      func(a models.SecurityAlert) string { return a.Source },
+    "severity":    func(a models.SecurityAlert) string { return a.Severity },
+    "category":    func(a models.SecurityAlert) string { return a.Category },
+    "title":       func(a models.SecurityAlert) string { return a.Title },
+    "description": func(a models.SecurityAlert) string { return a.Description },
+    "source_ip":   func(a models.SecurityAlert) string { return a.SourceIP },
+    "target_ip":   func(a models.SecurityAlert) string { return a.TargetIP },
+    "hostname":    func(a models.SecurityAlert) string { return a.Hostname },
+    "username":    func(a models.SecurityAlert) string { return a.Username },
+    "file_hash":   func(a models.SecurityAlert) string { return a.FileHash },
+}
+
+// Policy decides which incoming alerts are repeats of a stored one and when a
+// repeated alert is important enough to be analysed again.
+type Policy struct {
+    fields []string
+    // Window is how long after the last occurrence a repeat is still folded into
+    // the stored alert. Later repeats start a new alert.
+    Window time.Duration
+    // EscalateAt lists occurrence counts, in ascending order, at which a folded alert
+    // is published again so the processor re-analyses it with its new count.
+    EscalateAt []int
+}
+
+// NewPolicy creates a policy fingerprinting the given fields (see DefaultFields).
+func NewPolicy(fields []string, window time.Duration, escalateAt []int) (*Policy, error) {
+    if len(fields) == 0 {
+        return nil, fmt.Errorf("no deduplication fields configured")
+    }
+    p := &Policy{Window: window}
+    for _, f := range fields {
+        f = strings.ToLower(strings.TrimSpace(f))
+        if _, ok := fieldValues[f]; !ok {
+            return nil, fmt.Errorf("unknown deduplication field %q", f)
+        }
+        p.fields = append(p.fields, f)
+    }
+    for _, n := range escalateAt {
+        if n < 2 {
+            return nil, fmt.Errorf("escalation threshold %d must be at least 2", n)
+        }
+        p.EscalateAt = append(p.EscalateAt, n)
+    }
+    sort.Ints(p.EscalateAt)
+    return p, nil
+}
+
+// Fields returns the fields the fingerprint is computed from.
+func (p *Policy) Fields() []string {
+    return p.fields
+}
+
+// Fingerprint returns a hex SHA-256 over the policy's fields of alert. Values are
+// trimmed and compared case-insensitively, so trivial formatting differences
+// between sources do not defeat deduplication.
+func (p *Policy) Fingerprint(alert models.SecurityAlert) string {
+    h := sha256.New()
+    for _, f := range p.fields {
+        // Field name and a separator keep "ab"+"c" and "a"+"bc" apart.
+        fmt.Fprintf(h, "%s=%s\x1f", f, strings.ToLower(strings.TrimSpace(fieldValues[f](alert))))
+    }
+    return hex.EncodeToString(h.Sum(nil))
+}
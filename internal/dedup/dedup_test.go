@@ -0,0 +1,75 @@
+package dedup
+
+import (
+    "reflect"
+    "testing"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+func TestNewPolicy(t *testing.T) {
+    p, err := NewPolicy([]string{" Source ", "TITLE", "hostname"}, time.Minute, []int{10, 3})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(p.Fields(), []string{"source", "title", "hostname"}) {
+        t.Errorf("fields: got %v", p.Fields())
+    }
+    if p.Window != time.Minute || !reflect.DeepEqual(p.EscalateAt, []int{3, 10}) {
+        t.Errorf("got window %v, escalation %v, want 1m0s and [3 10]", p.Window, p.EscalateAt)
+    }
+
+    for _, tc := range []struct {
+        name       string
+        fields     []string
+        escalateAt []int
+    }{
+        {"no fields", nil, nil},
+        {"unknown field", []string{"source", "tenant_id"}, nil},
+        {"field of another struct", []string{"status"}, nil},
+        {"escalation below 2", DefaultFields, []int{1}},
+    } {
+        if _, err := NewPolicy(tc.fields, time.Minute, tc.escalateAt); err == nil {
+            t.Errorf("%s: expected an error", tc.name)
+        }
+    }
+}
+
+func TestFingerprint(t *testing.T) {
+    p, err := NewPolicy(DefaultFields, time.Minute, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    alert := models.SecurityAlert{Source: "firewall", Category: "network", Title: "Port scan", Hostname: "web-01", SourceIP: "10.0.0.1"}
+    fp := p.Fingerprint(alert)
+    if len(fp) != 64 {
+        t.Fatalf("expected a hex SHA-256, got %q", fp)
+    }
+
+    same := alert
+    same.Title, same.Hostname = "  PORT SCAN ", "Web-01" // Formatting differences are ignored
+    same.ID, same.Severity, same.Description, same.Timestamp = "other", "high", "ignored", time.Now()
+    if got := p.Fingerprint(same); got != fp {
+        t.Errorf("repeat with different formatting and unselected fields: got %s, want %s", got, fp)
+    }
+
+    other := alert
+    other.SourceIP = "10.0.0.2"
+    if p.Fingerprint(other) == fp {
+        t.Error("alerts from another source IP share the fingerprint")
+    }
+
+    // Values do not run into each other across fields
+    a := models.SecurityAlert{Source: "ab", Category: "c"}
+    b := models.SecurityAlert{Source: "a", Category: "bc"}
+    if p.Fingerprint(a) == p.Fingerprint(b) {
+        t.Error("shifting text between fields kept the fingerprint")
+    }
+
+    // Only the selected fields count
+    byUser, _ := NewPolicy([]string{"username"}, time.Minute, nil)
+    if byUser.Fingerprint(alert) != byUser.Fingerprint(other) {
+        t.Error("a field that is not selected changed the fingerprint")
+    }
+}
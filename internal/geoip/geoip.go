@@ -0,0 +1,168 @@
+package geoip
+
+import (
+    "fmt"
+    "net"
+    "net/netip"
+    "strings"
+
+    "github.com/oschwald/maxminddb-golang"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// reservedRanges are special-purpose blocks (RFC 6890 and friends) that are never
+// routed on the internet, apart from the private ranges reported by netip.
+var reservedRanges = []netip.Prefix{
+    netip.MustParsePrefix("0.0.0.0/8"),       // "This" network
+    netip.MustParsePrefix("100.64.0.0/10"),   // Carrier-grade NAT
+    netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+    netip.MustParsePrefix("192.0.2.0/24"),    // TEST-NET-1
+    netip.MustParsePrefix("198.18.0.0/15"),   // Benchmarking
+    netip.MustParsePrefix("198.51.100.0/24"), // TEST-NET-2
+    netip.MustParsePrefix("203.0.113.0/24"),  // TEST-NET-3
+    netip.MustParsePrefix("240.0.0.0/4"),     // Reserved, includes the broadcast address
+    netip.MustParsePrefix("64:ff9b:1::/48"),  // Local-use NAT64
+    netip.MustParsePrefix("100::/64"),        // Discard-only
+    netip.MustParsePrefix("2001::/23"),       // IETF protocol assignments
+    netip.MustParsePrefix("2001:db8::/32"),   // Documentation
+}
+
+// isReserved reports whether addr is loopback, link-local, multicast, unspecified
+// or in one of the reserved ranges.
+func isReserved(addr netip.Addr) bool {
+    if addr.IsLoopback() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+        addr.IsMulticast() || addr.IsUnspecified() {
+        return true
+    }
+    for _, p := range reservedRanges {
+        if p.Contains(addr) {
+            return true
+        }
+    }
+    return false
+}
+
+// record is the part of a MaxMind City, Country or ASN record the enricher reads.
+// Databases combining location and ASN data fill in both halves.
+type record struct {
+    Country struct {
+        ISOCode string            `maxminddb:"iso_code"`
+        Names   map[string]string `maxminddb:"names"`
+    } `maxminddb:"country"`
+    City struct {
+        Names map[string]string `maxminddb:"names"`
+    } `maxminddb:"city"`
+    Location struct {
+        Latitude  *float64 `maxminddb:"latitude"`
+        Longitude *float64 `maxminddb:"longitude"`
+    } `maxminddb:"location"`
+    ASN   uint32 `maxminddb:"autonomous_system_number"`
+    ASOrg string `maxminddb:"autonomous_system_organization"`
+}
+
+// Enricher looks up addresses in offline MaxMind-format (MMDB) databases: a City
+// or Country database for the location and an ASN database for the network owner.
+// It is safe for concurrent use.
+type Enricher struct {
+    readers []*maxminddb.Reader
+}
+
+// Open opens the given databases. Either path may be empty, and both may name the
+// same file for databases that carry location and ASN data together.
+func Open(cityPath, asnPath string) (*Enricher, error) {
+    paths := []string{cityPath}
+    if asnPath != cityPath {
+        paths = append(paths, asnPath)
+    }
+    e := &Enricher{}
+    for _, path := range paths {
+        if path == "" {
+            continue
+        }
+        reader, err := maxminddb.Open(path)
+        if err != nil {
+            e.Close()
+            return nil, fmt.Errorf("failed to open GeoIP database %s: %w", path, err)
+        }
+        e.readers = append(e.readers, reader)
+    }
+    if len(e.readers) == 0 {
+        return nil, fmt.Errorf("no GeoIP database configured")
+    }
+    return e, nil
+}
+
+// Close releases the databases.
+func (e *Enricher) Close() error {
+    var firstErr error
+    for _, r := range e.readers {
+        if err := r.Close(); err != nil && firstErr == nil {
+            firstErr = err
+        }
+    }
+    return firstErr
+}
+
+// Lookup describes an address. Private and reserved addresses are flagged and not
+// looked up. It returns nil if value is not an IP address.
+func (e *Enricher) Lookup(value string) (*models.GeoInfo, error) {
+    addr, err := netip.ParseAddr(strings.TrimSpace(value))
+    if err != nil {
+        return nil, nil
+    }
+    addr = addr.Unmap().WithZone("")
+
+    info := &models.GeoInfo{IP: addr.String()}
+    if addr.IsPrivate() {
+        info.Private = true
+        return info, nil
+    }
+    if isReserved(addr) {
+        info.Reserved = true
+        return info, nil
+    }
+
+    for _, reader := range e.readers {
+        var rec record
+        _, found, err := reader.LookupNetwork(net.IP(addr.AsSlice()), &rec)
+        if err != nil {
+            return nil, fmt.Errorf("GeoIP lookup of %s failed: %w", addr, err)
+        }
+        if !found {
+            continue
+        }
+        if info.Country == "" {
+            info.Country = strings.ToUpper(rec.Country.ISOCode)
+            info.CountryName = rec.Country.Names["en"]
+        }
+        if info.City == "" {
+            info.City = rec.City.Names["en"]
+        }
+        if info.Latitude == nil && rec.Location.Latitude != nil && rec.Location.Longitude != nil {
+            info.Latitude, info.Longitude = rec.Location.Latitude, rec.Location.Longitude
+        }
+        if info.ASN == 0 {
+            info.ASN, info.ASOrg = rec.ASN, rec.ASOrg
+        }
+    }
+    return info, nil
+}
+
+// Enrich records the location and network owner of the alert's source and target
+// addresses in its enrichment section. A failed lookup only loses that address's
+// information, so it is returned after the other address has been processed.
+func (e *Enricher) Enrich(alert *models.SecurityAlert) error {
+    source, sourceErr := e.Lookup(alert.SourceIP)
+    target, targetErr := e.Lookup(alert.TargetIP)
+    if source != nil || target != nil || alert.Enrichment != nil {
+        if alert.Enrichment == nil {
+            alert.Enrichment = &models.Enrichment{}
+        }
+        alert.Enrichment.SourceGeo, alert.Enrichment.TargetGeo = source, target
+    }
+    if sourceErr != nil {
+        return sourceErr
+    }
+    return targetErr
+}
@@ -0,0 +1,209 @@
+package geoip
+
+import (
+    "bytes"
+    "encoding/binary"
+    "math"
+    "net/netip"
+    "os"
+    "path/filepath"
+    "sort"
+    "testing"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// The helpers below write a minimal IPv4 MaxMind DB (24-bit records) so the tests
+// exercise the real reader without shipping binary fixtures.
+
+// encodeMMDB appends the MaxMind DB data section encoding of v.
+func encodeMMDB(buf *bytes.Buffer, v interface{}) {
+    control := func(typ, size int) {
+        if size >= 29+256 {
+            panic("test encoder only supports small values")
+        }
+        sizeBits, extra := size, -1
+        if size >= 29 {
+            sizeBits, extra = 29, size-29 // One extra size byte
+        }
+        if typ <= 7 {
+            buf.WriteByte(byte(typ<<5 | sizeBits))
+        } else {
+            buf.WriteByte(byte(sizeBits))
+            buf.WriteByte(byte(typ - 7))
+        }
+        if extra >= 0 {
+            buf.WriteByte(byte(extra))
+        }
+    }
+    uintBytes := func(n uint64) []byte {
+        b := make([]byte, 8)
+        binary.BigEndian.PutUint64(b, n)
+        return bytes.TrimLeft(b, "\x00")
+    }
+    switch v := v.(type) {
+    case string:
+        control(2, len(v))
+        buf.WriteString(v)
+    case float64:
+        control(3, 8)
+        binary.Write(buf, binary.BigEndian, math.Float64bits(v))
+    case uint16:
+        b := uintBytes(uint64(v))
+        control(5, len(b))
+        buf.Write(b)
+    case uint32:
+        b := uintBytes(uint64(v))
+        control(6, len(b))
+        buf.Write(b)
+    case uint64:
+        b := uintBytes(v)
+        control(9, len(b))
+        buf.Write(b)
+    case []interface{}:
+        control(11, len(v))
+        for _, item := range v {
+            encodeMMDB(buf, item)
+        }
+    case map[string]interface{}:
+        control(7, len(v))
+        keys := make([]string, 0, len(v))
+        for k := range v {
+            keys = append(keys, k)
+        }
+        sort.Strings(keys)
+        for _, k := range keys {
+            encodeMMDB(buf, k)
+            encodeMMDB(buf, v[k])
+        }
+    default:
+        panic("unsupported type")
+    }
+}
+
+type trieNode struct {
+    children [2]*trieNode
+    data     int // Offset into the data section + 1 for leaves, 0 for inner nodes
+}
+
+// writeTestDB writes an IPv4 database mapping each network to its record.
+func writeTestDB(t *testing.T, records map[string]map[string]interface{}) string {
+    t.Helper()
+    var data bytes.Buffer
+    root := &trieNode{}
+    for network, rec := range records {
+        offset := data.Len()
+        encodeMMDB(&data, rec)
+        prefix := netip.MustParsePrefix(network)
+        ip := prefix.Addr().As4()
+        node := root
+        for i := 0; i < prefix.Bits(); i++ {
+            bit := ip[i/8] >> (7 - i%8) & 1
+            if node.children[bit] == nil {
+                node.children[bit] = &trieNode{}
+            }
+            node = node.children[bit]
+        }
+        node.data = offset + 1
+    }
+
+    // Number the inner nodes, then write two 24-bit records per node
+    var inner []*trieNode
+    ids := make(map[*trieNode]int)
+    var walk func(n *trieNode)
+    walk = func(n *trieNode) {
+        ids[n] = len(inner)
+        inner = append(inner, n)
+        for _, c := range n.children {
+            if c != nil && c.data == 0 {
+                walk(c)
+            }
+        }
+    }
+    walk(root)
+    nodeCount := len(inner)
+
+    var file bytes.Buffer
+    for _, n := range inner {
+        for _, c := range n.children {
+            value := nodeCount // No data
+            if c != nil && c.data > 0 {
+                value = nodeCount + 16 + c.data - 1
+            } else if c != nil {
+                value = ids[c]
+            }
+            file.Write([]byte{byte(value >> 16), byte(value >> 8), byte(value)})
+        }
+    }
+    file.Write(make([]byte, 16)) // Data section separator
+    file.Write(data.Bytes())
+    file.WriteString("\xAB\xCD\xEFMaxMind.com")
+    encodeMMDB(&file, map[string]interface{}{
+        "node_count":                  uint32(nodeCount),
+        "record_size":                 uint16(24),
+        "ip_version":                  uint16(4),
+        "database_type":               "GuardianAI-Test",
+        "languages":                   []interface{}{"en"},
+        "binary_format_major_version": uint16(2),
+        "binary_format_minor_version": uint16(0),
+        "build_epoch":                 uint64(1700000000),
+        "description":                 map[string]interface{}{"en": "test"},
+    })
+
+    path := filepath.Join(t.TempDir(), "test.mmdb")
+    if err := os.WriteFile(path, file.Bytes(), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    return path
+}
+
+func TestEnrich(t *testing.T) {
+    city := writeTestDB(t, map[string]map[string]interface{}{
+        "8.8.8.0/24": {
+            "country":  map[string]interface{}{"iso_code": "US", "names": map[string]interface{}{"en": "United States"}},
+            "city":     map[string]interface{}{"names": map[string]interface{}{"en": "Mountain View"}},
+            "location": map[string]interface{}{"latitude": 37.4, "longitude": -122.1},
+        },
+        "81.2.69.0/24": {
+            "country": map[string]interface{}{"iso_code": "gb", "names": map[string]interface{}{"en": "United Kingdom"}},
+        },
+    })
+    asn := writeTestDB(t, map[string]map[string]interface{}{
+        "8.8.0.0/16": {"autonomous_system_number": uint32(15169), "autonomous_system_organization": "GOOGLE"},
+    })
+    e, err := Open(city, asn)
+    if err != nil {
+        t.Fatalf("Open: %v", err)
+    }
+    defer e.Close()
+
+    alert := models.SecurityAlert{SourceIP: "8.8.8.8", TargetIP: "192.168.1.10"}
+    if err := e.Enrich(&alert); err != nil {
+        t.Fatalf("Enrich: %v", err)
+    }
+    src := alert.Enrichment.SourceGeo
+    if src == nil || src.Country != "US" || src.CountryName != "United States" || src.City != "Mountain View" ||
+        src.ASN != 15169 || src.ASOrg != "GOOGLE" || src.Latitude == nil || *src.Latitude != 37.4 {
+        t.Errorf("source: got %+v", src)
+    }
+    if dst := alert.Enrichment.TargetGeo; dst == nil || !dst.Private || dst.Country != "" {
+        t.Errorf("target: got %+v, want a private address without location", dst)
+    }
+
+    for value, check := range map[string]func(g *models.GeoInfo) bool{
+        "81.2.69.160":    func(g *models.GeoInfo) bool { return g.Country == "GB" && g.ASN == 0 },
+        "203.0.113.5":    func(g *models.GeoInfo) bool { return g.Reserved && !g.Private },
+        "127.0.0.1":      func(g *models.GeoInfo) bool { return g.Reserved },
+        "fd00::1":        func(g *models.GeoInfo) bool { return g.Private },
+        "1.1.1.1":        func(g *models.GeoInfo) bool { return g.Country == "" && !g.Private && !g.Reserved },
+        "::ffff:8.8.8.8": func(g *models.GeoInfo) bool { return g.IP == "8.8.8.8" && g.Country == "US" },
+    } {
+        geo, err := e.Lookup(value)
+        if err != nil || geo == nil || !check(geo) {
+            t.Errorf("Lookup(%s): got %+v, %v", value, geo, err)
+        }
+    }
+    if geo, err := e.Lookup("not-an-ip"); geo != nil || err != nil {
+        t.Errorf("Lookup(not-an-ip): got %+v, %v, want nil, nil", geo, err)
+    }
+}
@@ -0,0 +1,382 @@
+package ingest
+
+import (
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// Media types accepted by POST /alerts besides JSON.
+const (
+    MediaTypeCEF  = "text/cef"
+    MediaTypeLEEF = "text/leef"
+)
+
+// cefFields maps the CEF extension keys with an alert field of their own, by both
+// their short and full ArcSight names, to that field. Other extensions end up in the
+// alert's attributes.
+var cefFields = map[string]func(a *models.SecurityAlert) *string{
+    "src":                 func(a *models.SecurityAlert) *string { return &a.SourceIP },
+    "sourceAddress":       func(a *models.SecurityAlert) *string { return &a.SourceIP },
+    "dst":                 func(a *models.SecurityAlert) *string { return &a.TargetIP },
+    "destinationAddress":  func(a *models.SecurityAlert) *string { return &a.TargetIP },
+    "shost":               func(a *models.SecurityAlert) *string { return &a.Hostname },
+    "sourceHostName":      func(a *models.SecurityAlert) *string { return &a.Hostname },
+    "suser":               func(a *models.SecurityAlert) *string { return &a.Username },
+    "sourceUserName":      func(a *models.SecurityAlert) *string { return &a.Username },
+    "fileHash":            func(a *models.SecurityAlert) *string { return &a.FileHash },
+    "cat":                 func(a *models.SecurityAlert) *string { return &a.Category },
+    "deviceEventCategory": func(a *models.SecurityAlert) *string { return &a.Category },
+    "msg":                 func(a *models.SecurityAlert) *string { return &a.Description },
+    "message":             func(a *models.SecurityAlert) *string { return &a.Description },
+}
+
+// cefTimestampKeys hold the event time, in order of preference.
+var cefTimestampKeys = []string{"rt", "deviceReceiptTime", "end", "endTime", "start", "startTime"}
+
+// cefTimeLayouts are the date formats CEF allows besides milliseconds since the epoch.
+var cefTimeLayouts = []string{
+    "Jan 02 2006 15:04:05.000 MST",
+    "Jan 02 2006 15:04:05.000",
+    "Jan 02 2006 15:04:05 MST",
+    "Jan 02 2006 15:04:05",
+    "Jan 02 15:04:05.000 MST",
+    "Jan 02 15:04:05.000",
+    "Jan 02 15:04:05 MST",
+    "Jan 02 15:04:05",
+    time.RFC3339Nano,
+}
+
+// isSIEMEvent reports whether s is a CEF or LEEF event.
+func isSIEMEvent(s string) bool {
+    return strings.HasPrefix(s, "CEF:") || strings.HasPrefix(s, "LEEF:")
+}
+
+// eventStart returns the position of the event prefix in s: at the start, or after a
+// space ending a syslog header. It returns -1 if there is none.
+func eventStart(s, prefix string) int {
+    for i := 0; ; {
+        j := strings.Index(s[i:], prefix)
+        if j < 0 {
+            return -1
+        }
+        if i+j == 0 || s[i+j-1] == ' ' {
+            return i + j
+        }
+        i += j + len(prefix)
+    }
+}
+
+// ParseCEF parses an ArcSight Common Event Format event:
+// CEF:Version|Device Vendor|Device Product|Device Version|Signature ID|Name|Severity|Extension
+// Header fields without an alert field, and unmapped extensions, are kept in the
+// alert's attributes. The custom string extensions cs1 to cs6 are stored under
+// their label (cs1Label, ...), if they have one.
+func ParseCEF(s string) (*models.SecurityAlert, error) {
+    start := eventStart(s, "CEF:")
+    if start < 0 {
+        return nil, fmt.Errorf("not a CEF event")
+    }
+    header, ext, err := splitHeader(strings.TrimRight(s[start+len("CEF:"):], "\r\n"), 7)
+    if err != nil {
+        return nil, fmt.Errorf("invalid CEF header: %w", err)
+    }
+    if header[1] == "" && header[2] == "" {
+        return nil, fmt.Errorf("invalid CEF header: no device vendor or product")
+    }
+
+    alert := &models.SecurityAlert{
+        Source:   strings.TrimSpace(header[1] + " " + header[2]),
+        Title:    header[5],
+        Severity: cefSeverity(header[6]),
+        Attributes: map[string]string{
+            "deviceVendor":  header[1],
+            "deviceProduct": header[2],
+            "deviceVersion": header[3],
+            "signatureId":   header[4],
+        },
+    }
+    if alert.Title == "" {
+        alert.Title = header[4]
+    }
+
+    extensions := parseCEFExtensions(ext)
+    keys := make([]string, 0, len(extensions))
+    for key := range extensions {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys) // Deterministic when both the short and the full name of a key are present
+    for _, key := range keys {
+        value := extensions[key]
+        if field, ok := cefFields[key]; ok {
+            if target := field(alert); *target == "" {
+                *target = value
+            }
+            continue
+        }
+        if strings.HasSuffix(key, "Label") && len(key) == len("cs1Label") && strings.HasPrefix(key, "cs") {
+            continue // Consumed together with its value below
+        }
+        if len(key) == 3 && strings.HasPrefix(key, "cs") && key[2] >= '1' && key[2] <= '6' {
+            if label := extensions[key+"Label"]; label != "" {
+                key = label
+            }
+        }
+        alert.Attributes[key] = value
+    }
+    for _, key := range cefTimestampKeys {
+        if ts, ok := parseSIEMTime(extensions[key]); ok {
+            alert.Timestamp = ts
+            break
+        }
+    }
+    if alert.Category == "" {
+        alert.Category = "cef"
+    }
+    alert.RawPayload = rawText(s)
+    removeEmpty(alert.Attributes)
+    return alert, nil
+}
+
+// ParseLEEF parses an IBM QRadar Log Event Extended Format event, version 1.0 or 2.0:
+// LEEF:Version|Vendor|Product|Version|EventID|[Delimiter|]Attributes
+// LEEF 1.0 separates attributes with tabs; 2.0 names its delimiter, as a character
+// or in hex (x09).
+func ParseLEEF(s string) (*models.SecurityAlert, error) {
+    start := eventStart(s, "LEEF:")
+    if start < 0 {
+        return nil, fmt.Errorf("not a LEEF event")
+    }
+    body := strings.TrimRight(s[start+len("LEEF:"):], "\r\n")
+    header, rest, err := splitHeader(body, 5)
+    if err != nil {
+        return nil, fmt.Errorf("invalid LEEF header: %w", err)
+    }
+    delimiter := "\t"
+    if strings.HasPrefix(header[0], "2") {
+        d, after, ok := strings.Cut(rest, "|")
+        if !ok {
+            return nil, fmt.Errorf("invalid LEEF 2.0 header: no delimiter field")
+        }
+        rest = after
+        if d, err = leefDelimiter(d); err != nil {
+            return nil, err
+        }
+        if d != "" {
+            delimiter = d
+        }
+    }
+
+    alert := &models.SecurityAlert{
+        Source: strings.TrimSpace(header[1] + " " + header[2]),
+        Title:  strings.TrimSpace(header[2] + ": " + header[4]),
+        Attributes: map[string]string{
+            "deviceVendor":  header[1],
+            "deviceProduct": header[2],
+            "deviceVersion": header[3],
+            "eventId":       header[4],
+        },
+    }
+    for _, pair := range strings.Split(rest, delimiter) {
+        key, value, ok := strings.Cut(pair, "=")
+        if key = strings.TrimSpace(key); !ok || key == "" {
+            continue
+        }
+        switch key {
+        case "src":
+            alert.SourceIP = value
+        case "dst":
+            alert.TargetIP = value
+        case "usrName":
+            alert.Username = value
+        case "identHostName", "srcHostName":
+            alert.Hostname = value
+        case "cat":
+            alert.Category = value
+        case "sev":
+            alert.Severity = cefSeverity(value)
+        case "devTime":
+            if ts, ok := parseSIEMTime(value); ok {
+                alert.Timestamp = ts
+                continue
+            }
+            alert.Attributes[key] = value
+        default:
+            alert.Attributes[key] = value
+        }
+    }
+    if alert.Severity == "" {
+        alert.Severity = "medium" // LEEF has no severity in its header
+    }
+    if alert.Category == "" {
+        alert.Category = "leef"
+    }
+    alert.RawPayload = rawText(s)
+    removeEmpty(alert.Attributes)
+    return alert, nil
+}
+
+// splitHeader splits the first n pipe-separated header fields off s, resolving the
+// \| and \\ escapes, and returns them with the unparsed rest.
+func splitHeader(s string, n int) ([]string, string, error) {
+    fields := make([]string, 0, n)
+    var b strings.Builder
+    for i := 0; i < len(s); i++ {
+        switch c := s[i]; {
+        case c == '\\' && i+1 < len(s) && (s[i+1] == '|' || s[i+1] == '\\'):
+            i++
+            b.WriteByte(s[i])
+        case c == '|':
+            fields = append(fields, b.String())
+            b.Reset()
+            if len(fields) == n {
+                return fields, s[i+1:], nil
+            }
+        default:
+            b.WriteByte(c)
+        }
+    }
+    if len(fields) == n-1 {
+        // The last header field is not followed by a pipe when there are no extensions
+        return append(fields, b.String()), "", nil
+    }
+    return nil, "", fmt.Errorf("expected %d fields, got %d", n, len(fields)+1)
+}
+
+// parseCEFExtensions parses the space-separated key=value pairs of a CEF extension.
+// Values may contain spaces: a value runs up to the space before the next unescaped
+// "key=". The \= \\ \n and \r escapes are resolved.
+func parseCEFExtensions(s string) map[string]string {
+    extensions := make(map[string]string)
+    var key string
+    var value strings.Builder
+    flush := func() {
+        if key != "" {
+            extensions[key] = strings.TrimRight(value.String(), " ")
+        }
+        value.Reset()
+    }
+    for i := 0; i < len(s); {
+        // A new pair starts at the beginning, or after a space, with "key=".
+        if i == 0 || s[i-1] == ' ' {
+            if k, ok := cefKeyAt(s[i:]); ok {
+                flush()
+                key = k
+                i += len(k) + 1
+                continue
+            }
+        }
+        c := s[i]
+        if c == '\\' && i+1 < len(s) {
+            switch s[i+1] {
+            case '=', '\\':
+                value.WriteByte(s[i+1])
+                i += 2
+                continue
+            case 'n':
+                value.WriteByte('\n')
+                i += 2
+                continue
+            case 'r':
+                value.WriteByte('\r')
+                i += 2
+                continue
+            }
+        }
+        if key != "" {
+            value.WriteByte(c)
+        }
+        i++
+    }
+    flush()
+    return extensions
+}
+
+// cefKeyAt returns the extension key s starts with, if s starts with "key=".
+func cefKeyAt(s string) (string, bool) {
+    for i := 0; i < len(s); i++ {
+        c := s[i]
+        switch {
+        case c == '=':
+            return s[:i], i > 0
+        case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '.', c == '-', c == '[', c == ']':
+        default:
+            return "", false
+        }
+    }
+    return "", false
+}
+
+// cefSeverity maps a CEF or LEEF severity, 0-10 or a keyword, to an alert severity.
+func cefSeverity(s string) string {
+    if n, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+        switch {
+        case n >= 9:
+            return "critical"
+        case n >= 7:
+            return "high"
+        case n >= 4:
+            return "medium"
+        default:
+            return "low"
+        }
+    }
+    switch strings.ToLower(strings.TrimSpace(s)) {
+    case "very-high", "very high", "critical":
+        return "critical"
+    case "high":
+        return "high"
+    case "medium":
+        return "medium"
+    default:
+        return "low" // "Low" and "Unknown"
+    }
+}
+
+// parseSIEMTime parses a CEF or LEEF timestamp: milliseconds since the epoch, or one
+// of the date formats in cefTimeLayouts. Dates without a year fall in the current one.
+func parseSIEMTime(s string) (time.Time, bool) {
+    s = strings.TrimSpace(s)
+    if s == "" {
+        return time.Time{}, false
+    }
+    if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+        return time.UnixMilli(ms).UTC(), true
+    }
+    for _, layout := range cefTimeLayouts {
+        if ts, err := time.Parse(layout, s); err == nil {
+            if ts.Year() == 0 {
+                ts = ts.AddDate(time.Now().Year(), 0, 0)
+            }
+            return ts, true
+        }
+    }
+    return time.Time{}, false
+}
+
+// leefDelimiter decodes the delimiter field of a LEEF 2.0 header: a single
+// character, or its code in hex such as x09 or 0x5E.
+func leefDelimiter(d string) (string, error) {
+    if len(d) <= 1 {
+        return d, nil
+    }
+    hex := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(d), "0"), "x")
+    n, err := strconv.ParseUint(hex, 16, 8)
+    if err != nil || n == 0 {
+        return "", fmt.Errorf("invalid LEEF delimiter %q", d)
+    }
+    return string(rune(n)), nil
+}
+
+// removeEmpty drops attributes without a value.
+func removeEmpty(attributes map[string]string) {
+    for k, v := range attributes {
+        if v == "" {
+            delete(attributes, k)
+        }
+    }
+}
@@ -0,0 +1,330 @@
+package ingest
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+
+    "gopkg.in/yaml.v3"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// HECEvent is one event envelope of the Splunk HTTP Event Collector protocol. Event
+// is either a JSON object or a string; numbers are kept as json.Number.
+type HECEvent struct {
+    Time       interface{}            `json:"time,omitempty"` // Seconds since the epoch, as a number or a string
+    Host       string                 `json:"host,omitempty"`
+    Source     string                 `json:"source,omitempty"`
+    SourceType string                 `json:"sourcetype,omitempty"`
+    Index      string                 `json:"index,omitempty"`
+    Event      interface{}            `json:"event"`
+    Fields     map[string]interface{} `json:"fields,omitempty"` // Indexed fields
+}
+
+// Errors returned by HECMapping.Alert for envelopes without a usable event.
+var (
+    ErrHECEventRequired = errors.New("event field is required")
+    ErrHECEventBlank    = errors.New("event field cannot be blank")
+)
+
+// hecAlertFields maps the alert fields a HEC mapping can fill to the fields.
+var hecAlertFields = map[string]func(a *models.SecurityAlert) *string{
+    "source":      func(a *models.SecurityAlert) *string { return &a.Source },
+    "severity":    func(a *models.SecurityAlert) *string { return &a.Severity },
+    "category":    func(a *models.SecurityAlert) *string { return &a.Category },
+    "title":       func(a *models.SecurityAlert) *string { return &a.Title },
+    "description": func(a *models.SecurityAlert) *string { return &a.Description },
+    "source_ip":   func(a *models.SecurityAlert) *string { return &a.SourceIP },
+    "target_ip":   func(a *models.SecurityAlert) *string { return &a.TargetIP },
+    "hostname":    func(a *models.SecurityAlert) *string { return &a.Hostname },
+    "username":    func(a *models.SecurityAlert) *string { return &a.Username },
+    "file_hash":   func(a *models.SecurityAlert) *string { return &a.FileHash },
+}
+
+// defaultHECMapping lists, per alert field, the event paths tried in order. Paths
+// start with "event." or "fields.", or name an envelope key (host, source, sourcetype,
+// index, time).
+var defaultHECMapping = map[string][]string{
+    "timestamp":   {"time", "event.timestamp", "event.@timestamp"},
+    "source":      {"event.source", "source", "sourcetype"},
+    "severity":    {"event.severity", "event.level", "fields.severity"},
+    "category":    {"event.category", "fields.category", "sourcetype"},
+    "title":       {"event.title", "event.signature", "event.name", "event.message", "event.msg"},
+    "description": {"event.description", "event.message", "event.msg"},
+    "source_ip":   {"event.source_ip", "event.src_ip", "event.src"},
+    "target_ip":   {"event.target_ip", "event.dest_ip", "event.dest", "event.dst"},
+    "hostname":    {"event.hostname", "event.host", "host"},
+    "username":    {"event.username", "event.user"},
+    "file_hash":   {"event.file_hash", "event.hash"},
+}
+
+// HECMapping translates HEC events into alerts. Every alert field takes the first
+// non-empty value among its paths; the event and indexed fields no path consumed are
+// kept as attributes.
+type HECMapping struct {
+    Fields map[string][]string // Alert field (or "timestamp") -> event paths
+}
+
+// DefaultHECMapping returns the built-in mapping.
+func DefaultHECMapping() *HECMapping {
+    m := &HECMapping{Fields: make(map[string][]string, len(defaultHECMapping))}
+    for field, paths := range defaultHECMapping {
+        m.Fields[field] = append([]string(nil), paths...)
+    }
+    return m
+}
+
+// hecMappingFile is the format of the mapping file, replacing the paths of the
+// fields it names:
+//
+//	fields:
+//	  title: [event.rule.name, event.message]
+//	  username: [event.actor.login]
+type hecMappingFile struct {
+    Fields map[string][]string `yaml:"fields"`
+}
+
+// LoadHECMapping returns the default mapping with the fields named in the YAML file
+// at path replaced. An empty path returns the default mapping.
+func LoadHECMapping(path string) (*HECMapping, error) {
+    m := DefaultHECMapping()
+    if path == "" {
+        return m, nil
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read HEC field mapping: %w", err)
+    }
+    var file hecMappingFile
+    if err := yaml.Unmarshal(data, &file); err != nil {
+        return nil, fmt.Errorf("failed to parse HEC field mapping %s: %w", path, err)
+    }
+    for field, paths := range file.Fields {
+        field = strings.ToLower(strings.TrimSpace(field))
+        if _, ok := hecAlertFields[field]; !ok && field != "timestamp" {
+            return nil, fmt.Errorf("HEC field mapping %s: unknown alert field %q", path, field)
+        }
+        for _, p := range paths {
+            if !validHECPath(p) {
+                return nil, fmt.Errorf("HEC field mapping %s: invalid path %q for %s", path, p, field)
+            }
+        }
+        m.Fields[field] = paths
+    }
+    return m, nil
+}
+
+// validHECPath reports whether p names an envelope key or a path into the event or
+// its indexed fields.
+func validHECPath(p string) bool {
+    switch p {
+    case "time", "host", "source", "sourcetype", "index":
+        return true
+    }
+    for _, prefix := range []string{"event.", "fields."} {
+        if strings.HasPrefix(p, prefix) && len(p) > len(prefix) {
+            return true
+        }
+    }
+    return false
+}
+
+// Alert builds the alert for an event. String events become the description, and
+// their first line the title, unless they are CEF or LEEF events, which are parsed
+// as such.
+func (m *HECMapping) Alert(e *HECEvent) (*models.SecurityAlert, error) {
+    if e.Event == nil {
+        return nil, ErrHECEventRequired
+    }
+    text, isText := e.Event.(string)
+    if isText {
+        text = strings.TrimSpace(text)
+        if text == "" {
+            return nil, ErrHECEventBlank
+        }
+        if isSIEMEvent(text) {
+            return m.siemAlert(e, text)
+        }
+    }
+
+    alert := &models.SecurityAlert{}
+    consumed := make(map[string]bool) // Paths whose value went into an alert field
+    for field, paths := range m.Fields {
+        for _, p := range paths {
+            value, ok := hecString(e.lookup(p))
+            if !ok || value == "" {
+                continue
+            }
+            if field == "timestamp" {
+                ts, ok := parseHECTime(value)
+                if !ok {
+                    continue
+                }
+                alert.Timestamp = ts
+            } else {
+                *hecAlertFields[field](alert) = value
+            }
+            consumed[p] = true
+            break
+        }
+    }
+
+    if isText {
+        if alert.Description == "" {
+            alert.Description = text
+        }
+        if alert.Title == "" {
+            alert.Title, _, _ = strings.Cut(text, "\n")
+        }
+    }
+    alert.Attributes = e.attributes(consumed)
+    if alert.Source == "" {
+        alert.Source = "hec"
+    }
+    if alert.Title == "" {
+        alert.Title = alert.Source + " event"
+    }
+    if r := []rune(alert.Title); len(r) > maxTitleLength {
+        alert.Title = string(r[:maxTitleLength])
+    }
+    if alert.Category == "" {
+        alert.Category = "hec"
+    }
+    if alert.Severity == "" {
+        alert.Severity = "medium"
+    }
+    alert.Severity = strings.ToLower(alert.Severity)
+    alert.RawPayload = e.raw()
+    return alert, nil
+}
+
+// siemAlert parses a CEF or LEEF event sent through HEC; the envelope fills in the
+// host and time the event lacks.
+func (m *HECMapping) siemAlert(e *HECEvent, text string) (*models.SecurityAlert, error) {
+    parse := ParseCEF
+    if strings.HasPrefix(text, "LEEF:") {
+        parse = ParseLEEF
+    }
+    alert, err := parse(text)
+    if err != nil {
+        return nil, err
+    }
+    if alert.Hostname == "" {
+        alert.Hostname = e.Host
+    }
+    if value, ok := hecString(e.Time); ok && alert.Timestamp.IsZero() {
+        alert.Timestamp, _ = parseHECTime(value)
+    }
+    alert.RawPayload = e.raw()
+    return alert, nil
+}
+
+// raw returns the envelope, to be kept as the alert's raw payload.
+func (e *HECEvent) raw() json.RawMessage {
+    data, err := json.Marshal(e)
+    if err != nil {
+        return nil // Decoded from JSON, so it encodes again
+    }
+    return data
+}
+
+// lookup returns the value at path p, or nil.
+func (e *HECEvent) lookup(p string) interface{} {
+    switch p {
+    case "time":
+        return e.Time
+    case "host":
+        return e.Host
+    case "source":
+        return e.Source
+    case "sourcetype":
+        return e.SourceType
+    case "index":
+        return e.Index
+    }
+    var v interface{}
+    if rest, ok := strings.CutPrefix(p, "event."); ok {
+        v, p = e.Event, rest
+    } else if rest, ok := strings.CutPrefix(p, "fields."); ok {
+        v, p = map[string]interface{}(e.Fields), rest
+    } else {
+        return nil
+    }
+    for _, key := range strings.Split(p, ".") {
+        obj, ok := v.(map[string]interface{})
+        if !ok {
+            return nil
+        }
+        v = obj[key]
+    }
+    return v
+}
+
+// attributes collects the top-level event keys, the indexed fields, and the
+// sourcetype and index, except those consumed by the mapping.
+func (e *HECEvent) attributes(consumed map[string]bool) map[string]string {
+    attributes := make(map[string]string)
+    add := func(path, key string, v interface{}) {
+        if consumed[path] || v == nil {
+            return
+        }
+        if s, ok := hecString(v); ok {
+            if s != "" {
+                attributes[key] = s
+            }
+            return
+        }
+        if data, err := json.Marshal(v); err == nil {
+            attributes[key] = string(data) // Nested objects and arrays, as JSON
+        }
+    }
+    if obj, ok := e.Event.(map[string]interface{}); ok {
+        for key, v := range obj {
+            add("event."+key, key, v)
+        }
+    }
+    for key, v := range e.Fields {
+        if _, taken := attributes[key]; !taken {
+            add("fields."+key, key, v)
+        }
+    }
+    add("sourcetype", "sourcetype", e.SourceType)
+    add("index", "index", e.Index)
+    if len(attributes) == 0 {
+        return nil
+    }
+    return attributes
+}
+
+// hecString converts a scalar JSON value to a string.
+func hecString(v interface{}) (string, bool) {
+    switch v := v.(type) {
+    case string:
+        return strings.TrimSpace(v), true
+    case json.Number:
+        return v.String(), true
+    case float64:
+        return strconv.FormatFloat(v, 'f', -1, 64), true
+    case bool:
+        return strconv.FormatBool(v), true
+    }
+    return "", false
+}
+
+// parseHECTime parses seconds since the epoch, with optional fraction, or an RFC 3339
+// timestamp.
+func parseHECTime(s string) (time.Time, bool) {
+    if secs, err := strconv.ParseFloat(s, 64); err == nil && secs > 0 {
+        whole := int64(secs)
+        return time.Unix(whole, int64((secs-float64(whole))*1e9)).Round(time.Millisecond).UTC(), true
+    }
+    if ts, err := time.Parse(time.RFC3339Nano, s); err == nil {
+        return ts, true
+    }
+    return time.Time{}, false
+}
@@ -0,0 +1,122 @@
+package ingest
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "strings"
+    "sync/atomic"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/dedup"
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+    "github.com/Kelvinkhyd/GuardianAI/internal/repository"
+)
+
+// Service stores incoming alerts and queues them for analysis. Every ingestion path,
+// the HTTP API as well as the syslog listeners, goes through it, so alerts get the
+// same IDs, defaults and deduplication however they arrived.
+// Alerts reach Kafka through the transactional outbox written by the repository,
+// so nothing here talks to Kafka directly.
+type Service struct {
+    repo  repository.AlertRepository
+    dedup *dedup.Policy // nil disables deduplication
+}
+
+// NewService creates a Service storing alerts in repo. If dedupPolicy is not nil,
+// repeated alerts are folded into the first occurrence.
+func NewService(repo repository.AlertRepository, dedupPolicy *dedup.Policy) *Service {
+    return &Service{repo: repo, dedup: dedupPolicy}
+}
+
+// lastAlertIDNanos is the timestamp used for the most recently generated alert ID.
+var lastAlertIDNanos int64
+
+// NewAlertID returns an ID for alerts submitted without one. IDs are based on the
+// current time in nanoseconds but are guaranteed unique even when many alerts are
+// created within the same clock tick, as happens in batch ingestion.
+func NewAlertID() string {
+    for {
+        last := atomic.LoadInt64(&lastAlertIDNanos)
+        now := time.Now().UnixNano()
+        if now <= last {
+            now = last + 1
+        }
+        if atomic.CompareAndSwapInt64(&lastAlertIDNanos, last, now) {
+            return fmt.Sprintf("alert-%d", now)
+        }
+    }
+}
+
+// rawText returns a text event, e.g. a syslog line, as a JSON string to be kept as
+// the alert's raw payload.
+func rawText(s string) json.RawMessage {
+    var buf bytes.Buffer
+    enc := json.NewEncoder(&buf)
+    enc.SetEscapeHTML(false) // Keep <PRI> readable
+    enc.Encode(strings.TrimRight(s, "\r\n\x00")) // Strings always encode
+    return bytes.TrimRight(buf.Bytes(), "\n")
+}
+
+// Prepare fills in the server-controlled fields of an incoming alert.
+func (s *Service) Prepare(alert *models.SecurityAlert) {
+    if alert.ID == "" {
+        alert.ID = NewAlertID()
+    }
+    alert.Status = models.StatusNew
+    // If the client provides a timestamp, use it. Otherwise, set it to now.
+    if alert.Timestamp.IsZero() {
+        alert.Timestamp = time.Now()
+    }
+    alert.OccurrenceCount = 1
+    alert.LastSeen = alert.Timestamp
+    alert.Fingerprint = ""
+    if s.dedup != nil {
+        alert.Fingerprint = s.dedup.Fingerprint(*alert)
+    }
+}
+
+// Submit prepares one alert and stores it for tenant. The repository queues it in the
+// outbox in the same transaction, and the outbox relay publishes it to Kafka for
+// processing. With deduplication enabled, repeats of a recent alert are folded into
+// it instead of being stored and analysed again.
+func (s *Service) Submit(ctx context.Context, tenant string, alert *models.SecurityAlert) (*repository.FoldResult, error) {
+    s.Prepare(alert)
+    if s.dedup == nil {
+        if err := s.repo.CreateAlert(ctx, tenant, alert); err != nil {
+            return nil, err
+        }
+        return &repository.FoldResult{AlertID: alert.ID, OccurrenceCount: 1}, nil
+    }
+    return s.repo.CreateOrFoldAlert(ctx, tenant, alert, s.dedup.Window, s.dedup.EscalateAt)
+}
+
+// SubmitAll prepares alerts and stores them for tenant like Submit, atomically: if an
+// error is returned nothing was stored, so the sender can safely retry. Alerts whose
+// ID the tenant already has are skipped and get a nil result.
+func (s *Service) SubmitAll(ctx context.Context, tenant string, alerts []models.SecurityAlert) ([]*repository.FoldResult, error) {
+    for i := range alerts {
+        s.Prepare(&alerts[i])
+    }
+    if s.dedup != nil {
+        return s.repo.CreateOrFoldAlerts(ctx, tenant, alerts, s.dedup.Window, s.dedup.EscalateAt)
+    }
+
+    inserted, err := s.repo.CreateAlerts(ctx, tenant, alerts)
+    if err != nil {
+        return nil, err
+    }
+    isInserted := make(map[string]bool, len(inserted))
+    for _, id := range inserted {
+        isInserted[id] = true
+    }
+    results := make([]*repository.FoldResult, len(alerts))
+    for i, alert := range alerts {
+        if isInserted[alert.ID] {
+            results[i] = &repository.FoldResult{AlertID: alert.ID, OccurrenceCount: 1}
+            delete(isInserted, alert.ID) // A repeated ID was only inserted once
+        }
+    }
+    return results, nil
+}
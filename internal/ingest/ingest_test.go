@@ -0,0 +1,307 @@
+package ingest
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+    "github.com/Kelvinkhyd/GuardianAI/internal/repository"
+)
+
+func TestParseSyslog(t *testing.T) {
+    now := time.Date(2025, 1, 1, 0, 30, 0, 0, time.UTC)
+
+    msg, err := ParseSyslog([]byte(`<165>1 2025-06-01T12:00:00.5Z fw01.example suricata 4242 ID47 [meta sev="high" note="a \"quoted\" \] value"][origin ip="10.0.0.9"] `+"\ufeff"+`ET SCAN Nmap from 203.0.113.7`+"\n"), now)
+    if err != nil {
+        t.Fatalf("RFC 5424: %v", err)
+    }
+    if !msg.RFC5424 || msg.Facility != 20 || msg.Severity != SyslogNotice || msg.Hostname != "fw01.example" ||
+        msg.AppName != "suricata" || msg.ProcID != "4242" || msg.MsgID != "ID47" || msg.Message != "ET SCAN Nmap from 203.0.113.7" {
+        t.Errorf("RFC 5424: unexpected message %+v", msg)
+    }
+    if !msg.Timestamp.Equal(time.Date(2025, 6, 1, 12, 0, 0, 5e8, time.UTC)) {
+        t.Errorf("RFC 5424: timestamp %v", msg.Timestamp)
+    }
+    if msg.StructuredData["meta"]["note"] != `a "quoted" ] value` || msg.StructuredData["origin"]["ip"] != "10.0.0.9" {
+        t.Errorf("RFC 5424: structured data %v", msg.StructuredData)
+    }
+
+    msg, err = ParseSyslog([]byte("<14>1 - - - - - -"), now)
+    if err != nil || msg.Hostname != "" || msg.Message != "" || !msg.Timestamp.IsZero() {
+        t.Errorf("RFC 5424 with nil values: %+v, %v", msg, err)
+    }
+
+    // RFC 3164: a December timestamp received in January belongs to the previous year
+    msg, err = ParseSyslog([]byte("<38>Dec 31 23:59:58 bastion sshd[812]: Failed password for invalid user admin from 198.51.100.4 port 22 ssh2"), now)
+    if err != nil {
+        t.Fatalf("RFC 3164: %v", err)
+    }
+    if msg.RFC5424 || msg.Facility != 4 || msg.Severity != SyslogInfo || msg.Hostname != "bastion" || msg.AppName != "sshd" || msg.ProcID != "812" {
+        t.Errorf("RFC 3164: unexpected message %+v", msg)
+    }
+    if !msg.Timestamp.Equal(time.Date(2024, 12, 31, 23, 59, 58, 0, time.UTC)) {
+        t.Errorf("RFC 3164: timestamp %v", msg.Timestamp)
+    }
+
+    // Without hostname, as many appliances send it
+    msg, err = ParseSyslog([]byte("<12>Jan  1 00:10:00 kernel: link down"), now)
+    if err != nil || msg.Hostname != "" || msg.AppName != "kernel" || msg.Message != "link down" {
+        t.Errorf("RFC 3164 without hostname: %+v, %v", msg, err)
+    }
+
+    for _, bad := range []string{"", "no pri", "<999>1 - - - - - -", "<13>1 yesterday host app - - -", `<13>1 - h a - - [sd x="unterminated]`} {
+        if _, err := ParseSyslog([]byte(bad), now); err == nil {
+            t.Errorf("expected %q to be rejected", bad)
+        }
+    }
+}
+
+func TestSyslogMapping(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "mapping.yaml")
+    os.WriteFile(path, []byte(`
+severities:
+  warning: critical
+  6: medium
+category: firewall
+extract:
+  - field: username
+    pattern: 'login=(\S+)'
+`), 0o644)
+    m, err := LoadSyslogMapping(path)
+    if err != nil {
+        t.Fatalf("LoadSyslogMapping: %v", err)
+    }
+
+    alert := m.Alert(&SyslogMessage{
+        Severity: SyslogWarning,
+        Hostname: "fw01",
+        AppName:  "asa",
+        Message:  "Deny tcp src=203.0.113.7 dst=10.0.0.5 login=jdoe user=ignored\nsecond line",
+    })
+    if alert.Severity != "critical" || alert.Source != "asa" || alert.Hostname != "fw01" || alert.Category != "firewall" {
+        t.Errorf("unexpected alert %+v", alert)
+    }
+    if alert.SourceIP != "203.0.113.7" || alert.TargetIP != "10.0.0.5" || alert.Username != "jdoe" {
+        t.Errorf("extraction: got source %q, target %q, user %q", alert.SourceIP, alert.TargetIP, alert.Username)
+    }
+    if alert.Title != "Deny tcp src=203.0.113.7 dst=10.0.0.5 login=jdoe user=ignored" {
+        t.Errorf("title %q", alert.Title)
+    }
+
+    // Values that are not addresses are not taken for one
+    alert = m.Alert(&SyslogMessage{Severity: SyslogInfo, Message: "src=gateway.example"})
+    if alert.SourceIP != "" || alert.Severity != "medium" || alert.Source != "syslog" {
+        t.Errorf("unexpected alert %+v", alert)
+    }
+
+    for _, bad := range []string{"severities: {loud: high}", "extract: [{field: severity, pattern: '(x)'}]", "extract: [{field: username, pattern: 'x'}]"} {
+        os.WriteFile(path, []byte(bad), 0o644)
+        if _, err := LoadSyslogMapping(path); err == nil {
+            t.Errorf("expected mapping %q to be rejected", bad)
+        }
+    }
+}
+
+func TestSyslogServer(t *testing.T) {
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+
+    repo := repository.NewMemoryRepository()
+    s := NewSyslogServer(NewService(repo, nil), DefaultSyslogMapping(), "acme")
+    udpAddr, err := s.ListenUDP("127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    tcpAddr, err := s.ListenTCP("127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    go s.Run(ctx)
+
+    udp, err := net.Dial("udp", udpAddr.String())
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer udp.Close()
+    fmt.Fprint(udp, "<11>1 2025-06-01T12:00:00Z web01 nginx - - - udp message")
+
+    tcp, err := net.Dial("tcp", tcpAddr.String())
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer tcp.Close()
+    framed := "<13>Jun  1 12:00:01 db01 postgres: octet-counted message"
+    fmt.Fprintf(tcp, "%d %s", len(framed), framed)
+    fmt.Fprint(tcp, "\n<13>Jun  1 12:00:02 db01 postgres: newline message\nnot syslog at all\n")
+    fmt.Fprint(tcp, "<134>Jun  1 12:00:03 fw02 CEF:0|Acme|IDS|1.0|42|cef message|9|src=10.9.9.9\n")
+
+    want := map[string]string{"udp message": "nginx", "octet-counted message": "postgres", "newline message": "postgres", "cef message": "Acme IDS"}
+    deadline := time.Now().Add(5 * time.Second)
+    for {
+        alerts, err := repo.GetAllAlerts(ctx, "acme", repository.AlertFilter{Limit: 10})
+        if err != nil {
+            t.Fatal(err)
+        }
+        if len(alerts) == len(want) {
+            for _, a := range alerts {
+                if want[a.Title] != a.Source || a.Status != models.StatusNew || a.TenantID != "acme" {
+                    t.Errorf("unexpected alert %+v", a)
+                }
+                if a.Title == "cef message" && (a.Hostname != "fw02" || a.SourceIP != "10.9.9.9" || a.Severity != "critical" ||
+                    string(a.RawPayload) != `"<134>Jun  1 12:00:03 fw02 CEF:0|Acme|IDS|1.0|42|cef message|9|src=10.9.9.9"`) {
+                    t.Errorf("CEF over syslog: unexpected alert %+v", a)
+                }
+            }
+            return
+        }
+        if time.Now().After(deadline) {
+            t.Fatalf("got %d alerts, want %d", len(alerts), len(want))
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+}
+
+func TestParseCEF(t *testing.T) {
+    alert, err := ParseCEF(`Sep 19 08:26:10 arcsight CEF:0|Trend Micro|Deep Security Agent|10.0|4000030|Malware \| quarantined|8|` +
+        `src=10.0.0.5 dst=203.0.113.9 shost=ws-042 suser=CORP\\jdoe fileHash=44d88612fea8a8f36de82e1278abb02f ` +
+        `rt=1718000000000 cs1Label=Policy cs1=Default policy with spaces cs2=no label msg=Detected a\=b\nsecond act=quarantine`)
+    if err != nil {
+        t.Fatalf("ParseCEF: %v", err)
+    }
+    want := models.SecurityAlert{
+        Source:      "Trend Micro Deep Security Agent",
+        Title:       "Malware | quarantined",
+        Severity:    "high",
+        Category:    "cef",
+        SourceIP:    "10.0.0.5",
+        TargetIP:    "203.0.113.9",
+        Hostname:    "ws-042",
+        Username:    `CORP\jdoe`,
+        FileHash:    "44d88612fea8a8f36de82e1278abb02f",
+        Description: "Detected a=b\nsecond",
+        Timestamp:   time.UnixMilli(1718000000000).UTC(),
+        Attributes: map[string]string{
+            "deviceVendor":  "Trend Micro",
+            "deviceProduct": "Deep Security Agent",
+            "deviceVersion": "10.0",
+            "signatureId":   "4000030",
+            "Policy":        "Default policy with spaces",
+            "cs2":           "no label",
+            "act":           "quarantine",
+            "rt":            "1718000000000",
+        },
+    }
+    if !strings.HasPrefix(string(alert.RawPayload), `"Sep 19 08:26:10 arcsight CEF:0|Trend Micro|`) {
+        t.Errorf("expected the event as raw payload, got %s", alert.RawPayload)
+    }
+    alert.RawPayload = nil
+    if !reflect.DeepEqual(*alert, want) {
+        t.Errorf("got %+v\nwant %+v", *alert, want)
+    }
+
+    for _, bad := range []string{"CEF:0|only|three", "not cef", "CEF:0||||100|name|5|"} {
+        if _, err := ParseCEF(bad); err == nil {
+            t.Errorf("expected %q to be rejected", bad)
+        }
+    }
+}
+
+func TestParseLEEF(t *testing.T) {
+    alert, err := ParseLEEF("LEEF:1.0|Microsoft|Windows|10|4625|src=198.51.100.4\tusrName=admin\tsev=9\tidentHostName=dc01\tlogonType=3")
+    if err != nil {
+        t.Fatalf("ParseLEEF 1.0: %v", err)
+    }
+    if alert.SourceIP != "198.51.100.4" || alert.Username != "admin" || alert.Severity != "critical" || alert.Hostname != "dc01" ||
+        alert.Title != "Windows: 4625" || alert.Attributes["logonType"] != "3" || alert.Attributes["eventId"] != "4625" {
+        t.Errorf("LEEF 1.0: unexpected alert %+v", *alert)
+    }
+
+    alert, err = ParseLEEF("LEEF:2.0|Palo Alto|PAN-OS|9.1|THREAT|^|src=10.1.1.1^dst=10.2.2.2^cat=spyware^devTime=Jun 01 2025 12:00:00")
+    if err != nil {
+        t.Fatalf("ParseLEEF 2.0: %v", err)
+    }
+    if alert.TargetIP != "10.2.2.2" || alert.Category != "spyware" || alert.Severity != "medium" ||
+        !alert.Timestamp.Equal(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)) {
+        t.Errorf("LEEF 2.0: unexpected alert %+v", *alert)
+    }
+
+    alert, err = ParseLEEF("LEEF:2.0|Vendor|Product|1|ID|x09|src=10.1.1.1\tdst=10.2.2.2")
+    if err != nil || alert.TargetIP != "10.2.2.2" {
+        t.Errorf("LEEF 2.0 with hex delimiter: %+v, %v", alert, err)
+    }
+}
+
+func TestHECMapping(t *testing.T) {
+    decode := func(s string) *HECEvent {
+        dec := json.NewDecoder(strings.NewReader(s))
+        dec.UseNumber()
+        var e HECEvent
+        if err := dec.Decode(&e); err != nil {
+            t.Fatal(err)
+        }
+        return &e
+    }
+    m := DefaultHECMapping()
+
+    alert, err := m.Alert(decode(`{"time": 1718000000.25, "host": "ids01", "sourcetype": "suricata", "index": "sec",
+        "event": {"signature": "ET SCAN Nmap", "severity": "High", "src_ip": "203.0.113.7", "dest_ip": "10.0.0.5", "flow": {"pkts": 4}, "proto": "TCP"},
+        "fields": {"site": "fra1"}}`))
+    if err != nil {
+        t.Fatalf("Alert: %v", err)
+    }
+    if alert.Title != "ET SCAN Nmap" || alert.Severity != "high" || alert.Source != "suricata" || alert.Category != "suricata" ||
+        alert.Hostname != "ids01" || alert.SourceIP != "203.0.113.7" || alert.TargetIP != "10.0.0.5" {
+        t.Errorf("unexpected alert %+v", *alert)
+    }
+    if !alert.Timestamp.Equal(time.Unix(1718000000, 250e6)) {
+        t.Errorf("timestamp %v", alert.Timestamp)
+    }
+    wantAttributes := map[string]string{"flow": `{"pkts":4}`, "proto": "TCP", "site": "fra1", "index": "sec"}
+    if !reflect.DeepEqual(alert.Attributes, wantAttributes) {
+        t.Errorf("attributes: got %v, want %v", alert.Attributes, wantAttributes)
+    }
+    if !strings.Contains(string(alert.RawPayload), `"sourcetype":"suricata"`) {
+        t.Errorf("expected the envelope as raw payload, got %s", alert.RawPayload)
+    }
+
+    // String events, plain and CEF
+    alert, err = m.Alert(decode(`{"host": "web01", "source": "/var/log/auth.log", "event": "Failed password for root\nfrom 198.51.100.4"}`))
+    if err != nil || alert.Title != "Failed password for root" || alert.Source != "/var/log/auth.log" || alert.Severity != "medium" {
+        t.Errorf("string event: %+v, %v", alert, err)
+    }
+    alert, err = m.Alert(decode(`{"host": "fw01", "time": "1718000000", "event": "CEF:0|Acme|FW|1|7|Blocked|3|src=10.1.1.1"}`))
+    if err != nil || alert.Source != "Acme FW" || alert.Hostname != "fw01" || alert.SourceIP != "10.1.1.1" || alert.Timestamp.Unix() != 1718000000 {
+        t.Errorf("CEF event: %+v, %v", alert, err)
+    }
+
+    if _, err := m.Alert(decode(`{"host": "x"}`)); !errors.Is(err, ErrHECEventRequired) {
+        t.Errorf("missing event: got %v", err)
+    }
+    if _, err := m.Alert(decode(`{"event": "  "}`)); !errors.Is(err, ErrHECEventBlank) {
+        t.Errorf("blank event: got %v", err)
+    }
+
+    // A mapping file replaces the paths of the fields it names
+    path := filepath.Join(t.TempDir(), "hec.yaml")
+    os.WriteFile(path, []byte("fields:\n  username: [event.actor.login]\n"), 0o644)
+    if m, err = LoadHECMapping(path); err != nil {
+        t.Fatalf("LoadHECMapping: %v", err)
+    }
+    alert, _ = m.Alert(decode(`{"event": {"actor": {"login": "jdoe"}, "user": "ignored"}}`))
+    if alert.Username != "jdoe" || alert.Attributes["user"] != "ignored" {
+        t.Errorf("custom mapping: %+v", *alert)
+    }
+    os.WriteFile(path, []byte("fields:\n  risk_score: [event.score]\n"), 0o644)
+    if _, err := LoadHECMapping(path); err == nil {
+        t.Error("expected a mapping of an unknown field to be rejected")
+    }
+}
@@ -0,0 +1,245 @@
+package ingest
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+    "unicode/utf8"
+)
+
+// Syslog severities, from the PRI field.
+const (
+    SyslogEmergency = iota
+    SyslogAlert
+    SyslogCritical
+    SyslogError
+    SyslogWarning
+    SyslogNotice
+    SyslogInfo
+    SyslogDebug
+)
+
+// syslogSeverityNames are the keywords of the severities, as used in mapping files.
+var syslogSeverityNames = []string{"emergency", "alert", "critical", "error", "warning", "notice", "info", "debug"}
+
+// SyslogMessage is a syslog message parsed from either RFC 5424 or RFC 3164
+// (BSD syslog) format. Fields the message did not carry are left empty.
+type SyslogMessage struct {
+    Facility  int
+    Severity  int       // SyslogEmergency (0) to SyslogDebug (7)
+    Timestamp time.Time // Zero if the message had none
+    Hostname  string
+    AppName   string // APP-NAME in RFC 5424, the TAG in RFC 3164
+    ProcID    string
+    MsgID     string // RFC 5424 only
+    // StructuredData holds the RFC 5424 structured data: SD-ID -> parameter -> value
+    StructuredData map[string]map[string]string
+    Message        string
+    RFC5424        bool // The message was in RFC 5424 format
+}
+
+// errNoPRI is returned for messages that do not start with a valid <PRI> field.
+var errNoPRI = errors.New("missing or invalid PRI field")
+
+// ParseSyslog parses one syslog message. RFC 5424 is recognised by its version
+// number after the PRI field, anything else is parsed as RFC 3164. RFC 3164
+// timestamps carry no year; now decides which year they fall in.
+func ParseSyslog(data []byte, now time.Time) (*SyslogMessage, error) {
+    data = bytes.TrimRight(data, "\r\n\x00")
+    if !utf8.Valid(data) {
+        data = bytes.ToValidUTF8(data, []byte("\uFFFD")) // Stored as text, so no invalid UTF-8
+    }
+    line := string(data)
+
+    pri, rest, err := parsePRI(line)
+    if err != nil {
+        return nil, err
+    }
+    msg := &SyslogMessage{Facility: pri / 8, Severity: pri % 8}
+    if strings.HasPrefix(rest, "1 ") {
+        msg.RFC5424 = true
+        if err := parseRFC5424(msg, rest[2:]); err != nil {
+            return nil, err
+        }
+        return msg, nil
+    }
+    parseRFC3164(msg, rest, now)
+    return msg, nil
+}
+
+// parsePRI reads the "<PRI>" prefix and returns its value and the rest of the line.
+func parsePRI(line string) (int, string, error) {
+    if len(line) < 3 || line[0] != '<' {
+        return 0, "", errNoPRI
+    }
+    end := strings.IndexByte(line, '>')
+    if end < 2 || end > 4 {
+        return 0, "", errNoPRI
+    }
+    pri, err := strconv.Atoi(line[1:end])
+    if err != nil || pri < 0 || pri > 191 {
+        return 0, "", errNoPRI
+    }
+    return pri, line[end+1:], nil
+}
+
+// nilValue is the RFC 5424 placeholder for a missing header field.
+const nilValue = "-"
+
+// parseRFC5424 parses the part of an RFC 5424 message after "<PRI>1 ":
+// TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG]
+func parseRFC5424(msg *SyslogMessage, rest string) error {
+    fields := make([]string, 5)
+    for i := range fields {
+        var ok bool
+        fields[i], rest, ok = strings.Cut(rest, " ")
+        if !ok && i < len(fields)-1 {
+            return fmt.Errorf("truncated RFC 5424 header")
+        }
+        if fields[i] == nilValue {
+            fields[i] = ""
+        }
+    }
+    if fields[0] != "" {
+        ts, err := time.Parse(time.RFC3339Nano, fields[0])
+        if err != nil {
+            return fmt.Errorf("invalid RFC 5424 timestamp %q", fields[0])
+        }
+        msg.Timestamp = ts
+    }
+    msg.Hostname, msg.AppName, msg.ProcID, msg.MsgID = fields[1], fields[2], fields[3], fields[4]
+
+    switch {
+    case rest == "" || rest == nilValue:
+        rest = ""
+    case strings.HasPrefix(rest, nilValue+" "):
+        rest = rest[2:]
+    case strings.HasPrefix(rest, "["):
+        sd, after, err := parseStructuredData(rest)
+        if err != nil {
+            return err
+        }
+        msg.StructuredData = sd
+        rest = strings.TrimPrefix(after, " ")
+    default:
+        return fmt.Errorf("invalid RFC 5424 structured data")
+    }
+    msg.Message = strings.TrimPrefix(rest, "\ufeff") // UTF-8 messages may start with a BOM
+    return nil
+}
+
+// parseStructuredData parses a run of SD-ELEMENTs, e.g.
+// [exampleSDID@32473 iut="3" eventSource="Application"][origin ip="10.0.0.1"],
+// and returns them with the rest of the line.
+func parseStructuredData(s string) (map[string]map[string]string, string, error) {
+    sd := make(map[string]map[string]string)
+    for strings.HasPrefix(s, "[") {
+        s = s[1:]
+        end := strings.IndexAny(s, " ]")
+        if end <= 0 {
+            return nil, "", fmt.Errorf("invalid RFC 5424 structured data")
+        }
+        params := make(map[string]string)
+        sd[s[:end]] = params
+        s = s[end:]
+        for {
+            s = strings.TrimLeft(s, " ")
+            if strings.HasPrefix(s, "]") {
+                s = s[1:]
+                break
+            }
+            eq := strings.Index(s, `="`)
+            if eq <= 0 {
+                return nil, "", fmt.Errorf("invalid RFC 5424 structured data parameter")
+            }
+            name := s[:eq]
+            value, n, err := parseSDValue(s[eq+2:])
+            if err != nil {
+                return nil, "", err
+            }
+            params[name] = value
+            s = s[eq+2+n:]
+        }
+    }
+    return sd, s, nil
+}
+
+// parseSDValue reads a quoted parameter value up to its closing quote, resolving the
+// \" \\ and \] escapes. It returns the value and the number of bytes consumed.
+func parseSDValue(s string) (string, int, error) {
+    var b strings.Builder
+    for i := 0; i < len(s); i++ {
+        switch c := s[i]; c {
+        case '"':
+            return b.String(), i + 1, nil
+        case '\\':
+            if i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\' || s[i+1] == ']') {
+                i++
+                c = s[i]
+            }
+            b.WriteByte(c)
+        default:
+            b.WriteByte(c)
+        }
+    }
+    return "", 0, fmt.Errorf("unterminated RFC 5424 structured data value")
+}
+
+// parseRFC3164 parses the part of a BSD syslog message after "<PRI>":
+// Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG
+// Real-world senders often omit the hostname, or use an RFC 3339 timestamp, so the
+// header is parsed leniently; whatever cannot be recognised stays in the message.
+func parseRFC3164(msg *SyslogMessage, rest string, now time.Time) {
+    if len(rest) >= len(time.Stamp) {
+        if ts, err := time.ParseInLocation(time.Stamp, rest[:len(time.Stamp)], now.Location()); err == nil {
+            // No year in the timestamp: pick the one that puts it closest to now, so
+            // messages logged on 31 December and received on 1 January stay in the past.
+            ts = ts.AddDate(now.Year(), 0, 0)
+            if ts.After(now.Add(24 * time.Hour)) {
+                ts = ts.AddDate(-1, 0, 0)
+            }
+            msg.Timestamp = ts
+            rest = strings.TrimPrefix(rest[len(time.Stamp):], " ")
+        }
+    }
+    if msg.Timestamp.IsZero() {
+        if first, after, ok := strings.Cut(rest, " "); ok {
+            if ts, err := time.Parse(time.RFC3339Nano, first); err == nil {
+                msg.Timestamp = ts
+                rest = after
+            }
+        }
+    }
+
+    // The hostname is the next word, unless that word is already the tag or the
+    // start of a CEF or LEEF event
+    if word, after, ok := strings.Cut(rest, " "); ok && !msg.Timestamp.IsZero() && !isSyslogTag(word) && !strings.Contains(word, "|") {
+        msg.Hostname = word
+        rest = after
+    }
+    if word, after, ok := strings.Cut(rest, " "); ok && isSyslogTag(word) {
+        tag := strings.TrimSuffix(word, ":")
+        if open := strings.IndexByte(tag, '['); open > 0 && strings.HasSuffix(tag, "]") {
+            msg.ProcID = tag[open+1 : len(tag)-1]
+            tag = tag[:open]
+        }
+        msg.AppName = tag
+        rest = after
+    }
+    msg.Message = rest
+}
+
+// isSyslogTag reports whether word looks like a BSD syslog tag, "sshd:" or "sshd[42]:".
+func isSyslogTag(word string) bool {
+    if !strings.HasSuffix(word, ":") || len(word) < 2 || strings.Contains(word, "|") {
+        return false
+    }
+    word = strings.TrimSuffix(word, ":")
+    if open := strings.IndexByte(word, '['); open >= 0 {
+        return open > 0 && strings.HasSuffix(word, "]")
+    }
+    return !strings.ContainsAny(word, "=\"'")
+}
@@ -0,0 +1,189 @@
+package ingest
+
+import (
+    "fmt"
+    "net"
+    "os"
+    "regexp"
+    "strconv"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// maxTitleLength caps the title taken from the first line of a syslog message.
+const maxTitleLength = 200
+
+// extractableFields maps the names of the alert fields a regex can fill to the fields.
+var extractableFields = map[string]func(a *models.SecurityAlert) *string{
+    "source_ip": func(a *models.SecurityAlert) *string { return &a.SourceIP },
+    "target_ip": func(a *models.SecurityAlert) *string { return &a.TargetIP },
+    "hostname":  func(a *models.SecurityAlert) *string { return &a.Hostname },
+    "username":  func(a *models.SecurityAlert) *string { return &a.Username },
+    "file_hash": func(a *models.SecurityAlert) *string { return &a.FileHash },
+    "category":  func(a *models.SecurityAlert) *string { return &a.Category },
+}
+
+// Extractor fills an alert field from the first capture group of a regular
+// expression matched against the syslog message.
+type Extractor struct {
+    Field   string
+    Pattern *regexp.Regexp
+}
+
+// defaultExtractors cover the key=value style of most firewalls and IDS sensors, and
+// the "for user from address" phrasing of sshd and PAM.
+var defaultExtractors = []Extractor{
+    {"source_ip", regexp.MustCompile(`(?i)\b(?:src|src_?ip|source_?ip|sip)[=:]\s*"?([0-9a-f.:]+)`)},
+    {"source_ip", regexp.MustCompile(`(?i)\bfrom\s+([0-9]{1,3}(?:\.[0-9]{1,3}){3})\b`)},
+    {"target_ip", regexp.MustCompile(`(?i)\b(?:dst|dst_?ip|dest_?ip|destination_?ip|dip)[=:]\s*"?([0-9a-f.:]+)`)},
+    {"username", regexp.MustCompile(`(?i)\b(?:user|user_?name|suser|duser|account)[=:]\s*"?([^\s",;]+)`)},
+    {"username", regexp.MustCompile(`\bfor (?:invalid user )?([^\s]+) from\b`)},
+}
+
+// SyslogMapping turns parsed syslog messages into alerts: the severity comes from
+// the PRI field, the source from the app-name, the hostname from the header, and
+// further fields from regular expressions run over the message.
+type SyslogMapping struct {
+    Severities [8]string   // Alert severity per syslog severity, indexed by SyslogEmergency...SyslogDebug
+    Category   string      // Category of alerts no extractor assigned one to
+    Extractors []Extractor // Tried in order; the first match of a field wins
+}
+
+// DefaultSyslogMapping returns the built-in mapping.
+func DefaultSyslogMapping() *SyslogMapping {
+    return &SyslogMapping{
+        Severities: [8]string{"critical", "critical", "critical", "high", "medium", "low", "low", "low"},
+        Category:   "syslog",
+        Extractors: append([]Extractor(nil), defaultExtractors...),
+    }
+}
+
+// syslogMappingFile is the format of the mapping file:
+//
+//	severities:          # Syslog severity (name or 0-7) -> alert severity
+//	  warning: high
+//	category: firewall
+//	replace_extractors: false # true drops the built-in extractors
+//	extract:
+//	  - field: username
+//	    pattern: 'login=(\S+)'
+type syslogMappingFile struct {
+    Severities        map[string]string `yaml:"severities"`
+    Category          string            `yaml:"category"`
+    ReplaceExtractors bool              `yaml:"replace_extractors"`
+    Extract           []struct {
+        Field   string `yaml:"field"`
+        Pattern string `yaml:"pattern"`
+    } `yaml:"extract"`
+}
+
+// LoadSyslogMapping returns the default mapping adjusted by the YAML file at path.
+// Extractors from the file are tried before the built-in ones. An empty path returns
+// the default mapping.
+func LoadSyslogMapping(path string) (*SyslogMapping, error) {
+    m := DefaultSyslogMapping()
+    if path == "" {
+        return m, nil
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read syslog mapping: %w", err)
+    }
+    var file syslogMappingFile
+    if err := yaml.Unmarshal(data, &file); err != nil {
+        return nil, fmt.Errorf("failed to parse syslog mapping %s: %w", path, err)
+    }
+
+    for name, severity := range file.Severities {
+        level, ok := syslogSeverity(name)
+        if !ok {
+            return nil, fmt.Errorf("unknown syslog severity %q in %s", name, path)
+        }
+        m.Severities[level] = severity
+    }
+    if file.Category != "" {
+        m.Category = file.Category
+    }
+
+    var extractors []Extractor
+    for i, e := range file.Extract {
+        field := strings.ToLower(strings.TrimSpace(e.Field))
+        if _, ok := extractableFields[field]; !ok {
+            return nil, fmt.Errorf("extractor %d in %s: unsupported field %q", i, path, e.Field)
+        }
+        re, err := regexp.Compile(e.Pattern)
+        if err != nil {
+            return nil, fmt.Errorf("extractor %d in %s: %w", i, path, err)
+        }
+        if re.NumSubexp() < 1 {
+            return nil, fmt.Errorf("extractor %d in %s: pattern needs a capture group", i, path)
+        }
+        extractors = append(extractors, Extractor{Field: field, Pattern: re})
+    }
+    if !file.ReplaceExtractors {
+        extractors = append(extractors, m.Extractors...)
+    }
+    m.Extractors = extractors
+    return m, nil
+}
+
+// syslogSeverity resolves a severity given as a keyword or number.
+func syslogSeverity(name string) (int, bool) {
+    name = strings.ToLower(strings.TrimSpace(name))
+    if n, err := strconv.Atoi(name); err == nil {
+        return n, n >= SyslogEmergency && n <= SyslogDebug
+    }
+    for level, keyword := range syslogSeverityNames {
+        if name == keyword {
+            return level, true
+        }
+    }
+    return 0, false
+}
+
+// Alert builds the alert for a syslog message.
+func (m *SyslogMapping) Alert(msg *SyslogMessage) models.SecurityAlert {
+    alert := models.SecurityAlert{
+        Source:      msg.AppName,
+        Timestamp:   msg.Timestamp,
+        Severity:    m.Severities[msg.Severity],
+        Hostname:    msg.Hostname,
+        Description: msg.Message,
+    }
+    if alert.Source == "" {
+        alert.Source = "syslog"
+    }
+
+    // The first line of the message makes the title
+    title, _, _ := strings.Cut(strings.TrimSpace(msg.Message), "\n")
+    if r := []rune(title); len(r) > maxTitleLength {
+        title = string(r[:maxTitleLength])
+    }
+    if title == "" {
+        title = alert.Source + " syslog message"
+    }
+    alert.Title = title
+
+    for _, e := range m.Extractors {
+        target := extractableFields[e.Field](&alert)
+        if *target != "" {
+            continue // Already set by the header or an earlier extractor
+        }
+        match := e.Pattern.FindStringSubmatch(msg.Message)
+        if match == nil || match[1] == "" {
+            continue
+        }
+        value := match[1]
+        if (e.Field == "source_ip" || e.Field == "target_ip") && net.ParseIP(value) == nil {
+            continue // e.g. "src=host.example" where an address was expected
+        }
+        *target = value
+    }
+    if alert.Category == "" {
+        alert.Category = m.Category
+    }
+    return alert
+}
@@ -0,0 +1,345 @@
+package ingest
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "crypto/tls"
+    "crypto/x509"
+    "errors"
+    "fmt"
+    "io"
+    "log"
+    "net"
+    "os"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/config"
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+const (
+    maxSyslogMessage   = 64 * 1024        // Longer messages are dropped (UDP) or end the connection (TCP)
+    syslogIdleTimeout  = 10 * time.Minute // TCP connections silent for this long are closed
+    syslogStoreTimeout = 5 * time.Second  // Bound on storing one alert
+)
+
+// SyslogServer receives syslog messages over UDP, TCP and TLS and stores each one as
+// an alert of a fixed tenant, through the same Service the HTTP API uses.
+// TCP and TLS streams may use either octet-counting or newline framing (RFC 6587).
+type SyslogServer struct {
+    ingest  *Service
+    mapping *SyslogMapping
+    tenant  string
+
+    mu        sync.Mutex
+    packets   []net.PacketConn
+    listeners []net.Listener
+    conns     map[net.Conn]struct{} // Open TCP connections, closed on shutdown
+    closed    bool
+}
+
+// NewSyslogServer creates a server storing alerts mapped by mapping through in,
+// owned by tenant. Listeners are added with ListenUDP, ListenTCP and ListenTLS.
+func NewSyslogServer(in *Service, mapping *SyslogMapping, tenant string) *SyslogServer {
+    return &SyslogServer{ingest: in, mapping: mapping, tenant: tenant, conns: make(map[net.Conn]struct{})}
+}
+
+// SyslogFromConfig creates the configured syslog server with its listeners bound, or
+// returns nil if no listen address is configured.
+func SyslogFromConfig(cfg *config.Config, in *Service) (*SyslogServer, error) {
+    if cfg.SyslogUDPAddr == "" && cfg.SyslogTCPAddr == "" && cfg.SyslogTLSAddr == "" {
+        return nil, nil
+    }
+    if !models.ValidTenantID(cfg.SyslogTenant) {
+        return nil, fmt.Errorf("invalid syslog tenant %q", cfg.SyslogTenant)
+    }
+    mapping, err := LoadSyslogMapping(cfg.SyslogMappingFile)
+    if err != nil {
+        return nil, err
+    }
+
+    s := NewSyslogServer(in, mapping, cfg.SyslogTenant)
+    if cfg.SyslogUDPAddr != "" {
+        if _, err := s.ListenUDP(cfg.SyslogUDPAddr); err != nil {
+            s.Close()
+            return nil, err
+        }
+    }
+    if cfg.SyslogTCPAddr != "" {
+        if _, err := s.ListenTCP(cfg.SyslogTCPAddr); err != nil {
+            s.Close()
+            return nil, err
+        }
+    }
+    if cfg.SyslogTLSAddr != "" {
+        tlsConfig, err := syslogTLSConfig(cfg)
+        if err != nil {
+            s.Close()
+            return nil, err
+        }
+        if _, err := s.ListenTLS(cfg.SyslogTLSAddr, tlsConfig); err != nil {
+            s.Close()
+            return nil, err
+        }
+    }
+    return s, nil
+}
+
+// syslogTLSConfig loads the server certificate and, if configured, the CA that
+// client certificates must be signed by.
+func syslogTLSConfig(cfg *config.Config) (*tls.Config, error) {
+    if cfg.SyslogTLSCert == "" || cfg.SyslogTLSKey == "" {
+        return nil, fmt.Errorf("SYSLOG_TLS_CERT and SYSLOG_TLS_KEY are required for the TLS syslog listener")
+    }
+    cert, err := tls.LoadX509KeyPair(cfg.SyslogTLSCert, cfg.SyslogTLSKey)
+    if err != nil {
+        return nil, fmt.Errorf("failed to load syslog TLS certificate: %w", err)
+    }
+    tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+    if cfg.SyslogTLSClientCA != "" {
+        pem, err := os.ReadFile(cfg.SyslogTLSClientCA)
+        if err != nil {
+            return nil, fmt.Errorf("failed to read syslog client CA: %w", err)
+        }
+        pool := x509.NewCertPool()
+        if !pool.AppendCertsFromPEM(pem) {
+            return nil, fmt.Errorf("no certificates found in %s", cfg.SyslogTLSClientCA)
+        }
+        tlsConfig.ClientCAs = pool
+        tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+    }
+    return tlsConfig, nil
+}
+
+// ListenUDP binds a UDP listener on addr and returns its address. Every datagram is
+// one message.
+func (s *SyslogServer) ListenUDP(addr string) (net.Addr, error) {
+    pc, err := net.ListenPacket("udp", addr)
+    if err != nil {
+        return nil, fmt.Errorf("failed to listen for syslog on udp %s: %w", addr, err)
+    }
+    s.mu.Lock()
+    s.packets = append(s.packets, pc)
+    s.mu.Unlock()
+    log.Printf("Syslog: Listening on udp %s", pc.LocalAddr())
+    return pc.LocalAddr(), nil
+}
+
+// ListenTCP binds a plain TCP listener on addr and returns its address.
+func (s *SyslogServer) ListenTCP(addr string) (net.Addr, error) {
+    l, err := net.Listen("tcp", addr)
+    if err != nil {
+        return nil, fmt.Errorf("failed to listen for syslog on tcp %s: %w", addr, err)
+    }
+    return s.addListener(l, "tcp"), nil
+}
+
+// ListenTLS binds a TLS listener on addr (RFC 5425) and returns its address.
+func (s *SyslogServer) ListenTLS(addr string, tlsConfig *tls.Config) (net.Addr, error) {
+    l, err := tls.Listen("tcp", addr, tlsConfig)
+    if err != nil {
+        return nil, fmt.Errorf("failed to listen for syslog on tls %s: %w", addr, err)
+    }
+    return s.addListener(l, "tls"), nil
+}
+
+func (s *SyslogServer) addListener(l net.Listener, kind string) net.Addr {
+    s.mu.Lock()
+    s.listeners = append(s.listeners, l)
+    s.mu.Unlock()
+    log.Printf("Syslog: Listening on %s %s", kind, l.Addr())
+    return l.Addr()
+}
+
+// Run serves all listeners until ctx is cancelled, then closes them.
+func (s *SyslogServer) Run(ctx context.Context) {
+    s.mu.Lock()
+    packets, listeners := s.packets, s.listeners
+    s.mu.Unlock()
+
+    var wg sync.WaitGroup
+    for _, pc := range packets {
+        wg.Add(1)
+        go func(pc net.PacketConn) {
+            defer wg.Done()
+            s.serveUDP(ctx, pc)
+        }(pc)
+    }
+    for _, l := range listeners {
+        wg.Add(1)
+        go func(l net.Listener) {
+            defer wg.Done()
+            s.serveStream(ctx, l)
+        }(l)
+    }
+
+    <-ctx.Done()
+    s.Close()
+    wg.Wait()
+}
+
+// Close closes all listeners and open connections.
+func (s *SyslogServer) Close() {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.closed = true
+    for _, pc := range s.packets {
+        pc.Close()
+    }
+    for _, l := range s.listeners {
+        l.Close()
+    }
+    for c := range s.conns {
+        c.Close()
+    }
+}
+
+func (s *SyslogServer) serveUDP(ctx context.Context, pc net.PacketConn) {
+    buf := make([]byte, maxSyslogMessage)
+    for {
+        n, from, err := pc.ReadFrom(buf)
+        if err != nil {
+            if ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+                log.Printf("ERROR Syslog: Failed to read from udp %s: %v", pc.LocalAddr(), err)
+            }
+            return
+        }
+        s.handle(ctx, buf[:n], from)
+    }
+}
+
+func (s *SyslogServer) serveStream(ctx context.Context, l net.Listener) {
+    var wg sync.WaitGroup
+    defer wg.Wait()
+    for {
+        conn, err := l.Accept()
+        if err != nil {
+            if ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+                log.Printf("ERROR Syslog: Failed to accept connection on %s: %v", l.Addr(), err)
+            }
+            return
+        }
+        s.mu.Lock()
+        if s.closed {
+            s.mu.Unlock()
+            conn.Close()
+            return
+        }
+        s.conns[conn] = struct{}{}
+        s.mu.Unlock()
+
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            s.serveConn(ctx, conn)
+            s.mu.Lock()
+            delete(s.conns, conn)
+            s.mu.Unlock()
+            conn.Close()
+        }()
+    }
+}
+
+// serveConn reads framed messages from a TCP or TLS connection until it is closed.
+func (s *SyslogServer) serveConn(ctx context.Context, conn net.Conn) {
+    r := bufio.NewReaderSize(conn, 16*1024)
+    for {
+        conn.SetReadDeadline(time.Now().Add(syslogIdleTimeout))
+        frame, err := readSyslogFrame(r)
+        if len(bytes.TrimSpace(frame)) > 0 {
+            s.handle(ctx, frame, conn.RemoteAddr())
+        }
+        if err != nil {
+            if err != io.EOF && ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+                log.Printf("Syslog: Closing connection from %s: %v", conn.RemoteAddr(), err)
+            }
+            return
+        }
+    }
+}
+
+// readSyslogFrame reads one message from a stream. A frame starting with a digit is
+// octet-counted ("LEN SP MSG"), anything else runs up to the next newline.
+func readSyslogFrame(r *bufio.Reader) ([]byte, error) {
+    first, err := r.Peek(1)
+    if err != nil {
+        return nil, err
+    }
+    if first[0] >= '0' && first[0] <= '9' {
+        lenField, err := r.ReadString(' ')
+        if err != nil {
+            return nil, fmt.Errorf("truncated octet count: %w", err)
+        }
+        n, err := strconv.Atoi(lenField[:len(lenField)-1])
+        if err != nil || n < 1 || n > maxSyslogMessage {
+            return nil, fmt.Errorf("invalid octet count %q", lenField)
+        }
+        frame := make([]byte, n)
+        if _, err := io.ReadFull(r, frame); err != nil {
+            return nil, fmt.Errorf("truncated message: %w", err)
+        }
+        return frame, nil
+    }
+
+    var frame []byte
+    for {
+        chunk, err := r.ReadSlice('\n')
+        frame = append(frame, chunk...)
+        if len(frame) > maxSyslogMessage {
+            return nil, fmt.Errorf("message exceeds %d bytes", maxSyslogMessage)
+        }
+        if err == bufio.ErrBufferFull {
+            continue
+        }
+        return frame, err // The last message may lack its newline
+    }
+}
+
+// alert builds the alert for a message. CEF and LEEF events, as sent by SIEM
+// forwarders, are parsed as such; the syslog header only fills in what they lack.
+func (s *SyslogServer) alert(msg *SyslogMessage) (models.SecurityAlert, error) {
+    if !isSIEMEvent(msg.Message) {
+        return s.mapping.Alert(msg), nil
+    }
+    parse := ParseCEF
+    if strings.HasPrefix(msg.Message, "LEEF:") {
+        parse = ParseLEEF
+    }
+    event, err := parse(msg.Message)
+    if err != nil {
+        return models.SecurityAlert{}, err
+    }
+    if event.Hostname == "" {
+        event.Hostname = msg.Hostname
+    }
+    if event.Timestamp.IsZero() {
+        event.Timestamp = msg.Timestamp
+    }
+    return *event, nil
+}
+
+// handle parses one message and stores it as an alert. Broken messages are logged
+// and dropped: the sender cannot be told, and retrying would not fix them.
+func (s *SyslogServer) handle(ctx context.Context, data []byte, from net.Addr) {
+    msg, err := ParseSyslog(data, time.Now())
+    if err != nil {
+        log.Printf("Syslog: Dropping message from %s: %v", from, err)
+        return
+    }
+    alert, err := s.alert(msg)
+    if err != nil {
+        log.Printf("Syslog: Dropping message from %s: %v", from, err)
+        return
+    }
+    alert.RawPayload = rawText(string(data)) // The whole line, including the syslog header
+
+    storeCtx, cancel := context.WithTimeout(ctx, syslogStoreTimeout)
+    defer cancel()
+    if _, err := s.ingest.Submit(storeCtx, s.tenant, &alert); err != nil {
+        log.Printf("ERROR Syslog: Failed to save alert from %s: %v", from, err)
+    }
+}
@@ -0,0 +1,48 @@
+package kafka
+
+import (
+    "context"
+
+    "github.com/segmentio/kafka-go"
+)
+
+// HeaderTenant carries the tenant an alert message belongs to, so consumers write
+// their results back under the right tenant.
+const HeaderTenant = "x-tenant-id"
+
+// TenantOf returns the tenant named in the HeaderTenant header of m, or "" if the
+// message has none.
+func TenantOf(m kafka.Message) string {
+    return headerValue(m, HeaderTenant)
+}
+
+// Publisher publishes messages to a topic. *Producer implements it for Kafka and
+// MemoryBus.NewProducer returns an in-process implementation.
+type Publisher interface {
+    PublishMessages(ctx context.Context, msgs ...kafka.Message) error
+    Close() error
+}
+
+// Subscriber consumes a topic as a member of a consumer group. *Consumer implements
+// it, on top of either a Kafka reader or a MemoryBus.
+type Subscriber interface {
+    // ConsumeMessages hands every message to handler until ctx is cancelled.
+    ConsumeMessages(ctx context.Context, handler func(message kafka.Message) error)
+    // SetGate installs a function that may block consumption, see Consumer.SetGate.
+    SetGate(gate func(ctx context.Context) error)
+    Close() error
+}
+
+// messageReader is the part of *kafka.Reader the Consumer relies on: fetching
+// messages in order and committing the offsets of handled ones for its group.
+type messageReader interface {
+    FetchMessage(ctx context.Context) (kafka.Message, error)
+    CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+    Close() error
+}
+
+var (
+    _ Publisher     = (*Producer)(nil)
+    _ Subscriber    = (*Consumer)(nil)
+    _ messageReader = (*kafka.Reader)(nil)
+)
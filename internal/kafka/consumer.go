@@ -2,19 +2,44 @@ package kafka
 
 import (
     "context"
+    "fmt"
+    "hash/fnv"
     "log"
+    "sync"
     "time"
 
     "github.com/segmentio/kafka-go"
 )
 
+// workerQueueSize is the number of messages buffered per worker. Together with the
+// worker count it bounds how many fetched messages can be in flight.
+const workerQueueSize = 16
+
 // Consumer represents a Kafka consumer.
 type Consumer struct {
-    reader *kafka.Reader
+    reader  messageReader // A *kafka.Reader, or a reader of a MemoryBus
+    policy  RetryPolicy
+    dlq     Publisher // nil when no dead-letter topic is configured
+    workers int
+
+    commitMu  sync.Mutex
+    committed map[partitionKey]int64 // Highest offset committed per partition
+
+    gate func(ctx context.Context) error // Optional, see SetGate
+}
+
+// SetGate installs a function that is called before every handler attempt and may
+// block to pause consumption, e.g. while a circuit breaker on a dependency is open.
+// Blocked time does not count against the retry policy. Must be called before
+// ConsumeMessages.
+func (c *Consumer) SetGate(gate func(ctx context.Context) error) {
+    c.gate = gate
 }
 
-// NewConsumer creates a new Kafka consumer.
-func NewConsumer(brokers []string, topic, groupID string) *Consumer {
+// NewConsumer creates a new Kafka consumer that handles messages with the given
+// number of concurrent workers. Messages whose handler keeps failing are retried
+// according to policy and then moved to policy.DeadLetterTopic.
+func NewConsumer(brokers []string, topic, groupID string, policy RetryPolicy, workers int) *Consumer {
     reader := kafka.NewReader(kafka.ReaderConfig{
         Brokers:        brokers,
         Topic:          topic,
@@ -29,11 +54,52 @@ func NewConsumer(brokers []string, topic, groupID string) *Consumer {
         StartOffset: kafka.FirstOffset,
     })
     log.Printf("Kafka consumer initialized for topic '%s', group '%s' on brokers %v", topic, groupID, brokers)
-    return &Consumer{reader: reader}
+
+    var dlq Publisher
+    if policy.DeadLetterTopic != "" {
+        dlq = NewProducer(brokers, policy.DeadLetterTopic)
+    }
+    return newConsumer(reader, dlq, policy, workers)
+}
+
+// newConsumer wraps reader with the worker pool, retry policy and offset handling
+// shared by the Kafka and in-memory consumers.
+func newConsumer(reader messageReader, dlq Publisher, policy RetryPolicy, workers int) *Consumer {
+    if workers < 1 {
+        workers = 1
+    }
+    return &Consumer{reader: reader, policy: policy, dlq: dlq, workers: workers, committed: make(map[partitionKey]int64)}
 }
 
 // ConsumeMessages continuously reads messages from Kafka and processes them using the provided handler func.
+//
+// Messages are spread over the consumer's workers by key, so messages with the same
+// key are always handled by the same worker, in order. Messages without a key are
+// spread by partition. Offsets are committed per partition only up to the highest
+// contiguous offset whose message has been handled.
+// ConsumeMessages returns once ctx is cancelled and every worker has stopped.
 func (c *Consumer) ConsumeMessages(ctx context.Context, handler func(message kafka.Message) error) {
+    tracker := newOffsetTracker()
+
+    var wg sync.WaitGroup
+    queues := make([]chan kafka.Message, c.workers)
+    for i := range queues {
+        queues[i] = make(chan kafka.Message, workerQueueSize)
+        wg.Add(1)
+        go func(queue <-chan kafka.Message) {
+            defer wg.Done()
+            for m := range queue {
+                c.handle(ctx, m, handler, tracker)
+            }
+        }(queues[i])
+    }
+    defer func() {
+        for _, q := range queues {
+            close(q)
+        }
+        wg.Wait()
+    }()
+
     for {
         select {
         case <-ctx.Done():
@@ -52,29 +118,148 @@ func (c *Consumer) ConsumeMessages(ctx context.Context, handler func(message kaf
                 continue
             }
 
-            // Process the message
-            processingErr := handler(m)
-            if processingErr != nil {
-                log.Printf("ERROR Consumer: Failed to process message (will not commit offset): %v", processingErr)
-                // If handler returns an error, we don't commit the offset, so Kafka will re-deliver.
-                // Depending on retry logic, this might go to a dead-letter queue eventually.
-            } else {
-                // Commit the offset if the message was processed successfully
-                // This tells Kafka we've successfully handled this message.
-                commitCtx, commitCancel := context.WithTimeout(context.Background(), 3*time.Second)
-                err = c.reader.CommitMessages(commitCtx, m)
-                commitCancel()
-                if err != nil {
-                    log.Printf("ERROR Consumer: Failed to commit message offset for topic %s partition %d offset %d: %v", m.Topic, m.Partition, m.Offset, err)
-                    // If commit fails, the message will be re-delivered on restart, which is safe.
-                }
+            if !tracker.track(m) {
+                // Fetched again after a rebalance while the first copy is in flight or done.
+                log.Printf("Consumer: skipping re-fetched message %s[%d]@%d", m.Topic, m.Partition, m.Offset)
+                continue
+            }
+            select {
+            case queues[c.workerFor(m)] <- m:
+            case <-ctx.Done():
+                log.Println("Consumer context cancelled, stopping message consumption.")
+                return
+            }
+        }
+    }
+}
+
+// workerFor picks the worker for m so that equal keys always map to the same worker.
+func (c *Consumer) workerFor(m kafka.Message) int {
+    if len(m.Key) == 0 {
+        return m.Partition % c.workers
+    }
+    h := fnv.New32a()
+    h.Write(m.Key)
+    return int(h.Sum32() % uint32(c.workers))
+}
+
+// handle processes one message on a worker and commits whatever offset became committable.
+func (c *Consumer) handle(ctx context.Context, m kafka.Message, handler func(message kafka.Message) error, tracker *offsetTracker) {
+    if ctx.Err() != nil {
+        return // Shutting down: leave queued messages uncommitted so they are redelivered
+    }
+
+    // Process the message, retrying with backoff; give up to the dead-letter topic.
+    attempts, processingErr := c.process(ctx, m, handler)
+    if processingErr != nil {
+        if ctx.Err() != nil {
+            // Shutting down mid-retry: leave the offset uncommitted so the message is redelivered.
+            log.Printf("Consumer stopping, message %s[%d]@%d will be redelivered: %v", m.Topic, m.Partition, m.Offset, processingErr)
+            return
+        }
+        if err := c.deadLetter(ctx, m, processingErr, attempts); err != nil {
+            log.Printf("Consumer stopping before message %s[%d]@%d reached the dead-letter topic: %v", m.Topic, m.Partition, m.Offset, err)
+            return
+        }
+    }
+
+    // The message was either processed or parked in the DLQ.
+    if commitPoint, ok := tracker.complete(m); ok {
+        c.commit(commitPoint)
+    }
+}
+
+// commit tells Kafka that every message up to and including m has been handled.
+// Commits are serialised and never move a partition's offset backwards, even if
+// two workers race to commit.
+func (c *Consumer) commit(m kafka.Message) {
+    c.commitMu.Lock()
+    defer c.commitMu.Unlock()
+
+    key := partitionKey{m.Topic, m.Partition}
+    if last, ok := c.committed[key]; ok && m.Offset <= last {
+        return
+    }
+
+    commitCtx, commitCancel := context.WithTimeout(context.Background(), 3*time.Second)
+    err := c.reader.CommitMessages(commitCtx, m)
+    commitCancel()
+    if err != nil {
+        log.Printf("ERROR Consumer: Failed to commit message offset for topic %s partition %d offset %d: %v", m.Topic, m.Partition, m.Offset, err)
+        // If commit fails, the message will be re-delivered on restart, which is safe.
+        return
+    }
+    c.committed[key] = m.Offset
+}
+
+// process runs handler on m, retrying failures with exponential backoff up to
+// policy.MaxRetries times. It returns the number of attempts made and the last error.
+func (c *Consumer) process(ctx context.Context, m kafka.Message, handler func(message kafka.Message) error) (int, error) {
+    attempt := 0
+    for {
+        if c.gate != nil {
+            if err := c.gate(ctx); err != nil {
+                return attempt, err // Only fails when ctx is cancelled
             }
         }
+
+        attempt++
+        err := handler(m)
+        if err == nil {
+            return attempt, nil
+        }
+        if IsPermanent(err) {
+            log.Printf("ERROR Consumer: Message %s[%d]@%d failed permanently: %v", m.Topic, m.Partition, m.Offset, err)
+            return attempt, err
+        }
+        if attempt > c.policy.MaxRetries {
+            log.Printf("ERROR Consumer: Message %s[%d]@%d failed after %d attempts: %v", m.Topic, m.Partition, m.Offset, attempt, err)
+            return attempt, err
+        }
+
+        delay := c.policy.backoff(attempt)
+        log.Printf("ERROR Consumer: Failed to process message %s[%d]@%d (attempt %d/%d), retrying in %s: %v",
+            m.Topic, m.Partition, m.Offset, attempt, c.policy.MaxRetries+1, delay, err)
+        select {
+        case <-ctx.Done():
+            return attempt, err
+        case <-time.After(delay):
+        }
     }
 }
 
-// Close closes the Kafka consumer connection.
+// deadLetter publishes m to the dead-letter topic. Publishing is retried until it
+// succeeds or ctx is cancelled, because committing the offset without a DLQ copy
+// would lose the message.
+func (c *Consumer) deadLetter(ctx context.Context, m kafka.Message, cause error, attempts int) error {
+    if c.dlq == nil {
+        log.Printf("ERROR Consumer: No dead-letter topic configured, dropping message %s[%d]@%d: %v", m.Topic, m.Partition, m.Offset, cause)
+        return nil
+    }
+
+    dm := deadLetterMessage(m, cause, attempts)
+    for retry := 1; ; retry++ {
+        err := c.dlq.PublishMessages(ctx, dm)
+        if err == nil {
+            log.Printf("Consumer: Moved message %s[%d]@%d to dead-letter topic %s after %d attempts",
+                m.Topic, m.Partition, m.Offset, c.policy.DeadLetterTopic, attempts)
+            return nil
+        }
+        delay := c.policy.backoff(retry)
+        log.Printf("ERROR Consumer: Failed to publish to dead-letter topic %s, retrying in %s: %v", c.policy.DeadLetterTopic, delay, err)
+        select {
+        case <-ctx.Done():
+            return fmt.Errorf("dead-letter publish aborted: %w", ctx.Err())
+        case <-time.After(delay):
+        }
+    }
+}
+
+// Close closes the consumer connection.
 func (c *Consumer) Close() error {
     log.Println("Closing Kafka consumer...")
+    if c.dlq != nil {
+        c.dlq.Close()
+    }
     return c.reader.Close()
 }
\ No newline at end of file
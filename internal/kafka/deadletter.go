@@ -0,0 +1,173 @@
+package kafka
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/segmentio/kafka-go"
+)
+
+// Headers added to messages moved to the dead-letter topic.
+const (
+    HeaderDLQError             = "x-dlq-error"
+    HeaderDLQAttempts          = "x-dlq-attempts"
+    HeaderDLQOriginalTopic     = "x-dlq-original-topic"
+    HeaderDLQOriginalPartition = "x-dlq-original-partition"
+    HeaderDLQOriginalOffset    = "x-dlq-original-offset"
+    HeaderDLQFailedAt          = "x-dlq-failed-at"
+)
+
+// RetryPolicy controls how the consumer deals with messages whose handler fails.
+type RetryPolicy struct {
+    MaxRetries     int           // Retries after the first attempt; 0 means dead-letter on first failure
+    InitialBackoff time.Duration // Delay before the first retry, doubled for every further retry
+    MaxBackoff     time.Duration // Upper bound for the delay between retries
+    // DeadLetterTopic receives messages that still fail after MaxRetries retries.
+    // If empty, such messages are logged and skipped.
+    DeadLetterTopic string
+}
+
+// backoff returns the delay before the given retry (1-based).
+// Without a MaxBackoff the delay keeps doubling.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+    d := p.InitialBackoff
+    for i := 1; i < retry && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+        d *= 2
+    }
+    if p.MaxBackoff > 0 && d > p.MaxBackoff {
+        d = p.MaxBackoff
+    }
+    return d
+}
+
+// permanentError marks a handler error that retrying cannot fix.
+type permanentError struct {
+    err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the consumer sends the message straight to the
+// dead-letter topic instead of retrying it, e.g. for malformed payloads.
+func Permanent(err error) error {
+    if err == nil {
+        return nil
+    }
+    return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+    var p *permanentError
+    return errors.As(err, &p)
+}
+
+// deadLetterMessage builds the DLQ copy of m. The original key, value and headers
+// are kept; the failure details are appended as x-dlq-* headers.
+func deadLetterMessage(m kafka.Message, cause error, attempts int) kafka.Message {
+    headers := make([]kafka.Header, 0, len(m.Headers)+6)
+    for _, h := range m.Headers {
+        if !strings.HasPrefix(h.Key, "x-dlq-") { // Drop details from a previous trip through the DLQ
+            headers = append(headers, h)
+        }
+    }
+    headers = append(headers,
+        kafka.Header{Key: HeaderDLQError, Value: []byte(cause.Error())},
+        kafka.Header{Key: HeaderDLQAttempts, Value: []byte(strconv.Itoa(attempts))},
+        kafka.Header{Key: HeaderDLQOriginalTopic, Value: []byte(m.Topic)},
+        kafka.Header{Key: HeaderDLQOriginalPartition, Value: []byte(strconv.Itoa(m.Partition))},
+        kafka.Header{Key: HeaderDLQOriginalOffset, Value: []byte(strconv.FormatInt(m.Offset, 10))},
+        kafka.Header{Key: HeaderDLQFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339))},
+    )
+    return kafka.Message{Key: m.Key, Value: m.Value, Headers: headers}
+}
+
+// headerValue returns the value of the first header named key, or "".
+func headerValue(m kafka.Message, key string) string {
+    for _, h := range m.Headers {
+        if h.Key == key {
+            return string(h.Value)
+        }
+    }
+    return ""
+}
+
+// Redrive moves messages from a dead-letter topic back to the topic they originally
+// came from (taken from the x-dlq-original-topic header, or fallbackTopic).
+// It stops after max messages (0 means no limit) or once no message arrived for idle,
+// and returns the number of messages re-driven. With dryRun set, messages are only
+// logged; nothing is published or committed.
+func Redrive(ctx context.Context, brokers []string, dlqTopic, groupID, fallbackTopic string, max int, idle time.Duration, dryRun bool) (int, error) {
+    reader := kafka.NewReader(kafka.ReaderConfig{
+        Brokers:     brokers,
+        Topic:       dlqTopic,
+        GroupID:     groupID,
+        StartOffset: kafka.FirstOffset,
+        Dialer:      &kafka.Dialer{Timeout: 10 * time.Second},
+    })
+    defer reader.Close()
+
+    // The writer has no fixed topic: each message names its original topic.
+    writer := &kafka.Writer{
+        Addr:         kafka.TCP(brokers...),
+        Balancer:     &kafka.Hash{},
+        RequiredAcks: kafka.RequireAll,
+        BatchTimeout: 10 * time.Millisecond,
+    }
+    defer writer.Close()
+
+    publish := func(ctx context.Context, m kafka.Message) error {
+        return writer.WriteMessages(ctx, m)
+    }
+    return redrive(ctx, reader, publish, dlqTopic, fallbackTopic, max, idle, dryRun)
+}
+
+// redrive is Redrive on top of any reader of the dead-letter topic; publish sends a
+// message to the topic it names.
+func redrive(ctx context.Context, reader messageReader, publish func(ctx context.Context, m kafka.Message) error, dlqTopic, fallbackTopic string, max int, idle time.Duration, dryRun bool) (int, error) {
+    moved := 0
+    for max == 0 || moved < max {
+        fetchCtx, cancel := context.WithTimeout(ctx, idle)
+        m, err := reader.FetchMessage(fetchCtx)
+        cancel()
+        if err != nil {
+            if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+                log.Printf("No message on %s for %s, dead-letter topic drained.", dlqTopic, idle)
+                return moved, nil
+            }
+            return moved, fmt.Errorf("failed to fetch from %s: %w", dlqTopic, err)
+        }
+
+        target := headerValue(m, HeaderDLQOriginalTopic)
+        if target == "" {
+            target = fallbackTopic
+        }
+        log.Printf("Re-driving %s[%d]@%d to %s (attempts=%s, error=%q)",
+            m.Topic, m.Partition, m.Offset, target, headerValue(m, HeaderDLQAttempts), headerValue(m, HeaderDLQError))
+        if dryRun {
+            moved++
+            continue
+        }
+
+        out := kafka.Message{Topic: target, Key: m.Key, Value: m.Value}
+        for _, h := range m.Headers {
+            if !strings.HasPrefix(h.Key, "x-dlq-") {
+                out.Headers = append(out.Headers, h)
+            }
+        }
+        if err := publish(ctx, out); err != nil {
+            return moved, fmt.Errorf("failed to republish message to %s: %w", target, err)
+        }
+        if err := reader.CommitMessages(ctx, m); err != nil {
+            return moved, fmt.Errorf("failed to commit %s[%d]@%d: %w", m.Topic, m.Partition, m.Offset, err)
+        }
+        moved++
+    }
+    return moved, nil
+}
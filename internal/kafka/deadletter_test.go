@@ -0,0 +1,198 @@
+package kafka
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "testing"
+    "time"
+
+    "github.com/segmentio/kafka-go"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+    capped := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+    uncapped := RetryPolicy{InitialBackoff: 100 * time.Millisecond}
+    tests := []struct {
+        name   string
+        policy RetryPolicy
+        retry  int
+        want   time.Duration
+    }{
+        {"first retry", capped, 1, 100 * time.Millisecond},
+        {"doubles", capped, 2, 200 * time.Millisecond},
+        {"doubles again", capped, 4, 800 * time.Millisecond},
+        {"capped", capped, 5, time.Second},
+        {"stays capped", capped, 1000, time.Second},
+        {"initial above max", RetryPolicy{InitialBackoff: 5 * time.Second, MaxBackoff: time.Second}, 1, time.Second},
+        {"no max", uncapped, 6, 3200 * time.Millisecond},
+        {"no backoff", RetryPolicy{}, 3, 0},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := tt.policy.backoff(tt.retry); got != tt.want {
+                t.Errorf("backoff(%d) = %s, want %s", tt.retry, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestPermanent(t *testing.T) {
+    if Permanent(nil) != nil {
+        t.Fatal("Permanent(nil) != nil")
+    }
+
+    base := errors.New("malformed payload")
+    err := fmt.Errorf("handling message: %w", Permanent(base))
+    if !IsPermanent(err) {
+        t.Errorf("IsPermanent(%v) = false, want true through wrapping", err)
+    }
+    if !errors.Is(err, base) {
+        t.Errorf("errors.Is(%v, base) = false, Permanent must unwrap to the cause", err)
+    }
+    if got := Permanent(base).Error(); got != base.Error() {
+        t.Errorf("Permanent(base).Error() = %q, want %q", got, base.Error())
+    }
+    if IsPermanent(base) || IsPermanent(nil) {
+        t.Error("IsPermanent reports true for an unmarked error")
+    }
+}
+
+func TestDeadLetterMessage(t *testing.T) {
+    m := kafka.Message{
+        Topic:     "alerts",
+        Partition: 2,
+        Offset:    41,
+        Key:       []byte("alert-1"),
+        Value:     []byte(`{"id":"alert-1"}`),
+        Headers: []kafka.Header{
+            {Key: HeaderTenant, Value: []byte("acme")},
+            {Key: HeaderDLQError, Value: []byte("failure of an earlier trip")},
+            {Key: HeaderDLQAttempts, Value: []byte("9")},
+        },
+    }
+    before := time.Now().UTC().Truncate(time.Second)
+    out := deadLetterMessage(m, errors.New("analyzer unavailable"), 3)
+
+    if out.Topic != "" {
+        t.Errorf("Topic = %q, want it left to the DLQ producer", out.Topic)
+    }
+    if string(out.Key) != "alert-1" || string(out.Value) != `{"id":"alert-1"}` {
+        t.Errorf("key/value = %q/%q, want the original ones", out.Key, out.Value)
+    }
+
+    counts := make(map[string]int)
+    for _, h := range out.Headers {
+        counts[h.Key]++
+    }
+    want := map[string]string{
+        HeaderTenant:               "acme",
+        HeaderDLQError:             "analyzer unavailable",
+        HeaderDLQAttempts:          "3",
+        HeaderDLQOriginalTopic:     "alerts",
+        HeaderDLQOriginalPartition: "2",
+        HeaderDLQOriginalOffset:    "41",
+    }
+    for key, value := range want {
+        if counts[key] != 1 {
+            t.Errorf("header %s appears %d times, want once", key, counts[key])
+        }
+        if got := headerValue(out, key); got != value {
+            t.Errorf("header %s = %q, want %q", key, got, value)
+        }
+    }
+
+    failedAt, err := time.Parse(time.RFC3339, headerValue(out, HeaderDLQFailedAt))
+    if err != nil {
+        t.Fatalf("header %s: %v", HeaderDLQFailedAt, err)
+    }
+    if failedAt.Before(before) || failedAt.After(time.Now().UTC()) || failedAt.Location() != time.UTC {
+        t.Errorf("header %s = %s, want the current UTC time", HeaderDLQFailedAt, failedAt)
+    }
+}
+
+// topicMessages returns a copy of every message published to topic on the bus.
+func topicMessages(bus *MemoryBus, topic string) []kafka.Message {
+    bus.mu.Lock()
+    defer bus.mu.Unlock()
+    var msgs []kafka.Message
+    if t, ok := bus.topics[topic]; ok {
+        for _, p := range t.partitions {
+            msgs = append(msgs, p...)
+        }
+    }
+    return msgs
+}
+
+func TestRedrive(t *testing.T) {
+    bus := NewMemoryBus(2)
+    defer bus.Close()
+
+    // Two dead-lettered messages from different topics and one without the
+    // original-topic header, e.g. written to the DLQ by hand.
+    tenant := kafka.Header{Key: HeaderTenant, Value: []byte("acme")}
+    dlq := bus.NewProducer("alerts.dlq")
+    for _, m := range []kafka.Message{
+        deadLetterMessage(kafka.Message{Topic: "alerts", Key: []byte("a"), Value: []byte("from alerts"), Headers: []kafka.Header{tenant}}, errors.New("boom"), 4),
+        deadLetterMessage(kafka.Message{Topic: "audit", Key: []byte("b"), Value: []byte("from audit"), Headers: []kafka.Header{tenant}}, errors.New("boom"), 4),
+        {Key: []byte("c"), Value: []byte("unknown origin"), Headers: []kafka.Header{{Key: HeaderDLQError, Value: []byte("boom")}}},
+    } {
+        if err := dlq.PublishMessages(context.Background(), m); err != nil {
+            t.Fatalf("PublishMessages: %v", err)
+        }
+    }
+
+    published := 0
+    publish := func(ctx context.Context, m kafka.Message) error {
+        published++
+        return bus.publish(m.Topic, []kafka.Message{m})
+    }
+    run := func(max int, dryRun bool) int {
+        t.Helper()
+        reader := bus.newReader("alerts.dlq", "redrive")
+        defer reader.Close()
+        moved, err := redrive(context.Background(), reader, publish, "alerts.dlq", "alerts.fallback", max, 50*time.Millisecond, dryRun)
+        if err != nil {
+            t.Fatalf("redrive: %v", err)
+        }
+        return moved
+    }
+
+    // A dry run reports everything but neither publishes nor commits.
+    if moved := run(0, true); moved != 3 {
+        t.Fatalf("dry run moved %d messages, want 3", moved)
+    }
+    if published != 0 || committed(bus, "alerts.dlq", "redrive") != 0 {
+        t.Fatalf("dry run published %d messages and committed %d offsets, want none", published, committed(bus, "alerts.dlq", "redrive"))
+    }
+
+    // max stops the run early; the next run picks up from the committed offsets.
+    if moved := run(2, false); moved != 2 {
+        t.Fatalf("redrive with max 2 moved %d messages", moved)
+    }
+    if moved := run(0, false); moved != 1 {
+        t.Fatalf("second redrive moved %d messages, want the remaining 1", moved)
+    }
+    if moved := run(0, false); moved != 0 {
+        t.Fatalf("redrive of a drained DLQ moved %d messages", moved)
+    }
+    if got := committed(bus, "alerts.dlq", "redrive"); got != 3 {
+        t.Fatalf("committed %d offsets, want 3", got)
+    }
+
+    for topic, value := range map[string]string{"alerts": "from alerts", "audit": "from audit", "alerts.fallback": "unknown origin"} {
+        msgs := topicMessages(bus, topic)
+        if len(msgs) != 1 || string(msgs[0].Value) != value {
+            t.Errorf("topic %s has %d messages, want only %q", topic, len(msgs), value)
+            continue
+        }
+        for _, h := range msgs[0].Headers {
+            if h.Key != HeaderTenant {
+                t.Errorf("topic %s: re-driven message kept header %s", topic, h.Key)
+            }
+        }
+        if topic != "alerts.fallback" && TenantOf(msgs[0]) != "acme" {
+            t.Errorf("topic %s: re-driven message lost its tenant header", topic)
+        }
+    }
+}
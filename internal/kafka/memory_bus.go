@@ -0,0 +1,229 @@
+package kafka
+
+import (
+    "context"
+    "errors"
+    "hash/fnv"
+    "log"
+    "sync"
+    "time"
+
+    "github.com/segmentio/kafka-go"
+)
+
+// ErrBusClosed is returned by readers and producers of a MemoryBus that has been closed.
+var ErrBusClosed = errors.New("message bus closed")
+
+// MemoryBus is an in-process stand-in for a Kafka cluster, so the API server and the
+// processor can run in one binary without a broker, e.g. for local development and
+// integration tests. Topics are split into partitions by message key like the Hash
+// balancer does, and consumer groups behave like Kafka's: every group sees every
+// message once, and a group that is reopened resumes after its last committed offset,
+// so uncommitted messages are redelivered. Messages are kept for the lifetime of the bus.
+type MemoryBus struct {
+    partitions int
+
+    mu      sync.Mutex
+    topics  map[string]*memoryTopic
+    changed chan struct{} // Closed and replaced whenever messages are appended
+    closed  bool
+}
+
+type memoryTopic struct {
+    partitions  [][]kafka.Message
+    groups      map[string]*memoryGroup
+    nextKeyless int // Round-robin partition for messages without a key
+}
+
+// memoryGroup holds a consumer group's offsets for one topic, indexed by partition.
+type memoryGroup struct {
+    committed []int64 // Next offset to deliver after the group is reopened
+    fetched   []int64 // Next offset to hand out to the group's readers
+    members   int
+}
+
+// NewMemoryBus creates an empty bus whose topics have the given number of partitions.
+func NewMemoryBus(partitions int) *MemoryBus {
+    if partitions < 1 {
+        partitions = 1
+    }
+    log.Printf("In-memory message bus initialized with %d partitions per topic", partitions)
+    return &MemoryBus{partitions: partitions, topics: make(map[string]*memoryTopic), changed: make(chan struct{})}
+}
+
+// topicLocked returns the named topic, creating it on first use like AllowAutoTopicCreation.
+func (b *MemoryBus) topicLocked(name string) *memoryTopic {
+    t, ok := b.topics[name]
+    if !ok {
+        t = &memoryTopic{partitions: make([][]kafka.Message, b.partitions), groups: make(map[string]*memoryGroup)}
+        b.topics[name] = t
+    }
+    return t
+}
+
+// partitionFor picks the partition for a key; keyless messages are spread round-robin.
+func (t *memoryTopic) partitionFor(key []byte) int {
+    if len(key) == 0 {
+        p := t.nextKeyless
+        t.nextKeyless = (t.nextKeyless + 1) % len(t.partitions)
+        return p
+    }
+    h := fnv.New32a()
+    h.Write(key)
+    return int(h.Sum32() % uint32(len(t.partitions)))
+}
+
+// publish appends msgs to topic and wakes up waiting readers.
+func (b *MemoryBus) publish(topic string, msgs []kafka.Message) error {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if b.closed {
+        return ErrBusClosed
+    }
+
+    t := b.topicLocked(topic)
+    now := time.Now()
+    for _, m := range msgs {
+        p := t.partitionFor(m.Key)
+        m.Topic = topic
+        m.Partition = p
+        m.Offset = int64(len(t.partitions[p]))
+        if m.Time.IsZero() {
+            m.Time = now
+        }
+        t.partitions[p] = append(t.partitions[p], m)
+    }
+    close(b.changed)
+    b.changed = make(chan struct{})
+    return nil
+}
+
+// Close stops the bus: blocked fetches return ErrBusClosed and publishing fails.
+func (b *MemoryBus) Close() error {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if !b.closed {
+        b.closed = true
+        close(b.changed)
+    }
+    return nil
+}
+
+// memoryProducer publishes to one topic of a MemoryBus.
+type memoryProducer struct {
+    bus   *MemoryBus
+    topic string
+}
+
+// NewProducer returns a Publisher for topic.
+func (b *MemoryBus) NewProducer(topic string) Publisher {
+    return &memoryProducer{bus: b, topic: topic}
+}
+
+func (p *memoryProducer) PublishMessages(ctx context.Context, msgs ...kafka.Message) error {
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+    return p.bus.publish(p.topic, msgs)
+}
+
+func (p *memoryProducer) Close() error { return nil }
+
+// NewConsumer returns a consumer of topic in the given group, with the same worker
+// pool, retry policy and dead-letter handling as the Kafka consumer. Dead-lettered
+// messages are published to policy.DeadLetterTopic on this bus.
+func (b *MemoryBus) NewConsumer(topic, groupID string, policy RetryPolicy, workers int) *Consumer {
+    var dlq Publisher
+    if policy.DeadLetterTopic != "" {
+        dlq = b.NewProducer(policy.DeadLetterTopic)
+    }
+    log.Printf("In-memory consumer initialized for topic '%s', group '%s'", topic, groupID)
+    return newConsumer(b.newReader(topic, groupID), dlq, policy, workers)
+}
+
+// memoryReader is a member of a consumer group on a MemoryBus.
+type memoryReader struct {
+    bus     *MemoryBus
+    topic   string
+    groupID string
+    next    int // Partition to look at first on the next fetch, so partitions take turns
+
+    closeOnce sync.Once
+}
+
+func (b *MemoryBus) newReader(topic, groupID string) *memoryReader {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    t := b.topicLocked(topic)
+    g, ok := t.groups[groupID]
+    if !ok {
+        g = &memoryGroup{committed: make([]int64, b.partitions), fetched: make([]int64, b.partitions)}
+        t.groups[groupID] = g
+    }
+    if g.members == 0 {
+        // The group was empty, like after a restart: resume from the committed offsets.
+        copy(g.fetched, g.committed)
+    }
+    g.members++
+    return &memoryReader{bus: b, topic: topic, groupID: groupID}
+}
+
+// FetchMessage returns the group's next message, blocking until one is published.
+func (r *memoryReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+    for {
+        r.bus.mu.Lock()
+        if r.bus.closed {
+            r.bus.mu.Unlock()
+            return kafka.Message{}, ErrBusClosed
+        }
+        t := r.bus.topics[r.topic]
+        g := t.groups[r.groupID]
+        for i := 0; i < len(t.partitions); i++ {
+            p := (r.next + i) % len(t.partitions)
+            if g.fetched[p] < int64(len(t.partitions[p])) {
+                m := t.partitions[p][g.fetched[p]]
+                g.fetched[p]++
+                r.next = (p + 1) % len(t.partitions)
+                r.bus.mu.Unlock()
+                return m, nil
+            }
+        }
+        changed := r.bus.changed
+        r.bus.mu.Unlock()
+
+        select {
+        case <-ctx.Done():
+            return kafka.Message{}, ctx.Err()
+        case <-changed:
+        }
+    }
+}
+
+// CommitMessages records that the group has handled every message up to and including msgs.
+func (r *memoryReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+    r.bus.mu.Lock()
+    defer r.bus.mu.Unlock()
+
+    g := r.bus.topics[r.topic].groups[r.groupID]
+    for _, m := range msgs {
+        if m.Partition < 0 || m.Partition >= len(g.committed) {
+            continue
+        }
+        if m.Offset+1 > g.committed[m.Partition] {
+            g.committed[m.Partition] = m.Offset + 1
+        }
+    }
+    return nil
+}
+
+// Close leaves the group. Once every member has left, the next reader starts over
+// from the committed offsets.
+func (r *memoryReader) Close() error {
+    r.closeOnce.Do(func() {
+        r.bus.mu.Lock()
+        r.bus.topics[r.topic].groups[r.groupID].members--
+        r.bus.mu.Unlock()
+    })
+    return nil
+}
@@ -0,0 +1,174 @@
+package kafka
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/segmentio/kafka-go"
+)
+
+func publishN(t *testing.T, p Publisher, n int) {
+    t.Helper()
+    for i := 0; i < n; i++ {
+        m := kafka.Message{Key: []byte(fmt.Sprintf("key-%d", i%3)), Value: []byte(fmt.Sprintf("msg-%d", i))}
+        if err := p.PublishMessages(context.Background(), m); err != nil {
+            t.Fatalf("PublishMessages: %v", err)
+        }
+    }
+}
+
+// consumeUntil runs c until handler has seen want messages or a timeout passes.
+func consumeUntil(t *testing.T, c *Consumer, want int, handler func(m kafka.Message) error) []string {
+    t.Helper()
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    var mu sync.Mutex
+    var seen []string
+    c.ConsumeMessages(ctx, func(m kafka.Message) error {
+        err := handler(m)
+        mu.Lock()
+        seen = append(seen, string(m.Value))
+        if len(seen) == want {
+            cancel()
+        }
+        mu.Unlock()
+        return err
+    })
+    if len(seen) != want {
+        t.Fatalf("handled %d messages, want %d", len(seen), want)
+    }
+    return seen
+}
+
+func TestMemoryBusGroups(t *testing.T) {
+    bus := NewMemoryBus(3)
+    defer bus.Close()
+    publishN(t, bus.NewProducer("alerts"), 10)
+
+    ok := func(kafka.Message) error { return nil }
+    for _, group := range []string{"g1", "g2"} {
+        c := bus.NewConsumer("alerts", group, RetryPolicy{}, 2)
+        seen := consumeUntil(t, c, 10, ok)
+        c.Close()
+
+        unique := make(map[string]bool)
+        for _, v := range seen {
+            unique[v] = true
+        }
+        if len(unique) != 10 {
+            t.Errorf("group %s saw %d distinct messages, want 10", group, len(unique))
+        }
+    }
+}
+
+func TestMemoryBusRedeliversUncommitted(t *testing.T) {
+    bus := NewMemoryBus(1)
+    defer bus.Close()
+    publishN(t, bus.NewProducer("alerts"), 3)
+
+    ctx := context.Background()
+    r := bus.newReader("alerts", "g")
+    first, _ := r.FetchMessage(ctx)
+    second, _ := r.FetchMessage(ctx)
+    if err := r.CommitMessages(ctx, first); err != nil {
+        t.Fatalf("CommitMessages: %v", err)
+    }
+    if second.Offset != 1 {
+        t.Fatalf("second message has offset %d, want 1", second.Offset)
+    }
+    r.Close()
+
+    // The group restarts: the fetched but uncommitted message comes back.
+    r = bus.newReader("alerts", "g")
+    defer r.Close()
+    m, err := r.FetchMessage(ctx)
+    if err != nil || string(m.Value) != "msg-1" {
+        t.Fatalf("after reopening the group: got %q, %v, want msg-1", m.Value, err)
+    }
+
+    fetchCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+    defer cancel()
+    r.FetchMessage(fetchCtx)
+    if _, err := r.FetchMessage(fetchCtx); !errors.Is(err, context.DeadlineExceeded) {
+        t.Fatalf("fetch past the end: got %v, want context.DeadlineExceeded", err)
+    }
+}
+
+// committed returns the total number of messages committed by group on topic.
+func committed(bus *MemoryBus, topic, group string) int64 {
+    bus.mu.Lock()
+    defer bus.mu.Unlock()
+    var n int64
+    for _, offset := range bus.topics[topic].groups[group].committed {
+        n += offset
+    }
+    return n
+}
+
+func TestMemoryBusDeadLetter(t *testing.T) {
+    bus := NewMemoryBus(2)
+    defer bus.Close()
+    publishN(t, bus.NewProducer("alerts"), 4)
+
+    policy := RetryPolicy{MaxRetries: 1, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, DeadLetterTopic: "alerts.dlq"}
+    c := bus.NewConsumer("alerts", "g", policy, 2)
+    defer c.Close()
+
+    var mu sync.Mutex
+    calls := make(map[string]int)
+    ctx, cancel := context.WithCancel(context.Background())
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        c.ConsumeMessages(ctx, func(m kafka.Message) error {
+            mu.Lock()
+            defer mu.Unlock()
+            calls[string(m.Value)]++
+            switch string(m.Value) {
+            case "msg-0":
+                return errors.New("temporary failure") // Retried once, then dead-lettered
+            case "msg-1":
+                return Permanent(errors.New("malformed")) // Dead-lettered straight away
+            }
+            return nil
+        })
+    }()
+
+    dlq := bus.newReader("alerts.dlq", "inspect")
+    defer dlq.Close()
+    got := make(map[string]string)
+    fetchCtx, cancelFetch := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancelFetch()
+    for len(got) < 2 {
+        m, err := dlq.FetchMessage(fetchCtx)
+        if err != nil {
+            t.Fatalf("dead-letter topic: got %d messages, want 2: %v", len(got), err)
+        }
+        got[string(m.Value)] = headerValue(m, HeaderDLQAttempts)
+        if headerValue(m, HeaderDLQOriginalTopic) != "alerts" {
+            t.Errorf("dead-lettered %s has original topic %q", m.Value, headerValue(m, HeaderDLQOriginalTopic))
+        }
+    }
+    if got["msg-0"] != "2" || got["msg-1"] != "1" {
+        t.Errorf("dead-letter attempts: got %v", got)
+    }
+
+    // Every message is committed once it was handled or dead-lettered.
+    deadline := time.Now().Add(5 * time.Second)
+    for committed(bus, "alerts", "g") < 4 && time.Now().Before(deadline) {
+        time.Sleep(5 * time.Millisecond)
+    }
+    cancel()
+    <-done
+    if n := committed(bus, "alerts", "g"); n != 4 {
+        t.Errorf("committed %d messages, want 4", n)
+    }
+    if calls["msg-0"] != 2 || calls["msg-1"] != 1 || calls["msg-2"] != 1 || calls["msg-3"] != 1 {
+        t.Errorf("handler calls: got %v, want msg-0 twice and every other message once", calls)
+    }
+}
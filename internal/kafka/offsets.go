@@ -0,0 +1,82 @@
+package kafka
+
+import (
+    "sync"
+
+    "github.com/segmentio/kafka-go"
+)
+
+// offsetTracker records which fetched messages have been fully handled so that,
+// with several workers finishing out of order, offsets are only committed up to
+// the highest contiguous completed offset of each partition. Committing further
+// would lose the messages still in flight if the processor crashed.
+// After a rebalance the reader may fetch again messages that were fetched but not
+// yet committed; those are recognised and never move a commit backwards.
+type offsetTracker struct {
+    mu         sync.Mutex
+    partitions map[partitionKey]*partitionOffsets
+}
+
+type partitionKey struct {
+    topic     string
+    partition int
+}
+
+type partitionOffsets struct {
+    pending   []int64        // Fetched but not yet committable, in fetch order
+    completed map[int64]bool // Members of pending that have been handled
+    next      int64          // One past the highest offset tracked so far
+}
+
+func newOffsetTracker() *offsetTracker {
+    return &offsetTracker{partitions: make(map[partitionKey]*partitionOffsets)}
+}
+
+// track registers a fetched message. It must be called in fetch order, before the
+// message is handed to a worker. It returns false if m was already tracked, i.e. it
+// was fetched again after a rebalance, in which case it must not be handled twice.
+func (t *offsetTracker) track(m kafka.Message) bool {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    key := partitionKey{m.Topic, m.Partition}
+    p, ok := t.partitions[key]
+    if !ok {
+        p = &partitionOffsets{completed: make(map[int64]bool)}
+        t.partitions[key] = p
+    }
+    if m.Offset < p.next {
+        return false // Already pending, or handled and committed
+    }
+    p.pending = append(p.pending, m.Offset)
+    p.next = m.Offset + 1
+    return true
+}
+
+// complete marks m as handled. If that extends the contiguous run of handled
+// messages at the head of its partition, it returns the last message of the run,
+// which is the one to commit. Messages that are not pending are ignored.
+func (t *offsetTracker) complete(m kafka.Message) (kafka.Message, bool) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    p, ok := t.partitions[partitionKey{m.Topic, m.Partition}]
+    if !ok || len(p.pending) == 0 || m.Offset < p.pending[0] || m.Offset >= p.next {
+        return kafka.Message{}, false
+    }
+    p.completed[m.Offset] = true
+
+    advanced := false
+    var last int64
+    for len(p.pending) > 0 && p.completed[p.pending[0]] {
+        last = p.pending[0]
+        delete(p.completed, last)
+        p.pending = p.pending[1:]
+        advanced = true
+    }
+    if !advanced {
+        return kafka.Message{}, false
+    }
+    // CommitMessages only looks at topic, partition and offset.
+    return kafka.Message{Topic: m.Topic, Partition: m.Partition, Offset: last}, true
+}
@@ -0,0 +1,104 @@
+package kafka
+
+import (
+    "testing"
+
+    "github.com/segmentio/kafka-go"
+)
+
+func msgAt(topic string, partition int, offset int64) kafka.Message {
+    return kafka.Message{Topic: topic, Partition: partition, Offset: offset}
+}
+
+// expectCommit completes m and checks which offset, if any, became committable.
+func expectCommit(t *testing.T, tr *offsetTracker, m kafka.Message, want int64) {
+    t.Helper()
+    got, ok := tr.complete(m)
+    if want < 0 {
+        if ok {
+            t.Fatalf("complete(%s[%d]@%d) committed offset %d, want nothing", m.Topic, m.Partition, m.Offset, got.Offset)
+        }
+        return
+    }
+    if !ok {
+        t.Fatalf("complete(%s[%d]@%d) committed nothing, want offset %d", m.Topic, m.Partition, m.Offset, want)
+    }
+    if got.Topic != m.Topic || got.Partition != m.Partition || got.Offset != want {
+        t.Fatalf("complete(%s[%d]@%d) committed %s[%d]@%d, want offset %d", m.Topic, m.Partition, m.Offset, got.Topic, got.Partition, got.Offset, want)
+    }
+}
+
+func trackAll(t *testing.T, tr *offsetTracker, msgs ...kafka.Message) {
+    t.Helper()
+    for _, m := range msgs {
+        if !tr.track(m) {
+            t.Fatalf("track(%s[%d]@%d) = false, want true", m.Topic, m.Partition, m.Offset)
+        }
+    }
+}
+
+func TestOffsetTrackerOutOfOrder(t *testing.T) {
+    tr := newOffsetTracker()
+    trackAll(t, tr, msgAt("alerts", 0, 0), msgAt("alerts", 0, 1), msgAt("alerts", 0, 2))
+
+    expectCommit(t, tr, msgAt("alerts", 0, 2), -1) // 0 and 1 are still in flight
+    expectCommit(t, tr, msgAt("alerts", 0, 1), -1)
+    expectCommit(t, tr, msgAt("alerts", 0, 0), 2) // The whole run becomes committable at once
+}
+
+func TestOffsetTrackerPartitions(t *testing.T) {
+    tr := newOffsetTracker()
+    trackAll(t, tr,
+        msgAt("alerts", 0, 10), msgAt("alerts", 1, 10),
+        msgAt("alerts", 0, 11), msgAt("alerts", 1, 11),
+        msgAt("audit", 0, 10),
+    )
+
+    expectCommit(t, tr, msgAt("alerts", 1, 10), 10)
+    expectCommit(t, tr, msgAt("alerts", 0, 11), -1) // Partition 1 progressing does not unblock partition 0
+    expectCommit(t, tr, msgAt("audit", 0, 10), 10)  // Same partition number, different topic
+    expectCommit(t, tr, msgAt("alerts", 1, 11), 11)
+    expectCommit(t, tr, msgAt("alerts", 0, 10), 11)
+}
+
+func TestOffsetTrackerGap(t *testing.T) {
+    tr := newOffsetTracker()
+    // Offsets need not be contiguous, e.g. on a compacted topic.
+    trackAll(t, tr, msgAt("alerts", 0, 3), msgAt("alerts", 0, 7), msgAt("alerts", 0, 8), msgAt("alerts", 0, 12))
+
+    expectCommit(t, tr, msgAt("alerts", 0, 3), 3)
+    expectCommit(t, tr, msgAt("alerts", 0, 8), -1)  // 7 is still in flight and blocks the commit
+    expectCommit(t, tr, msgAt("alerts", 0, 12), -1) // Still blocked
+    expectCommit(t, tr, msgAt("alerts", 0, 7), 12)
+}
+
+func TestOffsetTrackerRefetch(t *testing.T) {
+    tr := newOffsetTracker()
+    trackAll(t, tr, msgAt("alerts", 0, 0), msgAt("alerts", 0, 1), msgAt("alerts", 0, 2))
+    expectCommit(t, tr, msgAt("alerts", 0, 0), 0)
+    expectCommit(t, tr, msgAt("alerts", 0, 2), -1)
+
+    // A rebalance makes the reader resume from the committed offset, so 1 and 2
+    // are fetched again while 1 is in flight and 2 is done.
+    for _, offset := range []int64{1, 2} {
+        if tr.track(msgAt("alerts", 0, offset)) {
+            t.Fatalf("track(alerts[0]@%d) after a re-fetch = true, want false", offset)
+        }
+    }
+    // So is an offset that was already committed.
+    if tr.track(msgAt("alerts", 0, 0)) {
+        t.Fatal("track(alerts[0]@0) after a re-fetch = true, want false")
+    }
+    expectCommit(t, tr, msgAt("alerts", 0, 0), -1) // Completing a committed offset is a no-op
+
+    trackAll(t, tr, msgAt("alerts", 0, 3))
+    expectCommit(t, tr, msgAt("alerts", 0, 1), 2)
+    expectCommit(t, tr, msgAt("alerts", 0, 3), 3)
+
+    // Nothing is left behind once everything has been committed.
+    p := tr.partitions[partitionKey{"alerts", 0}]
+    if len(p.pending) != 0 || len(p.completed) != 0 {
+        t.Fatalf("after committing everything: pending %v, completed %v, want both empty", p.pending, p.completed)
+    }
+    expectCommit(t, tr, msgAt("alerts", 0, 2), -1)
+}
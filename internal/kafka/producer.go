@@ -18,18 +18,14 @@ func NewProducer(brokers []string, topic string) *Producer {
     writer := &kafka.Writer{
         Addr:     kafka.TCP(brokers...),
         Topic:    topic,
-        Balancer: &kafka.LeastBytes{}, // Distribute messages among partitions
+        Balancer: &kafka.Hash{}, // Messages with the same key always land on the same partition
         // Required for local development to ensure topics are created automatically
         // This is generally not recommended for production.
         AllowAutoTopicCreation: true,
-        // Async writes for performance
-        Async: true,
-        Completion: func(messages []kafka.Message, err error) {
-            if err != nil {
-                // For debugging, you might log errors, but in production, handle retries or dead-letter queues.
-                log.Printf("ERROR Kafka message completion: %v", err)
-            }
-        },
+        // Writes are synchronous so callers (the outbox relay) learn whether a message
+        // was really acknowledged by the brokers and can retry if not.
+        RequiredAcks: kafka.RequireAll,
+        BatchTimeout: 10 * time.Millisecond, // Don't hold single messages back for the default 1s
     }
     log.Printf("Kafka producer initialized for topic '%s' on brokers %v", topic, brokers)
     return &Producer{writer: writer}
@@ -45,6 +41,17 @@ func (p *Producer) PublishMessage(ctx context.Context, key, value []byte) error
     return p.writer.WriteMessages(ctx, msg)
 }
 
+// PublishMessages sends a batch of messages to Kafka in a single WriteMessages call.
+func (p *Producer) PublishMessages(ctx context.Context, msgs ...kafka.Message) error {
+    now := time.Now()
+    for i := range msgs {
+        if msgs[i].Time.IsZero() {
+            msgs[i].Time = now
+        }
+    }
+    return p.writer.WriteMessages(ctx, msgs...)
+}
+
 // Close closes the Kafka producer connection.
 func (p *Producer) Close() error {
     log.Println("Closing Kafka producer...")
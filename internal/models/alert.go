@@ -1,12 +1,14 @@
 package models
 
 import (
+    "encoding/json"
     "time"
 )
 
 // SecurityAlert represents a generic security alert structure.
 type SecurityAlert struct {
     ID               string    `json:"id"`
+    TenantID         string    `json:"tenant_id,omitempty"` // Set from the caller's credentials, never from the request body
     Source           string    `json:"source"`
     Timestamp        time.Time `json:"timestamp"`
     Severity         string    `json:"severity"`
@@ -18,7 +20,12 @@ type SecurityAlert struct {
     Hostname         string    `json:"hostname,omitempty"`
     Username         string    `json:"username,omitempty"`
     FileHash         string    `json:"file_hash,omitempty"`
-    Status           string    `json:"status"` // e.g., "new", "processed", "triaged"
+    // Vendor fields with no alert field of their own, e.g. unmapped CEF extensions
+    Attributes       map[string]string `json:"attributes,omitempty"`
+    // The payload the alert was submitted as, before mapping: the JSON body, or the
+    // CEF, LEEF or syslog line as a JSON string
+    RawPayload       json.RawMessage `json:"raw_payload,omitempty"`
+    Status           AlertStatus `json:"status"` // Lifecycle state, see status.go
     CreatedAt        time.Time `json:"created_at"` // This is usually set by DB, not client
 
     // New AI/ML related fields
@@ -26,4 +33,22 @@ type SecurityAlert struct {
     RiskScore         float64   `json:"risk_score,omitempty"`        // Numerical risk score from AI
     RecommendedAction string    `json:"recommended_action,omitempty"` // AI's recommended action
     AIModelVersion    string    `json:"ai_model_version,omitempty"`   // Version of AI model used
+
+    // Deduplication: repeats of this alert within the dedup window are folded into it
+    Fingerprint     string    `json:"fingerprint,omitempty"`      // Hash of the configured identifying fields
+    OccurrenceCount int       `json:"occurrence_count,omitempty"` // Number of times the alert was received
+    LastSeen        time.Time `json:"last_seen"`                  // Timestamp of the latest occurrence
+
+    // Sigma rules the alert matched in the processor
+    SigmaMatches []SigmaMatch `json:"sigma_matches,omitempty"`
+
+    // Context added by the processor's enrichment stage, see enrichment.go
+    Enrichment *Enrichment `json:"enrichment,omitempty"`
+}
+
+// SigmaMatch identifies a Sigma rule that matched an alert.
+type SigmaMatch struct {
+    RuleID string `json:"rule_id"`
+    Title  string `json:"title"`
+    Level  string `json:"level"` // informational, low, medium, high or critical
 }
\ No newline at end of file
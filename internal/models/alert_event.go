@@ -0,0 +1,24 @@
+package models
+
+import (
+    "time"
+)
+
+// AlertEventType names what happened to an alert in the alert event stream.
+type AlertEventType string
+
+// Alert event types.
+const (
+    AlertEventCreated  AlertEventType = "alert.created"  // A new alert was stored
+    AlertEventAnalyzed AlertEventType = "alert.analyzed" // The processor stored its analysis of an alert
+)
+
+// AlertEvent is one entry of the alert event log behind GET /alerts/stream. Events are
+// written in the same transaction as the change they record and carry a snapshot of
+// the alert as it was right after that change.
+type AlertEvent struct {
+    ID        int64          `json:"id"` // Increases with every event, used as SSE event ID for resuming
+    Type      AlertEventType `json:"type"`
+    Alert     SecurityAlert  `json:"alert"`
+    CreatedAt time.Time      `json:"created_at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+    "time"
+)
+
+// APIKey is a credential issued to a sensor or script calling the API. Only a hash of
+// the key is stored; the key itself is shown once, when it is created.
+type APIKey struct {
+    ID         string     `json:"id"` // Public part of the key, also used to look it up
+    Name       string     `json:"name"`
+    TenantID   string     `json:"tenant_id"` // Tenant whose alerts the key gives access to
+    Hash       []byte     `json:"-"` // SHA-256 of the full key
+    Roles      []string   `json:"roles"`
+    CreatedBy  string     `json:"created_by,omitempty"`
+    CreatedAt  time.Time  `json:"created_at"`
+    ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+    LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+    RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// IsActive reports whether the key may be used at the given time.
+func (k *APIKey) IsActive(now time.Time) bool {
+    return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
@@ -0,0 +1,64 @@
+package models
+
+// Enrichment holds context the processor adds to an alert from local data sources.
+type Enrichment struct {
+    ThreatIntel []ThreatIntelMatch `json:"threat_intel,omitempty"` // Known-bad indicators found in the alert
+    SourceGeo   *GeoInfo           `json:"source_geo,omitempty"`   // Where source_ip lives
+    TargetGeo   *GeoInfo           `json:"target_geo,omitempty"`   // Where target_ip lives
+}
+
+// IsEmpty reports whether no enrichment was recorded.
+func (e *Enrichment) IsEmpty() bool {
+    return e == nil || (len(e.ThreatIntel) == 0 && e.SourceGeo == nil && e.TargetGeo == nil)
+}
+
+// Clone returns a deep copy of e.
+func (e *Enrichment) Clone() *Enrichment {
+    if e == nil {
+        return nil
+    }
+    c := *e
+    c.ThreatIntel = append([]ThreatIntelMatch(nil), e.ThreatIntel...)
+    if e.SourceGeo != nil {
+        geo := *e.SourceGeo
+        c.SourceGeo = &geo
+    }
+    if e.TargetGeo != nil {
+        geo := *e.TargetGeo
+        c.TargetGeo = &geo
+    }
+    return &c
+}
+
+// GeoInfo describes where an IP address lives and who operates it, from the offline
+// GeoIP databases. Private and reserved addresses are flagged instead of looked up.
+type GeoInfo struct {
+    IP          string   `json:"ip"`
+    Country     string   `json:"country,omitempty"` // ISO 3166-1 alpha-2 code, upper case
+    CountryName string   `json:"country_name,omitempty"`
+    City        string   `json:"city,omitempty"`
+    Latitude    *float64 `json:"latitude,omitempty"`
+    Longitude   *float64 `json:"longitude,omitempty"`
+    ASN         uint32   `json:"asn,omitempty"`
+    ASOrg       string   `json:"as_org,omitempty"`
+    Private     bool     `json:"private,omitempty"`  // RFC 1918 and unique local addresses
+    Reserved    bool     `json:"reserved,omitempty"` // Loopback, link-local, multicast, documentation, ...
+}
+
+// Indicator types understood by the threat intel feeds.
+const (
+    IndicatorIP     = "ip"
+    IndicatorCIDR   = "cidr"
+    IndicatorDomain = "domain"
+    IndicatorHash   = "hash"
+)
+
+// ThreatIntelMatch is an indicator of compromise that matched one of the alert's fields.
+type ThreatIntelMatch struct {
+    Field       string `json:"field"`     // Alert field that matched, e.g. source_ip or description
+    Value       string `json:"value"`     // Value found in the alert
+    Indicator   string `json:"indicator"` // Indicator as listed in the feed, e.g. a CIDR block
+    Type        string `json:"type"`      // ip, cidr, domain or hash
+    Feed        string `json:"feed"`      // Name of the feed file
+    Description string `json:"description,omitempty"`
+}
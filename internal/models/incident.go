@@ -0,0 +1,148 @@
+package models
+
+import (
+    "math"
+    "strings"
+    "time"
+)
+
+// IncidentStatus is the lifecycle state of an Incident.
+type IncidentStatus string
+
+// Incident lifecycle states. Only open and investigating incidents receive new alerts.
+const (
+    IncidentOpen          IncidentStatus = "open"
+    IncidentInvestigating IncidentStatus = "investigating"
+    IncidentResolved      IncidentStatus = "resolved"
+)
+
+// incidentTransitions lists, for every state, the states it may move to next.
+var incidentTransitions = map[IncidentStatus][]IncidentStatus{
+    IncidentOpen:          {IncidentInvestigating, IncidentResolved},
+    IncidentInvestigating: {IncidentResolved},
+}
+
+// IsValid reports whether s is one of the known incident states.
+func (s IncidentStatus) IsValid() bool {
+    switch s {
+    case IncidentOpen, IncidentInvestigating, IncidentResolved:
+        return true
+    }
+    return false
+}
+
+// AcceptsAlerts reports whether the correlation engine may still attach alerts.
+func (s IncidentStatus) AcceptsAlerts() bool {
+    return s == IncidentOpen || s == IncidentInvestigating
+}
+
+// CanTransitionTo reports whether moving from s to next is allowed.
+func (s IncidentStatus) CanTransitionTo(next IncidentStatus) bool {
+    for _, allowed := range incidentTransitions[s] {
+        if allowed == next {
+            return true
+        }
+    }
+    return false
+}
+
+// Entity kinds alerts are correlated on.
+const (
+    EntityHostname = "hostname"
+    EntityUsername = "username"
+    EntitySourceIP = "source_ip"
+    EntityFileHash = "file_hash"
+)
+
+// IncidentEntity is a host, user, address or file shared by the alerts of an incident.
+type IncidentEntity struct {
+    Kind     string    `json:"kind"`
+    Value    string    `json:"value"`
+    LastSeen time.Time `json:"last_seen"` // Timestamp of the latest member alert with this entity
+}
+
+// Incident timeline event types.
+const (
+    IncidentEventCreated       = "created"
+    IncidentEventAlertAdded    = "alert_added"
+    IncidentEventStatusChanged = "status_changed"
+)
+
+// IncidentEvent is one entry of an incident's timeline.
+type IncidentEvent struct {
+    Time    time.Time `json:"time"`
+    Type    string    `json:"type"`
+    AlertID string    `json:"alert_id,omitempty"`
+    Message string    `json:"message"`
+}
+
+// Incident groups related alerts that analysts work as one case.
+type Incident struct {
+    ID         string         `json:"id"`
+    TenantID   string         `json:"tenant_id"` // Tenant of every member alert
+    Title      string         `json:"title"`
+    Status     IncidentStatus `json:"status"`
+    Severity   string         `json:"severity"`   // Highest severity among the member alerts
+    RiskScore  float64        `json:"risk_score"` // Aggregate of the member alerts' risk scores, see Recompute
+    AlertCount int            `json:"alert_count"`
+    FirstSeen  time.Time      `json:"first_seen"`
+    LastSeen   time.Time      `json:"last_seen"`
+    CreatedAt  time.Time      `json:"created_at"`
+    UpdatedAt  time.Time      `json:"updated_at"`
+
+    // Only filled in when a single incident is loaded
+    Entities []IncidentEntity `json:"entities,omitempty"`
+    Alerts   []SecurityAlert  `json:"alerts,omitempty"`
+    Timeline []IncidentEvent  `json:"timeline,omitempty"`
+}
+
+// severityRanks orders the severities used by alert sources and the analyzers.
+var severityRanks = map[string]int{
+    "informational": 1,
+    "info":          1,
+    "low":           2,
+    "medium":        3,
+    "high":          4,
+    "critical":      5,
+}
+
+// SeverityRank returns a number that orders severities, 0 for unknown ones.
+func SeverityRank(severity string) int {
+    return severityRanks[strings.ToLower(severity)]
+}
+
+// Recompute derives the incident's aggregates from its member alerts.
+//
+// The risk score combines the members' scores as independent pieces of evidence:
+// 1 - (1-r1)(1-r2)...(1-rn). It never drops when an alert is added, and several
+// medium-risk alerts on the same entities add up to a high-risk incident.
+// The severity is the highest predicted severity, or reported severity where the
+// alert has not been analysed yet.
+func (inc *Incident) Recompute(members []SecurityAlert) {
+    inc.AlertCount = len(members)
+    inc.Severity = ""
+    benign := 1.0
+    for i, a := range members {
+        if i == 0 || a.Timestamp.Before(inc.FirstSeen) {
+            inc.FirstSeen = a.Timestamp
+        }
+        lastSeen := a.LastSeen
+        if lastSeen.IsZero() || lastSeen.Before(a.Timestamp) {
+            lastSeen = a.Timestamp
+        }
+        if i == 0 || lastSeen.After(inc.LastSeen) {
+            inc.LastSeen = lastSeen
+        }
+
+        severity := a.PredictedSeverity
+        if severity == "" {
+            severity = a.Severity
+        }
+        if inc.Severity == "" || SeverityRank(severity) > SeverityRank(inc.Severity) {
+            inc.Severity = strings.ToLower(severity)
+        }
+
+        benign *= 1 - math.Max(0, math.Min(1, a.RiskScore))
+    }
+    inc.RiskScore = math.Round((1-benign)*1000) / 1000 // Same precision as the risk_score columns
+}
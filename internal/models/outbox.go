@@ -0,0 +1,18 @@
+package models
+
+import (
+    "time"
+)
+
+// OutboxMessage is a Kafka message waiting in the transactional outbox.
+// It is written in the same transaction as the alert it describes and
+// relayed to Kafka asynchronously, so a stored alert always reaches the processor.
+type OutboxMessage struct {
+    ID        int64
+    AlertID   string
+    TenantID  string // Sent as the message's tenant header
+    Key       []byte
+    Payload   []byte
+    Attempts  int // Failed publish attempts so far
+    CreatedAt time.Time
+}
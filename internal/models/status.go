@@ -0,0 +1,49 @@
+package models
+
+// AlertStatus is the lifecycle state of a SecurityAlert.
+type AlertStatus string
+
+// Alert lifecycle states. An alert enters the pipeline as "new", is moved to
+// "analyzed" by the processor, and is then worked by analysts until it ends up
+// either "resolved" or "false_positive".
+const (
+    StatusNew           AlertStatus = "new"
+    StatusAnalyzed      AlertStatus = "analyzed"
+    StatusTriaged       AlertStatus = "triaged"
+    StatusInvestigating AlertStatus = "investigating"
+    StatusResolved      AlertStatus = "resolved"
+    StatusFalsePositive AlertStatus = "false_positive"
+)
+
+// statusTransitions lists, for every state, the states it may move to next.
+// Terminal states have no entry.
+var statusTransitions = map[AlertStatus][]AlertStatus{
+    StatusNew:           {StatusAnalyzed},
+    StatusAnalyzed:      {StatusTriaged},
+    StatusTriaged:       {StatusInvestigating},
+    StatusInvestigating: {StatusResolved, StatusFalsePositive},
+}
+
+// IsValid reports whether s is one of the known lifecycle states.
+func (s AlertStatus) IsValid() bool {
+    switch s {
+    case StatusNew, StatusAnalyzed, StatusTriaged, StatusInvestigating, StatusResolved, StatusFalsePositive:
+        return true
+    }
+    return false
+}
+
+// IsTerminal reports whether no further transitions are possible from s.
+func (s AlertStatus) IsTerminal() bool {
+    return s == StatusResolved || s == StatusFalsePositive
+}
+
+// CanTransitionTo reports whether moving from s to next is allowed by the lifecycle.
+func (s AlertStatus) CanTransitionTo(next AlertStatus) bool {
+    for _, allowed := range statusTransitions[s] {
+        if allowed == next {
+            return true
+        }
+    }
+    return false
+}
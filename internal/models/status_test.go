@@ -0,0 +1,36 @@
+package models
+
+import "testing"
+
+func TestAlertStatusTransitions(t *testing.T) {
+    allowed := map[[2]AlertStatus]bool{
+        {StatusNew, StatusAnalyzed}:                true,
+        {StatusAnalyzed, StatusTriaged}:            true,
+        {StatusTriaged, StatusInvestigating}:       true,
+        {StatusInvestigating, StatusResolved}:      true,
+        {StatusInvestigating, StatusFalsePositive}: true,
+    }
+    all := []AlertStatus{StatusNew, StatusAnalyzed, StatusTriaged, StatusInvestigating, StatusResolved, StatusFalsePositive}
+    for _, from := range all {
+        if !from.IsValid() {
+            t.Errorf("%s: expected a valid status", from)
+        }
+        for _, to := range all {
+            if got := from.CanTransitionTo(to); got != allowed[[2]AlertStatus{from, to}] {
+                t.Errorf("%s -> %s: got %v", from, to, got)
+            }
+        }
+    }
+
+    for _, s := range all {
+        if got, want := s.IsTerminal(), s == StatusResolved || s == StatusFalsePositive; got != want {
+            t.Errorf("%s: IsTerminal got %v, want %v", s, got, want)
+        }
+    }
+    if AlertStatus("closed").IsValid() || AlertStatus("").IsValid() {
+        t.Error("unknown statuses must not be valid")
+    }
+    if AlertStatus("closed").CanTransitionTo(StatusResolved) {
+        t.Error("unknown statuses must not transition")
+    }
+}
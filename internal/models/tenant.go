@@ -0,0 +1,17 @@
+package models
+
+import (
+    "regexp"
+)
+
+// DefaultTenant owns the alerts of single-tenant deployments, of callers whose
+// credentials do not name a tenant, and the rows that predate multi-tenancy.
+const DefaultTenant = "default"
+
+// tenantIDPattern restricts tenant IDs to short lowercase slugs, e.g. "finance-eu".
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// ValidTenantID reports whether id is a well-formed tenant ID.
+func ValidTenantID(id string) bool {
+    return tenantIDPattern.MatchString(id)
+}
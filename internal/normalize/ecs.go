@@ -0,0 +1,437 @@
+package normalize
+
+import (
+    "encoding/json"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// ECS version the documents are written against.
+const ECSVersion = "8.11.0"
+
+// ecsCategories are the allowed values of event.category. Alert categories that are
+// one of them are also reported there; all of them are kept in rule.category.
+var ecsCategories = map[string]bool{
+    "api": true, "authentication": true, "configuration": true, "database": true, "driver": true,
+    "email": true, "file": true, "host": true, "iam": true, "intrusion_detection": true,
+    "library": true, "malware": true, "network": true, "package": true, "process": true,
+    "registry": true, "session": true, "threat": true, "vulnerability": true, "web": true,
+}
+
+// ecsFieldNames translates alert field names, as found in threat intel matches, to
+// the ECS fields they are written to.
+var ecsFieldNames = map[string]string{
+    "source_ip":   "source.ip",
+    "target_ip":   "destination.ip",
+    "hostname":    "host.name",
+    "username":    "user.name",
+    "file_hash":   "file.hash",
+    "title":       "rule.name",
+    "description": "message",
+}
+
+// ECSDocument is an alert as an Elastic Common Schema document. Alert fields ECS has
+// no place for are kept under the custom guardianai field set.
+type ECSDocument struct {
+    Timestamp    time.Time              `json:"@timestamp"`
+    ECS          ECSMeta                `json:"ecs"`
+    Message      string                 `json:"message,omitempty"` // The alert's description
+    Labels       map[string]interface{} `json:"labels,omitempty"`  // The alert's attributes
+    Event        ECSEvent               `json:"event"`
+    Log          *ECSLog                `json:"log,omitempty"`
+    Rule         *ECSRule               `json:"rule,omitempty"`
+    Source       *ECSEndpoint           `json:"source,omitempty"`
+    Destination  *ECSEndpoint           `json:"destination,omitempty"`
+    Host         *ECSHost               `json:"host,omitempty"`
+    User         *ECSUser               `json:"user,omitempty"`
+    File         *ECSFile               `json:"file,omitempty"`
+    Organization *ECSOrganization       `json:"organization,omitempty"` // The tenant
+    Threat       *ECSThreat             `json:"threat,omitempty"`
+    GuardianAI   *ECSGuardianAI         `json:"guardianai,omitempty"`
+}
+
+// ECSMeta holds the ECS version of the document.
+type ECSMeta struct {
+    Version string `json:"version"`
+}
+
+// ECSEvent describes the alert as an event.
+type ECSEvent struct {
+    Kind          string     `json:"kind"`
+    ID            string     `json:"id,omitempty"`
+    Provider      string     `json:"provider,omitempty"` // The alert's source
+    Category      []string   `json:"category,omitempty"`
+    Severity      int        `json:"severity,omitempty"` // models.SeverityRank of the severity
+    Created       *time.Time `json:"created,omitempty"`
+    End           *time.Time `json:"end,omitempty"`             // Last occurrence
+    RiskScore     *float64   `json:"risk_score,omitempty"`      // 0.0 - 1.0, as computed by the AI
+    RiskScoreNorm *float64   `json:"risk_score_norm,omitempty"` // 0 - 100
+    Original      string     `json:"original,omitempty"`        // The alert's raw payload
+}
+
+// ECSLog holds the severity as reported by the source.
+type ECSLog struct {
+    Level string `json:"level,omitempty"`
+}
+
+// ECSRule names the detection that raised the alert.
+type ECSRule struct {
+    Name     string `json:"name,omitempty"`     // The alert's title
+    Category string `json:"category,omitempty"` // The alert's category
+}
+
+// ECSEndpoint is the source or destination of network activity.
+type ECSEndpoint struct {
+    IP  string  `json:"ip,omitempty"`
+    Geo *ECSGeo `json:"geo,omitempty"`
+    AS  *ECSAS  `json:"as,omitempty"`
+}
+
+// ECSGeo is a geographical location.
+type ECSGeo struct {
+    CityName       string       `json:"city_name,omitempty"`
+    CountryISOCode string       `json:"country_iso_code,omitempty"`
+    CountryName    string       `json:"country_name,omitempty"`
+    Location       *ECSGeoPoint `json:"location,omitempty"`
+}
+
+// ECSGeoPoint is a latitude and longitude.
+type ECSGeoPoint struct {
+    Lat float64 `json:"lat"`
+    Lon float64 `json:"lon"`
+}
+
+// ECSAS is the autonomous system an address belongs to.
+type ECSAS struct {
+    Number       uint32           `json:"number,omitempty"`
+    Organization *ECSOrganization `json:"organization,omitempty"`
+}
+
+// ECSOrganization is an organization, identified by ID or name.
+type ECSOrganization struct {
+    ID   string `json:"id,omitempty"`
+    Name string `json:"name,omitempty"`
+}
+
+// ECSHost is the host the alert is about.
+type ECSHost struct {
+    Name string `json:"name,omitempty"`
+}
+
+// ECSUser is a user account.
+type ECSUser struct {
+    Name string `json:"name,omitempty"`
+}
+
+// ECSFile is a file involved in the alert.
+type ECSFile struct {
+    Hash ECSHash `json:"hash"`
+}
+
+// ECSHash holds file hashes by algorithm.
+type ECSHash struct {
+    MD5    string `json:"md5,omitempty"`
+    SHA1   string `json:"sha1,omitempty"`
+    SHA256 string `json:"sha256,omitempty"`
+    SHA512 string `json:"sha512,omitempty"`
+}
+
+// ECSThreat holds the threat intel indicators the alert matched.
+type ECSThreat struct {
+    Enrichments []ECSThreatEnrichment `json:"enrichments,omitempty"`
+}
+
+// ECSThreatEnrichment is one indicator match.
+type ECSThreatEnrichment struct {
+    Indicator ECSIndicator `json:"indicator"`
+    Matched   ECSMatched   `json:"matched"`
+}
+
+// ECSIndicator is an indicator of compromise from a feed.
+type ECSIndicator struct {
+    Name        string `json:"name"` // As listed in the feed
+    Type        string `json:"type,omitempty"`
+    Provider    string `json:"provider,omitempty"` // Feed
+    Description string `json:"description,omitempty"`
+}
+
+// ECSMatched is where an indicator was found in the alert.
+type ECSMatched struct {
+    Atomic string `json:"atomic"`
+    Field  string `json:"field"`
+    Type   string `json:"type"`
+}
+
+// ECSGuardianAI is the custom field set for alert fields without an ECS field.
+type ECSGuardianAI struct {
+    Status            models.AlertStatus  `json:"status,omitempty"`
+    OccurrenceCount   int                 `json:"occurrence_count,omitempty"`
+    Fingerprint       string              `json:"fingerprint,omitempty"`
+    PredictedSeverity string              `json:"predicted_severity,omitempty"`
+    RecommendedAction string              `json:"recommended_action,omitempty"`
+    AIModelVersion    string              `json:"ai_model_version,omitempty"`
+    SigmaMatches      []models.SigmaMatch `json:"sigma_matches,omitempty"`
+    FileHash          string              `json:"file_hash,omitempty"` // A hash of unknown algorithm
+}
+
+// ToECS converts an alert to an ECS document of kind alert.
+func ToECS(a *models.SecurityAlert) *ECSDocument {
+    d := &ECSDocument{
+        Timestamp: a.Timestamp,
+        ECS:       ECSMeta{Version: ECSVersion},
+        Message:   a.Description,
+        Labels:    attributeValues(a.Attributes),
+        Event: ECSEvent{
+            Kind:     "alert",
+            ID:       a.ID,
+            Provider: a.Source,
+            Severity: models.SeverityRank(a.Severity),
+            Original: rawText(a.RawPayload),
+        },
+    }
+    if !a.CreatedAt.IsZero() {
+        created := a.CreatedAt
+        d.Event.Created = &created
+    }
+    if !a.LastSeen.IsZero() {
+        end := a.LastSeen
+        d.Event.End = &end
+    }
+    if a.Severity != "" {
+        d.Log = &ECSLog{Level: a.Severity}
+    }
+    if a.Title != "" || a.Category != "" {
+        d.Rule = &ECSRule{Name: a.Title, Category: a.Category}
+    }
+    if ecsCategories[strings.ToLower(a.Category)] {
+        d.Event.Category = []string{strings.ToLower(a.Category)}
+    }
+    if a.TenantID != "" {
+        d.Organization = &ECSOrganization{ID: a.TenantID}
+    }
+    if a.Hostname != "" {
+        d.Host = &ECSHost{Name: a.Hostname}
+    }
+    if a.Username != "" {
+        d.User = &ECSUser{Name: a.Username}
+    }
+
+    g := &ECSGuardianAI{
+        Status:            a.Status,
+        OccurrenceCount:   a.OccurrenceCount,
+        Fingerprint:       a.Fingerprint,
+        PredictedSeverity: a.PredictedSeverity,
+        RecommendedAction: a.RecommendedAction,
+        AIModelVersion:    a.AIModelVersion,
+        SigmaMatches:      a.SigmaMatches,
+    }
+    if a.FileHash != "" {
+        d.File = &ECSFile{}
+        switch hashAlgorithm(a.FileHash) {
+        case hashMD5:
+            d.File.Hash.MD5 = a.FileHash
+        case hashSHA1:
+            d.File.Hash.SHA1 = a.FileHash
+        case hashSHA256:
+            d.File.Hash.SHA256 = a.FileHash
+        case hashSHA512:
+            d.File.Hash.SHA512 = a.FileHash
+        default:
+            d.File, g.FileHash = nil, a.FileHash
+        }
+    }
+    if a.RiskScore != 0 || a.AIModelVersion != "" {
+        score, norm := a.RiskScore, a.RiskScore*100
+        d.Event.RiskScore, d.Event.RiskScoreNorm = &score, &norm
+    }
+
+    var geoSrc, geoDst *models.GeoInfo
+    if a.Enrichment != nil {
+        geoSrc, geoDst = a.Enrichment.SourceGeo, a.Enrichment.TargetGeo
+        for _, m := range a.Enrichment.ThreatIntel {
+            if d.Threat == nil {
+                d.Threat = &ECSThreat{}
+            }
+            field := ecsFieldNames[m.Field]
+            if field == "" {
+                field = m.Field
+            }
+            d.Threat.Enrichments = append(d.Threat.Enrichments, ECSThreatEnrichment{
+                Indicator: ECSIndicator{Name: m.Indicator, Type: ecsIndicatorType(m), Provider: m.Feed, Description: m.Description},
+                Matched:   ECSMatched{Atomic: m.Value, Field: field, Type: "indicator_match_rule"},
+            })
+        }
+    }
+    d.Source = ecsEndpoint(a.SourceIP, geoSrc)
+    d.Destination = ecsEndpoint(a.TargetIP, geoDst)
+
+    if g.Status != "" || g.OccurrenceCount != 0 || g.Fingerprint != "" || g.PredictedSeverity != "" ||
+        g.RecommendedAction != "" || g.AIModelVersion != "" || len(g.SigmaMatches) > 0 || g.FileHash != "" {
+        d.GuardianAI = g
+    }
+    return d
+}
+
+// ecsIndicatorType returns the ECS threat.indicator.type of a threat intel match.
+func ecsIndicatorType(m models.ThreatIntelMatch) string {
+    switch m.Type {
+    case models.IndicatorIP, models.IndicatorCIDR:
+        if strings.Contains(m.Indicator, ":") {
+            return "ipv6-addr"
+        }
+        return "ipv4-addr"
+    case models.IndicatorDomain:
+        return "domain-name"
+    case models.IndicatorHash:
+        return "file"
+    }
+    return "unknown"
+}
+
+// ecsEndpoint returns the endpoint for ip, nil if ip is empty.
+func ecsEndpoint(ip string, geo *models.GeoInfo) *ECSEndpoint {
+    if ip == "" {
+        return nil
+    }
+    e := &ECSEndpoint{IP: ip}
+    if geo != nil && geo.IP == ip {
+        if geo.City != "" || geo.Country != "" || geo.Latitude != nil {
+            e.Geo = &ECSGeo{CityName: geo.City, CountryISOCode: geo.Country, CountryName: geo.CountryName}
+            if geo.Latitude != nil && geo.Longitude != nil {
+                e.Geo.Location = &ECSGeoPoint{Lat: *geo.Latitude, Lon: *geo.Longitude}
+            }
+        }
+        if geo.ASN != 0 {
+            e.AS = &ECSAS{Number: geo.ASN}
+            if geo.ASOrg != "" {
+                e.AS.Organization = &ECSOrganization{Name: geo.ASOrg}
+            }
+        }
+    }
+    return e
+}
+
+// ECSFromMap converts an ECS document, as decoded from JSON, to an alert. Fields may
+// be nested objects or dotted keys ({"source.ip": ...}), as both are valid in
+// Elasticsearch.
+func ECSFromMap(doc map[string]interface{}) (*models.SecurityAlert, error) {
+    data, err := json.Marshal(expandDottedKeys(doc))
+    if err != nil {
+        return nil, fmt.Errorf("failed to encode ECS document: %w", err)
+    }
+    var d ECSDocument
+    if err := json.Unmarshal(data, &d); err != nil {
+        return nil, fmt.Errorf("invalid ECS document: %w", err)
+    }
+    return FromECS(&d)
+}
+
+// expandDottedKeys turns dotted keys into nested objects, merging them with nested
+// objects under the same name. Keys of labels are kept as they are, dots are allowed
+// in label names.
+func expandDottedKeys(m map[string]interface{}) map[string]interface{} {
+    out := make(map[string]interface{}, len(m))
+    for key, value := range m {
+        if nested, ok := value.(map[string]interface{}); ok && key != "labels" {
+            value = expandDottedKeys(nested)
+        }
+        parts := strings.Split(key, ".")
+        if strings.HasPrefix(key, "labels.") {
+            parts = strings.SplitN(key, ".", 2)
+        }
+        target := out
+        for _, part := range parts[:len(parts)-1] {
+            next, ok := target[part].(map[string]interface{})
+            if !ok {
+                next = make(map[string]interface{})
+                target[part] = next
+            }
+            target = next
+        }
+        last := parts[len(parts)-1]
+        if existing, ok := target[last].(map[string]interface{}); ok {
+            if nested, ok := value.(map[string]interface{}); ok {
+                for k, v := range nested {
+                    existing[k] = v
+                }
+                continue
+            }
+        }
+        target[last] = value
+    }
+    return out
+}
+
+// FromECS converts an ECS document to an alert. Geo and threat intel fields are not
+// read back, the processor computes them again.
+func FromECS(d *ECSDocument) (*models.SecurityAlert, error) {
+    a := &models.SecurityAlert{
+        ID:          d.Event.ID,
+        Source:      d.Event.Provider,
+        Timestamp:   d.Timestamp,
+        Severity:    normalizedSeverity(d.Event.Severity),
+        Description: d.Message,
+        Attributes:  stringValues(d.Labels),
+        RawPayload:  rawPayload(d.Event.Original),
+    }
+    if d.Rule != nil {
+        a.Title, a.Category = d.Rule.Name, d.Rule.Category
+    }
+    if a.Title == "" {
+        a.Title = a.Description
+    }
+    if a.Title == "" {
+        return nil, fmt.Errorf("ECS document has no rule.name or message")
+    }
+    if a.Category == "" && len(d.Event.Category) > 0 {
+        a.Category = d.Event.Category[0]
+    }
+    if d.Log != nil && d.Log.Level != "" {
+        a.Severity = d.Log.Level // The source's own severity name
+    }
+    if d.Event.Created != nil {
+        a.CreatedAt = *d.Event.Created
+    }
+    if d.Event.End != nil {
+        a.LastSeen = *d.Event.End
+    }
+    if d.Event.RiskScore != nil {
+        a.RiskScore = *d.Event.RiskScore
+    }
+    if d.Source != nil {
+        a.SourceIP = d.Source.IP
+    }
+    if d.Destination != nil {
+        a.TargetIP = d.Destination.IP
+    }
+    if d.Host != nil {
+        a.Hostname = d.Host.Name
+    }
+    if d.User != nil {
+        a.Username = d.User.Name
+    }
+    if d.File != nil {
+        for _, hash := range []string{d.File.Hash.SHA256, d.File.Hash.SHA512, d.File.Hash.SHA1, d.File.Hash.MD5} {
+            if hash != "" {
+                a.FileHash = hash
+                break
+            }
+        }
+    }
+    if g := d.GuardianAI; g != nil {
+        a.Status = g.Status
+        a.OccurrenceCount = g.OccurrenceCount
+        a.Fingerprint = g.Fingerprint
+        a.PredictedSeverity = g.PredictedSeverity
+        a.RecommendedAction = g.RecommendedAction
+        a.AIModelVersion = g.AIModelVersion
+        a.SigmaMatches = g.SigmaMatches
+        if a.FileHash == "" {
+            a.FileHash = g.FileHash
+        }
+    }
+    return a, nil
+}
@@ -0,0 +1,257 @@
+// Package normalize converts SecurityAlerts to and from the standard security event
+// schemas our data lake speaks: OCSF Detection Findings and Elastic Common Schema
+// documents. Both directions are supported, so alerts can be submitted in either
+// schema and read back in it.
+package normalize
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io"
+    "reflect"
+    "strings"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// Format is a representation of an alert on the API.
+type Format string
+
+// Supported formats. FormatNative is the SecurityAlert JSON itself.
+const (
+    FormatNative Format = ""
+    FormatOCSF   Format = "ocsf"
+    FormatECS    Format = "ecs"
+)
+
+// Media types selecting a schema in the Content-Type of POST /alerts.
+const (
+    MediaTypeOCSF = "application/ocsf+json"
+    MediaTypeECS  = "application/ecs+json"
+)
+
+// ParseFormat parses the format query parameter: ocsf, ecs, or empty or native for
+// the SecurityAlert JSON.
+func ParseFormat(s string) (Format, error) {
+    switch f := Format(strings.ToLower(s)); f {
+    case FormatNative, "native":
+        return FormatNative, nil
+    case FormatOCSF, FormatECS:
+        return f, nil
+    }
+    return FormatNative, fmt.Errorf("unknown format %q, expected ocsf or ecs", s)
+}
+
+// FormatForMediaType returns the format selected by a media type, FormatNative for
+// any other media type.
+func FormatForMediaType(mediaType string) Format {
+    switch strings.ToLower(mediaType) {
+    case MediaTypeOCSF:
+        return FormatOCSF
+    case MediaTypeECS:
+        return FormatECS
+    }
+    return FormatNative
+}
+
+// Decode reads one alert in format f from r. The document read becomes the alert's
+// raw payload, unless it carries the original event itself (raw_payload, OCSF
+// raw_data or ECS event.original).
+func Decode(f Format, r io.Reader) (*models.SecurityAlert, error) {
+    data, err := io.ReadAll(r)
+    if err != nil {
+        return nil, err
+    }
+    var alert *models.SecurityAlert
+    switch f {
+    case FormatOCSF:
+        var finding OCSFDetectionFinding
+        if err := json.Unmarshal(data, &finding); err != nil {
+            return nil, err
+        }
+        alert, err = FromOCSF(&finding)
+    case FormatECS:
+        var doc map[string]interface{}
+        if err := json.Unmarshal(data, &doc); err != nil {
+            return nil, err
+        }
+        alert, err = ECSFromMap(doc)
+    default:
+        return DecodeNative(data)
+    }
+    if err != nil {
+        return nil, err
+    }
+    if len(alert.RawPayload) == 0 {
+        alert.RawPayload = compactJSON(data)
+    }
+    return alert, nil
+}
+
+// alertFields holds the JSON names of the SecurityAlert fields, in lower case as
+// encoding/json matches them case-insensitively.
+var alertFields = func() map[string]bool {
+    fields := make(map[string]bool)
+    t := reflect.TypeOf(models.SecurityAlert{})
+    for i := 0; i < t.NumField(); i++ {
+        name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+        if name == "" {
+            name = t.Field(i).Name
+        }
+        fields[strings.ToLower(name)] = true
+    }
+    return fields
+}()
+
+// DecodeNative decodes an alert from SecurityAlert JSON. Fields a SecurityAlert has
+// no place for are kept in its attributes instead of being dropped, next to any
+// attributes sent explicitly, which take precedence. The JSON becomes the alert's
+// raw payload unless it has a raw_payload of its own.
+func DecodeNative(data []byte) (*models.SecurityAlert, error) {
+    var alert models.SecurityAlert
+    if err := json.Unmarshal(data, &alert); err != nil {
+        return nil, err
+    }
+    var fields map[string]interface{}
+    json.Unmarshal(data, &fields) // Cannot fail, data was decoded above
+    extra := make(map[string]interface{})
+    for name, value := range fields {
+        if !alertFields[strings.ToLower(name)] {
+            extra[name] = value
+        }
+    }
+    for name, value := range stringValues(extra) {
+        if _, ok := alert.Attributes[name]; !ok {
+            if alert.Attributes == nil {
+                alert.Attributes = make(map[string]string)
+            }
+            alert.Attributes[name] = value
+        }
+    }
+    if len(alert.RawPayload) == 0 || string(alert.RawPayload) == "null" {
+        alert.RawPayload = compactJSON(data)
+    } else {
+        alert.RawPayload = compactJSON(alert.RawPayload) // Not shared with data
+    }
+    return &alert, nil
+}
+
+// compactJSON returns a copy of a JSON document without insignificant whitespace.
+func compactJSON(data []byte) json.RawMessage {
+    var buf bytes.Buffer
+    if err := json.Compact(&buf, data); err != nil {
+        return append(json.RawMessage(nil), data...)
+    }
+    return buf.Bytes()
+}
+
+// Encode returns alert in format f, ready to be marshalled to JSON.
+func Encode(f Format, alert *models.SecurityAlert) interface{} {
+    switch f {
+    case FormatOCSF:
+        return ToOCSF(alert)
+    case FormatECS:
+        return ToECS(alert)
+    }
+    return alert
+}
+
+// Hash algorithms, told apart by the length of the hex digest.
+const (
+    hashMD5    = "MD5"
+    hashSHA1   = "SHA-1"
+    hashSHA256 = "SHA-256"
+    hashSHA512 = "SHA-512"
+)
+
+// hashAlgorithm guesses the algorithm of a hex digest from its length. It returns ""
+// if the length matches none of the common ones.
+func hashAlgorithm(hash string) string {
+    switch len(hash) {
+    case 32:
+        return hashMD5
+    case 40:
+        return hashSHA1
+    case 64:
+        return hashSHA256
+    case 128:
+        return hashSHA512
+    }
+    return ""
+}
+
+// normalizedSeverity returns the name of a severity rank, the inverse of
+// models.SeverityRank.
+func normalizedSeverity(rank int) string {
+    switch rank {
+    case 1:
+        return "informational"
+    case 2:
+        return "low"
+    case 3:
+        return "medium"
+    case 4:
+        return "high"
+    case 5:
+        return "critical"
+    }
+    return ""
+}
+
+// stringValues converts the values of a JSON object to strings, for the alert's
+// attributes. Values that are not strings are kept as their JSON encoding. It
+// returns nil if m is empty.
+func stringValues(m map[string]interface{}) map[string]string {
+    if len(m) == 0 {
+        return nil
+    }
+    values := make(map[string]string, len(m))
+    for k, v := range m {
+        switch v := v.(type) {
+        case string:
+            values[k] = v
+        case nil:
+        default:
+            data, _ := json.Marshal(v)
+            values[k] = string(data)
+        }
+    }
+    return values
+}
+
+// rawText returns a raw payload as the text of the original event: JSON strings,
+// such as CEF events, unquoted and JSON documents as they are.
+func rawText(payload json.RawMessage) string {
+    var text string
+    if json.Unmarshal(payload, &text) == nil {
+        return text
+    }
+    return string(payload)
+}
+
+// rawPayload is the inverse of rawText: JSON documents are kept, other text becomes
+// a JSON string. It returns nil for empty text.
+func rawPayload(text string) json.RawMessage {
+    if text == "" {
+        return nil
+    }
+    if json.Valid([]byte(text)) {
+        return compactJSON([]byte(text))
+    }
+    data, _ := json.Marshal(text) // Strings always encode
+    return data
+}
+
+// attributeValues copies the alert's attributes into a JSON object, returning nil if
+// there are none.
+func attributeValues(attributes map[string]string) map[string]interface{} {
+    if len(attributes) == 0 {
+        return nil
+    }
+    values := make(map[string]interface{}, len(attributes))
+    for k, v := range attributes {
+        values[k] = v
+    }
+    return values
+}
@@ -0,0 +1,176 @@
+package normalize
+
+import (
+    "encoding/json"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+func testAlert() *models.SecurityAlert {
+    ts := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+    return &models.SecurityAlert{
+        ID:                "alert-1",
+        TenantID:          "acme",
+        Source:            "firewall",
+        Timestamp:         ts,
+        Severity:          "high",
+        Category:          "network",
+        Title:             "Port scan",
+        Description:       "Many ports probed",
+        SourceIP:          "203.0.113.7",
+        TargetIP:          "10.0.0.5",
+        Hostname:          "web-01",
+        Username:          "alice",
+        FileHash:          strings.Repeat("ab", 32),
+        Attributes:        map[string]string{"deviceVendor": "Acme", "policy.name": "default"},
+        Status:            models.StatusInvestigating,
+        CreatedAt:         ts.Add(time.Second),
+        PredictedSeverity: "critical",
+        RiskScore:         0.87,
+        RecommendedAction: "Block the source IP",
+        AIModelVersion:    "v2",
+        Fingerprint:       "f00d",
+        OccurrenceCount:   3,
+        LastSeen:          ts.Add(time.Minute),
+        SigmaMatches:      []models.SigmaMatch{{RuleID: "r1", Title: "Scan", Level: "high"}},
+        RawPayload:        json.RawMessage(`{"ports":[22,80],"vendor":"Acme"}`),
+    }
+}
+
+// roundTrip encodes alert in format f as JSON and decodes it again.
+func roundTrip(t *testing.T, f Format, alert *models.SecurityAlert) (*models.SecurityAlert, map[string]interface{}) {
+    t.Helper()
+    data, err := json.Marshal(Encode(f, alert))
+    if err != nil {
+        t.Fatalf("failed to encode %s: %v", f, err)
+    }
+    var doc map[string]interface{}
+    json.Unmarshal(data, &doc)
+    got, err := Decode(f, strings.NewReader(string(data)))
+    if err != nil {
+        t.Fatalf("failed to decode %s: %v\n%s", f, err, data)
+    }
+    return got, doc
+}
+
+func TestOCSF(t *testing.T) {
+    alert := testAlert()
+    alert.Enrichment = &models.Enrichment{
+        SourceGeo:   &models.GeoInfo{IP: "203.0.113.7", Country: "NL", ASN: 64500, ASOrg: "Example"},
+        ThreatIntel: []models.ThreatIntelMatch{{Field: "source_ip", Value: "203.0.113.7", Indicator: "203.0.113.0/24", Type: "cidr", Feed: "blocklist"}},
+    }
+    got, doc := roundTrip(t, FormatOCSF, alert)
+
+    if doc["class_uid"] != 2004.0 || doc["type_uid"] != 200402.0 || doc["severity_id"] != 4.0 || doc["status_id"] != 2.0 || doc["risk_score"] != 87.0 {
+        t.Errorf("unexpected OCSF classification: %v", doc)
+    }
+    evidence := doc["evidences"].([]interface{})[0].(map[string]interface{})
+    src := evidence["src_endpoint"].(map[string]interface{})
+    if src["ip"] != "203.0.113.7" || src["location"].(map[string]interface{})["country"] != "NL" {
+        t.Errorf("unexpected src_endpoint %v", src)
+    }
+    hash := evidence["file"].(map[string]interface{})["hashes"].([]interface{})[0].(map[string]interface{})
+    if hash["algorithm_id"] != 3.0 {
+        t.Errorf("expected a SHA-256 hash, got %v", hash)
+    }
+
+    alert.Enrichment, alert.TenantID = nil, "" // Neither is read back
+    if !reflect.DeepEqual(got, alert) {
+        t.Errorf("round trip changed the alert:\n got %+v\nwant %+v", got, alert)
+    }
+
+    // Findings from other tools carry only part of the attributes
+    finding := `{"class_uid": 2004, "time": 1772366400000, "severity_id": 6, "status_id": 3,
+        "metadata": {"product": {"name": "EDR"}}, "finding_info": {"uid": "x", "title": "Malware", "types": ["malware"]},
+        "unmapped": {"score": 7, "tag": "a"}}`
+    got, err := Decode(FormatOCSF, strings.NewReader(finding))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got.Source != "EDR" || got.Severity != "critical" || got.Status != models.StatusFalsePositive || got.Category != "malware" ||
+        got.Attributes["score"] != "7" || got.Attributes["tag"] != "a" || !got.Timestamp.Equal(alert.Timestamp) {
+        t.Errorf("unexpected alert from finding: %+v", got)
+    }
+    if !strings.HasPrefix(string(got.RawPayload), `{"class_uid":2004,`) {
+        t.Errorf("expected the finding as raw payload, got %s", got.RawPayload)
+    }
+
+    if _, err := Decode(FormatOCSF, strings.NewReader(`{"class_uid": 4001, "finding_info": {"title": "x"}}`)); err == nil {
+        t.Error("expected an error for a network activity event")
+    }
+}
+
+func TestECS(t *testing.T) {
+    alert := testAlert()
+    got, doc := roundTrip(t, FormatECS, alert)
+
+    event := doc["event"].(map[string]interface{})
+    if event["kind"] != "alert" || event["severity"] != 4.0 || event["category"].([]interface{})[0] != "network" {
+        t.Errorf("unexpected ECS event: %v", event)
+    }
+    if doc["source"].(map[string]interface{})["ip"] != "203.0.113.7" || doc["organization"].(map[string]interface{})["id"] != "acme" {
+        t.Errorf("unexpected ECS document: %v", doc)
+    }
+
+    alert.TenantID = ""
+    if !reflect.DeepEqual(got, alert) {
+        t.Errorf("round trip changed the alert:\n got %+v\nwant %+v", got, alert)
+    }
+
+    // Flattened documents, as exported by many pipelines
+    flat := `{"@timestamp": "2026-03-01T12:00:00Z", "rule.name": "Brute force", "event": {"provider": "sshd"},
+        "event.severity": 2, "source.ip": "198.51.100.1", "user.name": "root", "file.hash.md5": "` + strings.Repeat("0", 32) + `",
+        "labels.env": "prod", "labels": {"count": 5}}`
+    got, err := Decode(FormatECS, strings.NewReader(flat))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got.Title != "Brute force" || got.Source != "sshd" || got.Severity != "low" || got.SourceIP != "198.51.100.1" ||
+        got.Username != "root" || got.FileHash != strings.Repeat("0", 32) || got.Attributes["env"] != "prod" || got.Attributes["count"] != "5" {
+        t.Errorf("unexpected alert from flattened document: %+v", got)
+    }
+
+    if _, err := Decode(FormatECS, strings.NewReader(`{"event": {"kind": "alert"}}`)); err == nil {
+        t.Error("expected an error for a document without a title")
+    }
+}
+
+func TestDecodeNative(t *testing.T) {
+    body := `{"id": "a1", "title": "Login failure", "Severity": "high", "attributes": {"site": "hq"},
+        "site": "ignored", "rule_id": 4625, "vendor": {"name": "Acme"}, "note": null}`
+    got, err := Decode(FormatNative, strings.NewReader(body))
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := map[string]string{"site": "hq", "rule_id": "4625", "vendor": `{"name":"Acme"}`}
+    if got.Severity != "high" || !reflect.DeepEqual(got.Attributes, want) {
+        t.Errorf("unexpected alert %+v, want attributes %v", got, want)
+    }
+    if !strings.HasPrefix(string(got.RawPayload), `{"id":"a1","title":"Login failure",`) {
+        t.Errorf("expected the compacted body as raw payload, got %s", got.RawPayload)
+    }
+
+    // Forwarders may pass the original event along
+    got, err = DecodeNative([]byte(`{"title": "x", "raw_payload": "CEF:0|Acme|IDS|1|2|x|5|"}`))
+    if err != nil || string(got.RawPayload) != `"CEF:0|Acme|IDS|1|2|x|5|"` || got.Attributes != nil {
+        t.Errorf("unexpected alert %+v, %v", got, err)
+    }
+}
+
+func TestParseFormat(t *testing.T) {
+    for in, want := range map[string]Format{"": FormatNative, "native": FormatNative, "OCSF": FormatOCSF, "ecs": FormatECS} {
+        if got, err := ParseFormat(in); err != nil || got != want {
+            t.Errorf("ParseFormat(%q) = %q, %v, want %q", in, got, err, want)
+        }
+    }
+    if _, err := ParseFormat("stix"); err == nil {
+        t.Error("expected an error for an unknown format")
+    }
+    if FormatForMediaType(MediaTypeECS) != FormatECS || FormatForMediaType("application/json") != FormatNative {
+        t.Error("unexpected format for media type")
+    }
+}
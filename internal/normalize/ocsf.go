@@ -0,0 +1,419 @@
+package normalize
+
+import (
+    "fmt"
+    "math"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// OCSF schema version the findings are written against.
+const OCSFVersion = "1.3.0"
+
+// OCSF identifiers of a Detection Finding.
+const (
+    ocsfCategoryFindings      = 2
+    ocsfClassDetectionFinding = 2004
+    ocsfActivityCreate        = 1
+    ocsfActivityUpdate        = 2
+    ocsfSeverityOther         = 99
+    ocsfAnalyticRule          = 1
+    ocsfAnalyticLearning      = 4
+)
+
+// ocsfSeverities names the OCSF severity_id values. 1 to 5 match models.SeverityRank.
+var ocsfSeverities = map[int]string{0: "Unknown", 1: "Informational", 2: "Low", 3: "Medium", 4: "High", 5: "Critical", 6: "Fatal", 99: "Other"}
+
+// ocsfRiskLevels names the OCSF risk_level_id values, which start at 0.
+var ocsfRiskLevels = []string{"Info", "Low", "Medium", "High", "Critical"}
+
+// ocsfStatuses maps alert lifecycle states to OCSF finding status_id values:
+// 1 New, 2 In Progress, 3 Suppressed, 4 Resolved. The state itself is kept in
+// status_detail, as OCSF has fewer states than the alert lifecycle.
+var ocsfStatuses = map[models.AlertStatus]int{
+    models.StatusNew:           1,
+    models.StatusAnalyzed:      1,
+    models.StatusTriaged:       2,
+    models.StatusInvestigating: 2,
+    models.StatusFalsePositive: 3,
+    models.StatusResolved:      4,
+}
+
+// ocsfStatusStates maps OCSF status_id values back to the earliest lifecycle state
+// they stand for, for findings without a status_detail of ours.
+var ocsfStatusStates = map[int]models.AlertStatus{
+    1: models.StatusNew,
+    2: models.StatusTriaged,
+    3: models.StatusFalsePositive,
+    4: models.StatusResolved,
+}
+
+// ocsfStatusNames names the OCSF status_id values.
+var ocsfStatusNames = map[int]string{0: "Unknown", 1: "New", 2: "In Progress", 3: "Suppressed", 4: "Resolved", 99: "Other"}
+
+// ocsfHashAlgorithms maps hash algorithms to their OCSF algorithm_id.
+var ocsfHashAlgorithms = map[string]int{hashMD5: 1, hashSHA1: 2, hashSHA256: 3, hashSHA512: 4}
+
+// OCSFDetectionFinding is an OCSF Detection Finding (class 2004), the OCSF event for
+// an alert raised by a security product. Only the attributes GuardianAI reads or
+// writes are declared; times are in milliseconds since the epoch.
+type OCSFDetectionFinding struct {
+    ActivityID   int    `json:"activity_id"`
+    ActivityName string `json:"activity_name,omitempty"`
+    CategoryUID  int    `json:"category_uid"`
+    CategoryName string `json:"category_name,omitempty"`
+    ClassUID     int    `json:"class_uid"`
+    ClassName    string `json:"class_name,omitempty"`
+    TypeUID      int    `json:"type_uid"`
+    TypeName     string `json:"type_name,omitempty"`
+    Time         int64  `json:"time"`
+    SeverityID   int    `json:"severity_id"`
+    Severity     string `json:"severity,omitempty"`
+    StatusID     int    `json:"status_id,omitempty"`
+    Status       string `json:"status,omitempty"`
+    StatusDetail string `json:"status_detail,omitempty"`
+    Message      string `json:"message,omitempty"`
+    Count        int    `json:"count,omitempty"`
+
+    Metadata    OCSFMetadata     `json:"metadata"`
+    FindingInfo OCSFFindingInfo  `json:"finding_info"`
+    Device      *OCSFDevice      `json:"device,omitempty"`
+    Evidences   []OCSFEvidence   `json:"evidences,omitempty"`
+    Enrichments []OCSFEnrichment `json:"enrichments,omitempty"`
+
+    // Results of the AI analysis
+    RiskScore   *int             `json:"risk_score,omitempty"` // 0 - 100
+    RiskLevelID *int             `json:"risk_level_id,omitempty"`
+    RiskLevel   string           `json:"risk_level,omitempty"`
+    Remediation *OCSFRemediation `json:"remediation,omitempty"`
+
+    Unmapped map[string]interface{} `json:"unmapped,omitempty"` // The alert's attributes
+    RawData  string                 `json:"raw_data,omitempty"` // The alert's raw payload
+}
+
+// OCSFMetadata describes the event itself.
+type OCSFMetadata struct {
+    Version        string      `json:"version"`
+    Product        OCSFProduct `json:"product"`
+    LogProvider    string      `json:"log_provider,omitempty"` // The alert's source
+    TenantUID      string      `json:"tenant_uid,omitempty"`
+    CorrelationUID string      `json:"correlation_uid,omitempty"` // Deduplication fingerprint
+}
+
+// OCSFProduct identifies the product reporting the finding.
+type OCSFProduct struct {
+    Name       string `json:"name,omitempty"`
+    VendorName string `json:"vendor_name,omitempty"`
+}
+
+// OCSFFindingInfo describes what was detected.
+type OCSFFindingInfo struct {
+    UID              string         `json:"uid"`
+    Title            string         `json:"title"`
+    Desc             string         `json:"desc,omitempty"`
+    Types            []string       `json:"types,omitempty"` // The alert's category
+    CreatedTime      int64          `json:"created_time,omitempty"`
+    FirstSeenTime    int64          `json:"first_seen_time,omitempty"`
+    LastSeenTime     int64          `json:"last_seen_time,omitempty"`
+    Analytic         *OCSFAnalytic  `json:"analytic,omitempty"`          // The AI model
+    RelatedAnalytics []OCSFAnalytic `json:"related_analytics,omitempty"` // Matching Sigma rules
+}
+
+// OCSFAnalytic is a rule or model that contributed to the finding.
+type OCSFAnalytic struct {
+    UID      string `json:"uid,omitempty"`
+    Name     string `json:"name,omitempty"`
+    TypeID   int    `json:"type_id"`
+    Type     string `json:"type,omitempty"`
+    Category string `json:"category,omitempty"` // Sigma rule level
+    Version  string `json:"version,omitempty"`
+}
+
+// OCSFDevice is the host the finding is about.
+type OCSFDevice struct {
+    Hostname string `json:"hostname,omitempty"`
+}
+
+// OCSFEvidence holds the artifacts of the finding.
+type OCSFEvidence struct {
+    SrcEndpoint *OCSFEndpoint `json:"src_endpoint,omitempty"`
+    DstEndpoint *OCSFEndpoint `json:"dst_endpoint,omitempty"`
+    Actor       *OCSFActor    `json:"actor,omitempty"`
+    File        *OCSFFile     `json:"file,omitempty"`
+}
+
+// OCSFEndpoint is a network endpoint, with its location if it was looked up.
+type OCSFEndpoint struct {
+    IP               string                `json:"ip,omitempty"`
+    Location         *OCSFLocation         `json:"location,omitempty"`
+    AutonomousSystem *OCSFAutonomousSystem `json:"autonomous_system,omitempty"`
+}
+
+// OCSFLocation is a geographical location.
+type OCSFLocation struct {
+    City    string   `json:"city,omitempty"`
+    Country string   `json:"country,omitempty"`
+    Lat     *float64 `json:"lat,omitempty"`
+    Long    *float64 `json:"long,omitempty"`
+}
+
+// OCSFAutonomousSystem is the AS an address belongs to.
+type OCSFAutonomousSystem struct {
+    Number uint32 `json:"number,omitempty"`
+    Name   string `json:"name,omitempty"`
+}
+
+// OCSFActor is who caused the activity.
+type OCSFActor struct {
+    User *OCSFUser `json:"user,omitempty"`
+}
+
+// OCSFUser is a user account.
+type OCSFUser struct {
+    Name string `json:"name,omitempty"`
+}
+
+// OCSFFile is a file involved in the finding.
+type OCSFFile struct {
+    Name   string     `json:"name,omitempty"`
+    Hashes []OCSFHash `json:"hashes,omitempty"`
+}
+
+// OCSFHash is a file fingerprint.
+type OCSFHash struct {
+    AlgorithmID int    `json:"algorithm_id"`
+    Algorithm   string `json:"algorithm,omitempty"`
+    Value       string `json:"value"`
+}
+
+// OCSFEnrichment is context added to the finding, here threat intel matches.
+type OCSFEnrichment struct {
+    Name     string                 `json:"name"`  // Alert field that matched
+    Value    string                 `json:"value"` // Value found in the alert
+    Type     string                 `json:"type,omitempty"`
+    Provider string                 `json:"provider,omitempty"` // Feed
+    Data     map[string]interface{} `json:"data"`
+}
+
+// OCSFRemediation describes how to respond to the finding.
+type OCSFRemediation struct {
+    Desc string `json:"desc"`
+}
+
+// ocsfTime converts t to an OCSF timestamp, 0 for the zero time.
+func ocsfTime(t time.Time) int64 {
+    if t.IsZero() {
+        return 0
+    }
+    return t.UnixMilli()
+}
+
+// fromOCSFTime converts an OCSF timestamp to a time, the zero time for 0.
+func fromOCSFTime(ms int64) time.Time {
+    if ms == 0 {
+        return time.Time{}
+    }
+    return time.UnixMilli(ms).UTC()
+}
+
+// ToOCSF converts an alert to an OCSF Detection Finding. Alerts the processor has
+// analysed are reported as updates of the finding, others as its creation.
+func ToOCSF(a *models.SecurityAlert) *OCSFDetectionFinding {
+    f := &OCSFDetectionFinding{
+        ActivityID:   ocsfActivityCreate,
+        ActivityName: "Create",
+        CategoryUID:  ocsfCategoryFindings,
+        CategoryName: "Findings",
+        ClassUID:     ocsfClassDetectionFinding,
+        ClassName:    "Detection Finding",
+        Time:         ocsfTime(a.Timestamp),
+        Message:      a.Title,
+        Count:        a.OccurrenceCount,
+        Unmapped:     attributeValues(a.Attributes),
+        RawData:      rawText(a.RawPayload),
+        Metadata: OCSFMetadata{
+            Version:        OCSFVersion,
+            Product:        OCSFProduct{Name: "GuardianAI", VendorName: "GuardianAI"},
+            LogProvider:    a.Source,
+            TenantUID:      a.TenantID,
+            CorrelationUID: a.Fingerprint,
+        },
+        FindingInfo: OCSFFindingInfo{
+            UID:           a.ID,
+            Title:         a.Title,
+            Desc:          a.Description,
+            CreatedTime:   ocsfTime(a.CreatedAt),
+            FirstSeenTime: ocsfTime(a.Timestamp),
+            LastSeenTime:  ocsfTime(a.LastSeen),
+        },
+    }
+    if a.Status != "" && a.Status != models.StatusNew {
+        f.ActivityID, f.ActivityName = ocsfActivityUpdate, "Update"
+    }
+    f.TypeUID = ocsfClassDetectionFinding*100 + f.ActivityID
+    f.TypeName = "Detection Finding: " + f.ActivityName
+
+    f.SeverityID = models.SeverityRank(a.Severity)
+    f.Severity = ocsfSeverities[f.SeverityID]
+    if f.SeverityID == 0 && a.Severity != "" {
+        f.SeverityID, f.Severity = ocsfSeverityOther, a.Severity // Keep the source's own severity
+    }
+    if id, ok := ocsfStatuses[a.Status]; ok {
+        f.StatusID, f.Status, f.StatusDetail = id, ocsfStatusNames[id], string(a.Status)
+    }
+    if a.Category != "" {
+        f.FindingInfo.Types = []string{a.Category}
+    }
+    if a.Hostname != "" {
+        f.Device = &OCSFDevice{Hostname: a.Hostname}
+    }
+    for _, m := range a.SigmaMatches {
+        f.FindingInfo.RelatedAnalytics = append(f.FindingInfo.RelatedAnalytics, OCSFAnalytic{
+            UID: m.RuleID, Name: m.Title, TypeID: ocsfAnalyticRule, Type: "Rule", Category: m.Level,
+        })
+    }
+
+    var geoSrc, geoDst *models.GeoInfo
+    if a.Enrichment != nil {
+        geoSrc, geoDst = a.Enrichment.SourceGeo, a.Enrichment.TargetGeo
+        for _, m := range a.Enrichment.ThreatIntel {
+            f.Enrichments = append(f.Enrichments, OCSFEnrichment{
+                Name: m.Field, Value: m.Value, Type: "threat_intel", Provider: m.Feed,
+                Data: map[string]interface{}{"indicator": m.Indicator, "type": m.Type, "description": m.Description},
+            })
+        }
+    }
+    evidence := OCSFEvidence{SrcEndpoint: ocsfEndpoint(a.SourceIP, geoSrc), DstEndpoint: ocsfEndpoint(a.TargetIP, geoDst)}
+    if a.Username != "" {
+        evidence.Actor = &OCSFActor{User: &OCSFUser{Name: a.Username}}
+    }
+    if a.FileHash != "" {
+        algorithm := hashAlgorithm(a.FileHash)
+        hash := OCSFHash{AlgorithmID: ocsfHashAlgorithms[algorithm], Algorithm: algorithm, Value: a.FileHash}
+        if algorithm == "" {
+            hash.AlgorithmID, hash.Algorithm = 99, "Other"
+        }
+        evidence.File = &OCSFFile{Hashes: []OCSFHash{hash}}
+    }
+    if evidence != (OCSFEvidence{}) {
+        f.Evidences = []OCSFEvidence{evidence}
+    }
+
+    if a.AIModelVersion != "" || a.PredictedSeverity != "" || a.RiskScore != 0 {
+        f.FindingInfo.Analytic = &OCSFAnalytic{Name: "GuardianAI", TypeID: ocsfAnalyticLearning, Type: "Learning (ML/DL)", Version: a.AIModelVersion}
+        score := int(math.Round(a.RiskScore * 100))
+        f.RiskScore = &score
+    }
+    if rank := models.SeverityRank(a.PredictedSeverity); rank > 0 {
+        level := rank - 1
+        f.RiskLevelID, f.RiskLevel = &level, ocsfRiskLevels[level]
+    }
+    if a.RecommendedAction != "" {
+        f.Remediation = &OCSFRemediation{Desc: a.RecommendedAction}
+    }
+    return f
+}
+
+// ocsfEndpoint returns the endpoint for ip, nil if ip is empty.
+func ocsfEndpoint(ip string, geo *models.GeoInfo) *OCSFEndpoint {
+    if ip == "" {
+        return nil
+    }
+    e := &OCSFEndpoint{IP: ip}
+    if geo != nil && geo.IP == ip {
+        if geo.City != "" || geo.Country != "" || geo.Latitude != nil {
+            e.Location = &OCSFLocation{City: geo.City, Country: geo.Country, Lat: geo.Latitude, Long: geo.Longitude}
+        }
+        if geo.ASN != 0 {
+            e.AutonomousSystem = &OCSFAutonomousSystem{Number: geo.ASN, Name: geo.ASOrg}
+        }
+    }
+    return e
+}
+
+// FromOCSF converts an OCSF Detection Finding to an alert. Enrichments are not read
+// back, the processor computes them again.
+func FromOCSF(f *OCSFDetectionFinding) (*models.SecurityAlert, error) {
+    if f.ClassUID != 0 && f.ClassUID != ocsfClassDetectionFinding {
+        return nil, fmt.Errorf("unsupported OCSF class %d, expected Detection Finding (%d)", f.ClassUID, ocsfClassDetectionFinding)
+    }
+    a := &models.SecurityAlert{
+        ID:              f.FindingInfo.UID,
+        Source:          f.Metadata.LogProvider,
+        Timestamp:       fromOCSFTime(f.Time),
+        Title:           f.FindingInfo.Title,
+        Description:     f.FindingInfo.Desc,
+        Attributes:      stringValues(f.Unmapped),
+        RawPayload:      rawPayload(f.RawData),
+        CreatedAt:       fromOCSFTime(f.FindingInfo.CreatedTime),
+        Fingerprint:     f.Metadata.CorrelationUID,
+        OccurrenceCount: f.Count,
+        LastSeen:        fromOCSFTime(f.FindingInfo.LastSeenTime),
+    }
+    if a.Title == "" {
+        a.Title = f.Message
+    }
+    if a.Title == "" {
+        return nil, fmt.Errorf("OCSF finding has no finding_info.title")
+    }
+    if a.Source == "" {
+        a.Source = f.Metadata.Product.Name
+    }
+    if a.Timestamp.IsZero() {
+        a.Timestamp = fromOCSFTime(f.FindingInfo.FirstSeenTime)
+    }
+    if len(f.FindingInfo.Types) > 0 {
+        a.Category = f.FindingInfo.Types[0]
+    }
+
+    if f.SeverityID == ocsfSeverityOther {
+        a.Severity = f.Severity
+    } else if f.SeverityID == 6 {
+        a.Severity = "critical" // Fatal
+    } else {
+        a.Severity = normalizedSeverity(f.SeverityID)
+    }
+    if status := models.AlertStatus(f.StatusDetail); status.IsValid() {
+        a.Status = status
+    } else {
+        a.Status = ocsfStatusStates[f.StatusID]
+    }
+
+    if f.Device != nil {
+        a.Hostname = f.Device.Hostname
+    }
+    for _, e := range f.Evidences {
+        if e.SrcEndpoint != nil && a.SourceIP == "" {
+            a.SourceIP = e.SrcEndpoint.IP
+        }
+        if e.DstEndpoint != nil && a.TargetIP == "" {
+            a.TargetIP = e.DstEndpoint.IP
+        }
+        if e.Actor != nil && e.Actor.User != nil && a.Username == "" {
+            a.Username = e.Actor.User.Name
+        }
+        if e.File != nil && len(e.File.Hashes) > 0 && a.FileHash == "" {
+            a.FileHash = e.File.Hashes[0].Value
+        }
+    }
+    for _, an := range f.FindingInfo.RelatedAnalytics {
+        if an.TypeID == ocsfAnalyticRule {
+            a.SigmaMatches = append(a.SigmaMatches, models.SigmaMatch{RuleID: an.UID, Title: an.Name, Level: an.Category})
+        }
+    }
+
+    if f.FindingInfo.Analytic != nil {
+        a.AIModelVersion = f.FindingInfo.Analytic.Version
+    }
+    if f.RiskScore != nil {
+        a.RiskScore = float64(*f.RiskScore) / 100
+    }
+    if f.RiskLevelID != nil {
+        a.PredictedSeverity = normalizedSeverity(*f.RiskLevelID + 1)
+    }
+    if f.Remediation != nil {
+        a.RecommendedAction = f.Remediation.Desc
+    }
+    return a, nil
+}
@@ -0,0 +1,145 @@
+package outbox
+
+import (
+    "context"
+    "errors"
+    "log"
+    "time"
+
+    kafkalib "github.com/segmentio/kafka-go"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/kafka"
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+    "github.com/Kelvinkhyd/GuardianAI/internal/repository"
+)
+
+const (
+    // sentRetention is how long sent messages are kept around for troubleshooting.
+    sentRetention = 24 * time.Hour
+    purgeInterval = time.Hour
+
+    // maxPublishAttempts is how often a message may fail before it is parked in the
+    // outbox. With the default backoff that is a little over an hour of retries.
+    maxPublishAttempts = 20
+    publishTimeout     = 10 * time.Second
+)
+
+// Relay drains the transactional outbox to Kafka. Messages that fail to publish
+// stay in the outbox and are retried with exponential backoff, so every alert
+// stored in the database eventually reaches the processor. A message that keeps
+// failing, e.g. because it is too large for the broker, is parked after
+// maxPublishAttempts so it does not hold up the others.
+type Relay struct {
+    repo         repository.OutboxRepository
+    producer     kafka.Publisher
+    pollInterval time.Duration
+    batchSize    int
+    backoff      repository.OutboxBackoff
+}
+
+// NewRelay creates a relay that polls the outbox every pollInterval and publishes
+// up to batchSize messages per Kafka write.
+func NewRelay(repo repository.OutboxRepository, producer kafka.Publisher, pollInterval time.Duration, batchSize int) *Relay {
+    return &Relay{
+        repo:         repo,
+        producer:     producer,
+        pollInterval: pollInterval,
+        batchSize:    batchSize,
+        backoff:      repository.OutboxBackoff{Initial: time.Second, Max: 5 * time.Minute, MaxAttempts: maxPublishAttempts},
+    }
+}
+
+// Run relays messages until ctx is cancelled. It is meant to be started in its own goroutine.
+func (r *Relay) Run(ctx context.Context) {
+    log.Printf("Outbox relay started (poll interval %s, batch size %d)", r.pollInterval, r.batchSize)
+
+    ticker := time.NewTicker(r.pollInterval)
+    defer ticker.Stop()
+    lastPurge := time.Now()
+
+    for {
+        select {
+        case <-ctx.Done():
+            log.Println("Outbox relay stopped.")
+            return
+        case <-ticker.C:
+        }
+
+        r.drain(ctx)
+
+        if time.Since(lastPurge) >= purgeInterval {
+            lastPurge = time.Now()
+            purged, err := r.repo.PurgeSentOutbox(ctx, time.Now().Add(-sentRetention))
+            if err != nil {
+                log.Printf("ERROR Outbox: Failed to purge sent messages: %v", err)
+            } else if purged > 0 {
+                log.Printf("Outbox: Purged %d sent messages older than %s", purged, sentRetention)
+            }
+        }
+    }
+}
+
+// drain publishes due messages until the outbox has no full batch left.
+func (r *Relay) drain(ctx context.Context) {
+    for ctx.Err() == nil {
+        n, err := r.repo.DrainOutbox(ctx, r.batchSize, r.backoff, r.publish)
+        if err != nil {
+            log.Printf("ERROR Outbox: Failed to drain outbox: %v", err)
+            return
+        }
+        if n < r.batchSize {
+            return // Caught up, wait for the next tick
+        }
+    }
+}
+
+// publish writes a batch of outbox messages to Kafka in a single call. If the batch
+// fails as a whole, the messages are tried again one at a time, so that a message
+// Kafka will never accept does not take the rest of the batch down with it.
+func (r *Relay) publish(ctx context.Context, msgs []models.OutboxMessage) error {
+    kmsgs := make([]kafkalib.Message, len(msgs))
+    for i, m := range msgs {
+        kmsgs[i] = kafkalib.Message{
+            Key:     m.Key,
+            Value:   m.Payload,
+            Headers: []kafkalib.Header{{Key: kafka.HeaderTenant, Value: []byte(m.TenantID)}},
+        }
+    }
+
+    writeCtx, cancel := context.WithTimeout(ctx, publishTimeout)
+    err := r.producer.PublishMessages(writeCtx, kmsgs...)
+    cancel()
+    if err == nil {
+        log.Printf("Outbox: Published %d alerts to Kafka", len(msgs))
+        return nil
+    }
+
+    // kafka-go reports the outcome per message when only some partitions failed.
+    var results kafkalib.WriteErrors
+    if !errors.As(err, &results) || len(results) != len(msgs) {
+        log.Printf("ERROR Outbox: Failed to publish %d messages to Kafka, retrying them one by one: %v", len(msgs), err)
+        results = make(kafkalib.WriteErrors, len(msgs))
+        writeCtx, cancel := context.WithTimeout(ctx, publishTimeout) // Shared, so an unreachable broker costs one timeout
+        defer cancel()
+        for i := range kmsgs {
+            results[i] = r.producer.PublishMessages(writeCtx, kmsgs[i])
+        }
+    }
+
+    failed := 0
+    for i, err := range results {
+        if err == nil {
+            continue
+        }
+        failed++
+        if attempt := msgs[i].Attempts + 1; r.backoff.MaxAttempts > 0 && attempt >= r.backoff.MaxAttempts {
+            log.Printf("ERROR Outbox: Giving up on alert %s after %d attempts, parking its message: %v", msgs[i].AlertID, attempt, err)
+        }
+    }
+    if failed == 0 {
+        log.Printf("Outbox: Published %d alerts to Kafka", len(msgs))
+        return nil
+    }
+    log.Printf("ERROR Outbox: Published %d of %d alerts to Kafka, the others stay in the outbox", len(msgs)-failed, len(msgs))
+    return repository.OutboxErrors(results)
+}
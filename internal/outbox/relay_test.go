@@ -0,0 +1,193 @@
+package outbox
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "sort"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+
+    kafkalib "github.com/segmentio/kafka-go"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/kafka"
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+    "github.com/Kelvinkhyd/GuardianAI/internal/repository"
+)
+
+// fakeProducer records published messages. A write fails as a whole if it contains
+// a message with "poison" in its value, like kafka-go does for a message that is too
+// large, or every write fails while down is set.
+type fakeProducer struct {
+    mu        sync.Mutex
+    down      bool
+    writes    int
+    published []string // Alert IDs, from the message keys
+    tenants   []string
+    // writeErrors, if set, is returned once instead of writing, as per-message errors.
+    writeErrors kafkalib.WriteErrors
+}
+
+func (p *fakeProducer) PublishMessages(ctx context.Context, msgs ...kafkalib.Message) error {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.writes++
+    if p.down {
+        return errors.New("dial tcp: connection refused")
+    }
+    if p.writeErrors != nil {
+        werr := p.writeErrors
+        p.writeErrors = nil
+        for i, m := range msgs {
+            if werr[i] == nil {
+                p.published = append(p.published, string(m.Key))
+            }
+        }
+        return werr
+    }
+    for _, m := range msgs {
+        if strings.Contains(string(m.Value), "poison") {
+            return kafkalib.MessageTooLargeError{Message: m}
+        }
+    }
+    for _, m := range msgs {
+        p.published = append(p.published, string(m.Key))
+        p.tenants = append(p.tenants, kafka.TenantOf(m))
+    }
+    return nil
+}
+
+func (p *fakeProducer) Close() error { return nil }
+
+func (p *fakeProducer) take() []string {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    ids := p.published
+    p.published = nil
+    sort.Strings(ids)
+    return ids
+}
+
+// newTestRelay returns a relay without retry delays that parks messages after
+// maxAttempts failures, and stores one alert per title.
+func newTestRelay(t *testing.T, producer kafka.Publisher, maxAttempts int, titles ...string) (*Relay, *repository.MemoryRepository) {
+    t.Helper()
+    repo := repository.NewMemoryRepository()
+    for i, title := range titles {
+        alert := models.SecurityAlert{
+            ID:        fmt.Sprintf("a%d", i+1),
+            Source:    "test",
+            Timestamp: time.Now(),
+            Severity:  "high",
+            Category:  "malware",
+            Title:     title,
+            Status:    models.StatusNew,
+        }
+        if err := repo.CreateAlert(context.Background(), "acme", &alert); err != nil {
+            t.Fatalf("CreateAlert: %v", err)
+        }
+    }
+    relay := NewRelay(repo, producer, time.Hour, 10)
+    relay.backoff = repository.OutboxBackoff{MaxAttempts: maxAttempts}
+    return relay, repo
+}
+
+// pending returns the alert IDs the outbox would still hand to a relay. Those are
+// counted as failed once more, so it is best used when none are expected.
+func pending(t *testing.T, repo repository.OutboxRepository) []string {
+    t.Helper()
+    var ids []string
+    _, err := repo.DrainOutbox(context.Background(), 100, repository.OutboxBackoff{}, func(ctx context.Context, msgs []models.OutboxMessage) error {
+        for _, m := range msgs {
+            ids = append(ids, m.AlertID)
+        }
+        return errors.New("only looking")
+    })
+    if err != nil {
+        t.Fatalf("DrainOutbox: %v", err)
+    }
+    return ids
+}
+
+func expectIDs(t *testing.T, what string, got []string, want ...string) {
+    t.Helper()
+    if strings.Join(got, ",") != strings.Join(want, ",") {
+        t.Errorf("%s: got %v, want %v", what, got, want)
+    }
+}
+
+func TestRelayPublishes(t *testing.T) {
+    producer := &fakeProducer{}
+    relay, repo := newTestRelay(t, producer, 3, "one", "two", "three")
+
+    relay.drain(context.Background())
+    expectIDs(t, "published", producer.take(), "a1", "a2", "a3")
+    if producer.writes != 1 {
+        t.Errorf("published in %d writes, want 1", producer.writes)
+    }
+    for _, tenant := range producer.tenants {
+        if tenant != "acme" {
+            t.Errorf("message published with tenant header %q, want acme", tenant)
+        }
+    }
+    expectIDs(t, "pending", pending(t, repo))
+}
+
+func TestRelayIsolatesFailingMessage(t *testing.T) {
+    producer := &fakeProducer{}
+    relay, repo := newTestRelay(t, producer, 3, "one", "poison", "three")
+
+    // The batch fails, the messages are tried one at a time and only the poison is left.
+    relay.drain(context.Background())
+    expectIDs(t, "published", producer.take(), "a1", "a3")
+
+    // It is retried until it has used up its attempts, then parked.
+    relay.drain(context.Background())
+    relay.drain(context.Background())
+    expectIDs(t, "published on retries", producer.take())
+    expectIDs(t, "pending after 3 attempts", pending(t, repo))
+
+    // New alerts are not held up by it.
+    alert := models.SecurityAlert{ID: "a4", Source: "test", Timestamp: time.Now(), Title: "four", Status: models.StatusNew}
+    if err := repo.CreateAlert(context.Background(), "acme", &alert); err != nil {
+        t.Fatalf("CreateAlert: %v", err)
+    }
+    relay.drain(context.Background())
+    expectIDs(t, "published after parking", producer.take(), "a4")
+}
+
+func TestRelayPerMessageWriteErrors(t *testing.T) {
+    producer := &fakeProducer{writeErrors: kafkalib.WriteErrors{nil, errors.New("not leader for partition"), nil}}
+    relay, repo := newTestRelay(t, producer, 3, "one", "two", "three")
+
+    // kafka-go already says which messages failed, so nothing is tried one by one.
+    relay.drain(context.Background())
+    expectIDs(t, "published", producer.take(), "a1", "a3")
+    if producer.writes != 1 {
+        t.Errorf("published in %d writes, want 1", producer.writes)
+    }
+
+    relay.drain(context.Background())
+    expectIDs(t, "published on retry", producer.take(), "a2")
+    expectIDs(t, "pending", pending(t, repo))
+}
+
+func TestRelayBrokerDown(t *testing.T) {
+    producer := &fakeProducer{down: true}
+    relay, repo := newTestRelay(t, producer, 3, "one", "two")
+
+    relay.drain(context.Background())
+    expectIDs(t, "published while down", producer.take())
+    if producer.writes != 3 {
+        t.Errorf("tried %d writes, want the batch and then each message", producer.writes)
+    }
+
+    producer.mu.Lock()
+    producer.down = false
+    producer.mu.Unlock()
+    relay.drain(context.Background())
+    expectIDs(t, "published once up", producer.take(), "a1", "a2")
+    expectIDs(t, "pending", pending(t, repo))
+}
@@ -0,0 +1,344 @@
+package processor
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "log"
+    "time"
+
+    kafkalib "github.com/segmentio/kafka-go"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/analyzer"
+    "github.com/Kelvinkhyd/GuardianAI/internal/breaker"
+    "github.com/Kelvinkhyd/GuardianAI/internal/config"
+    "github.com/Kelvinkhyd/GuardianAI/internal/correlation"
+    "github.com/Kelvinkhyd/GuardianAI/internal/geoip"
+    "github.com/Kelvinkhyd/GuardianAI/internal/kafka"
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+    "github.com/Kelvinkhyd/GuardianAI/internal/repository"
+    "github.com/Kelvinkhyd/GuardianAI/internal/sigma"
+    "github.com/Kelvinkhyd/GuardianAI/internal/threatintel"
+)
+
+// ConsumerGroupID is the consumer group shared by all processor instances.
+const ConsumerGroupID = "guardianai-alert-processor-group"
+
+// Processor analyses the alerts published by the API server and stores the results.
+// It runs either in cmd/processor against Kafka, or inside the API server on an
+// in-memory bus.
+type Processor struct {
+    repo     repository.AlertRepository
+    analyzer analyzer.Analyzer
+    breaker  *breaker.Breaker // Breaker around the AI service, may be nil
+    fallback bool             // Whether the analyzer falls back to local rules
+
+    correlator *correlation.Engine // Groups analysed alerts into incidents, may be nil
+
+    sigma       *sigma.Engine            // Sigma rules evaluated against every alert, may be nil
+    sigmaReload time.Duration            // How often Run checks the rules directory for changes, 0 never
+    tenantSigma map[string]*sigma.Engine // Extra Sigma rules for the alerts of single tenants
+
+    threatIntel       *threatintel.Engine // Indicators of compromise matched against every alert, may be nil
+    threatIntelReload time.Duration       // How often Run reloads the feeds, 0 never
+
+    geoIP *geoip.Enricher // Locates source and target addresses, may be nil
+}
+
+// New creates a processor that analyses alerts with a and stores the results in repo.
+func New(repo repository.AlertRepository, a analyzer.Analyzer) *Processor {
+    return &Processor{repo: repo, analyzer: a, fallback: true}
+}
+
+// NewFromConfig creates a processor with the configured analyzer chain: the AI
+// service behind a circuit breaker, falling back to the local rules engine when it
+// is unavailable, if enabled. Tenants with their own AI service endpoint get a chain
+// of their own, with a separate breaker.
+func NewFromConfig(cfg *config.Config, repo repository.AlertRepository) *Processor {
+    log.Printf("AI Service URL: %s", cfg.AIServiceURL)
+    alertAnalyzer, aiBreaker := aiAnalyzer(cfg, "ai-service", cfg.AIServiceURL)
+    if cfg.AnalyzerFallback {
+        log.Println("Local rules analyzer enabled as fallback for the AI service.")
+    }
+    if len(cfg.TenantAIServiceURLs) > 0 {
+        tenants := make(map[string]analyzer.Analyzer, len(cfg.TenantAIServiceURLs))
+        for tenant, url := range cfg.TenantAIServiceURLs {
+            tenants[tenant], _ = aiAnalyzer(cfg, "ai-service:"+tenant, url)
+            log.Printf("AI Service URL for tenant %s: %s", tenant, url)
+        }
+        alertAnalyzer = analyzer.NewTenantRouter(alertAnalyzer, tenants)
+    }
+    // Only the default breaker gates consumption, an outage of one tenant's service
+    // must not stall the others.
+    return &Processor{repo: repo, analyzer: alertAnalyzer, breaker: aiBreaker, fallback: cfg.AnalyzerFallback}
+}
+
+// aiAnalyzer creates the analyzer chain for the AI service at url, with a breaker
+// named name.
+func aiAnalyzer(cfg *config.Config, name, url string) (analyzer.Analyzer, *breaker.Breaker) {
+    aiBreaker := breaker.New(breaker.Settings{
+        Name:             name,
+        FailureThreshold: cfg.AIBreakerFailureThreshold,
+        OpenTimeout:      cfg.AIBreakerOpenTimeout,
+        HalfOpenMaxCalls: cfg.AIBreakerHalfOpenCalls,
+    })
+    var a analyzer.Analyzer = analyzer.NewHTTPAnalyzer(url, cfg.AIServiceTimeout, aiBreaker)
+    if cfg.AnalyzerFallback {
+        a = analyzer.NewFallback(a, analyzer.NewRuleAnalyzer())
+    }
+    return a, aiBreaker
+}
+
+// CorrelatorFromConfig returns the configured correlation engine storing incidents
+// in incidents, or nil if correlation is disabled.
+func CorrelatorFromConfig(cfg *config.Config, incidents repository.IncidentRepository) (*correlation.Engine, error) {
+    if !cfg.CorrelationEnabled {
+        return nil, nil
+    }
+    engine, err := correlation.NewEngine(incidents, cfg.CorrelationWindow, cfg.CorrelationEntities)
+    if err != nil {
+        return nil, fmt.Errorf("invalid correlation settings: %w", err)
+    }
+    log.Printf("Correlating alerts into incidents on %v within %s", cfg.CorrelationEntities, cfg.CorrelationWindow)
+    return engine, nil
+}
+
+// RetryPolicy returns the configured retry and dead-letter policy for the processor's consumer.
+func RetryPolicy(cfg *config.Config) kafka.RetryPolicy {
+    return kafka.RetryPolicy{
+        MaxRetries:      cfg.KafkaMaxRetries,
+        InitialBackoff:  cfg.KafkaRetryBackoff,
+        MaxBackoff:      cfg.KafkaRetryMaxBackoff,
+        DeadLetterTopic: cfg.KafkaDLQTopic,
+    }
+}
+
+// SetCorrelator makes the processor attach every analysed alert to an incident.
+func (p *Processor) SetCorrelator(e *correlation.Engine) {
+    p.correlator = e
+}
+
+// SetSigma makes the processor evaluate every alert against the engine's Sigma rules.
+// While Run is active the rules are reloaded when their directory changes, checked
+// every reloadInterval; zero disables reloading.
+func (p *Processor) SetSigma(e *sigma.Engine, reloadInterval time.Duration) {
+    p.sigma = e
+    p.sigmaReload = reloadInterval
+}
+
+// SetTenantSigma makes the processor evaluate the alerts of tenant against the
+// engine's Sigma rules too, on top of the rules set with SetSigma. Reloading works as
+// for SetSigma; all rule sets share one reloadInterval.
+func (p *Processor) SetTenantSigma(tenant string, e *sigma.Engine, reloadInterval time.Duration) {
+    if p.tenantSigma == nil {
+        p.tenantSigma = make(map[string]*sigma.Engine)
+    }
+    p.tenantSigma[tenant] = e
+    p.sigmaReload = reloadInterval
+}
+
+// SigmaFromConfig loads the configured Sigma rules, or returns nil if no rules
+// directory is configured.
+func SigmaFromConfig(cfg *config.Config) (*sigma.Engine, error) {
+    if cfg.SigmaRulesDir == "" {
+        return nil, nil
+    }
+    return loadSigma(cfg, cfg.SigmaRulesDir)
+}
+
+// TenantSigmaFromConfig loads the configured per-tenant Sigma rules, keyed by tenant.
+func TenantSigmaFromConfig(cfg *config.Config) (map[string]*sigma.Engine, error) {
+    engines := make(map[string]*sigma.Engine, len(cfg.TenantSigmaRulesDirs))
+    for tenant, dir := range cfg.TenantSigmaRulesDirs {
+        engine, err := loadSigma(cfg, dir)
+        if err != nil {
+            return nil, fmt.Errorf("failed to load Sigma rules of tenant %s: %w", tenant, err)
+        }
+        engines[tenant] = engine
+    }
+    return engines, nil
+}
+
+// loadSigma loads the Sigma rules in dir with the configured field mapping.
+func loadSigma(cfg *config.Config, dir string) (*sigma.Engine, error) {
+    mapping, err := sigma.LoadFieldMapping(cfg.SigmaFieldMapping)
+    if err != nil {
+        return nil, err
+    }
+    engine, err := sigma.NewEngine(dir, mapping)
+    if err != nil {
+        return nil, err
+    }
+    log.Printf("Loaded %d Sigma rules from %s", engine.Rules().Len(), dir)
+    return engine, nil
+}
+
+// SetThreatIntel makes the processor match every alert against the engine's
+// threat intel feeds. While Run is active the feeds are reloaded every
+// reloadInterval; zero disables reloading.
+func (p *Processor) SetThreatIntel(e *threatintel.Engine, reloadInterval time.Duration) {
+    p.threatIntel = e
+    p.threatIntelReload = reloadInterval
+}
+
+// ThreatIntelFromConfig loads the configured threat intel feeds, or returns nil if
+// no feed directory is configured.
+func ThreatIntelFromConfig(cfg *config.Config) (*threatintel.Engine, error) {
+    if cfg.ThreatIntelDir == "" {
+        return nil, nil
+    }
+    engine, err := threatintel.NewEngine(cfg.ThreatIntelDir, cfg.ThreatIntelRiskBoost)
+    if err != nil {
+        return nil, err
+    }
+    log.Printf("Loaded %d threat intel indicators from %s", engine.Index().Len(), cfg.ThreatIntelDir)
+    return engine, nil
+}
+
+// SetGeoIP makes the processor record where each alert's addresses live.
+func (p *Processor) SetGeoIP(e *geoip.Enricher) {
+    p.geoIP = e
+}
+
+// GeoIPFromConfig opens the configured GeoIP databases, or returns nil if none is
+// configured. The caller must close the enricher.
+func GeoIPFromConfig(cfg *config.Config) (*geoip.Enricher, error) {
+    if cfg.GeoIPCityDB == "" && cfg.GeoIPASNDB == "" {
+        return nil, nil
+    }
+    enricher, err := geoip.Open(cfg.GeoIPCityDB, cfg.GeoIPASNDB)
+    if err != nil {
+        return nil, err
+    }
+    log.Printf("GeoIP enrichment enabled (city database: %q, ASN database: %q)", cfg.GeoIPCityDB, cfg.GeoIPASNDB)
+    return enricher, nil
+}
+
+// Breaker returns the circuit breaker around the AI service, or nil.
+func (p *Processor) Breaker() *breaker.Breaker {
+    return p.breaker
+}
+
+// Run consumes alerts from sub until ctx is cancelled.
+func (p *Processor) Run(ctx context.Context, sub kafka.Subscriber) {
+    if !p.fallback && p.breaker != nil {
+        // Without a fallback there is nothing useful to do while the breaker is open:
+        // pause consumption instead of burning through retries.
+        sub.SetGate(p.breaker.Wait)
+    }
+    if p.sigma != nil && p.sigmaReload > 0 {
+        go p.sigma.Watch(ctx, p.sigmaReload)
+    }
+    if p.sigmaReload > 0 {
+        for _, e := range p.tenantSigma {
+            go e.Watch(ctx, p.sigmaReload)
+        }
+    }
+    if p.threatIntel != nil && p.threatIntelReload > 0 {
+        go p.threatIntel.Run(ctx, p.threatIntelReload)
+    }
+    sub.ConsumeMessages(ctx, func(message kafkalib.Message) error {
+        return p.Handle(ctx, message)
+    })
+}
+
+// Handle analyses the alert in one message and stores the result.
+func (p *Processor) Handle(ctx context.Context, message kafkalib.Message) error {
+    var alert models.SecurityAlert // This will be the original alert from Kafka
+    err := json.Unmarshal(message.Value, &alert)
+    if err != nil {
+        log.Printf("ERROR Processor: Failed to unmarshal alert JSON from Kafka: %v", err)
+        return kafka.Permanent(fmt.Errorf("invalid alert JSON: %w", err)) // Retrying won't help, park it in the DLQ
+    }
+
+    // The tenant header is authoritative; messages published before tenants existed
+    // have neither header nor tenant_id and belong to the default tenant.
+    tenant := kafka.TenantOf(message)
+    if tenant == "" {
+        tenant = alert.TenantID
+    }
+    if tenant == "" {
+        tenant = models.DefaultTenant
+    }
+    alert.TenantID = tenant
+
+    log.Printf("Processor: Received alert ID: %s, Source: %s, Tenant: %s from Kafka for analysis.", alert.ID, alert.Source, tenant)
+
+    // The raw payload is left out of the message to keep it small; the analyzer and the
+    // Sigma rules get it from the stored alert.
+    if len(alert.RawPayload) == 0 {
+        stored, err := p.repo.GetAlertByID(ctx, tenant, alert.ID)
+        if err != nil {
+            log.Printf("ERROR Processor: Failed to load the raw payload of alert %s: %v", alert.ID, err)
+            return err // Re-queue if the database is unreachable
+        }
+        if stored != nil {
+            alert.RawPayload = stored.RawPayload
+        }
+    }
+
+    // --- Step 1: Analyze the alert (AI service, or local rules as fallback) ---
+    analyzedAlert, err := p.analyzer.Analyze(ctx, alert)
+    if err != nil {
+        log.Printf("ERROR Processor: Failed to analyze alert %s: %v", alert.ID, err)
+        return err // Re-queue if the analyzer is unreachable or responds with error
+    }
+
+    log.Printf("Processor: AI analysis complete for alert ID: %s (%s). Predicted Severity: %s, Risk Score: %.2f",
+        analyzedAlert.ID, analyzedAlert.AIModelVersion, analyzedAlert.PredictedSeverity, analyzedAlert.RiskScore)
+
+    // --- Step 1b: Evaluate the Sigma rules; a matching rule can raise the predicted severity ---
+    if p.sigma != nil {
+        analyzedAlert.SigmaMatches = p.sigma.Evaluate(alert)
+    }
+    if e := p.tenantSigma[tenant]; e != nil {
+        analyzedAlert.SigmaMatches = append(analyzedAlert.SigmaMatches, e.Evaluate(alert)...)
+    }
+    if len(analyzedAlert.SigmaMatches) > 0 {
+        sigma.Escalate(analyzedAlert)
+        log.Printf("Processor: Alert ID: %s matched %d Sigma rules. Predicted Severity: %s",
+            alert.ID, len(analyzedAlert.SigmaMatches), analyzedAlert.PredictedSeverity)
+    }
+
+    // --- Step 1c: Enrichment: threat intel (a match raises the risk score) and GeoIP ---
+    if p.threatIntel != nil {
+        if n := p.threatIntel.Enrich(analyzedAlert); n > 0 {
+            log.Printf("Processor: Alert ID: %s matched %d threat intel indicators. Risk Score: %.2f",
+                alert.ID, n, analyzedAlert.RiskScore)
+        }
+    }
+    if p.geoIP != nil {
+        if err := p.geoIP.Enrich(analyzedAlert); err != nil {
+            // Not worth retrying: the database will not change until it is replaced
+            log.Printf("ERROR Processor: GeoIP enrichment of alert %s failed: %v", alert.ID, err)
+        }
+    }
+
+    // --- Step 2: Update alert in database with AI results ---
+    // The analyzer returns the full alert with AI fields populated.
+    // We set status to 'analyzed' after AI processing.
+    analyzedAlert.Status = models.StatusAnalyzed
+    analyzedAlert.TenantID = tenant                                   // Analyzers copy the alert, but do not trust them with the tenant
+    err = p.repo.UpdateAlertWithAIResults(ctx, tenant, analyzedAlert) // Pass the full analyzedAlert
+    if err != nil {
+        log.Printf("ERROR Processor: Failed to update alert %s with AI results in DB: %v", alert.ID, err)
+        return err // Re-queue if DB update failed
+    }
+    log.Printf("Processor: Alert ID: %s updated in DB with AI results and status 'analyzed'.", alert.ID)
+
+    // --- Step 3: Group the alert into an incident ---
+    if p.correlator != nil {
+        incident, created, err := p.correlator.Correlate(ctx, *analyzedAlert)
+        if err != nil {
+            log.Printf("ERROR Processor: Failed to correlate alert %s: %v", alert.ID, err)
+            return err // Re-queue; attaching an alert twice is harmless
+        }
+        if created {
+            log.Printf("Processor: Alert ID: %s opened incident %s.", alert.ID, incident.ID)
+        } else {
+            log.Printf("Processor: Alert ID: %s added to incident %s (%d alerts, risk score %.2f).", alert.ID, incident.ID, incident.AlertCount, incident.RiskScore)
+        }
+    }
+
+    return nil // Message processed successfully
+}
@@ -0,0 +1,118 @@
+package processor
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    kafkalib "github.com/segmentio/kafka-go"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/analyzer"
+    "github.com/Kelvinkhyd/GuardianAI/internal/kafka"
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+    "github.com/Kelvinkhyd/GuardianAI/internal/outbox"
+    "github.com/Kelvinkhyd/GuardianAI/internal/repository"
+)
+
+// TestPipeline runs the API side (repository and outbox relay) and the processor in
+// one process on the in-memory repository and bus.
+func TestPipeline(t *testing.T) {
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+
+    repo := repository.NewMemoryRepository()
+    bus := kafka.NewMemoryBus(2)
+    defer bus.Close()
+
+    relay := outbox.NewRelay(repo, bus.NewProducer("alerts"), 10*time.Millisecond, 10)
+    go relay.Run(ctx)
+
+    consumer := bus.NewConsumer("alerts", ConsumerGroupID, kafka.RetryPolicy{DeadLetterTopic: "alerts.dlq"}, 2)
+    defer consumer.Close()
+    go New(repo, analyzer.NewRuleAnalyzer()).Run(ctx, consumer)
+
+    alert := models.SecurityAlert{
+        ID:        "pipeline-1",
+        Source:    "test",
+        Timestamp: time.Now(),
+        Severity:  "high",
+        Category:  "malware",
+        Title:     "Ransomware detected",
+        Status:    models.StatusNew,
+    }
+    if err := repo.CreateAlert(ctx, "acme", &alert); err != nil {
+        t.Fatalf("CreateAlert: %v", err)
+    }
+
+    deadline := time.Now().Add(5 * time.Second)
+    for time.Now().Before(deadline) {
+        // The tenant travels in a message header, the result must land under it
+        stored, err := repo.GetAlertByID(ctx, "acme", alert.ID)
+        if err != nil {
+            t.Fatalf("GetAlertByID: %v", err)
+        }
+        if stored.Status == models.StatusAnalyzed {
+            if stored.AIModelVersion == "" || stored.PredictedSeverity == "" {
+                t.Errorf("analysed alert is missing AI results: %+v", *stored)
+            }
+            return
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+    t.Fatal("alert was not analysed in time")
+}
+
+func TestHandleRejectsInvalidJSON(t *testing.T) {
+    p := New(repository.NewMemoryRepository(), analyzer.NewRuleAnalyzer())
+    err := p.Handle(context.Background(), kafkalib.Message{Value: []byte("not json")})
+    if !kafka.IsPermanent(err) {
+        t.Errorf("Handle(invalid JSON): got %v, want a permanent error", err)
+    }
+}
+
+// recordingAnalyzer remembers the alert it was asked to analyse.
+type recordingAnalyzer struct {
+    analyzer.Analyzer
+    got models.SecurityAlert
+}
+
+func (a *recordingAnalyzer) Analyze(ctx context.Context, alert models.SecurityAlert) (*models.SecurityAlert, error) {
+    a.got = alert
+    return a.Analyzer.Analyze(ctx, alert)
+}
+
+func TestHandleLoadsRawPayload(t *testing.T) {
+    ctx := context.Background()
+    repo := repository.NewMemoryRepository()
+    alert := models.SecurityAlert{
+        ID:         "raw-1",
+        Source:     "test",
+        Timestamp:  time.Now(),
+        Severity:   "high",
+        Category:   "malware",
+        Title:      "Ransomware detected",
+        Status:     models.StatusNew,
+        RawPayload: []byte(`{"vendor":{"rule_id":42}}`),
+    }
+    if err := repo.CreateAlert(ctx, "acme", &alert); err != nil {
+        t.Fatalf("CreateAlert: %v", err)
+    }
+
+    // Take the message as the relay would publish it: without the raw payload.
+    var message kafkalib.Message
+    _, err := repo.DrainOutbox(ctx, 10, repository.OutboxBackoff{}, func(ctx context.Context, msgs []models.OutboxMessage) error {
+        message = kafkalib.Message{Value: msgs[0].Payload, Headers: []kafkalib.Header{{Key: kafka.HeaderTenant, Value: []byte(msgs[0].TenantID)}}}
+        return nil
+    })
+    if err != nil {
+        t.Fatalf("DrainOutbox: %v", err)
+    }
+
+    a := &recordingAnalyzer{Analyzer: analyzer.NewRuleAnalyzer()}
+    if err := New(repo, a).Handle(ctx, message); err != nil {
+        t.Fatalf("Handle: %v", err)
+    }
+    if string(a.got.RawPayload) != string(alert.RawPayload) {
+        t.Errorf("analyzer got raw payload %s, want %s from the stored alert", a.got.RawPayload, alert.RawPayload)
+    }
+}
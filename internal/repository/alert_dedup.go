@@ -0,0 +1,371 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "sort"
+    "time"
+
+    "github.com/lib/pq"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// FoldResult describes what CreateOrFoldAlert did with an incoming alert.
+type FoldResult struct {
+    AlertID         string // The stored alert: the new one, or the one the repeat was folded into
+    Folded          bool   // The alert was a repeat and no new row was created
+    OccurrenceCount int    // Occurrences of the stored alert, including this one
+    Escalated       bool   // The count reached an escalation threshold and the alert was queued again
+}
+
+// reachesThreshold reports whether count is one of the escalation thresholds.
+// Counts grow one by one, so every threshold is hit exactly once.
+func reachesThreshold(count int, escalateAt []int) bool {
+    for _, n := range escalateAt {
+        if count == n {
+            return true
+        }
+    }
+    return false
+}
+
+// CreateOrFoldAlert looks for a recent alert of the tenant with the same fingerprint and
+// either folds the new one into it or inserts it. A transaction-scoped advisory lock on
+// the tenant and fingerprint serialises concurrent requests for the same alert, so two
+// identical alerts arriving at once cannot both be inserted.
+func (r *pgAlertRepository) CreateOrFoldAlert(ctx context.Context, tenant string, alert *models.SecurityAlert, window time.Duration, escalateAt []int) (*FoldResult, error) {
+    if alert.Fingerprint == "" {
+        if err := r.CreateAlert(ctx, tenant, alert); err != nil {
+            return nil, err
+        }
+        return &FoldResult{AlertID: alert.ID, OccurrenceCount: alert.OccurrenceCount}, nil
+    }
+    if err := checkTenant(tenant); err != nil {
+        return nil, err
+    }
+    alert.TenantID = tenant
+
+    tx, err := r.db.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, fmt.Errorf("failed to begin transaction: %w", err)
+    }
+    defer tx.Rollback() // No-op after a successful commit
+
+    if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, tenant+":"+alert.Fingerprint); err != nil {
+        return nil, fmt.Errorf("failed to lock fingerprint %s: %w", alert.Fingerprint, err)
+    }
+
+    existingID, err := foldTarget(ctx, tx, tenant, alert, window)
+    if err != nil {
+        return nil, err
+    }
+    if existingID == "" {
+        // First occurrence within the window: store it like CreateAlert does.
+        setOccurrenceDefaults(alert)
+        if err := insertAlert(ctx, tx, alert); err != nil {
+            return nil, err
+        }
+        if err := enqueueOutbox(ctx, tx, *alert); err != nil {
+            return nil, err
+        }
+        if err := recordAlertEvents(ctx, tx, models.AlertEventCreated, *alert); err != nil {
+            return nil, err
+        }
+        if err := tx.Commit(); err != nil {
+            return nil, fmt.Errorf("failed to commit alert %s: %w", alert.ID, err)
+        }
+        return &FoldResult{AlertID: alert.ID, OccurrenceCount: alert.OccurrenceCount}, nil
+    }
+
+    res, err := foldInto(ctx, tx, tenant, existingID, alert, escalateAt)
+    if err != nil {
+        return nil, err
+    }
+    if err := tx.Commit(); err != nil {
+        return nil, fmt.Errorf("failed to commit folded alert %s: %w", existingID, err)
+    }
+    return res, nil
+}
+
+// CreateOrFoldAlerts stores or folds every alert like CreateOrFoldAlert, in a single
+// transaction. The advisory locks of all fingerprints are taken up front, in sorted
+// order so that concurrent batches cannot deadlock. With the locks held, the fold
+// targets and taken IDs are looked up once for the whole batch, the outcome of every
+// alert is worked out in order, and the new alerts are inserted and the folds applied
+// with a few multi-row statements, so a large batch costs a handful of round trips.
+func (r *pgAlertRepository) CreateOrFoldAlerts(ctx context.Context, tenant string, alerts []models.SecurityAlert, window time.Duration, escalateAt []int) ([]*FoldResult, error) {
+    if err := checkTenant(tenant); err != nil {
+        return nil, err
+    }
+    if len(alerts) == 0 {
+        return nil, nil
+    }
+
+    tx, err := r.db.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, fmt.Errorf("failed to begin transaction: %w", err)
+    }
+    defer tx.Rollback() // No-op after a successful commit
+
+    fingerprints := make([]string, 0, len(alerts))
+    ids := make([]string, 0, len(alerts))
+    for i := range alerts {
+        alerts[i].TenantID = tenant
+        if alerts[i].Fingerprint != "" {
+            fingerprints = append(fingerprints, alerts[i].Fingerprint)
+        }
+        ids = append(ids, alerts[i].ID)
+    }
+    sort.Strings(fingerprints)
+    for i, fp := range fingerprints {
+        if i > 0 && fp == fingerprints[i-1] {
+            continue
+        }
+        if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, tenant+":"+fp); err != nil {
+            return nil, fmt.Errorf("failed to lock fingerprint %s: %w", fp, err)
+        }
+    }
+
+    latest, err := latestByFingerprint(ctx, tx, tenant, fingerprints)
+    if err != nil {
+        return nil, err
+    }
+    taken, err := existingAlertIDs(ctx, tx, tenant, ids)
+    if err != nil {
+        return nil, err
+    }
+
+    // Decide what happens to each alert, as if they arrived one after the other.
+    results := make([]*FoldResult, len(alerts))
+    var created []models.SecurityAlert
+    folds := make(map[string][]int) // Target alert ID -> positions folded into it, in order
+    var targets []string            // Keys of folds, in order of their first fold
+    for i := range alerts {
+        alert := &alerts[i]
+        if target, ok := latest[alert.Fingerprint]; ok && alert.Fingerprint != "" && !target.lastSeen.Before(alert.Timestamp.Add(-window)) {
+            if len(folds[target.id]) == 0 {
+                targets = append(targets, target.id)
+            }
+            folds[target.id] = append(folds[target.id], i)
+            if alert.Timestamp.After(target.lastSeen) {
+                target.lastSeen = alert.Timestamp
+            }
+            continue
+        }
+        if taken[alert.ID] {
+            continue // The tenant already has an alert with this ID
+        }
+        taken[alert.ID] = true
+        setOccurrenceDefaults(alert)
+        created = append(created, *alert)
+        results[i] = &FoldResult{AlertID: alert.ID, OccurrenceCount: alert.OccurrenceCount}
+        if alert.Fingerprint != "" {
+            latest[alert.Fingerprint] = &foldCandidate{id: alert.ID, lastSeen: alert.LastSeen}
+        }
+    }
+
+    createdAt := make(map[string]time.Time, len(created))
+    for start := 0; start < len(created); start += insertBatchSize {
+        end := start + insertBatchSize
+        if end > len(created) {
+            end = len(created)
+        }
+        if _, err := insertAlertChunk(ctx, tx, created[start:end], createdAt); err != nil {
+            return nil, err
+        }
+    }
+    for i := range created {
+        at, ok := createdAt[created[i].ID]
+        if !ok {
+            // Inserted by a concurrent request after existingAlertIDs looked.
+            return nil, fmt.Errorf("failed to create alert: duplicate alert ID %s: %w", created[i].ID, ErrAlertExists)
+        }
+        created[i].CreatedAt = at
+    }
+
+    escalated, err := applyFolds(ctx, tx, tenant, alerts, targets, folds, escalateAt, results)
+    if err != nil {
+        return nil, err
+    }
+
+    for start := 0; start < len(created); start += insertBatchSize {
+        end := start + insertBatchSize
+        if end > len(created) {
+            end = len(created)
+        }
+        if err := enqueueOutbox(ctx, tx, created[start:end]...); err != nil {
+            return nil, err
+        }
+        if err := recordAlertEvents(ctx, tx, models.AlertEventCreated, created[start:end]...); err != nil {
+            return nil, err
+        }
+    }
+    if err := enqueueOutbox(ctx, tx, escalated...); err != nil {
+        return nil, err
+    }
+    if err := tx.Commit(); err != nil {
+        return nil, fmt.Errorf("failed to commit alert batch: %w", err)
+    }
+    return results, nil
+}
+
+// foldCandidate is the most recently seen alert with a given fingerprint.
+type foldCandidate struct {
+    id       string
+    lastSeen time.Time
+}
+
+// latestByFingerprint returns the tenant's most recently seen alert for each of
+// fingerprints that has one.
+func latestByFingerprint(ctx context.Context, tx *sql.Tx, tenant string, fingerprints []string) (map[string]*foldCandidate, error) {
+    latest := make(map[string]*foldCandidate)
+    if len(fingerprints) == 0 {
+        return latest, nil
+    }
+    rows, err := tx.QueryContext(ctx, `
+        SELECT DISTINCT ON (fingerprint) fingerprint, id, last_seen
+        FROM alerts
+        WHERE tenant_id = $1 AND fingerprint = ANY($2)
+        ORDER BY fingerprint, last_seen DESC`, tenant, pq.Array(fingerprints))
+    if err != nil {
+        return nil, fmt.Errorf("failed to look up alerts by fingerprint: %w", err)
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var fp string
+        c := &foldCandidate{}
+        if err := rows.Scan(&fp, &c.id, &c.lastSeen); err != nil {
+            return nil, fmt.Errorf("failed to scan alert fingerprint: %w", err)
+        }
+        latest[fp] = c
+    }
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("row iteration error: %w", err)
+    }
+    return latest, nil
+}
+
+// existingAlertIDs returns which of ids the tenant already has alerts for.
+func existingAlertIDs(ctx context.Context, tx *sql.Tx, tenant string, ids []string) (map[string]bool, error) {
+    rows, err := tx.QueryContext(ctx, `SELECT id FROM alerts WHERE tenant_id = $1 AND id = ANY($2)`, tenant, pq.Array(ids))
+    if err != nil {
+        return nil, fmt.Errorf("failed to look up existing alert IDs: %w", err)
+    }
+    defer rows.Close()
+    existing := make(map[string]bool)
+    for rows.Next() {
+        var id string
+        if err := rows.Scan(&id); err != nil {
+            return nil, fmt.Errorf("failed to scan alert ID: %w", err)
+        }
+        existing[id] = true
+    }
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("row iteration error: %w", err)
+    }
+    return existing, nil
+}
+
+// applyFolds counts the alerts at the positions in folds as occurrences of their
+// target alerts with a single UPDATE, and fills in their results. It returns the
+// copies of target alerts to queue again because their count passed a threshold.
+func applyFolds(ctx context.Context, tx *sql.Tx, tenant string, alerts []models.SecurityAlert, targets []string, folds map[string][]int, escalateAt []int, results []*FoldResult) ([]models.SecurityAlert, error) {
+    if len(targets) == 0 {
+        return nil, nil
+    }
+    counts := make([]int, len(targets))
+    seen := make([]string, len(targets)) // Latest timestamp of the repeats, as timestamptz text
+    for i, id := range targets {
+        counts[i] = len(folds[id])
+        var latest time.Time
+        for _, pos := range folds[id] {
+            if alerts[pos].Timestamp.After(latest) {
+                latest = alerts[pos].Timestamp
+            }
+        }
+        seen[i] = latest.Format(time.RFC3339Nano)
+    }
+
+    rows, err := tx.QueryContext(ctx, `
+        UPDATE alerts a SET
+            occurrence_count = a.occurrence_count + f.n,
+            last_seen = GREATEST(a.last_seen, f.seen)
+        FROM unnest($1::text[], $2::int[], $3::timestamptz[]) AS f(target_id, n, seen)
+        WHERE a.tenant_id = $4 AND a.id = f.target_id
+        RETURNING`+alertColumns,
+        pq.Array(targets), pq.Array(counts), pq.Array(seen), tenant)
+    if err != nil {
+        return nil, fmt.Errorf("failed to fold alert batch: %w", err)
+    }
+    defer rows.Close()
+
+    var escalated []models.SecurityAlert
+    for rows.Next() {
+        folded, err := scanAlert(rows)
+        if err != nil {
+            return nil, fmt.Errorf("failed to scan folded alert: %w", err)
+        }
+        positions := folds[folded.ID]
+        count := folded.OccurrenceCount - len(positions) // Before this batch
+        for _, pos := range positions {
+            count++
+            results[pos] = &FoldResult{AlertID: folded.ID, Folded: true, OccurrenceCount: count}
+            if reachesThreshold(count, escalateAt) {
+                results[pos].Escalated = true
+                snapshot := *folded
+                snapshot.OccurrenceCount = count
+                escalated = append(escalated, snapshot)
+            }
+        }
+    }
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("row iteration error: %w", err)
+    }
+    return escalated, nil
+}
+
+// foldTarget returns the ID of the tenant's most recently seen alert with the same
+// fingerprint as alert, if it was last seen within window of it, or "" if there is none.
+func foldTarget(ctx context.Context, tx *sql.Tx, tenant string, alert *models.SecurityAlert, window time.Duration) (string, error) {
+    if alert.Fingerprint == "" {
+        return "", nil
+    }
+    var existingID string
+    err := tx.QueryRowContext(ctx, `
+        SELECT id FROM alerts
+        WHERE tenant_id = $1 AND fingerprint = $2 AND last_seen >= $3
+        ORDER BY last_seen DESC
+        LIMIT 1`, tenant, alert.Fingerprint, alert.Timestamp.Add(-window)).Scan(&existingID)
+    if err == sql.ErrNoRows {
+        return "", nil
+    }
+    if err != nil {
+        return "", fmt.Errorf("failed to look up alerts with fingerprint %s: %w", alert.Fingerprint, err)
+    }
+    return existingID, nil
+}
+
+// foldInto counts alert as another occurrence of the stored alert existingID and
+// queues that again if its count reaches one of escalateAt.
+func foldInto(ctx context.Context, tx *sql.Tx, tenant, existingID string, alert *models.SecurityAlert, escalateAt []int) (*FoldResult, error) {
+    folded, err := scanAlert(tx.QueryRowContext(ctx, `
+        UPDATE alerts SET
+            occurrence_count = occurrence_count + 1,
+            last_seen = GREATEST(last_seen, $1)
+        WHERE id = $2 AND tenant_id = $3
+        RETURNING`+alertColumns, alert.Timestamp, existingID, tenant))
+    if err != nil {
+        return nil, fmt.Errorf("failed to fold alert into %s: %w", existingID, err)
+    }
+
+    res := &FoldResult{AlertID: folded.ID, Folded: true, OccurrenceCount: folded.OccurrenceCount}
+    if reachesThreshold(folded.OccurrenceCount, escalateAt) {
+        if err := enqueueOutbox(ctx, tx, *folded); err != nil {
+            return nil, err
+        }
+        res.Escalated = true
+    }
+    return res, nil
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "log"
+    "strings"
+    "time"
+
+    "github.com/lib/pq"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// AlertEventsChannel is the Postgres NOTIFY channel signalled whenever alert events
+// are committed.
+const AlertEventsChannel = "alert_events"
+
+// alertEventsLockKey is the advisory lock serialising the transactions that record
+// alert events, see recordAlertEvents.
+const alertEventsLockKey = 7215320118
+
+// AlertEventRepository gives the alert stream access to the alert event log. Events
+// are recorded by AlertRepository when alerts are created and when AI results are
+// stored, in the same transaction as the change.
+type AlertEventRepository interface {
+    // LatestAlertEventID returns the ID of the newest event, or 0 if there is none.
+    LatestAlertEventID(ctx context.Context) (int64, error)
+    // GetAlertEventsAfter returns up to limit events with an ID greater than afterID,
+    // oldest first.
+    GetAlertEventsAfter(ctx context.Context, afterID int64, limit int) ([]models.AlertEvent, error)
+    // PurgeAlertEvents deletes events recorded before the given time.
+    PurgeAlertEvents(ctx context.Context, before time.Time) (int64, error)
+}
+
+// pgAlertEventRepository implements AlertEventRepository for PostgreSQL.
+type pgAlertEventRepository struct {
+    db *sql.DB
+}
+
+// NewPgAlertEventRepository creates a new instance of pgAlertEventRepository.
+func NewPgAlertEventRepository(db *sql.DB) AlertEventRepository {
+    return &pgAlertEventRepository{db: db}
+}
+
+// recordAlertEvents writes one event per alert inside the caller's transaction and
+// notifies AlertEventsChannel, which Postgres delivers when the transaction commits.
+//
+// Readers page through the log by ID, so IDs must become visible in increasing order.
+// A sequence alone does not guarantee that: a transaction can take ID 5, commit after
+// the one holding ID 6, and be skipped by a reader that already moved past 6. The
+// advisory lock makes event-writing transactions commit in ID order. It is taken as
+// the last step before the commit, so it is only held for a moment.
+func recordAlertEvents(ctx context.Context, tx *sql.Tx, eventType models.AlertEventType, alerts ...models.SecurityAlert) error {
+    if len(alerts) == 0 {
+        return nil
+    }
+    if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, int64(alertEventsLockKey)); err != nil {
+        return fmt.Errorf("failed to lock alert event log: %w", err)
+    }
+
+    placeholders := make([]string, 0, len(alerts))
+    args := make([]interface{}, 0, len(alerts)*4)
+    for i, alert := range alerts {
+        payload, err := json.Marshal(alert)
+        if err != nil {
+            return fmt.Errorf("failed to marshal alert %s for event log: %w", alert.ID, err)
+        }
+        placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d)", i*4+1, i*4+2, i*4+3, i*4+4))
+        args = append(args, eventType, alert.TenantID, alert.ID, string(payload))
+    }
+
+    query := `INSERT INTO alert_events (type, tenant_id, alert_id, payload) VALUES ` + strings.Join(placeholders, ", ")
+    if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+        return fmt.Errorf("failed to write alert events: %w", err)
+    }
+    if _, err := tx.ExecContext(ctx, `SELECT pg_notify($1, '')`, AlertEventsChannel); err != nil {
+        return fmt.Errorf("failed to notify alert event listeners: %w", err)
+    }
+    return nil
+}
+
+// LatestAlertEventID returns the ID of the newest event, or 0 if there is none.
+func (r *pgAlertEventRepository) LatestAlertEventID(ctx context.Context) (int64, error) {
+    var id int64
+    if err := r.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM alert_events`).Scan(&id); err != nil {
+        return 0, fmt.Errorf("failed to get latest alert event ID: %w", err)
+    }
+    return id, nil
+}
+
+// GetAlertEventsAfter returns up to limit events with an ID greater than afterID, oldest first.
+func (r *pgAlertEventRepository) GetAlertEventsAfter(ctx context.Context, afterID int64, limit int) ([]models.AlertEvent, error) {
+    rows, err := r.db.QueryContext(ctx, `
+        SELECT id, type, payload, created_at
+        FROM alert_events
+        WHERE id > $1
+        ORDER BY id
+        LIMIT $2`, afterID, limit)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get alert events after %d: %w", afterID, err)
+    }
+    defer rows.Close()
+
+    var events []models.AlertEvent
+    for rows.Next() {
+        var e models.AlertEvent
+        var payload []byte
+        if err := rows.Scan(&e.ID, &e.Type, &payload, &e.CreatedAt); err != nil {
+            return nil, fmt.Errorf("failed to scan alert event: %w", err)
+        }
+        if err := json.Unmarshal(payload, &e.Alert); err != nil {
+            return nil, fmt.Errorf("failed to decode alert event %d: %w", e.ID, err)
+        }
+        events = append(events, e)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("row iteration error: %w", err)
+    }
+    return events, nil
+}
+
+// PurgeAlertEvents deletes events recorded before the given time.
+func (r *pgAlertEventRepository) PurgeAlertEvents(ctx context.Context, before time.Time) (int64, error) {
+    res, err := r.db.ExecContext(ctx, `DELETE FROM alert_events WHERE created_at < $1`, before)
+    if err != nil {
+        return 0, fmt.Errorf("failed to purge alert events: %w", err)
+    }
+    return res.RowsAffected()
+}
+
+// ListenAlertEvents listens on AlertEventsChannel with a dedicated connection to
+// databaseURL until ctx is cancelled. The returned channel receives a value whenever
+// new events were committed, and after the connection was re-established, since
+// notifications sent in the meantime are lost. Values are coalesced, so a slow reader
+// never blocks the listener.
+func ListenAlertEvents(ctx context.Context, databaseURL string) (<-chan struct{}, error) {
+    listener := pq.NewListener(databaseURL, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+        if err != nil {
+            log.Printf("ERROR: Alert event listener: %v", err)
+        }
+    })
+    if err := listener.Listen(AlertEventsChannel); err != nil {
+        listener.Close()
+        return nil, fmt.Errorf("failed to listen on %s: %w", AlertEventsChannel, err)
+    }
+
+    wake := make(chan struct{}, 1)
+    go func() {
+        defer listener.Close()
+        for {
+            select {
+            case <-ctx.Done():
+                return
+            case <-listener.Notify: // nil after a reconnect
+            }
+            select {
+            case wake <- struct{}{}:
+            default: // A wake-up is already pending
+            }
+        }
+    }()
+    return wake, nil
+}
@@ -0,0 +1,488 @@
+package repository
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "net/netip"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// AlertSortField names a column alerts can be ordered by.
+type AlertSortField string
+
+// Supported sort orders for GetAllAlerts.
+const (
+    SortByCreatedAt AlertSortField = "created_at"
+    SortByRiskScore AlertSortField = "risk_score"
+    SortByTimestamp AlertSortField = "timestamp"
+)
+
+// sortExpressions maps each sort field to the SQL expression used in ORDER BY.
+// risk_score is nullable until the processor has analysed an alert, so unanalysed
+// alerts sort as if they had a score of zero.
+var sortExpressions = map[AlertSortField]string{
+    SortByCreatedAt: "created_at",
+    SortByRiskScore: "COALESCE(risk_score, 0)",
+    SortByTimestamp: "timestamp",
+}
+
+// IsValid reports whether f is a supported sort field.
+func (f AlertSortField) IsValid() bool {
+    _, ok := sortExpressions[f]
+    return ok
+}
+
+// AlertFilter narrows down and orders the result of GetAllAlerts.
+// Zero values mean "no constraint" for every field.
+type AlertFilter struct {
+    Status            models.AlertStatus
+    Severity          string
+    PredictedSeverity string
+    Category          string
+    Source            string
+    Hostname          string
+    Username          string
+    Fingerprint       string
+    SigmaRule         string // ID of a Sigma rule the alert matched
+
+    // SourceIP and TargetIP accept either a single address or a CIDR block.
+    SourceIP string
+    TargetIP string
+
+    // GeoIP enrichment of the source and target addresses: ISO country code and
+    // autonomous system number (0 for no constraint).
+    SourceCountry string
+    TargetCountry string
+    SourceASN     uint32
+    TargetASN     uint32
+
+    // Attributes requires each attribute to have the given value. RawPayload requires
+    // the value at each JSON path of the raw payload to be the given text; a path is
+    // made of object keys and array indexes separated by dots, e.g. "vendor.tags.0".
+    // Only scalars match: numbers as Postgres prints them (1e2 as 100), booleans as
+    // true or false. Null, objects and arrays never match.
+    Attributes map[string]string
+    RawPayload map[string]string
+
+    // Inclusive risk score range.
+    MinRiskScore *float64
+    MaxRiskScore *float64
+
+    // Window on the alert's own timestamp: Since is inclusive, Until is exclusive.
+    Since time.Time
+    Until time.Time
+
+    SortBy        AlertSortField // Defaults to SortByCreatedAt
+    SortAscending bool           // Newest / highest first unless set
+
+    // After resumes a listing right behind the given position (keyset pagination).
+    // When set, Offset should be left at zero.
+    After *AlertCursor
+
+    Limit  int
+    Offset int
+}
+
+// AlertCursor is a position in an ordered alert listing: the sort key of the last
+// row returned plus its ID as tie-breaker. Unlike OFFSET it stays stable while new
+// alerts are being inserted, and lets Postgres seek straight to the next page.
+type AlertCursor struct {
+    SortBy    AlertSortField
+    Ascending bool
+    CreatedAt time.Time
+    Timestamp time.Time
+    RiskScore float64
+    ID        string
+}
+
+// CursorAfter returns the cursor pointing just behind alert in a listing ordered by f.
+func CursorAfter(alert models.SecurityAlert, f AlertFilter) AlertCursor {
+    return AlertCursor{
+        SortBy:    f.sortField(),
+        Ascending: f.SortAscending,
+        CreatedAt: alert.CreatedAt,
+        Timestamp: alert.Timestamp,
+        RiskScore: alert.RiskScore,
+        ID:        alert.ID,
+    }
+}
+
+// sortValue returns the cursor's value for the column it was sorted by.
+func (c AlertCursor) sortValue() interface{} {
+    switch c.SortBy {
+    case SortByRiskScore:
+        return c.RiskScore
+    case SortByTimestamp:
+        return c.Timestamp
+    default:
+        return c.CreatedAt
+    }
+}
+
+// queryBuilder assembles a WHERE clause with positional ($n) parameters so user
+// input is never concatenated into the SQL text.
+type queryBuilder struct {
+    conditions []string
+    args       []interface{}
+}
+
+// arg registers a parameter and returns its placeholder.
+func (b *queryBuilder) arg(v interface{}) string {
+    b.args = append(b.args, v)
+    return fmt.Sprintf("$%d", len(b.args))
+}
+
+// where adds a condition. Every "?" in cond is replaced by the placeholder of the
+// corresponding value in args.
+func (b *queryBuilder) where(cond string, args ...interface{}) {
+    for _, a := range args {
+        cond = strings.Replace(cond, "?", b.arg(a), 1)
+    }
+    b.conditions = append(b.conditions, cond)
+}
+
+// whereEqual adds "column = value" when value is not empty.
+func (b *queryBuilder) whereEqual(column, value string) {
+    if value != "" {
+        b.where(column+" = ?", value)
+    }
+}
+
+// whereIP matches column against a single address or, if value contains a "/", a CIDR block.
+// Blocks with host bits set (10.0.0.1/8) are masked first, as Postgres rejects them as
+// cidr values; a block that does not parse matches nothing. Rows whose column is not
+// an address are skipped by try_inet instead of failing the cast.
+func (b *queryBuilder) whereIP(column, value string) {
+    if value == "" {
+        return
+    }
+    if !strings.Contains(value, "/") {
+        b.where(column+" = ?", value)
+        return
+    }
+    prefix, err := netip.ParsePrefix(value)
+    if err != nil {
+        b.where("false")
+        return
+    }
+    b.where("try_inet("+column+") <<= ?::cidr", prefix.Masked().String())
+}
+
+// whereGeo constrains the country and ASN of one of the GeoIP enrichment sections.
+// Containment queries are answered by the GIN index on enrichment.
+func (b *queryBuilder) whereGeo(section, country string, asn uint32) {
+    if country != "" {
+        b.where("enrichment @> jsonb_build_object('"+section+"', jsonb_build_object('country', ?::text))", strings.ToUpper(country))
+    }
+    if asn != 0 {
+        b.where("enrichment @> jsonb_build_object('"+section+"', jsonb_build_object('asn', ?::bigint))", int64(asn))
+    }
+}
+
+// whereClause renders the accumulated conditions, or an empty string if there are none.
+func (b *queryBuilder) whereClause() string {
+    if len(b.conditions) == 0 {
+        return ""
+    }
+    return " WHERE " + strings.Join(b.conditions, " AND ")
+}
+
+// apply adds every constraint from the filter to the builder.
+func (f AlertFilter) apply(b *queryBuilder) {
+    b.whereEqual("status", string(f.Status))
+    b.whereEqual("severity", f.Severity)
+    b.whereEqual("predicted_severity", f.PredictedSeverity)
+    b.whereEqual("category", f.Category)
+    b.whereEqual("source", f.Source)
+    b.whereEqual("hostname", f.Hostname)
+    b.whereEqual("username", f.Username)
+    b.whereEqual("fingerprint", f.Fingerprint)
+    if f.SigmaRule != "" {
+        // Containment is answered by the GIN index on sigma_matches
+        b.where("sigma_matches @> jsonb_build_array(jsonb_build_object('rule_id', ?::text))", f.SigmaRule)
+    }
+    b.whereIP("source_ip", f.SourceIP)
+    b.whereIP("target_ip", f.TargetIP)
+    for _, key := range sortedKeys(f.Attributes) {
+        // Containment is answered by the GIN index on attributes
+        b.where("attributes @> jsonb_build_object(?::text, ?::text)", key, f.Attributes[key])
+    }
+    for _, path := range sortedKeys(f.RawPayload) {
+        // Objects and arrays are skipped: their text depends on how jsonb prints them.
+        b.where("jsonb_typeof(raw_payload #> string_to_array(?::text, '.')) NOT IN ('object', 'array') AND raw_payload #>> string_to_array(?::text, '.') = ?",
+            path, path, f.RawPayload[path])
+    }
+    b.whereGeo("source_geo", f.SourceCountry, f.SourceASN)
+    b.whereGeo("target_geo", f.TargetCountry, f.TargetASN)
+    if f.MinRiskScore != nil {
+        b.where("risk_score >= ?", *f.MinRiskScore)
+    }
+    if f.MaxRiskScore != nil {
+        b.where("risk_score <= ?", *f.MaxRiskScore)
+    }
+    if !f.Since.IsZero() {
+        b.where("timestamp >= ?", f.Since)
+    }
+    if !f.Until.IsZero() {
+        b.where("timestamp < ?", f.Until)
+    }
+    if f.After != nil {
+        // Row comparison matches the ORDER BY below, so this seeks to the next page.
+        op := "<"
+        if f.SortAscending {
+            op = ">"
+        }
+        b.where(fmt.Sprintf("(%s, id) %s (?, ?)", sortExpressions[f.sortField()], op), f.After.sortValue(), f.After.ID)
+    }
+}
+
+// sortedKeys returns the keys of m in order, so the same filter always builds the
+// same SQL.
+func sortedKeys(m map[string]string) []string {
+    keys := make([]string, 0, len(m))
+    for k := range m {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    return keys
+}
+
+// sortField returns the effective sort field, falling back to created_at.
+func (f AlertFilter) sortField() AlertSortField {
+    if f.SortBy.IsValid() {
+        return f.SortBy
+    }
+    return SortByCreatedAt
+}
+
+// orderBy renders the ORDER BY clause. id is always the last key so the order is total.
+func (f AlertFilter) orderBy() string {
+    dir := "DESC"
+    if f.SortAscending {
+        dir = "ASC"
+    }
+    return fmt.Sprintf(" ORDER BY %s %s, id %s", sortExpressions[f.sortField()], dir, dir)
+}
+
+// Matches reports whether alert satisfies every constraint of the filter, including
+// the After cursor. It is the in-process equivalent of the WHERE clause built by apply;
+// ordering and Limit/Offset are not considered.
+func (f AlertFilter) Matches(alert models.SecurityAlert) bool {
+    if !matchEqual(string(alert.Status), string(f.Status)) ||
+        !matchEqual(alert.Severity, f.Severity) ||
+        !matchEqual(alert.PredictedSeverity, f.PredictedSeverity) ||
+        !matchEqual(alert.Category, f.Category) ||
+        !matchEqual(alert.Source, f.Source) ||
+        !matchEqual(alert.Hostname, f.Hostname) ||
+        !matchEqual(alert.Username, f.Username) ||
+        !matchEqual(alert.Fingerprint, f.Fingerprint) ||
+        !matchIP(alert.SourceIP, f.SourceIP) ||
+        !matchIP(alert.TargetIP, f.TargetIP) {
+        return false
+    }
+    if f.SigmaRule != "" && !matchedSigmaRule(alert, f.SigmaRule) {
+        return false
+    }
+    for key, value := range f.Attributes {
+        if v, ok := alert.Attributes[key]; !ok || v != value {
+            return false
+        }
+    }
+    if len(f.RawPayload) > 0 && !matchRawPayload(alert.RawPayload, f.RawPayload) {
+        return false
+    }
+    var sourceGeo, targetGeo *models.GeoInfo
+    if alert.Enrichment != nil {
+        sourceGeo, targetGeo = alert.Enrichment.SourceGeo, alert.Enrichment.TargetGeo
+    }
+    if !matchGeo(sourceGeo, f.SourceCountry, f.SourceASN) || !matchGeo(targetGeo, f.TargetCountry, f.TargetASN) {
+        return false
+    }
+    if f.MinRiskScore != nil && alert.RiskScore < *f.MinRiskScore {
+        return false
+    }
+    if f.MaxRiskScore != nil && alert.RiskScore > *f.MaxRiskScore {
+        return false
+    }
+    if !f.Since.IsZero() && alert.Timestamp.Before(f.Since) {
+        return false
+    }
+    if !f.Until.IsZero() && !alert.Timestamp.Before(f.Until) {
+        return false
+    }
+    if f.After != nil && f.compare(alert, *f.After) <= 0 {
+        return false
+    }
+    return true
+}
+
+func matchGeo(geo *models.GeoInfo, country string, asn uint32) bool {
+    if country == "" && asn == 0 {
+        return true
+    }
+    if geo == nil {
+        return false
+    }
+    return (country == "" || geo.Country == strings.ToUpper(country)) && (asn == 0 || geo.ASN == asn)
+}
+
+func matchedSigmaRule(alert models.SecurityAlert, ruleID string) bool {
+    for _, m := range alert.SigmaMatches {
+        if m.RuleID == ruleID {
+            return true
+        }
+    }
+    return false
+}
+
+// matchRawPayload mirrors the #>> conditions on raw_payload: every path must lead to
+// a scalar whose text is the wanted one. Strings are compared without their quotes,
+// numbers in the form a jsonb numeric takes.
+func matchRawPayload(raw json.RawMessage, paths map[string]string) bool {
+    dec := json.NewDecoder(bytes.NewReader(raw))
+    dec.UseNumber() // Compare numbers as they were written
+    var doc interface{}
+    if len(raw) == 0 || dec.Decode(&doc) != nil {
+        return false
+    }
+    for path, want := range paths {
+        v := doc
+        for _, key := range strings.Split(path, ".") {
+            switch node := v.(type) {
+            case map[string]interface{}:
+                v = node[key]
+            case []interface{}:
+                i, err := strconv.Atoi(key)
+                if err != nil || i < 0 || i >= len(node) {
+                    return false
+                }
+                v = node[i]
+            default:
+                return false
+            }
+        }
+        var text string
+        switch v := v.(type) {
+        case string:
+            text = v
+        case json.Number:
+            text = numericText(string(v))
+        case bool:
+            text = strconv.FormatBool(v)
+        default: // null, object or array
+            return false
+        }
+        if text != want {
+            return false
+        }
+    }
+    return true
+}
+
+// maxNumericScale is the largest number of decimal digits after the point a Postgres
+// numeric can have.
+const maxNumericScale = 16383
+
+// numericText returns the text Postgres gives the JSON number n once stored as
+// numeric: the exponent is applied, the digits after the decimal point are kept
+// (1.50 stays 1.50, 1.5e1 becomes 15) and there is no negative zero.
+func numericText(n string) string {
+    mantissa, exponent := n, 0
+    if i := strings.IndexAny(n, "eE"); i >= 0 {
+        exp, err := strconv.Atoi(n[i+1:])
+        if err != nil || exp > maxNumericScale || exp < -maxNumericScale {
+            return n // Beyond what a numeric can hold
+        }
+        mantissa, exponent = n[:i], exp
+    }
+    negative := strings.HasPrefix(mantissa, "-")
+    mantissa = strings.TrimPrefix(mantissa, "-")
+    intPart, fracPart, _ := strings.Cut(mantissa, ".")
+
+    // The value is digits * 10^shift.
+    digits := intPart + fracPart
+    shift := exponent - len(fracPart)
+    var text string
+    if shift >= 0 {
+        text = digits + strings.Repeat("0", shift)
+    } else {
+        if pad := -shift - len(digits) + 1; pad > 0 {
+            digits = strings.Repeat("0", pad) + digits
+        }
+        text = digits[:len(digits)+shift] + "." + digits[len(digits)+shift:]
+    }
+    if text = strings.TrimLeft(text, "0"); text == "" || text[0] == '.' {
+        text = "0" + text
+    }
+    if negative && strings.Trim(text, "0.") != "" {
+        text = "-" + text
+    }
+    return text
+}
+
+// Less reports whether a sorts before b in a listing ordered by f, matching orderBy.
+func (f AlertFilter) Less(a, b models.SecurityAlert) bool {
+    return f.compare(a, CursorAfter(b, f)) < 0
+}
+
+// compare returns -1, 0 or +1 depending on whether alert sorts before, at or after
+// the cursor position in a listing ordered by f.
+func (f AlertFilter) compare(alert models.SecurityAlert, c AlertCursor) int {
+    var cmp int
+    switch f.sortField() {
+    case SortByRiskScore:
+        cmp = compareFloat(alert.RiskScore, c.RiskScore)
+    case SortByTimestamp:
+        cmp = alert.Timestamp.Compare(c.Timestamp)
+    default:
+        cmp = alert.CreatedAt.Compare(c.CreatedAt)
+    }
+    if cmp == 0 {
+        cmp = strings.Compare(alert.ID, c.ID)
+    }
+    if !f.SortAscending {
+        cmp = -cmp
+    }
+    return cmp
+}
+
+func compareFloat(a, b float64) int {
+    switch {
+    case a < b:
+        return -1
+    case a > b:
+        return 1
+    }
+    return 0
+}
+
+// matchEqual mirrors whereEqual: an empty filter value matches everything.
+func matchEqual(value, filter string) bool {
+    return filter == "" || value == filter
+}
+
+// matchIP mirrors whereIP: a single address must match exactly, a CIDR block matches
+// every address inside it. Values that are not addresses never match a block.
+func matchIP(value, filter string) bool {
+    if filter == "" {
+        return true
+    }
+    if !strings.Contains(filter, "/") {
+        return value == filter
+    }
+    prefix, err := netip.ParsePrefix(filter)
+    if err != nil {
+        return false
+    }
+    addr, err := netip.ParseAddr(value)
+    if err != nil {
+        return false
+    }
+    return prefix.Masked().Contains(addr)
+}
\ No newline at end of file
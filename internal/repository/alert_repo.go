@@ -3,19 +3,69 @@ package repository
 import (
     "context"
     "database/sql"
+    "encoding/json"
+    "errors"
     "fmt"
+    "strings"
     "time"
 
+    "github.com/lib/pq"
+
     "github.com/Kelvinkhyd/GuardianAI/internal/models" // Make sure this path is correct
 )
 
+// Errors returned by AlertRepository implementations.
+var (
+    // ErrAlertNotFound is returned by methods that modify an alert which does not exist.
+    ErrAlertNotFound = errors.New("alert not found")
+    // ErrStatusConflict is returned by TransitionAlertStatus when the alert is no longer in the expected state.
+    ErrStatusConflict = errors.New("alert status changed concurrently")
+    // ErrAlertExists is returned by CreateAlert when the tenant already has an alert with the same ID.
+    ErrAlertExists = errors.New("alert already exists")
+    // ErrNoTenant is returned by methods called without a tenant.
+    ErrNoTenant = errors.New("no tenant given")
+)
+
+// checkTenant rejects calls that are not scoped to a tenant.
+func checkTenant(tenant string) error {
+    if tenant == "" {
+        return ErrNoTenant
+    }
+    return nil
+}
+
 // AlertRepository defines the interface for alert data operations.
+// Every method is scoped to a tenant: new alerts are stored under it, and alerts of
+// other tenants are invisible, as if they did not exist.
 type AlertRepository interface {
-    CreateAlert(ctx context.Context, alert *models.SecurityAlert) error
-    GetAlertByID(ctx context.Context, id string) (*models.SecurityAlert, error)
-    GetAllAlerts(ctx context.Context, limit, offset int) ([]models.SecurityAlert, error)
-    UpdateAlertStatus(ctx context.Context, id, status string) error // Ensure this method exists and matches
-    UpdateAlertWithAIResults(ctx context.Context, alert *models.SecurityAlert) error // The new method
+    // CreateAlert, CreateAlerts and CreateOrFoldAlert set the TenantID of the alerts
+    // passed in to tenant.
+    CreateAlert(ctx context.Context, tenant string, alert *models.SecurityAlert) error
+    // CreateAlert and CreateAlerts also queue the new alerts in the outbox, atomically
+    // with the insert, so every stored alert is eventually published to Kafka, and
+    // record an alert.created event for the alert stream.
+    // CreateAlerts returns the IDs that were actually inserted. Alerts whose ID
+    // already exists are skipped rather than failing the batch.
+    CreateAlerts(ctx context.Context, tenant string, alerts []models.SecurityAlert) ([]string, error)
+    // CreateOrFoldAlert stores alert, unless an alert with the same fingerprint was
+    // last seen within window of it: then the existing alert's occurrence count and
+    // last_seen are updated instead. A folded alert is only queued in the outbox again
+    // when its count reaches one of escalateAt.
+    CreateOrFoldAlert(ctx context.Context, tenant string, alert *models.SecurityAlert, window time.Duration, escalateAt []int) (*FoldResult, error)
+    // CreateOrFoldAlerts does what CreateOrFoldAlert does for every alert, atomically:
+    // either all alerts are stored or folded, or none is. Repeats are also folded into
+    // earlier alerts of the same call. Alerts whose ID already exists are skipped, as
+    // in CreateAlerts, and get a nil result.
+    CreateOrFoldAlerts(ctx context.Context, tenant string, alerts []models.SecurityAlert, window time.Duration, escalateAt []int) ([]*FoldResult, error)
+    GetAlertByID(ctx context.Context, tenant, id string) (*models.SecurityAlert, error)
+    GetAllAlerts(ctx context.Context, tenant string, filter AlertFilter) ([]models.SecurityAlert, error)
+    UpdateAlertStatus(ctx context.Context, tenant, id string, status models.AlertStatus) error
+    // TransitionAlertStatus moves an alert from one status to another, failing with
+    // ErrStatusConflict if the alert is no longer in the "from" state.
+    TransitionAlertStatus(ctx context.Context, tenant, id string, from, to models.AlertStatus) error
+    // UpdateAlertWithAIResults stores the processor's analysis and records an
+    // alert.analyzed event for the alert stream.
+    UpdateAlertWithAIResults(ctx context.Context, tenant string, alert *models.SecurityAlert) error
 }
 
 // pgAlertRepository implements AlertRepository for PostgreSQL.
@@ -28,35 +78,236 @@ func NewPgAlertRepository(db *sql.DB) AlertRepository {
     return &pgAlertRepository{db: db}
 }
 
-// CreateAlert inserts a new security alert into the database.
-func (r *pgAlertRepository) CreateAlert(ctx context.Context, alert *models.SecurityAlert) error {
-    query := `
-        INSERT INTO alerts (
-            id, source, timestamp, severity, category, title, description,
+// CreateAlert inserts a new security alert into the database and, in the same
+// transaction, queues it in the outbox for publication to Kafka and records its
+// alert.created event.
+func (r *pgAlertRepository) CreateAlert(ctx context.Context, tenant string, alert *models.SecurityAlert) error {
+    if err := checkTenant(tenant); err != nil {
+        return err
+    }
+    alert.TenantID = tenant
+
+    tx, err := r.db.BeginTx(ctx, nil)
+    if err != nil {
+        return fmt.Errorf("failed to begin transaction: %w", err)
+    }
+    defer tx.Rollback() // No-op after a successful commit
+
+    setOccurrenceDefaults(alert)
+    if err := insertAlert(ctx, tx, alert); err != nil {
+        return err
+    }
+
+    if err := enqueueOutbox(ctx, tx, *alert); err != nil {
+        return err
+    }
+    if err := recordAlertEvents(ctx, tx, models.AlertEventCreated, *alert); err != nil {
+        return err
+    }
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("failed to commit alert %s: %w", alert.ID, err)
+    }
+    return nil
+}
+
+// alertInsertColumns is the column list of every INSERT INTO alerts; alertInsertArgs
+// returns the matching values. created_at is left to the database default.
+const alertInsertColumns = `
+            id, tenant_id, source, timestamp, severity, category, title, description,
             source_ip, target_ip, hostname, username, file_hash, status,
-            predicted_severity, risk_score, recommended_action, ai_model_version
-        ) VALUES (
-            $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17
-        )`
-    _, err := r.db.ExecContext(ctx, query,
-        alert.ID, alert.Source, alert.Timestamp, alert.Severity, alert.Category,
+            predicted_severity, risk_score, recommended_action, ai_model_version,
+            fingerprint, occurrence_count, last_seen, attributes, raw_payload`
+
+const alertInsertColumnCount = 23
+
+func alertInsertArgs(alert *models.SecurityAlert) []interface{} {
+    attributes := []byte("{}")
+    if len(alert.Attributes) > 0 {
+        attributes, _ = json.Marshal(alert.Attributes) // A map of strings always encodes
+    }
+    return []interface{}{
+        alert.ID, alert.TenantID, alert.Source, alert.Timestamp, alert.Severity, alert.Category,
         alert.Title, alert.Description, alert.SourceIP, alert.TargetIP,
         alert.Hostname, alert.Username, alert.FileHash, alert.Status,
-        alert.PredictedSeverity, alert.RiskScore, alert.RecommendedAction, alert.AIModelVersion)
-    if err != nil {
+        alert.PredictedSeverity, alert.RiskScore, alert.RecommendedAction, alert.AIModelVersion,
+        sql.NullString{String: alert.Fingerprint, Valid: alert.Fingerprint != ""}, alert.OccurrenceCount, alert.LastSeen,
+        attributes, nullJSON(alert.RawPayload),
+    }
+}
+
+// nullJSON passes a JSON value to a nullable JSONB column, NULL if it is empty.
+func nullJSON(data json.RawMessage) interface{} {
+    if len(data) == 0 {
+        return nil
+    }
+    return []byte(data)
+}
+
+// setOccurrenceDefaults makes a newly stored alert its own first occurrence.
+func setOccurrenceDefaults(alert *models.SecurityAlert) {
+    if alert.OccurrenceCount < 1 {
+        alert.OccurrenceCount = 1
+    }
+    if alert.LastSeen.IsZero() {
+        alert.LastSeen = alert.Timestamp
+    }
+}
+
+// insertAlert inserts a single alert inside the caller's transaction and sets its
+// CreatedAt to the value assigned by the database.
+func insertAlert(ctx context.Context, tx *sql.Tx, alert *models.SecurityAlert) error {
+    placeholders := make([]string, alertInsertColumnCount)
+    for i := range placeholders {
+        placeholders[i] = fmt.Sprintf("$%d", i+1)
+    }
+    query := `INSERT INTO alerts (` + alertInsertColumns + `) VALUES (` + strings.Join(placeholders, ", ") + `) RETURNING created_at`
+    if err := tx.QueryRowContext(ctx, query, alertInsertArgs(alert)...).Scan(&alert.CreatedAt); err != nil {
+        if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // unique_violation
+            return fmt.Errorf("failed to create alert: duplicate alert ID %s: %w", alert.ID, ErrAlertExists)
+        }
         return fmt.Errorf("failed to create alert: %w", err)
     }
     return nil
 }
 
-// GetAlertByID retrieves a single alert by its ID.
-func (r *pgAlertRepository) GetAlertByID(ctx context.Context, id string) (*models.SecurityAlert, error) {
-    query := `SELECT
-        id, source, timestamp, severity, category, title, description,
+// insertBatchSize bounds the rows per multi-row INSERT. With 23 parameters per row this
+// stays well below Postgres' limit of 65535 bind parameters per statement.
+const insertBatchSize = 500
+
+// CreateAlerts inserts alerts with multi-row INSERT statements, one per chunk of
+// insertBatchSize rows. All chunks run in a single transaction, together with the
+// outbox messages and alert events for the alerts that were inserted. Only the first
+// alert with a given ID is considered, so that every inserted row can be matched to
+// the alert it came from by its ID.
+func (r *pgAlertRepository) CreateAlerts(ctx context.Context, tenant string, alerts []models.SecurityAlert) ([]string, error) {
+    if err := checkTenant(tenant); err != nil {
+        return nil, err
+    }
+    if len(alerts) == 0 {
+        return nil, nil
+    }
+    unique := make([]models.SecurityAlert, 0, len(alerts))
+    seen := make(map[string]bool, len(alerts))
+    for i := range alerts {
+        alerts[i].TenantID = tenant
+        if !seen[alerts[i].ID] {
+            seen[alerts[i].ID] = true
+            unique = append(unique, alerts[i])
+        }
+    }
+
+    tx, err := r.db.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, fmt.Errorf("failed to begin alert batch transaction: %w", err)
+    }
+    defer tx.Rollback() // No-op after a successful commit
+
+    inserted := make([]string, 0, len(unique))
+    createdAt := make(map[string]time.Time, len(unique))
+    for start := 0; start < len(unique); start += insertBatchSize {
+        end := start + insertBatchSize
+        if end > len(unique) {
+            end = len(unique)
+        }
+        ids, err := insertAlertChunk(ctx, tx, unique[start:end], createdAt)
+        if err != nil {
+            return nil, err
+        }
+        inserted = append(inserted, ids...)
+    }
+
+    // Only queue the alerts that were actually inserted, not the skipped duplicates.
+    queued := make([]models.SecurityAlert, 0, len(inserted))
+    for _, alert := range unique {
+        if at, ok := createdAt[alert.ID]; ok {
+            setOccurrenceDefaults(&alert)
+            alert.CreatedAt = at
+            queued = append(queued, alert)
+        }
+    }
+    for start := 0; start < len(queued); start += insertBatchSize {
+        end := start + insertBatchSize
+        if end > len(queued) {
+            end = len(queued)
+        }
+        if err := enqueueOutbox(ctx, tx, queued[start:end]...); err != nil {
+            return nil, err
+        }
+    }
+    for start := 0; start < len(queued); start += insertBatchSize {
+        end := start + insertBatchSize
+        if end > len(queued) {
+            end = len(queued)
+        }
+        if err := recordAlertEvents(ctx, tx, models.AlertEventCreated, queued[start:end]...); err != nil {
+            return nil, err
+        }
+    }
+
+    if err := tx.Commit(); err != nil {
+        return nil, fmt.Errorf("failed to commit alert batch: %w", err)
+    }
+    return inserted, nil
+}
+
+// insertAlertChunk inserts one chunk of alerts and returns the IDs that did not already
+// exist. The creation time of each inserted alert is stored in createdAt.
+func insertAlertChunk(ctx context.Context, tx *sql.Tx, alerts []models.SecurityAlert, createdAt map[string]time.Time) ([]string, error) {
+    placeholders := make([]string, 0, len(alerts))
+    args := make([]interface{}, 0, len(alerts)*alertInsertColumnCount)
+    for i, alert := range alerts {
+        row := make([]string, alertInsertColumnCount)
+        for j := range row {
+            row[j] = fmt.Sprintf("$%d", i*alertInsertColumnCount+j+1)
+        }
+        placeholders = append(placeholders, "("+strings.Join(row, ", ")+")")
+        setOccurrenceDefaults(&alert)
+        args = append(args, alertInsertArgs(&alert)...)
+    }
+
+    query := `
+        INSERT INTO alerts (` + alertInsertColumns + `
+        ) VALUES ` + strings.Join(placeholders, ", ") + `
+        ON CONFLICT (tenant_id, id) DO NOTHING
+        RETURNING id, created_at`
+
+    rows, err := tx.QueryContext(ctx, query, args...)
+    if err != nil {
+        return nil, fmt.Errorf("failed to insert alert batch: %w", err)
+    }
+    defer rows.Close()
+
+    var ids []string
+    for rows.Next() {
+        var id string
+        var at time.Time
+        if err := rows.Scan(&id, &at); err != nil {
+            return nil, fmt.Errorf("failed to scan inserted alert ID: %w", err)
+        }
+        ids = append(ids, id)
+        createdAt[id] = at
+    }
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("row iteration error: %w", err)
+    }
+    return ids, nil
+}
+
+// alertColumns is the column list shared by every query that loads full alerts.
+// Keep it in sync with scanAlert.
+const alertColumns = `
+        id, tenant_id, source, timestamp, severity, category, title, description,
         source_ip, target_ip, hostname, username, file_hash, status, created_at,
-        predicted_severity, risk_score, recommended_action, ai_model_version
-        FROM alerts WHERE id = $1`
+        predicted_severity, risk_score, recommended_action, ai_model_version,
+        fingerprint, occurrence_count, last_seen, sigma_matches, enrichment, attributes, raw_payload`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+    Scan(dest ...interface{}) error
+}
 
+// scanAlert reads one row selected with alertColumns into a SecurityAlert.
+func scanAlert(row rowScanner) (*models.SecurityAlert, error) {
     var alert models.SecurityAlert
     var createdAt time.Time
     // Use sql.Null* for columns that can be NULL in the database
@@ -64,18 +315,41 @@ func (r *pgAlertRepository) GetAlertByID(ctx context.Context, id string) (*model
     var riskScore sql.NullFloat64
     var recommendedAction sql.NullString
     var aiModelVersion sql.NullString
+    var fingerprint sql.NullString
+    var sigmaMatches []byte
+    var enrichment []byte
+    var attributes []byte
+    var rawPayload []byte // NULL for alerts stored without one
 
-    err := r.db.QueryRowContext(ctx, query, id).Scan(
-        &alert.ID, &alert.Source, &alert.Timestamp, &alert.Severity, &alert.Category,
+    err := row.Scan(
+        &alert.ID, &alert.TenantID, &alert.Source, &alert.Timestamp, &alert.Severity, &alert.Category,
         &alert.Title, &alert.Description, &alert.SourceIP, &alert.TargetIP,
         &alert.Hostname, &alert.Username, &alert.FileHash, &alert.Status, &createdAt,
-        &predictedSeverity, &riskScore, &recommendedAction, &aiModelVersion)
-
+        &predictedSeverity, &riskScore, &recommendedAction, &aiModelVersion,
+        &fingerprint, &alert.OccurrenceCount, &alert.LastSeen, &sigmaMatches, &enrichment, &attributes, &rawPayload)
     if err != nil {
-        if err == sql.ErrNoRows {
-            return nil, nil // Alert not found
-        }
-        return nil, fmt.Errorf("failed to get alert by ID %s: %w", id, err)
+        return nil, err
+    }
+    if err := json.Unmarshal(sigmaMatches, &alert.SigmaMatches); err != nil {
+        return nil, fmt.Errorf("failed to decode sigma_matches of alert %s: %w", alert.ID, err)
+    }
+    if len(alert.SigmaMatches) == 0 {
+        alert.SigmaMatches = nil // '[]' in the database, omitted in JSON
+    }
+    if err := json.Unmarshal(enrichment, &alert.Enrichment); err != nil {
+        return nil, fmt.Errorf("failed to decode enrichment of alert %s: %w", alert.ID, err)
+    }
+    if alert.Enrichment.IsEmpty() {
+        alert.Enrichment = nil // '{}' in the database, omitted in JSON
+    }
+    if err := json.Unmarshal(attributes, &alert.Attributes); err != nil {
+        return nil, fmt.Errorf("failed to decode attributes of alert %s: %w", alert.ID, err)
+    }
+    if len(alert.Attributes) == 0 {
+        alert.Attributes = nil
+    }
+    if len(rawPayload) > 0 {
+        alert.RawPayload = json.RawMessage(rawPayload)
     }
 
     // Assign nullable types to actual struct fields
@@ -83,20 +357,47 @@ func (r *pgAlertRepository) GetAlertByID(ctx context.Context, id string) (*model
     if riskScore.Valid { alert.RiskScore = riskScore.Float64 }
     if recommendedAction.Valid { alert.RecommendedAction = recommendedAction.String }
     if aiModelVersion.Valid { alert.AIModelVersion = aiModelVersion.String }
+    if fingerprint.Valid { alert.Fingerprint = fingerprint.String }
 
     alert.CreatedAt = createdAt // Assign created_at to the struct field
     return &alert, nil
 }
 
-// GetAllAlerts retrieves a list of alerts with pagination.
-func (r *pgAlertRepository) GetAllAlerts(ctx context.Context, limit, offset int) ([]models.SecurityAlert, error) {
-    query := `SELECT
-        id, source, timestamp, severity, category, title, description,
-        source_ip, target_ip, hostname, username, file_hash, status, created_at,
-        predicted_severity, risk_score, recommended_action, ai_model_version
-        FROM alerts ORDER BY created_at DESC LIMIT $1 OFFSET $2`
+// GetAlertByID retrieves a single alert of the tenant by its ID.
+func (r *pgAlertRepository) GetAlertByID(ctx context.Context, tenant, id string) (*models.SecurityAlert, error) {
+    if err := checkTenant(tenant); err != nil {
+        return nil, err
+    }
+    query := `SELECT` + alertColumns + ` FROM alerts WHERE id = $1 AND tenant_id = $2`
 
-    rows, err := r.db.QueryContext(ctx, query, limit, offset)
+    alert, err := scanAlert(r.db.QueryRowContext(ctx, query, id, tenant))
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return nil, nil // Alert not found
+        }
+        return nil, fmt.Errorf("failed to get alert by ID %s: %w", id, err)
+    }
+    return alert, nil
+}
+
+// GetAllAlerts retrieves the tenant's alerts matching the filter, ordered and paginated as requested.
+func (r *pgAlertRepository) GetAllAlerts(ctx context.Context, tenant string, filter AlertFilter) ([]models.SecurityAlert, error) {
+    if err := checkTenant(tenant); err != nil {
+        return nil, err
+    }
+    var qb queryBuilder
+    qb.where("tenant_id = ?", tenant)
+    filter.apply(&qb)
+
+    query := `SELECT` + alertColumns + ` FROM alerts` + qb.whereClause() + filter.orderBy()
+    if filter.Limit > 0 {
+        query += " LIMIT " + qb.arg(filter.Limit)
+    }
+    if filter.Offset > 0 {
+        query += " OFFSET " + qb.arg(filter.Offset)
+    }
+
+    rows, err := r.db.QueryContext(ctx, query, qb.args...)
     if err != nil {
         return nil, fmt.Errorf("failed to get all alerts: %w", err)
     }
@@ -104,30 +405,11 @@ func (r *pgAlertRepository) GetAllAlerts(ctx context.Context, limit, offset int)
 
     var alerts []models.SecurityAlert
     for rows.Next() {
-        var alert models.SecurityAlert
-        var createdAt time.Time
-        // Use sql.Null* for columns that can be NULL in the database
-        var predictedSeverity sql.NullString
-        var riskScore sql.NullFloat64
-        var recommendedAction sql.NullString
-        var aiModelVersion sql.NullString
-
-        err := rows.Scan(
-            &alert.ID, &alert.Source, &alert.Timestamp, &alert.Severity, &alert.Category,
-            &alert.Title, &alert.Description, &alert.SourceIP, &alert.TargetIP,
-            &alert.Hostname, &alert.Username, &alert.FileHash, &alert.Status, &createdAt,
-            &predictedSeverity, &riskScore, &recommendedAction, &aiModelVersion)
+        alert, err := scanAlert(rows)
         if err != nil {
             return nil, fmt.Errorf("failed to scan alert row: %w", err)
         }
-
-        if predictedSeverity.Valid { alert.PredictedSeverity = predictedSeverity.String }
-        if riskScore.Valid { alert.RiskScore = riskScore.Float64 }
-        if recommendedAction.Valid { alert.RecommendedAction = recommendedAction.String }
-        if aiModelVersion.Valid { alert.AIModelVersion = aiModelVersion.String }
-
-        alert.CreatedAt = createdAt // Assign created_at to the struct field
-        alerts = append(alerts, alert)
+        alerts = append(alerts, *alert)
     }
 
     if err = rows.Err(); err != nil {
@@ -138,9 +420,12 @@ func (r *pgAlertRepository) GetAllAlerts(ctx context.Context, limit, offset int)
 }
 
 // UpdateAlertStatus updates the status of a specific alert by its ID.
-func (r *pgAlertRepository) UpdateAlertStatus(ctx context.Context, id, status string) error {
-    query := `UPDATE alerts SET status = $1 WHERE id = $2`
-    res, err := r.db.ExecContext(ctx, query, status, id)
+func (r *pgAlertRepository) UpdateAlertStatus(ctx context.Context, tenant, id string, status models.AlertStatus) error {
+    if err := checkTenant(tenant); err != nil {
+        return err
+    }
+    query := `UPDATE alerts SET status = $1 WHERE id = $2 AND tenant_id = $3`
+    res, err := r.db.ExecContext(ctx, query, status, id, tenant)
     if err != nil {
         return fmt.Errorf("failed to update alert status for ID %s: %w", id, err)
     }
@@ -149,33 +434,96 @@ func (r *pgAlertRepository) UpdateAlertStatus(ctx context.Context, id, status st
         return fmt.Errorf("failed to get rows affected after updating status for ID %s: %w", id, err)
     }
     if rowsAffected == 0 {
-        return fmt.Errorf("no alert found with ID %s to update status", id)
+        return fmt.Errorf("no alert found with ID %s to update status: %w", id, ErrAlertNotFound)
     }
     return nil
 }
 
-// UpdateAlertWithAIResults updates an alert's AI-generated fields and status in the database.
-func (r *pgAlertRepository) UpdateAlertWithAIResults(ctx context.Context, alert *models.SecurityAlert) error {
+// TransitionAlertStatus atomically moves an alert from status "from" to status "to".
+// The update only applies if the alert is still in "from", so two analysts racing on
+// the same alert cannot both succeed.
+func (r *pgAlertRepository) TransitionAlertStatus(ctx context.Context, tenant, id string, from, to models.AlertStatus) error {
+    if err := checkTenant(tenant); err != nil {
+        return err
+    }
+    query := `UPDATE alerts SET status = $1 WHERE id = $2 AND tenant_id = $3 AND status = $4`
+    res, err := r.db.ExecContext(ctx, query, to, id, tenant, from)
+    if err != nil {
+        return fmt.Errorf("failed to transition alert %s from %s to %s: %w", id, from, to, err)
+    }
+    rowsAffected, err := res.RowsAffected()
+    if err != nil {
+        return fmt.Errorf("failed to get rows affected after transitioning alert %s: %w", id, err)
+    }
+    if rowsAffected > 0 {
+        return nil
+    }
+
+    // Nothing was updated: either the alert is gone or someone else moved it first.
+    var exists bool
+    err = r.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM alerts WHERE id = $1 AND tenant_id = $2)`, id, tenant).Scan(&exists)
+    if err != nil {
+        return fmt.Errorf("failed to check existence of alert %s: %w", id, err)
+    }
+    if !exists {
+        return fmt.Errorf("no alert found with ID %s to transition: %w", id, ErrAlertNotFound)
+    }
+    return fmt.Errorf("alert %s is no longer in status %s: %w", id, from, ErrStatusConflict)
+}
+
+// UpdateAlertWithAIResults updates an alert's AI-generated fields and status in the database
+// and records an alert.analyzed event carrying the updated alert. The status is only applied
+// while the alert is still "new", so re-analysing an alert never moves it backwards once an
+// analyst has started working on it.
+func (r *pgAlertRepository) UpdateAlertWithAIResults(ctx context.Context, tenant string, alert *models.SecurityAlert) error {
+    if err := checkTenant(tenant); err != nil {
+        return err
+    }
     query := `
         UPDATE alerts SET
-            status = $1,
+            status = CASE WHEN status = 'new' THEN $1 ELSE status END,
             predicted_severity = $2,
             risk_score = $3,
             recommended_action = $4,
-            ai_model_version = $5
-        WHERE id = $6`
-    res, err := r.db.ExecContext(ctx, query,
+            ai_model_version = $5,
+            sigma_matches = $6,
+            enrichment = $7
+        WHERE id = $8 AND tenant_id = $9
+        RETURNING` + alertColumns
+    sigmaMatches, err := json.Marshal(alert.SigmaMatches)
+    if err != nil {
+        return fmt.Errorf("failed to encode Sigma matches of alert %s: %w", alert.ID, err)
+    }
+    if alert.SigmaMatches == nil {
+        sigmaMatches = []byte("[]")
+    }
+    enrichment := []byte("{}")
+    if !alert.Enrichment.IsEmpty() {
+        if enrichment, err = json.Marshal(alert.Enrichment); err != nil {
+            return fmt.Errorf("failed to encode enrichment of alert %s: %w", alert.ID, err)
+        }
+    }
+
+    tx, err := r.db.BeginTx(ctx, nil)
+    if err != nil {
+        return fmt.Errorf("failed to begin transaction: %w", err)
+    }
+    defer tx.Rollback() // No-op after a successful commit
+
+    updated, err := scanAlert(tx.QueryRowContext(ctx, query,
         alert.Status, alert.PredictedSeverity, alert.RiskScore,
-        alert.RecommendedAction, alert.AIModelVersion, alert.ID)
+        alert.RecommendedAction, alert.AIModelVersion, sigmaMatches, enrichment, alert.ID, tenant))
+    if err == sql.ErrNoRows {
+        return fmt.Errorf("no alert found with ID %s to update with AI results: %w", alert.ID, ErrAlertNotFound)
+    }
     if err != nil {
         return fmt.Errorf("failed to update alert %s with AI results: %w", alert.ID, err)
     }
-    rowsAffected, err := res.RowsAffected()
-    if err != nil {
-        return fmt.Errorf("failed to get rows affected for alert %s update: %w", alert.ID, err)
+    if err := recordAlertEvents(ctx, tx, models.AlertEventAnalyzed, *updated); err != nil {
+        return err
     }
-    if rowsAffected == 0 {
-        return fmt.Errorf("no alert found with ID %s to update with AI results", alert.ID)
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("failed to commit AI results of alert %s: %w", alert.ID, err)
     }
     return nil
 }
\ No newline at end of file
@@ -0,0 +1,139 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "time"
+
+    "github.com/lib/pq"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// ErrAPIKeyNotFound is returned by methods that modify an API key which does not exist.
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+// APIKeyRepository stores the API keys checked by the authentication middleware.
+type APIKeyRepository interface {
+    // CreateAPIKey stores a new key and sets its CreatedAt.
+    CreateAPIKey(ctx context.Context, key *models.APIKey) error
+    // GetAPIKeyByID returns the key, or nil, nil if it does not exist.
+    GetAPIKeyByID(ctx context.Context, id string) (*models.APIKey, error)
+    // GetAllAPIKeys returns every key, revoked ones included, newest first.
+    GetAllAPIKeys(ctx context.Context) ([]models.APIKey, error)
+    // RevokeAPIKey disables a key for good. Revoking a revoked key keeps its original
+    // revocation time.
+    RevokeAPIKey(ctx context.Context, id string) error
+    // TouchAPIKey records when a key was last used.
+    TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error
+}
+
+// pgAPIKeyRepository implements APIKeyRepository for PostgreSQL.
+type pgAPIKeyRepository struct {
+    db *sql.DB
+}
+
+// NewPgAPIKeyRepository creates a new instance of pgAPIKeyRepository.
+func NewPgAPIKeyRepository(db *sql.DB) APIKeyRepository {
+    return &pgAPIKeyRepository{db: db}
+}
+
+const apiKeyColumns = `
+        id, name, tenant_id, key_hash, roles, created_by, created_at, expires_at, last_used_at, revoked_at`
+
+// scanAPIKey reads one row selected with apiKeyColumns.
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+    var key models.APIKey
+    var expiresAt, lastUsedAt, revokedAt sql.NullTime
+    err := row.Scan(&key.ID, &key.Name, &key.TenantID, &key.Hash, pq.Array(&key.Roles), &key.CreatedBy, &key.CreatedAt,
+        &expiresAt, &lastUsedAt, &revokedAt)
+    if err != nil {
+        return nil, err
+    }
+    if expiresAt.Valid {
+        key.ExpiresAt = &expiresAt.Time
+    }
+    if lastUsedAt.Valid {
+        key.LastUsedAt = &lastUsedAt.Time
+    }
+    if revokedAt.Valid {
+        key.RevokedAt = &revokedAt.Time
+    }
+    return &key, nil
+}
+
+// CreateAPIKey stores a new key and sets its CreatedAt.
+func (r *pgAPIKeyRepository) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+    err := r.db.QueryRowContext(ctx, `
+        INSERT INTO api_keys (id, name, tenant_id, key_hash, roles, created_by, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING created_at`,
+        key.ID, key.Name, key.TenantID, key.Hash, pq.Array(key.Roles), key.CreatedBy, key.ExpiresAt).Scan(&key.CreatedAt)
+    if err != nil {
+        return fmt.Errorf("failed to create API key %s: %w", key.ID, err)
+    }
+    return nil
+}
+
+// GetAPIKeyByID returns the key, or nil, nil if it does not exist.
+func (r *pgAPIKeyRepository) GetAPIKeyByID(ctx context.Context, id string) (*models.APIKey, error) {
+    key, err := scanAPIKey(r.db.QueryRowContext(ctx, `SELECT`+apiKeyColumns+` FROM api_keys WHERE id = $1`, id))
+    if err == sql.ErrNoRows {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to get API key %s: %w", id, err)
+    }
+    return key, nil
+}
+
+// GetAllAPIKeys returns every key, newest first.
+func (r *pgAPIKeyRepository) GetAllAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+    rows, err := r.db.QueryContext(ctx, `SELECT`+apiKeyColumns+` FROM api_keys ORDER BY created_at DESC, id DESC`)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get API keys: %w", err)
+    }
+    defer rows.Close()
+
+    var keys []models.APIKey
+    for rows.Next() {
+        key, err := scanAPIKey(rows)
+        if err != nil {
+            return nil, fmt.Errorf("failed to scan API key row: %w", err)
+        }
+        keys = append(keys, *key)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("row iteration error: %w", err)
+    }
+    return keys, nil
+}
+
+// RevokeAPIKey sets revoked_at unless the key is already revoked.
+func (r *pgAPIKeyRepository) RevokeAPIKey(ctx context.Context, id string) error {
+    res, err := r.db.ExecContext(ctx, `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW()) WHERE id = $1`, id)
+    if err != nil {
+        return fmt.Errorf("failed to revoke API key %s: %w", id, err)
+    }
+    rowsAffected, err := res.RowsAffected()
+    if err != nil {
+        return fmt.Errorf("failed to get rows affected after revoking API key %s: %w", id, err)
+    }
+    if rowsAffected == 0 {
+        return fmt.Errorf("no API key found with ID %s to revoke: %w", id, ErrAPIKeyNotFound)
+    }
+    return nil
+}
+
+// TouchAPIKey records when a key was last used. Times older than the recorded one are ignored.
+func (r *pgAPIKeyRepository) TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error {
+    _, err := r.db.ExecContext(ctx, `
+        UPDATE api_keys SET last_used_at = $1
+        WHERE id = $2 AND (last_used_at IS NULL OR last_used_at < $1)`, usedAt, id)
+    if err != nil {
+        return fmt.Errorf("failed to record use of API key %s: %w", id, err)
+    }
+    return nil
+}
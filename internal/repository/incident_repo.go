@@ -0,0 +1,393 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "sort"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+var (
+    // ErrIncidentNotFound is returned by methods that modify an incident which does not exist.
+    ErrIncidentNotFound = errors.New("incident not found")
+    // ErrIncidentTransition is returned by UpdateIncidentStatus when the incident's current
+    // status may not move to the requested one, e.g. because it was resolved concurrently.
+    ErrIncidentTransition = errors.New("incident status transition not allowed")
+)
+
+// IncidentFilter narrows down the result of GetAllIncidents. Incidents are listed
+// most recently active first. Zero values mean "no constraint".
+type IncidentFilter struct {
+    Status       models.IncidentStatus
+    MinRiskScore *float64
+    Limit        int
+    Offset       int
+}
+
+// Matches reports whether incident satisfies the filter's constraints.
+func (f IncidentFilter) Matches(incident models.Incident) bool {
+    if f.Status != "" && incident.Status != f.Status {
+        return false
+    }
+    if f.MinRiskScore != nil && incident.RiskScore < *f.MinRiskScore {
+        return false
+    }
+    return true
+}
+
+// IncidentRepository stores incidents and their member alerts. Like alerts, every
+// incident belongs to a tenant and only contains alerts of that tenant.
+type IncidentRepository interface {
+    // AttachAlert adds an analysed alert to the most recently active incident of the
+    // alert's tenant that accepts alerts and has one of entities seen at or after
+    // since. If there is none, a new incident with ID newID is created. The incident's aggregates are
+    // recomputed from its members. Attaching an alert that already belongs to an
+    // incident only refreshes that incident, so redelivered messages are harmless.
+    // It reports whether a new incident was created.
+    AttachAlert(ctx context.Context, alert models.SecurityAlert, entities []models.IncidentEntity, since time.Time, newID string) (*models.Incident, bool, error)
+    // GetIncidentByID returns the incident with its entities, member alerts and
+    // timeline, or nil, nil if it does not exist.
+    GetIncidentByID(ctx context.Context, tenant, id string) (*models.Incident, error)
+    GetAllIncidents(ctx context.Context, tenant string, filter IncidentFilter) ([]models.Incident, error)
+    // UpdateIncidentStatus sets the status and records the change in the timeline. The
+    // transition is checked against the status at the time of the update and rejected
+    // with ErrIncidentTransition if the lifecycle does not allow it.
+    UpdateIncidentStatus(ctx context.Context, tenant, id string, status models.IncidentStatus) error
+}
+
+// sortEntities orders entities so advisory locks are always taken in the same order.
+func sortEntities(entities []models.IncidentEntity) []models.IncidentEntity {
+    sorted := append([]models.IncidentEntity(nil), entities...)
+    sort.Slice(sorted, func(i, j int) bool {
+        if sorted[i].Kind != sorted[j].Kind {
+            return sorted[i].Kind < sorted[j].Kind
+        }
+        return sorted[i].Value < sorted[j].Value
+    })
+    return sorted
+}
+
+// pgIncidentRepository implements IncidentRepository for PostgreSQL.
+type pgIncidentRepository struct {
+    db *sql.DB
+}
+
+// NewPgIncidentRepository creates a new instance of pgIncidentRepository.
+func NewPgIncidentRepository(db *sql.DB) IncidentRepository {
+    return &pgIncidentRepository{db: db}
+}
+
+const incidentColumns = `
+        id, tenant_id, title, status, severity, risk_score, alert_count,
+        first_seen, last_seen, created_at, updated_at`
+
+// scanIncident reads one row selected with incidentColumns.
+func scanIncident(row rowScanner) (*models.Incident, error) {
+    var inc models.Incident
+    err := row.Scan(&inc.ID, &inc.TenantID, &inc.Title, &inc.Status, &inc.Severity, &inc.RiskScore, &inc.AlertCount,
+        &inc.FirstSeen, &inc.LastSeen, &inc.CreatedAt, &inc.UpdatedAt)
+    if err != nil {
+        return nil, err
+    }
+    return &inc, nil
+}
+
+// AttachAlert locks the alert's entities with transaction-scoped advisory locks, so
+// two alerts sharing an entity are correlated one after the other and cannot both
+// open a new incident.
+func (r *pgIncidentRepository) AttachAlert(ctx context.Context, alert models.SecurityAlert, entities []models.IncidentEntity, since time.Time, newID string) (*models.Incident, bool, error) {
+    tenant := alert.TenantID
+    if err := checkTenant(tenant); err != nil {
+        return nil, false, err
+    }
+
+    tx, err := r.db.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+    }
+    defer tx.Rollback() // No-op after a successful commit
+
+    entities = sortEntities(entities)
+    for _, e := range entities {
+        if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, "incident-entity:"+tenant+":"+e.Kind+":"+e.Value); err != nil {
+            return nil, false, fmt.Errorf("failed to lock entity %s=%s: %w", e.Kind, e.Value, err)
+        }
+    }
+
+    // Already attached, e.g. the message was redelivered or the alert re-analysed.
+    var incidentID string
+    err = tx.QueryRowContext(ctx, `SELECT incident_id FROM incident_alerts WHERE tenant_id = $1 AND alert_id = $2`, tenant, alert.ID).Scan(&incidentID)
+    attached := err == nil
+    if err != nil && err != sql.ErrNoRows {
+        return nil, false, fmt.Errorf("failed to look up incident of alert %s: %w", alert.ID, err)
+    }
+
+    created := false
+    if !attached {
+        if len(entities) > 0 {
+            var qb queryBuilder
+            qb.where("i.tenant_id = ?", tenant)
+            qb.where("i.status IN ('open', 'investigating')")
+            qb.where("e.last_seen >= ?", since)
+            cond := ""
+            for i, e := range entities {
+                if i > 0 {
+                    cond += " OR "
+                }
+                cond += fmt.Sprintf("(e.kind = %s AND e.value = %s)", qb.arg(e.Kind), qb.arg(e.Value))
+            }
+            qb.where("(" + cond + ")")
+            err = tx.QueryRowContext(ctx, `
+                SELECT i.id FROM incidents i
+                JOIN incident_entities e ON e.incident_id = i.id`+qb.whereClause()+`
+                ORDER BY i.last_seen DESC, i.id DESC
+                LIMIT 1`, qb.args...).Scan(&incidentID)
+            if err != nil && err != sql.ErrNoRows {
+                return nil, false, fmt.Errorf("failed to find incident for alert %s: %w", alert.ID, err)
+            }
+        }
+
+        if incidentID == "" {
+            incidentID, created = newID, true
+            _, err = tx.ExecContext(ctx, `
+                INSERT INTO incidents (id, tenant_id, title, status, first_seen, last_seen)
+                VALUES ($1, $2, $3, $4, $5, $5)`, newID, tenant, alert.Title, models.IncidentOpen, alert.Timestamp)
+            if err != nil {
+                return nil, false, fmt.Errorf("failed to create incident: %w", err)
+            }
+            if err := addIncidentEvent(ctx, tx, incidentID, models.IncidentEventCreated, alert.ID, "Incident opened for alert "+alert.ID); err != nil {
+                return nil, false, err
+            }
+        }
+
+        if _, err := tx.ExecContext(ctx, `INSERT INTO incident_alerts (incident_id, tenant_id, alert_id) VALUES ($1, $2, $3)`, incidentID, tenant, alert.ID); err != nil {
+            return nil, false, fmt.Errorf("failed to attach alert %s to incident %s: %w", alert.ID, incidentID, err)
+        }
+        if err := addIncidentEvent(ctx, tx, incidentID, models.IncidentEventAlertAdded, alert.ID, "Alert added: "+alert.Title); err != nil {
+            return nil, false, err
+        }
+    }
+
+    for _, e := range entities {
+        _, err := tx.ExecContext(ctx, `
+            INSERT INTO incident_entities (incident_id, kind, value, last_seen) VALUES ($1, $2, $3, $4)
+            ON CONFLICT (incident_id, kind, value) DO UPDATE SET last_seen = GREATEST(incident_entities.last_seen, EXCLUDED.last_seen)`,
+            incidentID, e.Kind, e.Value, e.LastSeen)
+        if err != nil {
+            return nil, false, fmt.Errorf("failed to record entity %s=%s of incident %s: %w", e.Kind, e.Value, incidentID, err)
+        }
+    }
+
+    incident, err := recomputeIncident(ctx, tx, incidentID)
+    if err != nil {
+        return nil, false, err
+    }
+    if err := tx.Commit(); err != nil {
+        return nil, false, fmt.Errorf("failed to commit incident %s: %w", incidentID, err)
+    }
+    return incident, created, nil
+}
+
+// recomputeIncident refreshes an incident's aggregates from its member alerts.
+func recomputeIncident(ctx context.Context, tx *sql.Tx, id string) (*models.Incident, error) {
+    members, err := queryAlerts(ctx, tx, `
+        SELECT`+alertColumns+` FROM alerts
+        WHERE (tenant_id, id) IN (SELECT tenant_id, alert_id FROM incident_alerts WHERE incident_id = $1)`, id)
+    if err != nil {
+        return nil, fmt.Errorf("failed to load alerts of incident %s: %w", id, err)
+    }
+
+    incident, err := scanIncident(tx.QueryRowContext(ctx, `SELECT`+incidentColumns+` FROM incidents WHERE id = $1 FOR UPDATE`, id))
+    if err != nil {
+        return nil, fmt.Errorf("failed to load incident %s: %w", id, err)
+    }
+    incident.Recompute(members)
+
+    err = tx.QueryRowContext(ctx, `
+        UPDATE incidents SET
+            severity = $1, risk_score = $2, alert_count = $3, first_seen = $4, last_seen = $5, updated_at = NOW()
+        WHERE id = $6
+        RETURNING updated_at`,
+        incident.Severity, incident.RiskScore, incident.AlertCount, incident.FirstSeen, incident.LastSeen, id).Scan(&incident.UpdatedAt)
+    if err != nil {
+        return nil, fmt.Errorf("failed to update incident %s: %w", id, err)
+    }
+    return incident, nil
+}
+
+// addIncidentEvent appends an entry to an incident's timeline.
+func addIncidentEvent(ctx context.Context, tx *sql.Tx, incidentID, eventType, alertID, message string) error {
+    _, err := tx.ExecContext(ctx, `
+        INSERT INTO incident_events (incident_id, type, alert_id, message) VALUES ($1, $2, NULLIF($3, ''), $4)`,
+        incidentID, eventType, alertID, message)
+    if err != nil {
+        return fmt.Errorf("failed to record %s event of incident %s: %w", eventType, incidentID, err)
+    }
+    return nil
+}
+
+// queryer is satisfied by *sql.DB and *sql.Tx.
+type queryer interface {
+    QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// queryAlerts runs a query selecting alertColumns and scans every row.
+func queryAlerts(ctx context.Context, q queryer, query string, args ...interface{}) ([]models.SecurityAlert, error) {
+    rows, err := q.QueryContext(ctx, query, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var alerts []models.SecurityAlert
+    for rows.Next() {
+        alert, err := scanAlert(rows)
+        if err != nil {
+            return nil, fmt.Errorf("failed to scan alert row: %w", err)
+        }
+        alerts = append(alerts, *alert)
+    }
+    return alerts, rows.Err()
+}
+
+// GetIncidentByID loads an incident of the tenant with its entities, member alerts
+// (oldest first) and timeline.
+func (r *pgIncidentRepository) GetIncidentByID(ctx context.Context, tenant, id string) (*models.Incident, error) {
+    if err := checkTenant(tenant); err != nil {
+        return nil, err
+    }
+    incident, err := scanIncident(r.db.QueryRowContext(ctx, `SELECT`+incidentColumns+` FROM incidents WHERE id = $1 AND tenant_id = $2`, id, tenant))
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return nil, nil // Incident not found
+        }
+        return nil, fmt.Errorf("failed to get incident by ID %s: %w", id, err)
+    }
+
+    incident.Alerts, err = queryAlerts(ctx, r.db, `
+        SELECT`+alertColumns+` FROM alerts
+        WHERE (tenant_id, id) IN (SELECT tenant_id, alert_id FROM incident_alerts WHERE incident_id = $1)
+        ORDER BY timestamp, id`, id)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get alerts of incident %s: %w", id, err)
+    }
+
+    rows, err := r.db.QueryContext(ctx, `
+        SELECT kind, value, last_seen FROM incident_entities
+        WHERE incident_id = $1
+        ORDER BY kind, value`, id)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get entities of incident %s: %w", id, err)
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var e models.IncidentEntity
+        if err := rows.Scan(&e.Kind, &e.Value, &e.LastSeen); err != nil {
+            return nil, fmt.Errorf("failed to scan incident entity: %w", err)
+        }
+        incident.Entities = append(incident.Entities, e)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("row iteration error: %w", err)
+    }
+
+    events, err := r.db.QueryContext(ctx, `
+        SELECT occurred_at, type, COALESCE(alert_id, ''), message FROM incident_events
+        WHERE incident_id = $1
+        ORDER BY id`, id)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get timeline of incident %s: %w", id, err)
+    }
+    defer events.Close()
+    for events.Next() {
+        var ev models.IncidentEvent
+        if err := events.Scan(&ev.Time, &ev.Type, &ev.AlertID, &ev.Message); err != nil {
+            return nil, fmt.Errorf("failed to scan incident event: %w", err)
+        }
+        incident.Timeline = append(incident.Timeline, ev)
+    }
+    if err := events.Err(); err != nil {
+        return nil, fmt.Errorf("row iteration error: %w", err)
+    }
+    return incident, nil
+}
+
+// GetAllIncidents lists the tenant's incidents matching the filter, most recently active first.
+func (r *pgIncidentRepository) GetAllIncidents(ctx context.Context, tenant string, filter IncidentFilter) ([]models.Incident, error) {
+    if err := checkTenant(tenant); err != nil {
+        return nil, err
+    }
+    var qb queryBuilder
+    qb.where("tenant_id = ?", tenant)
+    qb.whereEqual("status", string(filter.Status))
+    if filter.MinRiskScore != nil {
+        qb.where("risk_score >= ?", *filter.MinRiskScore)
+    }
+    query := `SELECT` + incidentColumns + ` FROM incidents` + qb.whereClause() + ` ORDER BY last_seen DESC, id DESC`
+    if filter.Limit > 0 {
+        query += " LIMIT " + qb.arg(filter.Limit)
+    }
+    if filter.Offset > 0 {
+        query += " OFFSET " + qb.arg(filter.Offset)
+    }
+
+    rows, err := r.db.QueryContext(ctx, query, qb.args...)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get incidents: %w", err)
+    }
+    defer rows.Close()
+
+    var incidents []models.Incident
+    for rows.Next() {
+        incident, err := scanIncident(rows)
+        if err != nil {
+            return nil, fmt.Errorf("failed to scan incident row: %w", err)
+        }
+        incidents = append(incidents, *incident)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("row iteration error: %w", err)
+    }
+    return incidents, nil
+}
+
+// UpdateIncidentStatus sets an incident's status and adds a timeline entry.
+func (r *pgIncidentRepository) UpdateIncidentStatus(ctx context.Context, tenant, id string, status models.IncidentStatus) error {
+    if err := checkTenant(tenant); err != nil {
+        return err
+    }
+
+    tx, err := r.db.BeginTx(ctx, nil)
+    if err != nil {
+        return fmt.Errorf("failed to begin transaction: %w", err)
+    }
+    defer tx.Rollback() // No-op after a successful commit
+
+    var previous models.IncidentStatus
+    err = tx.QueryRowContext(ctx, `SELECT status FROM incidents WHERE id = $1 AND tenant_id = $2 FOR UPDATE`, id, tenant).Scan(&previous)
+    if err == sql.ErrNoRows {
+        return fmt.Errorf("no incident found with ID %s to update status: %w", id, ErrIncidentNotFound)
+    }
+    if err != nil {
+        return fmt.Errorf("failed to get status of incident %s: %w", id, err)
+    }
+    if !previous.CanTransitionTo(status) {
+        return fmt.Errorf("cannot move incident %s from %s to %s: %w", id, previous, status, ErrIncidentTransition)
+    }
+
+    if _, err := tx.ExecContext(ctx, `UPDATE incidents SET status = $1, updated_at = NOW() WHERE id = $2`, status, id); err != nil {
+        return fmt.Errorf("failed to update status of incident %s: %w", id, err)
+    }
+    message := fmt.Sprintf("Status changed from %s to %s", previous, status)
+    if err := addIncidentEvent(ctx, tx, id, models.IncidentEventStatusChanged, "", message); err != nil {
+        return err
+    }
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("failed to commit status of incident %s: %w", id, err)
+    }
+    return nil
+}
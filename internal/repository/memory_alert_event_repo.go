@@ -0,0 +1,81 @@
+package repository
+
+import (
+    "context"
+    "sort"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// recordEventLocked appends an event carrying a copy of alert and wakes the listener.
+func (r *MemoryRepository) recordEventLocked(eventType models.AlertEventType, alert models.SecurityAlert, createdAt time.Time) {
+    alert.SigmaMatches = append([]models.SigmaMatch(nil), alert.SigmaMatches...)
+    alert.Enrichment = alert.Enrichment.Clone()
+    r.nextEventID++
+    r.events = append(r.events, models.AlertEvent{
+        ID:        r.nextEventID,
+        Type:      eventType,
+        Alert:     alert,
+        CreatedAt: createdAt,
+    })
+    select {
+    case r.eventNotify <- struct{}{}:
+    default: // A wake-up is already pending
+    }
+}
+
+// AlertEventNotifications returns a channel that receives a value whenever new events
+// were recorded, the in-memory counterpart of ListenAlertEvents. Values are coalesced
+// and there is a single channel, so it should only be read by one consumer.
+func (r *MemoryRepository) AlertEventNotifications() <-chan struct{} {
+    return r.eventNotify
+}
+
+// LatestAlertEventID returns the ID of the newest event, or 0 if there is none.
+func (r *MemoryRepository) LatestAlertEventID(ctx context.Context) (int64, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if len(r.events) == 0 {
+        return 0, nil
+    }
+    return r.events[len(r.events)-1].ID, nil
+}
+
+// GetAlertEventsAfter returns copies of up to limit events with an ID greater than
+// afterID, oldest first.
+func (r *MemoryRepository) GetAlertEventsAfter(ctx context.Context, afterID int64, limit int) ([]models.AlertEvent, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    start := sort.Search(len(r.events), func(i int) bool { return r.events[i].ID > afterID })
+    var events []models.AlertEvent
+    for _, e := range r.events[start:] {
+        if len(events) >= limit {
+            break
+        }
+        e.Alert.SigmaMatches = append([]models.SigmaMatch(nil), e.Alert.SigmaMatches...)
+        e.Alert.Enrichment = e.Alert.Enrichment.Clone()
+        events = append(events, e)
+    }
+    return events, nil
+}
+
+// PurgeAlertEvents deletes events recorded before the given time.
+func (r *MemoryRepository) PurgeAlertEvents(ctx context.Context, before time.Time) (int64, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    kept := r.events[:0]
+    var purged int64
+    for _, e := range r.events {
+        if e.CreatedAt.Before(before) {
+            purged++
+            continue
+        }
+        kept = append(kept, e)
+    }
+    r.events = kept
+    return purged, nil
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// copyAPIKey returns a deep copy of key, so callers never share state with the repository.
+func copyAPIKey(key models.APIKey) models.APIKey {
+    key.Hash = append([]byte(nil), key.Hash...)
+    key.Roles = append([]string(nil), key.Roles...)
+    for _, t := range []**time.Time{&key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt} {
+        if *t != nil {
+            v := **t
+            *t = &v
+        }
+    }
+    return key
+}
+
+// CreateAPIKey stores a copy of key and sets its CreatedAt.
+func (r *MemoryRepository) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if _, exists := r.apiKeys[key.ID]; exists {
+        return fmt.Errorf("failed to create API key: duplicate ID %s", key.ID)
+    }
+    key.CreatedAt = memoryNow()
+    stored := copyAPIKey(*key)
+    r.apiKeys[key.ID] = &stored
+    return nil
+}
+
+// GetAPIKeyByID returns a copy of the key, or nil, nil if it does not exist.
+func (r *MemoryRepository) GetAPIKeyByID(ctx context.Context, id string) (*models.APIKey, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    key, ok := r.apiKeys[id]
+    if !ok {
+        return nil, nil
+    }
+    found := copyAPIKey(*key)
+    return &found, nil
+}
+
+// GetAllAPIKeys returns copies of every key, newest first.
+func (r *MemoryRepository) GetAllAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+    r.mu.Lock()
+    keys := make([]models.APIKey, 0, len(r.apiKeys))
+    for _, key := range r.apiKeys {
+        keys = append(keys, copyAPIKey(*key))
+    }
+    r.mu.Unlock()
+
+    sort.Slice(keys, func(i, j int) bool {
+        if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+            return keys[i].CreatedAt.After(keys[j].CreatedAt)
+        }
+        return keys[i].ID > keys[j].ID
+    })
+    return keys, nil
+}
+
+// RevokeAPIKey sets the revocation time unless the key is already revoked.
+func (r *MemoryRepository) RevokeAPIKey(ctx context.Context, id string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    key, ok := r.apiKeys[id]
+    if !ok {
+        return fmt.Errorf("no API key found with ID %s to revoke: %w", id, ErrAPIKeyNotFound)
+    }
+    if key.RevokedAt == nil {
+        now := memoryNow()
+        key.RevokedAt = &now
+    }
+    return nil
+}
+
+// TouchAPIKey records when a key was last used. Times older than the recorded one are ignored.
+func (r *MemoryRepository) TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if key, ok := r.apiKeys[id]; ok && (key.LastUsedAt == nil || key.LastUsedAt.Before(usedAt)) {
+        usedAt = usedAt.Truncate(time.Microsecond)
+        key.LastUsedAt = &usedAt
+    }
+    return nil
+}
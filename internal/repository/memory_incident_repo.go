@@ -0,0 +1,214 @@
+package repository
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// memoryIncident is one incident of the in-memory repository.
+type memoryIncident struct {
+    incident models.Incident // Without Entities, Alerts and Timeline
+    alertIDs []string
+    entities map[memoryEntityKey]time.Time // Entity -> last seen
+    timeline []models.IncidentEvent
+}
+
+type memoryEntityKey struct {
+    kind, value string
+}
+
+// addEvent appends an entry to the incident's timeline.
+func (m *memoryIncident) addEvent(eventType, alertID, message string) {
+    m.timeline = append(m.timeline, models.IncidentEvent{
+        Time:    memoryNow(),
+        Type:    eventType,
+        AlertID: alertID,
+        Message: message,
+    })
+}
+
+// AttachAlert adds alert to a matching incident or opens a new one, see IncidentRepository.
+func (r *MemoryRepository) AttachAlert(ctx context.Context, alert models.SecurityAlert, entities []models.IncidentEntity, since time.Time, newID string) (*models.Incident, bool, error) {
+    tenant := alert.TenantID
+    if err := checkTenant(tenant); err != nil {
+        return nil, false, err
+    }
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if _, ok := r.alertLocked(tenant, alert.ID); !ok {
+        return nil, false, fmt.Errorf("failed to attach alert %s to an incident: %w", alert.ID, ErrAlertNotFound)
+    }
+
+    created := false
+    m, attached := r.incidents[r.incidentOf[memoryAlertKey{tenant, alert.ID}]]
+    if !attached {
+        for _, candidate := range r.incidents {
+            if candidate.incident.TenantID != tenant || !candidate.incident.Status.AcceptsAlerts() || !candidate.sharesEntity(entities, since) {
+                continue
+            }
+            if m == nil || candidate.incident.LastSeen.After(m.incident.LastSeen) ||
+                (candidate.incident.LastSeen.Equal(m.incident.LastSeen) && candidate.incident.ID > m.incident.ID) {
+                m = candidate
+            }
+        }
+
+        if m == nil {
+            if _, exists := r.incidents[newID]; exists {
+                return nil, false, fmt.Errorf("failed to create incident: incident %s already exists", newID)
+            }
+            now := memoryNow()
+            m = &memoryIncident{
+                incident: models.Incident{
+                    ID:        newID,
+                    TenantID:  tenant,
+                    Title:     alert.Title,
+                    Status:    models.IncidentOpen,
+                    CreatedAt: now,
+                },
+                entities: make(map[memoryEntityKey]time.Time),
+            }
+            r.incidents[newID] = m
+            created = true
+            m.addEvent(models.IncidentEventCreated, alert.ID, "Incident opened for alert "+alert.ID)
+        }
+
+        m.alertIDs = append(m.alertIDs, alert.ID)
+        r.incidentOf[memoryAlertKey{tenant, alert.ID}] = m.incident.ID
+        m.addEvent(models.IncidentEventAlertAdded, alert.ID, "Alert added: "+alert.Title)
+    }
+
+    for _, e := range entities {
+        key := memoryEntityKey{e.Kind, e.Value}
+        lastSeen := e.LastSeen.Truncate(time.Microsecond)
+        if current, ok := m.entities[key]; !ok || lastSeen.After(current) {
+            m.entities[key] = lastSeen
+        }
+    }
+
+    members := make([]models.SecurityAlert, 0, len(m.alertIDs))
+    for _, id := range m.alertIDs {
+        members = append(members, *r.alerts[memoryAlertKey{tenant, id}])
+    }
+    m.incident.Recompute(members)
+    m.incident.UpdatedAt = memoryNow()
+
+    incident := m.incident
+    return &incident, created, nil
+}
+
+// sharesEntity reports whether the incident has one of entities seen at or after since.
+func (m *memoryIncident) sharesEntity(entities []models.IncidentEntity, since time.Time) bool {
+    for _, e := range entities {
+        if lastSeen, ok := m.entities[memoryEntityKey{e.Kind, e.Value}]; ok && !lastSeen.Before(since) {
+            return true
+        }
+    }
+    return false
+}
+
+// GetIncidentByID returns a copy of the incident with its entities, member alerts
+// (oldest first) and timeline, or nil, nil if the tenant has no such incident.
+func (r *MemoryRepository) GetIncidentByID(ctx context.Context, tenant, id string) (*models.Incident, error) {
+    if err := checkTenant(tenant); err != nil {
+        return nil, err
+    }
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    m, ok := r.incidents[id]
+    if !ok || m.incident.TenantID != tenant {
+        return nil, nil // Incident not found
+    }
+    incident := m.incident
+
+    for _, alertID := range m.alertIDs {
+        incident.Alerts = append(incident.Alerts, *r.alerts[memoryAlertKey{tenant, alertID}])
+    }
+    sort.Slice(incident.Alerts, func(i, j int) bool {
+        a, b := incident.Alerts[i], incident.Alerts[j]
+        if !a.Timestamp.Equal(b.Timestamp) {
+            return a.Timestamp.Before(b.Timestamp)
+        }
+        return a.ID < b.ID
+    })
+
+    for key, lastSeen := range m.entities {
+        incident.Entities = append(incident.Entities, models.IncidentEntity{Kind: key.kind, Value: key.value, LastSeen: lastSeen})
+    }
+    sort.Slice(incident.Entities, func(i, j int) bool {
+        a, b := incident.Entities[i], incident.Entities[j]
+        if a.Kind != b.Kind {
+            return a.Kind < b.Kind
+        }
+        return a.Value < b.Value
+    })
+
+    incident.Timeline = append([]models.IncidentEvent(nil), m.timeline...)
+    return &incident, nil
+}
+
+// GetAllIncidents returns copies of the tenant's incidents matching the filter, most
+// recently active first.
+func (r *MemoryRepository) GetAllIncidents(ctx context.Context, tenant string, filter IncidentFilter) ([]models.Incident, error) {
+    if err := checkTenant(tenant); err != nil {
+        return nil, err
+    }
+
+    r.mu.Lock()
+    var incidents []models.Incident
+    for _, m := range r.incidents {
+        if m.incident.TenantID == tenant && filter.Matches(m.incident) {
+            incidents = append(incidents, m.incident)
+        }
+    }
+    r.mu.Unlock()
+
+    sort.Slice(incidents, func(i, j int) bool {
+        a, b := incidents[i], incidents[j]
+        if !a.LastSeen.Equal(b.LastSeen) {
+            return a.LastSeen.After(b.LastSeen)
+        }
+        return a.ID > b.ID
+    })
+
+    if filter.Offset > 0 {
+        if filter.Offset >= len(incidents) {
+            return nil, nil
+        }
+        incidents = incidents[filter.Offset:]
+    }
+    if filter.Limit > 0 && len(incidents) > filter.Limit {
+        incidents = incidents[:filter.Limit]
+    }
+    return incidents, nil
+}
+
+// UpdateIncidentStatus sets an incident's status and adds a timeline entry.
+func (r *MemoryRepository) UpdateIncidentStatus(ctx context.Context, tenant, id string, status models.IncidentStatus) error {
+    if err := checkTenant(tenant); err != nil {
+        return err
+    }
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    m, ok := r.incidents[id]
+    if !ok || m.incident.TenantID != tenant {
+        return fmt.Errorf("no incident found with ID %s to update status: %w", id, ErrIncidentNotFound)
+    }
+    if !m.incident.Status.CanTransitionTo(status) {
+        return fmt.Errorf("cannot move incident %s from %s to %s: %w", id, m.incident.Status, status, ErrIncidentTransition)
+    }
+    message := fmt.Sprintf("Status changed from %s to %s", m.incident.Status, status)
+    m.incident.Status = status
+    m.incident.UpdatedAt = memoryNow()
+    m.addEvent(models.IncidentEventStatusChanged, "", message)
+    return nil
+}
@@ -0,0 +1,449 @@
+package repository
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "sort"
+    "sync"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// MemoryRepository keeps alerts, their outbox messages, alert events, incidents and API
+// keys in memory. It implements AlertRepository, OutboxRepository, AlertEventRepository,
+// IncidentRepository and APIKeyRepository with the same semantics as the Postgres
+// implementations, so the API and the processor can run without a database, e.g. in
+// tests and demos. Nothing survives a restart. It is safe for concurrent use.
+type MemoryRepository struct {
+    mu     sync.Mutex
+    alerts map[memoryAlertKey]*models.SecurityAlert
+    outbox []*memoryOutboxEntry // In id order
+    nextID int64                // Last outbox id handed out
+
+    events      []models.AlertEvent // In id order
+    nextEventID int64               // Last event id handed out
+    eventNotify chan struct{}       // See AlertEventNotifications
+
+    incidents  map[string]*memoryIncident
+    incidentOf map[memoryAlertKey]string // Alert -> incident ID
+
+    apiKeys map[string]*models.APIKey
+}
+
+// memoryAlertKey identifies an alert. Alert IDs are only unique within a tenant.
+type memoryAlertKey struct {
+    tenant, id string
+}
+
+// memoryOutboxEntry is one row of the in-memory outbox.
+type memoryOutboxEntry struct {
+    msg           models.OutboxMessage
+    nextAttemptAt time.Time
+    sentAt        time.Time // Zero while pending
+    failedAt      time.Time // Set once the message is parked after too many failures
+    lastError     string
+    claimed       bool // Being published by a DrainOutbox call, like a row lock
+}
+
+// NewMemoryRepository creates an empty in-memory repository.
+func NewMemoryRepository() *MemoryRepository {
+    return &MemoryRepository{
+        alerts:      make(map[memoryAlertKey]*models.SecurityAlert),
+        eventNotify: make(chan struct{}, 1),
+        incidents:   make(map[string]*memoryIncident),
+        incidentOf:  make(map[memoryAlertKey]string),
+        apiKeys:     make(map[string]*models.APIKey),
+    }
+}
+
+// memoryNow returns the current time at the precision Postgres stores timestamps with,
+// so values round-trip the same way through both implementations.
+func memoryNow() time.Time {
+    return time.Now().Truncate(time.Microsecond)
+}
+
+// insertLocked stores a copy of alert with the given creation time, queues it in the
+// outbox and records its alert.created event. It reports false if the tenant already
+// has an alert with the same ID.
+func (r *MemoryRepository) insertLocked(alert models.SecurityAlert, createdAt time.Time) (bool, error) {
+    if _, exists := r.alerts[memoryAlertKey{alert.TenantID, alert.ID}]; exists {
+        return false, nil
+    }
+    setOccurrenceDefaults(&alert)
+    if err := r.enqueueLocked(alert, createdAt); err != nil {
+        return false, err
+    }
+
+    alert.Timestamp = alert.Timestamp.Truncate(time.Microsecond)
+    alert.LastSeen = alert.LastSeen.Truncate(time.Microsecond)
+    alert.CreatedAt = createdAt // Set by the database default in Postgres
+    if len(alert.Attributes) > 0 {
+        // Not shared with the caller, who may go on to modify the map
+        attributes := make(map[string]string, len(alert.Attributes))
+        for k, v := range alert.Attributes {
+            attributes[k] = v
+        }
+        alert.Attributes = attributes
+    }
+    alert.RawPayload = append(json.RawMessage(nil), alert.RawPayload...)
+    r.alerts[memoryAlertKey{alert.TenantID, alert.ID}] = &alert
+    r.recordEventLocked(models.AlertEventCreated, alert, createdAt)
+    return true, nil
+}
+
+// enqueueLocked appends an outbox message carrying alert.
+func (r *MemoryRepository) enqueueLocked(alert models.SecurityAlert, createdAt time.Time) error {
+    payload, err := outboxPayload(alert)
+    if err != nil {
+        return err
+    }
+    r.nextID++
+    r.outbox = append(r.outbox, &memoryOutboxEntry{
+        msg: models.OutboxMessage{
+            ID:        r.nextID,
+            AlertID:   alert.ID,
+            TenantID:  alert.TenantID,
+            Key:       []byte(alert.ID),
+            Payload:   payload,
+            CreatedAt: createdAt,
+        },
+        nextAttemptAt: createdAt,
+    })
+    return nil
+}
+
+// CreateAlert stores a new alert of the tenant and queues it in the outbox.
+func (r *MemoryRepository) CreateAlert(ctx context.Context, tenant string, alert *models.SecurityAlert) error {
+    if err := checkTenant(tenant); err != nil {
+        return err
+    }
+    alert.TenantID = tenant
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    ok, err := r.insertLocked(*alert, memoryNow())
+    if err != nil {
+        return err
+    }
+    if !ok {
+        return fmt.Errorf("failed to create alert: duplicate alert ID %s: %w", alert.ID, ErrAlertExists)
+    }
+    setOccurrenceDefaults(alert)
+    return nil
+}
+
+// CreateAlerts stores every alert whose ID does not exist yet and returns their IDs.
+// As with a single Postgres transaction, all alerts of the batch share one creation time.
+func (r *MemoryRepository) CreateAlerts(ctx context.Context, tenant string, alerts []models.SecurityAlert) ([]string, error) {
+    if err := checkTenant(tenant); err != nil {
+        return nil, err
+    }
+    if len(alerts) == 0 {
+        return nil, nil
+    }
+    for i := range alerts {
+        alerts[i].TenantID = tenant
+    }
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    createdAt := memoryNow()
+    inserted := make([]string, 0, len(alerts))
+    for _, alert := range alerts {
+        ok, err := r.insertLocked(alert, createdAt)
+        if err != nil {
+            return nil, err
+        }
+        if ok {
+            inserted = append(inserted, alert.ID)
+        }
+    }
+    return inserted, nil
+}
+
+// CreateOrFoldAlert stores alert, or folds it into the tenant's most recently seen
+// alert with the same fingerprint if that was last seen within window of it.
+func (r *MemoryRepository) CreateOrFoldAlert(ctx context.Context, tenant string, alert *models.SecurityAlert, window time.Duration, escalateAt []int) (*FoldResult, error) {
+    if err := checkTenant(tenant); err != nil {
+        return nil, err
+    }
+    alert.TenantID = tenant
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    res, err := r.createOrFoldLocked(alert, window, escalateAt, memoryNow())
+    if err != nil {
+        return nil, err
+    }
+    if res == nil {
+        return nil, fmt.Errorf("failed to create alert: duplicate alert ID %s: %w", alert.ID, ErrAlertExists)
+    }
+    return res, nil
+}
+
+// CreateOrFoldAlerts stores or folds every alert like CreateOrFoldAlert, under one
+// lock. As with a single Postgres transaction, all new alerts share one creation time.
+func (r *MemoryRepository) CreateOrFoldAlerts(ctx context.Context, tenant string, alerts []models.SecurityAlert, window time.Duration, escalateAt []int) ([]*FoldResult, error) {
+    if err := checkTenant(tenant); err != nil {
+        return nil, err
+    }
+    if len(alerts) == 0 {
+        return nil, nil
+    }
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    createdAt := memoryNow()
+    results := make([]*FoldResult, len(alerts))
+    for i := range alerts {
+        alerts[i].TenantID = tenant
+        res, err := r.createOrFoldLocked(&alerts[i], window, escalateAt, createdAt)
+        if err != nil {
+            return nil, err
+        }
+        results[i] = res
+    }
+    return results, nil
+}
+
+// createOrFoldLocked stores alert or folds it into a recent alert with the same
+// fingerprint. It returns nil if nothing was stored because the tenant already has
+// an alert with the same ID.
+func (r *MemoryRepository) createOrFoldLocked(alert *models.SecurityAlert, window time.Duration, escalateAt []int, createdAt time.Time) (*FoldResult, error) {
+    var existing *models.SecurityAlert
+    if alert.Fingerprint != "" {
+        since := alert.Timestamp.Add(-window)
+        for _, a := range r.alerts {
+            if a.TenantID != alert.TenantID || a.Fingerprint != alert.Fingerprint || a.LastSeen.Before(since) {
+                continue
+            }
+            if existing == nil || a.LastSeen.After(existing.LastSeen) {
+                existing = a
+            }
+        }
+    }
+
+    if existing == nil {
+        ok, err := r.insertLocked(*alert, createdAt)
+        if err != nil || !ok {
+            return nil, err
+        }
+        setOccurrenceDefaults(alert)
+        return &FoldResult{AlertID: alert.ID, OccurrenceCount: alert.OccurrenceCount}, nil
+    }
+
+    existing.OccurrenceCount++
+    if seen := alert.Timestamp.Truncate(time.Microsecond); seen.After(existing.LastSeen) {
+        existing.LastSeen = seen
+    }
+    res := &FoldResult{AlertID: existing.ID, Folded: true, OccurrenceCount: existing.OccurrenceCount}
+    if reachesThreshold(existing.OccurrenceCount, escalateAt) {
+        if err := r.enqueueLocked(*existing, createdAt); err != nil {
+            return nil, err
+        }
+        res.Escalated = true
+    }
+    return res, nil
+}
+
+// alertLocked returns the stored alert with the given ID if it belongs to tenant.
+func (r *MemoryRepository) alertLocked(tenant, id string) (*models.SecurityAlert, bool) {
+    alert, ok := r.alerts[memoryAlertKey{tenant, id}]
+    return alert, ok
+}
+
+// GetAlertByID returns a copy of the alert, or nil, nil if the tenant has no such alert.
+func (r *MemoryRepository) GetAlertByID(ctx context.Context, tenant, id string) (*models.SecurityAlert, error) {
+    if err := checkTenant(tenant); err != nil {
+        return nil, err
+    }
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    alert, ok := r.alertLocked(tenant, id)
+    if !ok {
+        return nil, nil // Alert not found
+    }
+    found := *alert
+    return &found, nil
+}
+
+// GetAllAlerts returns copies of the tenant's alerts matching the filter, ordered and
+// paginated as requested.
+func (r *MemoryRepository) GetAllAlerts(ctx context.Context, tenant string, filter AlertFilter) ([]models.SecurityAlert, error) {
+    if err := checkTenant(tenant); err != nil {
+        return nil, err
+    }
+
+    r.mu.Lock()
+    var alerts []models.SecurityAlert
+    for _, alert := range r.alerts {
+        if alert.TenantID == tenant && filter.Matches(*alert) {
+            alerts = append(alerts, *alert)
+        }
+    }
+    r.mu.Unlock()
+
+    sort.Slice(alerts, func(i, j int) bool { return filter.Less(alerts[i], alerts[j]) })
+
+    if filter.Offset > 0 {
+        if filter.Offset >= len(alerts) {
+            return nil, nil
+        }
+        alerts = alerts[filter.Offset:]
+    }
+    if filter.Limit > 0 && len(alerts) > filter.Limit {
+        alerts = alerts[:filter.Limit]
+    }
+    return alerts, nil
+}
+
+// UpdateAlertStatus sets the status of an alert.
+func (r *MemoryRepository) UpdateAlertStatus(ctx context.Context, tenant, id string, status models.AlertStatus) error {
+    if err := checkTenant(tenant); err != nil {
+        return err
+    }
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    alert, ok := r.alertLocked(tenant, id)
+    if !ok {
+        return fmt.Errorf("no alert found with ID %s to update status: %w", id, ErrAlertNotFound)
+    }
+    alert.Status = status
+    return nil
+}
+
+// TransitionAlertStatus moves an alert from status "from" to status "to".
+func (r *MemoryRepository) TransitionAlertStatus(ctx context.Context, tenant, id string, from, to models.AlertStatus) error {
+    if err := checkTenant(tenant); err != nil {
+        return err
+    }
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    alert, ok := r.alertLocked(tenant, id)
+    if !ok {
+        return fmt.Errorf("no alert found with ID %s to transition: %w", id, ErrAlertNotFound)
+    }
+    if alert.Status != from {
+        return fmt.Errorf("alert %s is no longer in status %s: %w", id, from, ErrStatusConflict)
+    }
+    alert.Status = to
+    return nil
+}
+
+// UpdateAlertWithAIResults stores the AI fields of alert. The status is only applied
+// while the stored alert is still "new".
+func (r *MemoryRepository) UpdateAlertWithAIResults(ctx context.Context, tenant string, alert *models.SecurityAlert) error {
+    if err := checkTenant(tenant); err != nil {
+        return err
+    }
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    stored, ok := r.alertLocked(tenant, alert.ID)
+    if !ok {
+        return fmt.Errorf("no alert found with ID %s to update with AI results: %w", alert.ID, ErrAlertNotFound)
+    }
+    if stored.Status == models.StatusNew {
+        stored.Status = alert.Status
+    }
+    stored.PredictedSeverity = alert.PredictedSeverity
+    stored.RiskScore = alert.RiskScore
+    stored.RecommendedAction = alert.RecommendedAction
+    stored.AIModelVersion = alert.AIModelVersion
+    stored.SigmaMatches = append([]models.SigmaMatch(nil), alert.SigmaMatches...)
+    stored.Enrichment = nil
+    if !alert.Enrichment.IsEmpty() {
+        stored.Enrichment = alert.Enrichment.Clone()
+    }
+    r.recordEventLocked(models.AlertEventAnalyzed, *stored, memoryNow())
+    return nil
+}
+
+// DrainOutbox claims up to limit due messages and passes them to publish. Claimed
+// messages are skipped by concurrent calls until publish has returned, like the
+// row locks taken by the Postgres implementation.
+func (r *MemoryRepository) DrainOutbox(ctx context.Context, limit int, backoff OutboxBackoff, publish func(ctx context.Context, msgs []models.OutboxMessage) error) (int, error) {
+    r.mu.Lock()
+    current := memoryNow()
+    var claimed []*memoryOutboxEntry
+    var msgs []models.OutboxMessage
+    for _, e := range r.outbox {
+        if len(claimed) >= limit {
+            break
+        }
+        if e.claimed || !e.sentAt.IsZero() || !e.failedAt.IsZero() || e.nextAttemptAt.After(current) {
+            continue
+        }
+        e.claimed = true
+        claimed = append(claimed, e)
+        msgs = append(msgs, e.msg)
+    }
+    r.mu.Unlock()
+
+    if len(msgs) == 0 {
+        return 0, nil
+    }
+
+    results := publishResults(publish(ctx, msgs), len(msgs))
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    current = memoryNow()
+    for i, e := range claimed {
+        e.claimed = false
+        if publishErr := results[i]; publishErr != nil {
+            // Same schedule as the Postgres implementation: initial * 2^attempts, capped at max.
+            delay := backoff.Initial
+            for i := 0; i < e.msg.Attempts && delay < backoff.Max; i++ {
+                delay *= 2
+            }
+            if delay > backoff.Max {
+                delay = backoff.Max
+            }
+            e.msg.Attempts++
+            e.lastError = publishErr.Error()
+            e.nextAttemptAt = current.Add(delay)
+            if backoff.MaxAttempts > 0 && e.msg.Attempts >= backoff.MaxAttempts {
+                e.failedAt = current
+            }
+        } else {
+            e.sentAt = current
+            e.lastError = ""
+        }
+    }
+    return len(msgs), nil
+}
+
+// PurgeSentOutbox deletes messages that were sent before sentBefore.
+func (r *MemoryRepository) PurgeSentOutbox(ctx context.Context, sentBefore time.Time) (int64, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    kept := r.outbox[:0]
+    var purged int64
+    for _, e := range r.outbox {
+        if !e.sentAt.IsZero() && e.sentAt.Before(sentBefore) {
+            purged++
+            continue
+        }
+        kept = append(kept, e)
+    }
+    for i := len(kept); i < len(r.outbox); i++ {
+        r.outbox[i] = nil // Let the purged entries be collected
+    }
+    r.outbox = kept
+    return purged, nil
+}
@@ -0,0 +1,207 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/lib/pq"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// OutboxBackoff controls when a message that failed to publish is retried:
+// the delay starts at Initial and doubles with every attempt, up to Max.
+// A message that has failed MaxAttempts times is parked instead: it is no longer
+// claimed, but kept with its last error. 0 means retrying forever.
+type OutboxBackoff struct {
+    Initial     time.Duration
+    Max         time.Duration
+    MaxAttempts int
+}
+
+// OutboxErrors is returned by a DrainOutbox publish function when only some of the
+// messages failed. It holds one error per message, nil for those that were published.
+type OutboxErrors []error
+
+func (e OutboxErrors) Error() string {
+    failed := 0
+    var first error
+    for _, err := range e {
+        if err != nil {
+            if first == nil {
+                first = err
+            }
+            failed++
+        }
+    }
+    return fmt.Sprintf("%d of %d outbox messages failed to publish, first error: %v", failed, len(e), first)
+}
+
+// publishResults returns the outcome of each of n messages given the error returned
+// by a publish function: nil for all, the errors of OutboxErrors, or err for all.
+func publishResults(err error, n int) []error {
+    results := make([]error, n)
+    var perMessage OutboxErrors
+    if errors.As(err, &perMessage) && len(perMessage) == n {
+        copy(results, perMessage)
+    } else if err != nil {
+        for i := range results {
+            results[i] = err
+        }
+    }
+    return results
+}
+
+// OutboxRepository gives the outbox relay access to pending messages.
+type OutboxRepository interface {
+    // DrainOutbox claims up to limit due messages and passes them to publish.
+    // Published messages are marked as sent; the attempt count of the others is
+    // increased and they are rescheduled, or parked, according to backoff. publish
+    // returns OutboxErrors if only some messages failed. It returns how many messages
+    // were claimed. Claimed rows are locked, so several relays can run side by side
+    // without publishing the same message twice.
+    DrainOutbox(ctx context.Context, limit int, backoff OutboxBackoff, publish func(ctx context.Context, msgs []models.OutboxMessage) error) (int, error)
+    // PurgeSentOutbox deletes messages that were sent before the given time.
+    PurgeSentOutbox(ctx context.Context, sentBefore time.Time) (int64, error)
+}
+
+// pgOutboxRepository implements OutboxRepository for PostgreSQL.
+type pgOutboxRepository struct {
+    db *sql.DB
+}
+
+// NewPgOutboxRepository creates a new instance of pgOutboxRepository.
+func NewPgOutboxRepository(db *sql.DB) OutboxRepository {
+    return &pgOutboxRepository{db: db}
+}
+
+// outboxPayload encodes alert as the value of its Kafka message. The raw payload is
+// left out: it can be as large as the request body it came from and would push the
+// message past the broker's size limit. The processor loads it from the alert's row.
+func outboxPayload(alert models.SecurityAlert) ([]byte, error) {
+    alert.RawPayload = nil
+    payload, err := json.Marshal(alert)
+    if err != nil {
+        return nil, fmt.Errorf("failed to marshal alert %s for outbox: %w", alert.ID, err)
+    }
+    return payload, nil
+}
+
+// enqueueOutbox writes one outbox message per alert inside the caller's transaction.
+func enqueueOutbox(ctx context.Context, tx *sql.Tx, alerts ...models.SecurityAlert) error {
+    if len(alerts) == 0 {
+        return nil
+    }
+
+    placeholders := make([]string, 0, len(alerts))
+    args := make([]interface{}, 0, len(alerts)*4)
+    for i, alert := range alerts {
+        payload, err := outboxPayload(alert)
+        if err != nil {
+            return err
+        }
+        placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d)", i*4+1, i*4+2, i*4+3, i*4+4))
+        // Use alert ID as key for Kafka message to ensure order for a specific alert (if partitions are by key)
+        args = append(args, alert.ID, alert.TenantID, []byte(alert.ID), string(payload))
+    }
+
+    query := `INSERT INTO alert_outbox (alert_id, tenant_id, message_key, payload) VALUES ` + strings.Join(placeholders, ", ")
+    if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+        return fmt.Errorf("failed to write outbox messages: %w", err)
+    }
+    return nil
+}
+
+// DrainOutbox claims due messages with SELECT ... FOR UPDATE SKIP LOCKED and keeps the
+// transaction open while publishing, so the claim is released automatically if the
+// relay crashes half-way.
+func (r *pgOutboxRepository) DrainOutbox(ctx context.Context, limit int, backoff OutboxBackoff, publish func(ctx context.Context, msgs []models.OutboxMessage) error) (int, error) {
+    tx, err := r.db.BeginTx(ctx, nil)
+    if err != nil {
+        return 0, fmt.Errorf("failed to begin outbox transaction: %w", err)
+    }
+    defer tx.Rollback() // No-op after a successful commit
+
+    rows, err := tx.QueryContext(ctx, `
+        SELECT id, alert_id, tenant_id, message_key, payload, attempts, created_at
+        FROM alert_outbox
+        WHERE sent_at IS NULL AND failed_at IS NULL AND next_attempt_at <= NOW()
+        ORDER BY id
+        LIMIT $1
+        FOR UPDATE SKIP LOCKED`, limit)
+    if err != nil {
+        return 0, fmt.Errorf("failed to claim outbox messages: %w", err)
+    }
+
+    var msgs []models.OutboxMessage
+    var ids []int64
+    for rows.Next() {
+        var m models.OutboxMessage
+        if err := rows.Scan(&m.ID, &m.AlertID, &m.TenantID, &m.Key, &m.Payload, &m.Attempts, &m.CreatedAt); err != nil {
+            rows.Close()
+            return 0, fmt.Errorf("failed to scan outbox message: %w", err)
+        }
+        msgs = append(msgs, m)
+        ids = append(ids, m.ID)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return 0, fmt.Errorf("row iteration error: %w", err)
+    }
+    if len(msgs) == 0 {
+        return 0, nil
+    }
+
+    var sentIDs, failedIDs []int64
+    var failures []string
+    for i, err := range publishResults(publish(ctx, msgs), len(msgs)) {
+        if err != nil {
+            failedIDs = append(failedIDs, ids[i])
+            failures = append(failures, err.Error())
+        } else {
+            sentIDs = append(sentIDs, ids[i])
+        }
+    }
+
+    if len(sentIDs) > 0 {
+        _, err = tx.ExecContext(ctx, `UPDATE alert_outbox SET sent_at = NOW(), last_error = NULL WHERE id = ANY($1)`, pq.Array(sentIDs))
+        if err != nil {
+            return 0, fmt.Errorf("failed to mark outbox messages as sent: %w", err)
+        }
+    }
+    if len(failedIDs) > 0 {
+        // Reschedule with exponential backoff: initial * 2^attempts, capped at max,
+        // or park the message once it has used up its attempts.
+        _, err = tx.ExecContext(ctx, `
+            UPDATE alert_outbox o SET
+                attempts = o.attempts + 1,
+                last_error = f.error,
+                next_attempt_at = NOW() + LEAST($3 * POWER(2, LEAST(o.attempts, 30)), $4) * INTERVAL '1 millisecond',
+                failed_at = CASE WHEN $5 > 0 AND o.attempts + 1 >= $5 THEN NOW() END
+            FROM unnest($1::bigint[], $2::text[]) AS f(id, error)
+            WHERE o.id = f.id`,
+            pq.Array(failedIDs), pq.Array(failures), backoff.Initial.Milliseconds(), backoff.Max.Milliseconds(), backoff.MaxAttempts)
+        if err != nil {
+            return 0, fmt.Errorf("failed to reschedule outbox messages: %w", err)
+        }
+    }
+
+    if err := tx.Commit(); err != nil {
+        return 0, fmt.Errorf("failed to commit outbox transaction: %w", err)
+    }
+    return len(msgs), nil
+}
+
+// PurgeSentOutbox deletes messages that were sent before sentBefore.
+func (r *pgOutboxRepository) PurgeSentOutbox(ctx context.Context, sentBefore time.Time) (int64, error) {
+    res, err := r.db.ExecContext(ctx, `DELETE FROM alert_outbox WHERE sent_at IS NOT NULL AND sent_at < $1`, sentBefore)
+    if err != nil {
+        return 0, fmt.Errorf("failed to purge sent outbox messages: %w", err)
+    }
+    return res.RowsAffected()
+}
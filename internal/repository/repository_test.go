@@ -0,0 +1,48 @@
+package repository_test
+
+import (
+    "context"
+    "os"
+    "testing"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/database"
+    "github.com/Kelvinkhyd/GuardianAI/internal/repository"
+    "github.com/Kelvinkhyd/GuardianAI/internal/repository/repositorytest"
+)
+
+func TestMemoryRepository(t *testing.T) {
+    repositorytest.Run(t, func(t *testing.T) repositorytest.Repos {
+        repo := repository.NewMemoryRepository()
+        return repositorytest.Repos{Alerts: repo, Outbox: repo, Incidents: repo, Events: repo, APIKeys: repo}
+    })
+}
+
+// TestPgRepository runs the suite against the database named by TEST_DATABASE_URL.
+// Every table the suite touches is truncated, so never point it at real data.
+func TestPgRepository(t *testing.T) {
+    url := os.Getenv("TEST_DATABASE_URL")
+    if url == "" {
+        t.Skip("TEST_DATABASE_URL not set, skipping Postgres conformance tests")
+    }
+    db, err := database.NewDBConnection(url)
+    if err != nil {
+        t.Fatalf("failed to connect to test database: %v", err)
+    }
+    defer db.Close()
+    if _, err := db.MigrateUp(context.Background()); err != nil {
+        t.Fatalf("failed to migrate test database: %v", err)
+    }
+
+    repositorytest.Run(t, func(t *testing.T) repositorytest.Repos {
+        if _, err := db.Exec(`TRUNCATE alerts, alert_outbox, alert_events, api_keys, incidents, incident_alerts, incident_entities, incident_events RESTART IDENTITY`); err != nil {
+            t.Fatalf("failed to reset test database: %v", err)
+        }
+        return repositorytest.Repos{
+            Alerts:    repository.NewPgAlertRepository(db.DB),
+            Outbox:    repository.NewPgOutboxRepository(db.DB),
+            Incidents: repository.NewPgIncidentRepository(db.DB),
+            Events:    repository.NewPgAlertEventRepository(db.DB),
+            APIKeys:   repository.NewPgAPIKeyRepository(db.DB),
+        }
+    })
+}
@@ -0,0 +1,1338 @@
+// Package repositorytest holds the conformance suite every repository
+// implementation must pass, so the in-memory repository and the Postgres one
+// cannot drift apart.
+package repositorytest
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "reflect"
+    "sort"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+    "github.com/Kelvinkhyd/GuardianAI/internal/repository"
+)
+
+// Repos bundles the repositories under test. All must share the same storage.
+type Repos struct {
+    Alerts    repository.AlertRepository
+    Outbox    repository.OutboxRepository
+    Incidents repository.IncidentRepository
+    Events    repository.AlertEventRepository
+    APIKeys   repository.APIKeyRepository
+}
+
+// Run runs the whole suite. newRepos is called once per subtest and must return
+// repositories backed by empty storage.
+func Run(t *testing.T, newRepos func(t *testing.T) Repos) {
+    tests := []struct {
+        name string
+        fn   func(t *testing.T, r Repos)
+    }{
+        {"CreateAndGet", testCreateAndGet},
+        {"GetMissing", testGetMissing},
+        {"CreateDuplicate", testCreateDuplicate},
+        {"CreateAlertsSkipsDuplicates", testCreateAlertsSkipsDuplicates},
+        {"ListOrdering", testListOrdering},
+        {"ListFilters", testListFilters},
+        {"ListPagination", testListPagination},
+        {"UpdateAlertStatus", testUpdateAlertStatus},
+        {"TransitionAlertStatus", testTransitionAlertStatus},
+        {"UpdateAlertWithAIResults", testUpdateAlertWithAIResults},
+        {"SigmaMatches", testSigmaMatches},
+        {"Enrichment", testEnrichment},
+        {"GeoFilters", testGeoFilters},
+        {"AlertEvents", testAlertEvents},
+        {"APIKeys", testAPIKeys},
+        {"OutboxDrain", testOutboxDrain},
+        {"OutboxRetry", testOutboxRetry},
+        {"OutboxSkipsDuplicates", testOutboxSkipsDuplicates},
+        {"OutboxPartialFailure", testOutboxPartialFailure},
+        {"OutboxParking", testOutboxParking},
+        {"FoldWithinWindow", testFoldWithinWindow},
+        {"FoldEscalation", testFoldEscalation},
+        {"FoldBatch", testFoldBatch},
+        {"FoldBatchEscalation", testFoldBatchEscalation},
+        {"IncidentCorrelation", testIncidentCorrelation},
+        {"IncidentWindowAndStatus", testIncidentWindowAndStatus},
+        {"IncidentAttachTwice", testIncidentAttachTwice},
+        {"IncidentList", testIncidentList},
+        {"TenantIsolation", testTenantIsolation},
+        {"SameIDInTwoTenants", testSameIDInTwoTenants},
+    }
+    for _, tc := range tests {
+        t.Run(tc.name, func(t *testing.T) {
+            tc.fn(t, newRepos(t))
+        })
+    }
+}
+
+// tenant owns the alerts and incidents of every test but testTenantIsolation.
+const tenant = "acme"
+
+// baseTime is the reference timestamp of the generated alerts.
+var baseTime = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// newAlert returns a valid alert with the given ID and its timestamp offset from baseTime.
+func newAlert(id string, offset time.Duration) models.SecurityAlert {
+    return models.SecurityAlert{
+        ID:        id,
+        TenantID:  tenant,
+        Source:    "conformance",
+        Timestamp: baseTime.Add(offset),
+        Severity:  "high",
+        Category:  "malware",
+        Title:     "Test alert " + id,
+        SourceIP:  "10.0.0.1",
+        TargetIP:  "192.168.1.10",
+        Hostname:  "host-1",
+        Username:  "alice",
+        Status:    models.StatusNew,
+    }
+}
+
+func createAll(t *testing.T, r Repos, alerts ...models.SecurityAlert) {
+    t.Helper()
+    for i := range alerts {
+        if err := r.Alerts.CreateAlert(context.Background(), tenant, &alerts[i]); err != nil {
+            t.Fatalf("CreateAlert(%s): %v", alerts[i].ID, err)
+        }
+    }
+}
+
+func list(t *testing.T, r Repos, f repository.AlertFilter) []string {
+    t.Helper()
+    alerts, err := r.Alerts.GetAllAlerts(context.Background(), tenant, f)
+    if err != nil {
+        t.Fatalf("GetAllAlerts(%+v): %v", f, err)
+    }
+    ids := make([]string, 0, len(alerts))
+    for _, a := range alerts {
+        ids = append(ids, a.ID)
+    }
+    return ids
+}
+
+func expectIDs(t *testing.T, what string, got []string, want ...string) {
+    t.Helper()
+    if len(got) == 0 && len(want) == 0 {
+        return
+    }
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("%s: got %v, want %v", what, got, want)
+    }
+}
+
+func mustGet(t *testing.T, r Repos, id string) *models.SecurityAlert {
+    t.Helper()
+    alert, err := r.Alerts.GetAlertByID(context.Background(), tenant, id)
+    if err != nil {
+        t.Fatalf("GetAlertByID(%s): %v", id, err)
+    }
+    if alert == nil {
+        t.Fatalf("GetAlertByID(%s): not found", id)
+    }
+    return alert
+}
+
+func testCreateAndGet(t *testing.T, r Repos) {
+    want := newAlert("a1", 0)
+    want.Description = "something happened"
+    want.FileHash = "d41d8cd98f00b204e9800998ecf8427e"
+    want.Attributes = map[string]string{"deviceVendor": "Acme", "cs1": "policy 7"}
+    want.RawPayload = json.RawMessage(`{"title":"x","vendor":{"rule_id":42}}`)
+    createAll(t, r, want)
+
+    got := mustGet(t, r, "a1")
+    if got.CreatedAt.IsZero() {
+        t.Error("CreatedAt was not set")
+    }
+    if !got.Timestamp.Equal(want.Timestamp) {
+        t.Errorf("Timestamp: got %v, want %v", got.Timestamp, want.Timestamp)
+    }
+    if !got.LastSeen.Equal(want.Timestamp) {
+        t.Errorf("LastSeen: got %v, want the alert timestamp %v", got.LastSeen, want.Timestamp)
+    }
+    // Postgres normalises the JSON of the raw payload, so it is compared by value.
+    var gotRaw, wantRaw interface{}
+    json.Unmarshal(got.RawPayload, &gotRaw)
+    json.Unmarshal(want.RawPayload, &wantRaw)
+    if !reflect.DeepEqual(gotRaw, wantRaw) {
+        t.Errorf("RawPayload: got %s, want %s", got.RawPayload, want.RawPayload)
+    }
+    got.RawPayload = want.RawPayload
+    // Times are compared above; the zone they come back in is implementation specific.
+    got.CreatedAt, got.Timestamp, got.LastSeen = time.Time{}, want.Timestamp, time.Time{}
+    want.OccurrenceCount = 1
+    if !reflect.DeepEqual(*got, want) {
+        t.Errorf("GetAlertByID: got %+v, want %+v", *got, want)
+    }
+}
+
+func testGetMissing(t *testing.T, r Repos) {
+    alert, err := r.Alerts.GetAlertByID(context.Background(), tenant, "missing")
+    if alert != nil || err != nil {
+        t.Errorf("GetAlertByID(missing): got %v, %v, want nil, nil", alert, err)
+    }
+}
+
+func testCreateDuplicate(t *testing.T, r Repos) {
+    createAll(t, r, newAlert("a1", 0))
+    dup := newAlert("a1", time.Hour)
+    if err := r.Alerts.CreateAlert(context.Background(), tenant, &dup); !errors.Is(err, repository.ErrAlertExists) {
+        t.Errorf("CreateAlert with an existing ID: got %v, want ErrAlertExists", err)
+    }
+    if got := mustGet(t, r, "a1"); !got.Timestamp.Equal(baseTime) {
+        t.Error("CreateAlert with an existing ID overwrote the stored alert")
+    }
+}
+
+func testCreateAlertsSkipsDuplicates(t *testing.T, r Repos) {
+    ctx := context.Background()
+    ids, err := r.Alerts.CreateAlerts(ctx, tenant, nil)
+    if err != nil || len(ids) != 0 {
+        t.Errorf("CreateAlerts(nil): got %v, %v, want no IDs and no error", ids, err)
+    }
+
+    createAll(t, r, newAlert("a1", 0))
+    ids, err = r.Alerts.CreateAlerts(ctx, tenant, []models.SecurityAlert{
+        newAlert("a2", 0), newAlert("a1", 0), newAlert("a3", 0), newAlert("a2", time.Hour),
+    })
+    if err != nil {
+        t.Fatalf("CreateAlerts: %v", err)
+    }
+    expectIDs(t, "inserted IDs", ids, "a2", "a3")
+    if got := mustGet(t, r, "a2"); !got.Timestamp.Equal(baseTime) {
+        t.Error("a duplicate later in the batch replaced the first alert with its ID")
+    }
+    expectIDs(t, "stored alerts", list(t, r, repository.AlertFilter{SortBy: repository.SortByTimestamp, SortAscending: true}), "a1", "a2", "a3")
+
+    // The duplicate was not stored, so it must not be published or streamed either.
+    expectIDs(t, "outbox", drain(t, r, 10, noBackoff, nil), "a1", "a2", "a3")
+    events, err := r.Events.GetAlertEventsAfter(ctx, 0, 100)
+    if err != nil {
+        t.Fatalf("GetAlertEventsAfter: %v", err)
+    }
+    var created []string
+    for _, e := range events {
+        created = append(created, e.Alert.ID)
+        if e.Alert.ID == "a2" && !e.Alert.Timestamp.Equal(baseTime) {
+            t.Errorf("alert.created event of a2 carries the duplicate: timestamp %v", e.Alert.Timestamp)
+        }
+    }
+    expectIDs(t, "alert.created events", created, "a1", "a2", "a3")
+}
+
+func testListOrdering(t *testing.T, r Repos) {
+    ctx := context.Background()
+    alerts := []models.SecurityAlert{
+        newAlert("a1", 2*time.Minute),
+        newAlert("a2", 1*time.Minute),
+        newAlert("a3", 3*time.Minute),
+        newAlert("a4", 3*time.Minute),
+    }
+    alerts[0].RiskScore = 0.5
+    alerts[1].RiskScore = 0.9
+    alerts[3].RiskScore = 0.5
+    if _, err := r.Alerts.CreateAlerts(ctx, tenant, alerts); err != nil {
+        t.Fatalf("CreateAlerts: %v", err)
+    }
+
+    // created_at is the same for the whole batch, so the ID decides.
+    expectIDs(t, "default order", list(t, r, repository.AlertFilter{}), "a4", "a3", "a2", "a1")
+    expectIDs(t, "timestamp desc", list(t, r, repository.AlertFilter{SortBy: repository.SortByTimestamp}), "a4", "a3", "a1", "a2")
+    expectIDs(t, "timestamp asc", list(t, r, repository.AlertFilter{SortBy: repository.SortByTimestamp, SortAscending: true}), "a2", "a1", "a3", "a4")
+    expectIDs(t, "risk desc", list(t, r, repository.AlertFilter{SortBy: repository.SortByRiskScore}), "a2", "a4", "a1", "a3")
+    expectIDs(t, "risk asc", list(t, r, repository.AlertFilter{SortBy: repository.SortByRiskScore, SortAscending: true}), "a3", "a1", "a4", "a2")
+
+    // Alerts created one after another are ordered by creation.
+    time.Sleep(2 * time.Millisecond)
+    createAll(t, r, newAlert("a0", 0))
+    expectIDs(t, "newest first", list(t, r, repository.AlertFilter{Limit: 1}), "a0")
+}
+
+func testListFilters(t *testing.T, r Repos) {
+    ctx := context.Background()
+    alerts := []models.SecurityAlert{
+        newAlert("a1", 0),
+        newAlert("a2", time.Hour),
+        newAlert("a3", 2*time.Hour),
+        newAlert("a4", 3*time.Hour),
+    }
+    alerts[1].Severity = "low"
+    alerts[1].SourceIP = "10.1.2.3"
+    alerts[2].SourceIP = "172.16.0.5"
+    alerts[2].TargetIP = "not-an-ip"
+    alerts[3].TargetIP = "999.1.1.1" // Looks like an address, but is none
+    alerts[3].Category = "phishing"
+    alerts[3].Username = "bob"
+    alerts[3].Hostname = "host-2"
+    alerts[3].Source = "email-gateway"
+    alerts[1].Attributes = map[string]string{"policy": "strict"}
+    alerts[2].RawPayload = json.RawMessage(`"CEF:0|Acme|IDS|1.0|100|Scan|5|"`)
+    alerts[3].RawPayload = json.RawMessage(`{"vendor":{"rule_id":42,"tags":["mail","spoof"]},"score":null,"hits":1e2,"ratio":1.50,"small":5E-3,"delta":-0.0,"flagged":true}`)
+    for i := range alerts {
+        alerts[i].RiskScore = float64(i+1) / 10
+        alerts[i].PredictedSeverity = "medium"
+    }
+    alerts[0].PredictedSeverity = "critical"
+    if _, err := r.Alerts.CreateAlerts(ctx, tenant, alerts); err != nil {
+        t.Fatalf("CreateAlerts: %v", err)
+    }
+    if err := r.Alerts.UpdateAlertStatus(ctx, tenant, "a3", models.StatusTriaged); err != nil {
+        t.Fatalf("UpdateAlertStatus: %v", err)
+    }
+
+    asc := func(f repository.AlertFilter) repository.AlertFilter {
+        f.SortBy, f.SortAscending = repository.SortByTimestamp, true
+        return f
+    }
+    minRisk, maxRisk := 0.2, 0.3
+    cases := []struct {
+        name   string
+        filter repository.AlertFilter
+        want   []string
+    }{
+        {"status", repository.AlertFilter{Status: models.StatusTriaged}, []string{"a3"}},
+        {"severity", repository.AlertFilter{Severity: "low"}, []string{"a2"}},
+        {"predicted severity", repository.AlertFilter{PredictedSeverity: "critical"}, []string{"a1"}},
+        {"category", repository.AlertFilter{Category: "phishing"}, []string{"a4"}},
+        {"source", repository.AlertFilter{Source: "email-gateway"}, []string{"a4"}},
+        {"hostname", repository.AlertFilter{Hostname: "host-2"}, []string{"a4"}},
+        {"username", repository.AlertFilter{Username: "bob"}, []string{"a4"}},
+        {"source ip", repository.AlertFilter{SourceIP: "10.0.0.1"}, []string{"a1", "a4"}},
+        {"source cidr", repository.AlertFilter{SourceIP: "10.0.0.0/8"}, []string{"a1", "a2", "a4"}},
+        {"source cidr with host bits", repository.AlertFilter{SourceIP: "10.0.0.1/8"}, []string{"a1", "a2", "a4"}},
+        {"target cidr skips non-addresses", repository.AlertFilter{TargetIP: "0.0.0.0/0"}, []string{"a1", "a2"}},
+        {"invalid cidr matches nothing", repository.AlertFilter{SourceIP: "10.0.0.0/99"}, nil},
+        {"min risk", repository.AlertFilter{MinRiskScore: &minRisk}, []string{"a2", "a3", "a4"}},
+        {"risk range", repository.AlertFilter{MinRiskScore: &minRisk, MaxRiskScore: &maxRisk}, []string{"a2", "a3"}},
+        {"since inclusive", repository.AlertFilter{Since: baseTime.Add(time.Hour)}, []string{"a2", "a3", "a4"}},
+        {"until exclusive", repository.AlertFilter{Until: baseTime.Add(2 * time.Hour)}, []string{"a1", "a2"}},
+        {"combined", repository.AlertFilter{Severity: "high", SourceIP: "10.0.0.0/8", Since: baseTime.Add(time.Minute)}, []string{"a4"}},
+        {"attribute", repository.AlertFilter{Attributes: map[string]string{"policy": "strict"}}, []string{"a2"}},
+        {"attribute no match", repository.AlertFilter{Attributes: map[string]string{"policy": "lax"}}, nil},
+        {"raw payload number", repository.AlertFilter{RawPayload: map[string]string{"vendor.rule_id": "42"}}, []string{"a4"}},
+        {"raw payload array", repository.AlertFilter{RawPayload: map[string]string{"vendor.tags.1": "spoof"}}, []string{"a4"}},
+        {"raw payload null", repository.AlertFilter{RawPayload: map[string]string{"score": "null"}}, nil},
+        {"raw payload boolean", repository.AlertFilter{RawPayload: map[string]string{"flagged": "true"}}, []string{"a4"}},
+        // Numbers are compared as Postgres prints a jsonb numeric, not as they were written.
+        {"raw payload exponent", repository.AlertFilter{RawPayload: map[string]string{"hits": "100"}}, []string{"a4"}},
+        {"raw payload exponent as written", repository.AlertFilter{RawPayload: map[string]string{"hits": "1e2"}}, nil},
+        {"raw payload trailing zero", repository.AlertFilter{RawPayload: map[string]string{"ratio": "1.50"}}, []string{"a4"}},
+        {"raw payload trailing zero dropped", repository.AlertFilter{RawPayload: map[string]string{"ratio": "1.5"}}, nil},
+        {"raw payload negative exponent", repository.AlertFilter{RawPayload: map[string]string{"small": "0.005"}}, []string{"a4"}},
+        {"raw payload negative zero", repository.AlertFilter{RawPayload: map[string]string{"delta": "0.0"}}, []string{"a4"}},
+        // Only scalars match, whatever the spacing of the wanted text.
+        {"raw payload object", repository.AlertFilter{RawPayload: map[string]string{"vendor": `{"rule_id":42,"tags":["mail","spoof"]}`}}, nil},
+        {"raw payload object spaced", repository.AlertFilter{RawPayload: map[string]string{"vendor": `{"tags": ["mail", "spoof"], "rule_id": 42}`}}, nil},
+        {"raw payload whole array", repository.AlertFilter{RawPayload: map[string]string{"vendor.tags": `["mail", "spoof"]`}}, nil},
+        {"raw payload index out of range", repository.AlertFilter{RawPayload: map[string]string{"vendor.tags.2": "spoof"}}, nil},
+        {"raw payload path into a string", repository.AlertFilter{RawPayload: map[string]string{"0": "CEF:0|Acme|IDS|1.0|100|Scan|5|"}}, nil},
+        {"no match", repository.AlertFilter{Severity: "high", Category: "nothing"}, nil},
+    }
+    for _, tc := range cases {
+        expectIDs(t, tc.name, list(t, r, asc(tc.filter)), tc.want...)
+    }
+}
+
+func testListPagination(t *testing.T, r Repos) {
+    ctx := context.Background()
+    var alerts []models.SecurityAlert
+    for i := 0; i < 7; i++ {
+        a := newAlert(fmt.Sprintf("a%d", i), time.Duration(i%3)*time.Minute) // Repeated timestamps test the tie-breaker
+        a.RiskScore = float64(i%2) / 2
+        alerts = append(alerts, a)
+    }
+    if _, err := r.Alerts.CreateAlerts(ctx, tenant, alerts); err != nil {
+        t.Fatalf("CreateAlerts: %v", err)
+    }
+
+    for _, sortBy := range []repository.AlertSortField{repository.SortByCreatedAt, repository.SortByTimestamp, repository.SortByRiskScore} {
+        for _, ascending := range []bool{false, true} {
+            base := repository.AlertFilter{SortBy: sortBy, SortAscending: ascending}
+            all := list(t, r, base)
+            if len(all) != len(alerts) {
+                t.Fatalf("%s: listed %d alerts, want %d", sortBy, len(all), len(alerts))
+            }
+
+            // Walk the listing three at a time with cursors.
+            var walked []string
+            f := base
+            f.Limit = 3
+            for page := 0; page < 5; page++ {
+                got, err := r.Alerts.GetAllAlerts(ctx, tenant, f)
+                if err != nil {
+                    t.Fatalf("GetAllAlerts: %v", err)
+                }
+                if len(got) == 0 {
+                    break
+                }
+                for _, a := range got {
+                    walked = append(walked, a.ID)
+                }
+                cursor := repository.CursorAfter(got[len(got)-1], f)
+                f.After = &cursor
+            }
+            expectIDs(t, fmt.Sprintf("keyset walk by %s (ascending=%t)", sortBy, ascending), walked, all...)
+
+            // Limit and offset select the same window.
+            f = base
+            f.Limit, f.Offset = 2, 3
+            expectIDs(t, fmt.Sprintf("offset by %s (ascending=%t)", sortBy, ascending), list(t, r, f), all[3:5]...)
+        }
+    }
+
+    f := repository.AlertFilter{Offset: 100}
+    expectIDs(t, "offset past the end", list(t, r, f))
+}
+
+func testUpdateAlertStatus(t *testing.T, r Repos) {
+    ctx := context.Background()
+    createAll(t, r, newAlert("a1", 0))
+    if err := r.Alerts.UpdateAlertStatus(ctx, tenant, "a1", models.StatusResolved); err != nil {
+        t.Fatalf("UpdateAlertStatus: %v", err)
+    }
+    if got := mustGet(t, r, "a1").Status; got != models.StatusResolved {
+        t.Errorf("status: got %s, want %s", got, models.StatusResolved)
+    }
+    if err := r.Alerts.UpdateAlertStatus(ctx, tenant, "missing", models.StatusResolved); !errors.Is(err, repository.ErrAlertNotFound) {
+        t.Errorf("UpdateAlertStatus(missing): got %v, want ErrAlertNotFound", err)
+    }
+}
+
+func testTransitionAlertStatus(t *testing.T, r Repos) {
+    ctx := context.Background()
+    createAll(t, r, newAlert("a1", 0))
+    if err := r.Alerts.TransitionAlertStatus(ctx, tenant, "a1", models.StatusNew, models.StatusAnalyzed); err != nil {
+        t.Fatalf("TransitionAlertStatus: %v", err)
+    }
+    if err := r.Alerts.TransitionAlertStatus(ctx, tenant, "a1", models.StatusNew, models.StatusAnalyzed); !errors.Is(err, repository.ErrStatusConflict) {
+        t.Errorf("TransitionAlertStatus from a stale status: got %v, want ErrStatusConflict", err)
+    }
+    if err := r.Alerts.TransitionAlertStatus(ctx, tenant, "missing", models.StatusNew, models.StatusAnalyzed); !errors.Is(err, repository.ErrAlertNotFound) {
+        t.Errorf("TransitionAlertStatus(missing): got %v, want ErrAlertNotFound", err)
+    }
+    if got := mustGet(t, r, "a1").Status; got != models.StatusAnalyzed {
+        t.Errorf("status: got %s, want %s", got, models.StatusAnalyzed)
+    }
+
+    // Alerts of another tenant do not exist for the caller, even with the right status.
+    if err := r.Alerts.TransitionAlertStatus(ctx, "other-tenant", "a1", models.StatusAnalyzed, models.StatusTriaged); !errors.Is(err, repository.ErrAlertNotFound) {
+        t.Errorf("TransitionAlertStatus(other tenant): got %v, want ErrAlertNotFound", err)
+    }
+    // A terminal alert cannot be moved by a caller still holding an earlier status.
+    if err := r.Alerts.UpdateAlertStatus(ctx, tenant, "a1", models.StatusResolved); err != nil {
+        t.Fatalf("UpdateAlertStatus: %v", err)
+    }
+    if err := r.Alerts.TransitionAlertStatus(ctx, tenant, "a1", models.StatusInvestigating, models.StatusFalsePositive); !errors.Is(err, repository.ErrStatusConflict) {
+        t.Errorf("TransitionAlertStatus from a resolved alert: got %v, want ErrStatusConflict", err)
+    }
+    if got := mustGet(t, r, "a1").Status; got != models.StatusResolved {
+        t.Errorf("status: got %s, want %s", got, models.StatusResolved)
+    }
+}
+
+func testUpdateAlertWithAIResults(t *testing.T, r Repos) {
+    ctx := context.Background()
+    createAll(t, r, newAlert("a1", 0), newAlert("a2", 0))
+
+    analyzed := newAlert("a1", 0)
+    analyzed.Status = models.StatusAnalyzed
+    analyzed.PredictedSeverity = "critical"
+    analyzed.RiskScore = 0.87
+    analyzed.RecommendedAction = "Isolate host"
+    analyzed.AIModelVersion = "test:1"
+    if err := r.Alerts.UpdateAlertWithAIResults(ctx, tenant, &analyzed); err != nil {
+        t.Fatalf("UpdateAlertWithAIResults: %v", err)
+    }
+    got := mustGet(t, r, "a1")
+    if got.Status != models.StatusAnalyzed || got.PredictedSeverity != "critical" || got.RiskScore != 0.87 ||
+        got.RecommendedAction != "Isolate host" || got.AIModelVersion != "test:1" {
+        t.Errorf("after UpdateAlertWithAIResults: got %+v", *got)
+    }
+
+    // Re-analysing an alert an analyst is working on must not reset its status.
+    if err := r.Alerts.UpdateAlertStatus(ctx, tenant, "a2", models.StatusInvestigating); err != nil {
+        t.Fatalf("UpdateAlertStatus: %v", err)
+    }
+    analyzed.ID = "a2"
+    analyzed.RiskScore = 0.5
+    if err := r.Alerts.UpdateAlertWithAIResults(ctx, tenant, &analyzed); err != nil {
+        t.Fatalf("UpdateAlertWithAIResults: %v", err)
+    }
+    got = mustGet(t, r, "a2")
+    if got.Status != models.StatusInvestigating || got.RiskScore != 0.5 {
+        t.Errorf("re-analysed alert: got status %s and risk %v, want %s and 0.5", got.Status, got.RiskScore, models.StatusInvestigating)
+    }
+
+    analyzed.ID = "missing"
+    if err := r.Alerts.UpdateAlertWithAIResults(ctx, tenant, &analyzed); !errors.Is(err, repository.ErrAlertNotFound) {
+        t.Errorf("UpdateAlertWithAIResults(missing): got %v, want ErrAlertNotFound", err)
+    }
+}
+
+func testSigmaMatches(t *testing.T, r Repos) {
+    ctx := context.Background()
+    createAll(t, r, newAlert("a1", 0), newAlert("a2", time.Minute))
+
+    matches := []models.SigmaMatch{
+        {RuleID: "rule-1", Title: "Rule one", Level: "high"},
+        {RuleID: "rule-2", Title: "Rule two", Level: "low"},
+    }
+    analyzed := newAlert("a1", 0)
+    analyzed.Status = models.StatusAnalyzed
+    analyzed.SigmaMatches = matches
+    if err := r.Alerts.UpdateAlertWithAIResults(ctx, tenant, &analyzed); err != nil {
+        t.Fatalf("UpdateAlertWithAIResults: %v", err)
+    }
+    if got := mustGet(t, r, "a1").SigmaMatches; !reflect.DeepEqual(got, matches) {
+        t.Errorf("SigmaMatches: got %+v, want %+v", got, matches)
+    }
+    if got := mustGet(t, r, "a2").SigmaMatches; got != nil {
+        t.Errorf("SigmaMatches of an alert without matches: got %+v, want nil", got)
+    }
+
+    expectIDs(t, "sigma_rule=rule-2", list(t, r, repository.AlertFilter{SigmaRule: "rule-2"}), "a1")
+    expectIDs(t, "sigma_rule=rule-3", list(t, r, repository.AlertFilter{SigmaRule: "rule-3"}))
+
+    // Re-analysis replaces the matches
+    analyzed.SigmaMatches = nil
+    if err := r.Alerts.UpdateAlertWithAIResults(ctx, tenant, &analyzed); err != nil {
+        t.Fatalf("UpdateAlertWithAIResults: %v", err)
+    }
+    if got := mustGet(t, r, "a1").SigmaMatches; got != nil {
+        t.Errorf("SigmaMatches after re-analysis without matches: got %+v, want nil", got)
+    }
+}
+
+// drain runs DrainOutbox and returns the IDs of the alerts it passed to publish.
+func drain(t *testing.T, r Repos, limit int, backoff repository.OutboxBackoff, publishErr error) []string {
+    t.Helper()
+    var ids []string
+    n, err := r.Outbox.DrainOutbox(context.Background(), limit, backoff, func(ctx context.Context, msgs []models.OutboxMessage) error {
+        for _, m := range msgs {
+            var alert models.SecurityAlert
+            if err := json.Unmarshal(m.Payload, &alert); err != nil {
+                t.Errorf("outbox payload of %s is not an alert: %v", m.AlertID, err)
+            }
+            if alert.ID != m.AlertID || string(m.Key) != m.AlertID {
+                t.Errorf("outbox message for %s has payload ID %q and key %q", m.AlertID, alert.ID, m.Key)
+            }
+            if m.TenantID == "" || m.TenantID != alert.TenantID {
+                t.Errorf("outbox message for %s has tenant %q, its payload %q", m.AlertID, m.TenantID, alert.TenantID)
+            }
+            if alert.RawPayload != nil {
+                t.Errorf("outbox message for %s carries the raw payload", m.AlertID)
+            }
+            ids = append(ids, m.AlertID)
+        }
+        return publishErr
+    })
+    if err != nil {
+        t.Fatalf("DrainOutbox: %v", err)
+    }
+    if n != len(ids) {
+        t.Errorf("DrainOutbox returned %d, but published %d messages", n, len(ids))
+    }
+    return ids
+}
+
+var noBackoff = repository.OutboxBackoff{}
+
+func testOutboxDrain(t *testing.T, r Repos) {
+    withRaw := newAlert("a1", 0)
+    withRaw.RawPayload = json.RawMessage(`{"event":"kept in the row, not in the message"}`)
+    createAll(t, r, withRaw, newAlert("a2", 0), newAlert("a3", 0))
+
+    expectIDs(t, "first drain", drain(t, r, 2, noBackoff, nil), "a1", "a2")
+    expectIDs(t, "second drain", drain(t, r, 2, noBackoff, nil), "a3")
+    expectIDs(t, "drained outbox", drain(t, r, 2, noBackoff, nil))
+
+    purged, err := r.Outbox.PurgeSentOutbox(context.Background(), time.Now().Add(-time.Hour))
+    if err != nil || purged != 0 {
+        t.Errorf("PurgeSentOutbox(an hour ago): got %d, %v, want 0, nil", purged, err)
+    }
+    purged, err = r.Outbox.PurgeSentOutbox(context.Background(), time.Now().Add(time.Hour))
+    if err != nil || purged != 3 {
+        t.Errorf("PurgeSentOutbox(in an hour): got %d, %v, want 3, nil", purged, err)
+    }
+}
+
+func testOutboxRetry(t *testing.T, r Repos) {
+    createAll(t, r, newAlert("a1", 0))
+    failure := errors.New("broker unavailable")
+
+    // A failed message is rescheduled according to the backoff...
+    expectIDs(t, "failed drain", drain(t, r, 10, repository.OutboxBackoff{Initial: time.Hour, Max: time.Hour}, failure), "a1")
+    expectIDs(t, "drain during backoff", drain(t, r, 10, noBackoff, nil))
+
+    // ...and retried with its attempt count once it is due.
+    createAll(t, r, newAlert("a2", 0))
+    expectIDs(t, "failed drain without backoff", drain(t, r, 10, noBackoff, failure), "a2")
+    var attempts int
+    _, err := r.Outbox.DrainOutbox(context.Background(), 10, noBackoff, func(ctx context.Context, msgs []models.OutboxMessage) error {
+        for _, m := range msgs {
+            attempts = m.Attempts
+        }
+        return nil
+    })
+    if err != nil {
+        t.Fatalf("DrainOutbox: %v", err)
+    }
+    if attempts != 1 {
+        t.Errorf("retried message: got %d attempts, want 1", attempts)
+    }
+
+    purged, err := r.Outbox.PurgeSentOutbox(context.Background(), time.Now().Add(time.Hour))
+    if err != nil || purged != 1 {
+        t.Errorf("PurgeSentOutbox: got %d, %v, want 1, nil (the pending message must be kept)", purged, err)
+    }
+}
+
+func testOutboxPartialFailure(t *testing.T, r Repos) {
+    createAll(t, r, newAlert("a1", 0), newAlert("a2", 0), newAlert("a3", 0))
+    failure := errors.New("message too large")
+
+    // Only the message that failed is retried, with its own error counted.
+    _, err := r.Outbox.DrainOutbox(context.Background(), 10, noBackoff, func(ctx context.Context, msgs []models.OutboxMessage) error {
+        results := make(repository.OutboxErrors, len(msgs))
+        for i, m := range msgs {
+            if m.AlertID == "a2" {
+                results[i] = failure
+            }
+        }
+        return results
+    })
+    if err != nil {
+        t.Fatalf("DrainOutbox: %v", err)
+    }
+    var attempts []int
+    _, err = r.Outbox.DrainOutbox(context.Background(), 10, noBackoff, func(ctx context.Context, msgs []models.OutboxMessage) error {
+        for _, m := range msgs {
+            if m.AlertID != "a2" {
+                t.Errorf("published message %s was claimed again", m.AlertID)
+            }
+            attempts = append(attempts, m.Attempts)
+        }
+        return nil
+    })
+    if err != nil {
+        t.Fatalf("DrainOutbox: %v", err)
+    }
+    if len(attempts) != 1 || attempts[0] != 1 {
+        t.Errorf("retried messages: got attempts %v, want [1]", attempts)
+    }
+}
+
+func testOutboxParking(t *testing.T, r Repos) {
+    createAll(t, r, newAlert("a1", 0))
+    failure := errors.New("message too large")
+    parkAfterTwo := repository.OutboxBackoff{MaxAttempts: 2}
+
+    expectIDs(t, "first attempt", drain(t, r, 10, parkAfterTwo, failure), "a1")
+    expectIDs(t, "second attempt", drain(t, r, 10, parkAfterTwo, failure), "a1")
+    // The message has used up its attempts and is no longer claimed...
+    createAll(t, r, newAlert("a2", 0))
+    expectIDs(t, "after parking", drain(t, r, 10, parkAfterTwo, nil), "a2")
+
+    // ...but kept: only sent messages are purged.
+    purged, err := r.Outbox.PurgeSentOutbox(context.Background(), time.Now().Add(time.Hour))
+    if err != nil || purged != 1 {
+        t.Errorf("PurgeSentOutbox: got %d, %v, want 1, nil (the parked message must be kept)", purged, err)
+    }
+}
+
+func testOutboxSkipsDuplicates(t *testing.T, r Repos) {
+    createAll(t, r, newAlert("a1", 0))
+    dup := newAlert("a1", 0)
+    r.Alerts.CreateAlert(context.Background(), tenant, &dup) // Fails, see testCreateDuplicate
+    if _, err := r.Alerts.CreateAlerts(context.Background(), tenant, []models.SecurityAlert{newAlert("a1", 0), newAlert("a2", 0)}); err != nil {
+        t.Fatalf("CreateAlerts: %v", err)
+    }
+    expectIDs(t, "outbox", drain(t, r, 10, noBackoff, nil), "a1", "a2")
+}
+
+func testEnrichment(t *testing.T, r Repos) {
+    ctx := context.Background()
+    createAll(t, r, newAlert("a1", 0))
+
+    enrichment := &models.Enrichment{ThreatIntel: []models.ThreatIntelMatch{
+        {Field: "source_ip", Value: "10.0.0.1", Indicator: "10.0.0.0/8", Type: models.IndicatorCIDR, Feed: "test.txt", Description: "botnet"},
+    }}
+    analyzed := newAlert("a1", 0)
+    analyzed.Enrichment = enrichment
+    if err := r.Alerts.UpdateAlertWithAIResults(ctx, tenant, &analyzed); err != nil {
+        t.Fatalf("UpdateAlertWithAIResults: %v", err)
+    }
+    if got := mustGet(t, r, "a1").Enrichment; !reflect.DeepEqual(got, enrichment) {
+        t.Errorf("Enrichment: got %+v, want %+v", got, enrichment)
+    }
+
+    analyzed.Enrichment = &models.Enrichment{}
+    if err := r.Alerts.UpdateAlertWithAIResults(ctx, tenant, &analyzed); err != nil {
+        t.Fatalf("UpdateAlertWithAIResults: %v", err)
+    }
+    if got := mustGet(t, r, "a1").Enrichment; got != nil {
+        t.Errorf("empty Enrichment: got %+v, want nil", got)
+    }
+}
+
+func testGeoFilters(t *testing.T, r Repos) {
+    ctx := context.Background()
+    createAll(t, r, newAlert("a1", 0), newAlert("a2", time.Minute), newAlert("a3", 2*time.Minute))
+
+    geo := map[string]*models.Enrichment{
+        "a1": {SourceGeo: &models.GeoInfo{IP: "10.0.0.1", Country: "US", ASN: 15169, ASOrg: "GOOGLE"}},
+        "a2": {
+            SourceGeo: &models.GeoInfo{IP: "10.0.0.1", Country: "GB", ASN: 2856},
+            TargetGeo: &models.GeoInfo{IP: "192.168.1.10", Private: true},
+        },
+    }
+    for id, enrichment := range geo {
+        analyzed := newAlert(id, 0)
+        analyzed.Enrichment = enrichment
+        if err := r.Alerts.UpdateAlertWithAIResults(ctx, tenant, &analyzed); err != nil {
+            t.Fatalf("UpdateAlertWithAIResults(%s): %v", id, err)
+        }
+    }
+    if got := mustGet(t, r, "a2").Enrichment; !reflect.DeepEqual(got, geo["a2"]) {
+        t.Errorf("Enrichment: got %+v, want %+v", got, geo["a2"])
+    }
+
+    expectIDs(t, "source_country=US", list(t, r, repository.AlertFilter{SourceCountry: "US", Limit: 10}), "a1")
+    expectIDs(t, "source_country=gb", list(t, r, repository.AlertFilter{SourceCountry: "gb", Limit: 10}), "a2")
+    expectIDs(t, "source_asn=15169", list(t, r, repository.AlertFilter{SourceASN: 15169, Limit: 10}), "a1")
+    expectIDs(t, "source_country=US&source_asn=2856", list(t, r, repository.AlertFilter{SourceCountry: "US", SourceASN: 2856, Limit: 10}))
+    expectIDs(t, "target_country=US", list(t, r, repository.AlertFilter{TargetCountry: "US", Limit: 10}))
+}
+
+func testAlertEvents(t *testing.T, r Repos) {
+    ctx := context.Background()
+    if id, err := r.Events.LatestAlertEventID(ctx); err != nil || id != 0 {
+        t.Fatalf("LatestAlertEventID on empty log: got %d, %v, want 0", id, err)
+    }
+
+    createAll(t, r, newAlert("a1", 0))
+    if _, err := r.Alerts.CreateAlerts(ctx, tenant, []models.SecurityAlert{newAlert("a2", 0), newAlert("a1", 0)}); err != nil {
+        t.Fatalf("CreateAlerts: %v", err)
+    }
+    fold(t, r, "a3", "fp", 0)
+    fold(t, r, "a4", "fp", time.Minute) // Folded into a3, no event
+    analyzed := newAlert("a1", 0)
+    analyzed.Status = models.StatusAnalyzed
+    analyzed.RiskScore = 0.9
+    if err := r.Alerts.UpdateAlertWithAIResults(ctx, tenant, &analyzed); err != nil {
+        t.Fatalf("UpdateAlertWithAIResults: %v", err)
+    }
+
+    events, err := r.Events.GetAlertEventsAfter(ctx, 0, 100)
+    if err != nil {
+        t.Fatalf("GetAlertEventsAfter: %v", err)
+    }
+    var got []string
+    for i, e := range events {
+        got = append(got, string(e.Type)+" "+e.Alert.ID)
+        if i > 0 && e.ID <= events[i-1].ID {
+            t.Errorf("event IDs not increasing: %d after %d", e.ID, events[i-1].ID)
+        }
+    }
+    expectIDs(t, "events", got, "alert.created a1", "alert.created a2", "alert.created a3", "alert.analyzed a1")
+    if len(events) != 4 {
+        t.FailNow()
+    }
+
+    stored := mustGet(t, r, "a1")
+    if created := events[0].Alert; created.Status != models.StatusNew || created.RiskScore != 0 || !created.CreatedAt.Equal(stored.CreatedAt) {
+        t.Errorf("alert.created snapshot: got %+v", created)
+    }
+    if updated := events[3].Alert; updated.Status != models.StatusAnalyzed || updated.RiskScore != 0.9 || updated.Title != stored.Title {
+        t.Errorf("alert.analyzed snapshot: got %+v", updated)
+    }
+
+    if id, err := r.Events.LatestAlertEventID(ctx); err != nil || id != events[3].ID {
+        t.Errorf("LatestAlertEventID: got %d, %v, want %d", id, err, events[3].ID)
+    }
+    page, err := r.Events.GetAlertEventsAfter(ctx, events[1].ID, 1)
+    if err != nil || len(page) != 1 || page[0].ID != events[2].ID {
+        t.Errorf("GetAlertEventsAfter(%d, 1): got %+v, %v, want event %d", events[1].ID, page, err, events[2].ID)
+    }
+
+    if purged, err := r.Events.PurgeAlertEvents(ctx, time.Now().Add(-time.Hour)); err != nil || purged != 0 {
+        t.Errorf("PurgeAlertEvents(an hour ago): got %d, %v, want 0", purged, err)
+    }
+    if purged, err := r.Events.PurgeAlertEvents(ctx, time.Now().Add(time.Hour)); err != nil || purged != 4 {
+        t.Errorf("PurgeAlertEvents(in an hour): got %d, %v, want 4", purged, err)
+    }
+    if events, err := r.Events.GetAlertEventsAfter(ctx, 0, 100); err != nil || len(events) != 0 {
+        t.Errorf("GetAlertEventsAfter after purge: got %d events, %v", len(events), err)
+    }
+}
+
+func testAPIKeys(t *testing.T, r Repos) {
+    ctx := context.Background()
+    expires := baseTime.Add(24 * time.Hour)
+    keys := []models.APIKey{
+        {ID: "k1", Name: "sensor", Hash: []byte{1, 2, 3}, Roles: []string{"ingest"}, CreatedBy: "admin"},
+        {ID: "k2", Name: "script", TenantID: tenant, Hash: []byte{4, 5, 6}, Roles: []string{"analyst", "ingest"}, ExpiresAt: &expires},
+    }
+    for i := range keys {
+        if err := r.APIKeys.CreateAPIKey(ctx, &keys[i]); err != nil {
+            t.Fatalf("CreateAPIKey(%s): %v", keys[i].ID, err)
+        }
+        if keys[i].CreatedAt.IsZero() {
+            t.Errorf("CreateAPIKey(%s) did not set CreatedAt", keys[i].ID)
+        }
+    }
+    if err := r.APIKeys.CreateAPIKey(ctx, &models.APIKey{ID: "k1", Name: "again", Hash: []byte{0}, Roles: []string{}}); err == nil {
+        t.Errorf("CreateAPIKey with a duplicate ID: got nil error")
+    }
+
+    got, err := r.APIKeys.GetAPIKeyByID(ctx, "k2")
+    if err != nil || got == nil {
+        t.Fatalf("GetAPIKeyByID(k2): got %v, %v", got, err)
+    }
+    if got.Name != "script" || got.TenantID != tenant || !reflect.DeepEqual(got.Hash, []byte{4, 5, 6}) || !reflect.DeepEqual(got.Roles, []string{"analyst", "ingest"}) ||
+        got.ExpiresAt == nil || !got.ExpiresAt.Equal(expires) || got.LastUsedAt != nil || got.RevokedAt != nil {
+        t.Errorf("GetAPIKeyByID(k2): got %+v", *got)
+    }
+    if got, err := r.APIKeys.GetAPIKeyByID(ctx, "missing"); got != nil || err != nil {
+        t.Errorf("GetAPIKeyByID(missing): got %v, %v, want nil, nil", got, err)
+    }
+
+    usedAt := time.Now().Truncate(time.Second)
+    for _, at := range []time.Time{usedAt, usedAt.Add(-time.Minute)} { // The older use is ignored
+        if err := r.APIKeys.TouchAPIKey(ctx, "k1", at); err != nil {
+            t.Fatalf("TouchAPIKey: %v", err)
+        }
+    }
+    if got, _ := r.APIKeys.GetAPIKeyByID(ctx, "k1"); got.LastUsedAt == nil || !got.LastUsedAt.Equal(usedAt) {
+        t.Errorf("LastUsedAt: got %v, want %v", got.LastUsedAt, usedAt)
+    }
+
+    if err := r.APIKeys.RevokeAPIKey(ctx, "k1"); err != nil {
+        t.Fatalf("RevokeAPIKey: %v", err)
+    }
+    revoked, _ := r.APIKeys.GetAPIKeyByID(ctx, "k1")
+    if revoked.RevokedAt == nil || revoked.IsActive(time.Now()) {
+        t.Fatalf("revoked key: got %+v", *revoked)
+    }
+    if err := r.APIKeys.RevokeAPIKey(ctx, "k1"); err != nil {
+        t.Fatalf("RevokeAPIKey again: %v", err)
+    }
+    if again, _ := r.APIKeys.GetAPIKeyByID(ctx, "k1"); !again.RevokedAt.Equal(*revoked.RevokedAt) {
+        t.Errorf("revoking twice moved RevokedAt from %v to %v", revoked.RevokedAt, again.RevokedAt)
+    }
+    if err := r.APIKeys.RevokeAPIKey(ctx, "missing"); !errors.Is(err, repository.ErrAPIKeyNotFound) {
+        t.Errorf("RevokeAPIKey(missing): got %v, want ErrAPIKeyNotFound", err)
+    }
+
+    all, err := r.APIKeys.GetAllAPIKeys(ctx)
+    if err != nil {
+        t.Fatalf("GetAllAPIKeys: %v", err)
+    }
+    var ids []string
+    for _, k := range all {
+        ids = append(ids, k.ID)
+    }
+    expectIDs(t, "GetAllAPIKeys", ids, "k2", "k1")
+}
+
+// fold runs CreateOrFoldAlert for a new alert with the given ID, fingerprint and offset.
+func fold(t *testing.T, r Repos, id, fingerprint string, offset time.Duration, escalateAt ...int) *repository.FoldResult {
+    t.Helper()
+    alert := newAlert(id, offset)
+    alert.Fingerprint = fingerprint
+    res, err := r.Alerts.CreateOrFoldAlert(context.Background(), tenant, &alert, 10*time.Minute, escalateAt)
+    if err != nil {
+        t.Fatalf("CreateOrFoldAlert(%s): %v", id, err)
+    }
+    return res
+}
+
+func testFoldWithinWindow(t *testing.T, r Repos) {
+    if res := fold(t, r, "a1", "fp-1", 0); res.Folded || res.AlertID != "a1" || res.OccurrenceCount != 1 {
+        t.Errorf("first occurrence: got %+v", *res)
+    }
+    if res := fold(t, r, "a2", "fp-1", 5*time.Minute); !res.Folded || res.AlertID != "a1" || res.OccurrenceCount != 2 {
+        t.Errorf("repeat within the window: got %+v", *res)
+    }
+    // The window runs from the last occurrence, so a steady stream keeps folding.
+    if res := fold(t, r, "a3", "fp-1", 14*time.Minute); !res.Folded || res.AlertID != "a1" || res.OccurrenceCount != 3 {
+        t.Errorf("repeat within the window of the last occurrence: got %+v", *res)
+    }
+    if res := fold(t, r, "a4", "fp-2", 14*time.Minute); res.Folded {
+        t.Errorf("other fingerprint: got %+v, want a new alert", *res)
+    }
+    if res := fold(t, r, "a5", "fp-1", time.Hour); res.Folded || res.AlertID != "a5" {
+        t.Errorf("repeat after the window: got %+v, want a new alert", *res)
+    }
+    if res := fold(t, r, "a6", "", 0); res.Folded || res.AlertID != "a6" {
+        t.Errorf("alert without fingerprint: got %+v, want a new alert", *res)
+    }
+
+    got := mustGet(t, r, "a1")
+    if got.OccurrenceCount != 3 || !got.LastSeen.Equal(baseTime.Add(14*time.Minute)) || got.Fingerprint != "fp-1" {
+        t.Errorf("folded alert: got count %d, last seen %v, fingerprint %q", got.OccurrenceCount, got.LastSeen, got.Fingerprint)
+    }
+    if alert, _ := r.Alerts.GetAlertByID(context.Background(), tenant, "a2"); alert != nil {
+        t.Error("a folded repeat was stored as an alert of its own")
+    }
+
+    // Only first occurrences were queued for processing.
+    expectIDs(t, "outbox", drain(t, r, 10, noBackoff, nil), "a1", "a4", "a5", "a6")
+}
+
+func testFoldBatch(t *testing.T, r Repos) {
+    fold(t, r, "a1", "fp-1", 0)
+
+    batch := []models.SecurityAlert{
+        newAlert("a2", time.Minute),   // Repeat of a1
+        newAlert("a3", 2*time.Minute), // First of fp-2
+        newAlert("a4", 3*time.Minute), // Repeat of a3, from the same batch
+        newAlert("a1", 4*time.Minute), // Existing ID
+        newAlert("a5", 5*time.Minute), // No fingerprint
+    }
+    for i, fp := range []string{"fp-1", "fp-2", "fp-2", "", ""} {
+        batch[i].Fingerprint = fp
+    }
+    results, err := r.Alerts.CreateOrFoldAlerts(context.Background(), tenant, batch, 10*time.Minute, nil)
+    if err != nil {
+        t.Fatalf("CreateOrFoldAlerts: %v", err)
+    }
+    want := []*repository.FoldResult{
+        {AlertID: "a1", Folded: true, OccurrenceCount: 2},
+        {AlertID: "a3", OccurrenceCount: 1},
+        {AlertID: "a3", Folded: true, OccurrenceCount: 2},
+        nil,
+        {AlertID: "a5", OccurrenceCount: 1},
+    }
+    if !reflect.DeepEqual(results, want) {
+        t.Errorf("CreateOrFoldAlerts: got %s, want %s", foldResults(results), foldResults(want))
+    }
+    if batch[1].TenantID != tenant {
+        t.Errorf("CreateOrFoldAlerts did not set the tenant: got %q", batch[1].TenantID)
+    }
+
+    expectIDs(t, "alerts", list(t, r, repository.AlertFilter{SortBy: repository.SortByTimestamp, SortAscending: true}), "a1", "a3", "a5")
+    if got := mustGet(t, r, "a1"); !got.Timestamp.Equal(baseTime) || got.OccurrenceCount != 2 {
+        t.Errorf("a1 after the batch: got timestamp %v, count %d", got.Timestamp, got.OccurrenceCount)
+    }
+    expectIDs(t, "outbox", drain(t, r, 10, noBackoff, nil), "a1", "a3", "a5")
+}
+
+func testFoldBatchEscalation(t *testing.T, r Repos) {
+    var batch []models.SecurityAlert
+    for i, offset := range []time.Duration{0, time.Minute, 2 * time.Minute, 3 * time.Minute, 4 * time.Minute, 30 * time.Minute} {
+        alert := newAlert(fmt.Sprintf("a%d", i+1), offset)
+        alert.Fingerprint = "fp-1"
+        batch = append(batch, alert)
+    }
+    dup := newAlert("a6", 31*time.Minute) // Same ID as an earlier alert of the batch
+    dup.Fingerprint = "fp-2"
+    batch = append(batch, dup)
+
+    results, err := r.Alerts.CreateOrFoldAlerts(context.Background(), tenant, batch, 10*time.Minute, []int{3, 5})
+    if err != nil {
+        t.Fatalf("CreateOrFoldAlerts: %v", err)
+    }
+    want := []*repository.FoldResult{
+        {AlertID: "a1", OccurrenceCount: 1},
+        {AlertID: "a1", Folded: true, OccurrenceCount: 2},
+        {AlertID: "a1", Folded: true, OccurrenceCount: 3, Escalated: true},
+        {AlertID: "a1", Folded: true, OccurrenceCount: 4},
+        {AlertID: "a1", Folded: true, OccurrenceCount: 5, Escalated: true},
+        {AlertID: "a6", OccurrenceCount: 1}, // Outside the window of the last repeat
+        nil,
+    }
+    if !reflect.DeepEqual(results, want) {
+        t.Errorf("CreateOrFoldAlerts: got %s, want %s", foldResults(results), foldResults(want))
+    }
+    if got := mustGet(t, r, "a1"); got.OccurrenceCount != 5 || !got.LastSeen.Equal(baseTime.Add(4*time.Minute)) {
+        t.Errorf("a1 after the batch: got count %d, last seen %v", got.OccurrenceCount, got.LastSeen)
+    }
+    if got := mustGet(t, r, "a6"); got.Fingerprint != "fp-1" {
+        t.Errorf("a6 after the batch: got fingerprint %q, want the first a6 of the batch", got.Fingerprint)
+    }
+
+    // New alerts are queued once, escalated ones again with the count they reached.
+    counts := make(map[string][]int)
+    _, err = r.Outbox.DrainOutbox(context.Background(), 10, noBackoff, func(ctx context.Context, msgs []models.OutboxMessage) error {
+        for _, m := range msgs {
+            var alert models.SecurityAlert
+            if err := json.Unmarshal(m.Payload, &alert); err != nil {
+                t.Fatalf("outbox payload is not an alert: %v", err)
+            }
+            counts[alert.ID] = append(counts[alert.ID], alert.OccurrenceCount)
+        }
+        return nil
+    })
+    if err != nil {
+        t.Fatalf("DrainOutbox: %v", err)
+    }
+    for _, c := range counts {
+        sort.Ints(c)
+    }
+    if wantCounts := map[string][]int{"a1": {1, 3, 5}, "a6": {1}}; !reflect.DeepEqual(counts, wantCounts) {
+        t.Errorf("outbox occurrence counts: got %v, want %v", counts, wantCounts)
+    }
+}
+
+// foldResults formats fold results for test failures.
+func foldResults(results []*repository.FoldResult) string {
+    parts := make([]string, len(results))
+    for i, res := range results {
+        parts[i] = "<nil>"
+        if res != nil {
+            parts[i] = fmt.Sprintf("%+v", *res)
+        }
+    }
+    return "[" + strings.Join(parts, " ") + "]"
+}
+
+func testFoldEscalation(t *testing.T, r Repos) {
+    var escalated []int
+    for i := 1; i <= 5; i++ {
+        res := fold(t, r, fmt.Sprintf("a%d", i), "fp-1", time.Duration(i)*time.Second, 3, 5)
+        if res.Escalated {
+            escalated = append(escalated, res.OccurrenceCount)
+        }
+    }
+    if !reflect.DeepEqual(escalated, []int{3, 5}) {
+        t.Errorf("escalated at counts %v, want [3 5]", escalated)
+    }
+
+    // The first occurrence plus one message per escalation, each carrying the count.
+    var counts []int
+    _, err := r.Outbox.DrainOutbox(context.Background(), 10, noBackoff, func(ctx context.Context, msgs []models.OutboxMessage) error {
+        for _, m := range msgs {
+            var alert models.SecurityAlert
+            if err := json.Unmarshal(m.Payload, &alert); err != nil {
+                t.Fatalf("outbox payload is not an alert: %v", err)
+            }
+            if alert.ID != "a1" {
+                t.Errorf("outbox message for %s, want a1", alert.ID)
+            }
+            counts = append(counts, alert.OccurrenceCount)
+        }
+        return nil
+    })
+    if err != nil {
+        t.Fatalf("DrainOutbox: %v", err)
+    }
+    if !reflect.DeepEqual(counts, []int{1, 3, 5}) {
+        t.Errorf("outbox occurrence counts: got %v, want [1 3 5]", counts)
+    }
+}
+
+// attach stores a new alert with the given entities and attaches it to an incident,
+// looking back window from the alert's timestamp. It returns the incident and whether
+// it was created.
+func attach(t *testing.T, r Repos, alert models.SecurityAlert, window time.Duration, entities ...string) (*models.Incident, bool) {
+    t.Helper()
+    createAll(t, r, alert)
+    var es []models.IncidentEntity
+    for i := 0; i+1 < len(entities); i += 2 {
+        es = append(es, models.IncidentEntity{Kind: entities[i], Value: entities[i+1], LastSeen: alert.Timestamp})
+    }
+    incident, created, err := r.Incidents.AttachAlert(context.Background(), alert, es, alert.Timestamp.Add(-window), "incident-"+alert.ID)
+    if err != nil {
+        t.Fatalf("AttachAlert(%s): %v", alert.ID, err)
+    }
+    return incident, created
+}
+
+func mustGetIncident(t *testing.T, r Repos, id string) *models.Incident {
+    t.Helper()
+    incident, err := r.Incidents.GetIncidentByID(context.Background(), tenant, id)
+    if err != nil {
+        t.Fatalf("GetIncidentByID(%s): %v", id, err)
+    }
+    if incident == nil {
+        t.Fatalf("GetIncidentByID(%s): not found", id)
+    }
+    return incident
+}
+
+func testIncidentCorrelation(t *testing.T, r Repos) {
+    a1 := newAlert("a1", 0)
+    a1.Severity, a1.RiskScore = "medium", 0.5
+    incident, created := attach(t, r, a1, time.Hour, models.EntityHostname, "host-1", models.EntityUsername, "alice")
+    if !created || incident.ID != "incident-a1" || incident.Status != models.IncidentOpen {
+        t.Fatalf("first alert: got %+v, created %v, want a new open incident", *incident, created)
+    }
+
+    // Shares only the user with a1
+    a2 := newAlert("a2", 10*time.Minute)
+    a2.Severity, a2.RiskScore = "high", 0.5
+    incident, created = attach(t, r, a2, time.Hour, models.EntityHostname, "host-2", models.EntityUsername, "alice")
+    if created || incident.ID != "incident-a1" {
+        t.Fatalf("alert sharing a user: got incident %s, created %v, want incident-a1", incident.ID, created)
+    }
+    if incident.AlertCount != 2 || incident.RiskScore != 0.75 || incident.Severity != "high" {
+        t.Errorf("aggregates: got %d alerts, risk %v, severity %q, want 2, 0.75, high", incident.AlertCount, incident.RiskScore, incident.Severity)
+    }
+    if !incident.FirstSeen.Equal(a1.Timestamp) || !incident.LastSeen.Equal(a2.Timestamp) {
+        t.Errorf("first/last seen: got %v/%v", incident.FirstSeen, incident.LastSeen)
+    }
+
+    // Shares nothing
+    if incident, created := attach(t, r, newAlert("a3", 20*time.Minute), time.Hour, models.EntityHostname, "host-3"); !created || incident.ID != "incident-a3" {
+        t.Errorf("unrelated alert: got incident %s, created %v, want a new incident", incident.ID, created)
+    }
+
+    got := mustGetIncident(t, r, "incident-a1")
+    var alertIDs []string
+    for _, a := range got.Alerts {
+        alertIDs = append(alertIDs, a.ID)
+    }
+    expectIDs(t, "member alerts", alertIDs, "a1", "a2")
+    var entities []string
+    for _, e := range got.Entities {
+        entities = append(entities, e.Kind+"="+e.Value)
+    }
+    expectIDs(t, "entities", entities, "hostname=host-1", "hostname=host-2", "username=alice")
+    var events []string
+    for _, e := range got.Timeline {
+        events = append(events, e.Type+":"+e.AlertID)
+    }
+    expectIDs(t, "timeline", events, "created:a1", "alert_added:a1", "alert_added:a2")
+
+    if incident, err := r.Incidents.GetIncidentByID(context.Background(), tenant, "missing"); incident != nil || err != nil {
+        t.Errorf("GetIncidentByID(missing): got %v, %v, want nil, nil", incident, err)
+    }
+}
+
+func testIncidentWindowAndStatus(t *testing.T, r Repos) {
+    attach(t, r, newAlert("a1", 0), time.Hour, models.EntityHostname, "host-1")
+
+    // The entity was last seen more than the window ago
+    if incident, created := attach(t, r, newAlert("a2", 2*time.Hour), time.Hour, models.EntityHostname, "host-1"); !created {
+        t.Errorf("alert after the window: got incident %s, want a new one", incident.ID)
+    }
+
+    if err := r.Incidents.UpdateIncidentStatus(context.Background(), tenant, "incident-a2", models.IncidentInvestigating); err != nil {
+        t.Fatalf("UpdateIncidentStatus: %v", err)
+    }
+    if incident, created := attach(t, r, newAlert("a3", 150*time.Minute), time.Hour, models.EntityHostname, "host-1"); created || incident.ID != "incident-a2" {
+        t.Errorf("alert for an incident under investigation: got incident %s, created %v, want incident-a2", incident.ID, created)
+    }
+
+    if err := r.Incidents.UpdateIncidentStatus(context.Background(), tenant, "incident-a2", models.IncidentResolved); err != nil {
+        t.Fatalf("UpdateIncidentStatus: %v", err)
+    }
+    if incident, created := attach(t, r, newAlert("a4", 160*time.Minute), time.Hour, models.EntityHostname, "host-1"); !created {
+        t.Errorf("alert for a resolved incident: got incident %s, want a new one", incident.ID)
+    }
+
+    got := mustGetIncident(t, r, "incident-a2")
+    if got.Status != models.IncidentResolved {
+        t.Errorf("status: got %s, want resolved", got.Status)
+    }
+    if last := got.Timeline[len(got.Timeline)-1]; last.Type != models.IncidentEventStatusChanged {
+        t.Errorf("last timeline event: got %+v, want a status change", last)
+    }
+
+    // A resolved incident stays resolved, even for an update that checked an older status
+    err := r.Incidents.UpdateIncidentStatus(context.Background(), tenant, "incident-a2", models.IncidentInvestigating)
+    if !errors.Is(err, repository.ErrIncidentTransition) {
+        t.Errorf("UpdateIncidentStatus of a resolved incident: got %v, want ErrIncidentTransition", err)
+    }
+    if got := mustGetIncident(t, r, "incident-a2"); got.Status != models.IncidentResolved {
+        t.Errorf("status after a rejected transition: got %s, want resolved", got.Status)
+    }
+
+    err = r.Incidents.UpdateIncidentStatus(context.Background(), tenant, "missing", models.IncidentResolved)
+    if !errors.Is(err, repository.ErrIncidentNotFound) {
+        t.Errorf("UpdateIncidentStatus(missing): got %v, want ErrIncidentNotFound", err)
+    }
+}
+
+func testIncidentAttachTwice(t *testing.T, r Repos) {
+    alert := newAlert("a1", 0)
+    attach(t, r, alert, time.Hour, models.EntityHostname, "host-1")
+
+    // A redelivered alert stays in its incident, even with other entities
+    alert.RiskScore = 0.9
+    if err := r.Alerts.UpdateAlertWithAIResults(context.Background(), tenant, &alert); err != nil {
+        t.Fatalf("UpdateAlertWithAIResults: %v", err)
+    }
+    es := []models.IncidentEntity{{Kind: models.EntityHostname, Value: "host-2", LastSeen: alert.Timestamp}}
+    incident, created, err := r.Incidents.AttachAlert(context.Background(), alert, es, alert.Timestamp.Add(-time.Hour), "incident-other")
+    if err != nil {
+        t.Fatalf("AttachAlert: %v", err)
+    }
+    if created || incident.ID != "incident-a1" || incident.AlertCount != 1 || incident.RiskScore != 0.9 {
+        t.Errorf("second attach: got %+v, created %v, want incident-a1 with 1 alert and the new risk score", *incident, created)
+    }
+    if got := mustGetIncident(t, r, "incident-a1"); len(got.Timeline) != 2 {
+        t.Errorf("timeline: got %d events, want 2", len(got.Timeline))
+    }
+}
+
+func testIncidentList(t *testing.T, r Repos) {
+    for i, host := range []string{"host-1", "host-2", "host-3"} {
+        alert := newAlert(fmt.Sprintf("a%d", i+1), time.Duration(i)*time.Minute)
+        alert.RiskScore = 0.2 * float64(i+1)
+        attach(t, r, alert, time.Hour, models.EntityHostname, host)
+    }
+    if err := r.Incidents.UpdateIncidentStatus(context.Background(), tenant, "incident-a2", models.IncidentResolved); err != nil {
+        t.Fatalf("UpdateIncidentStatus: %v", err)
+    }
+
+    listIncidents := func(f repository.IncidentFilter) []string {
+        t.Helper()
+        incidents, err := r.Incidents.GetAllIncidents(context.Background(), tenant, f)
+        if err != nil {
+            t.Fatalf("GetAllIncidents(%+v): %v", f, err)
+        }
+        var ids []string
+        for _, inc := range incidents {
+            if inc.Alerts != nil || inc.Timeline != nil {
+                t.Errorf("GetAllIncidents returned the details of %s", inc.ID)
+            }
+            ids = append(ids, inc.ID)
+        }
+        return ids
+    }
+    minRisk := 0.4
+    expectIDs(t, "all", listIncidents(repository.IncidentFilter{}), "incident-a3", "incident-a2", "incident-a1")
+    expectIDs(t, "status", listIncidents(repository.IncidentFilter{Status: models.IncidentOpen}), "incident-a3", "incident-a1")
+    expectIDs(t, "min risk", listIncidents(repository.IncidentFilter{MinRiskScore: &minRisk}), "incident-a3", "incident-a2")
+    expectIDs(t, "page", listIncidents(repository.IncidentFilter{Limit: 1, Offset: 1}), "incident-a2")
+}
+
+// testTenantIsolation checks that alerts and incidents of one tenant are invisible to
+// every method called for another.
+func testTenantIsolation(t *testing.T, r Repos) {
+    ctx := context.Background()
+    const other = "globex"
+
+    if err := r.Alerts.CreateAlert(ctx, "", &models.SecurityAlert{ID: "x"}); !errors.Is(err, repository.ErrNoTenant) {
+        t.Errorf("CreateAlert without a tenant: got %v, want ErrNoTenant", err)
+    }
+
+    attach(t, r, newAlert("a1", 0), time.Hour, models.EntityHostname, "host-1")
+    b1 := newAlert("b1", time.Minute) // Tenant "acme" in the body is overridden
+    if err := r.Alerts.CreateAlert(ctx, other, &b1); err != nil {
+        t.Fatalf("CreateAlert(%s): %v", other, err)
+    }
+    if b1.TenantID != other {
+        t.Errorf("CreateAlert did not set the tenant: got %q", b1.TenantID)
+    }
+
+    expectIDs(t, tenant+" alerts", list(t, r, repository.AlertFilter{}), "a1")
+    others, err := r.Alerts.GetAllAlerts(ctx, other, repository.AlertFilter{})
+    if err != nil || len(others) != 1 || others[0].ID != "b1" || others[0].TenantID != other {
+        t.Errorf("GetAllAlerts(%s): got %+v, %v", other, others, err)
+    }
+    if alert, err := r.Alerts.GetAlertByID(ctx, other, "a1"); alert != nil || err != nil {
+        t.Errorf("GetAlertByID of another tenant's alert: got %v, %v, want nil, nil", alert, err)
+    }
+    if err := r.Alerts.UpdateAlertStatus(ctx, other, "a1", models.StatusResolved); !errors.Is(err, repository.ErrAlertNotFound) {
+        t.Errorf("UpdateAlertStatus of another tenant's alert: got %v, want ErrAlertNotFound", err)
+    }
+    if err := r.Alerts.TransitionAlertStatus(ctx, other, "a1", models.StatusNew, models.StatusTriaged); !errors.Is(err, repository.ErrAlertNotFound) {
+        t.Errorf("TransitionAlertStatus of another tenant's alert: got %v, want ErrAlertNotFound", err)
+    }
+    analyzed := newAlert("a1", 0)
+    analyzed.RiskScore = 0.9
+    if err := r.Alerts.UpdateAlertWithAIResults(ctx, other, &analyzed); !errors.Is(err, repository.ErrAlertNotFound) {
+        t.Errorf("UpdateAlertWithAIResults of another tenant's alert: got %v, want ErrAlertNotFound", err)
+    }
+    if got := mustGet(t, r, "a1"); got.Status != models.StatusNew || got.RiskScore != 0 {
+        t.Errorf("another tenant changed a1: %+v", *got)
+    }
+
+    // Repeats are only folded within a tenant
+    original := newAlert("f1", 0)
+    original.Fingerprint = "fp"
+    if _, err := r.Alerts.CreateOrFoldAlert(ctx, tenant, &original, time.Hour, nil); err != nil {
+        t.Fatalf("CreateOrFoldAlert: %v", err)
+    }
+    repeat := newAlert("f2", time.Minute)
+    repeat.Fingerprint = "fp"
+    if res, err := r.Alerts.CreateOrFoldAlert(ctx, other, &repeat, time.Hour, nil); err != nil || res.Folded {
+        t.Errorf("CreateOrFoldAlert in another tenant: got %+v, %v, want a new alert", res, err)
+    }
+
+    // An alert sharing an entity with another tenant's incident opens its own
+    b2 := newAlert("b2", 2*time.Minute)
+    if err := r.Alerts.CreateAlert(ctx, other, &b2); err != nil {
+        t.Fatalf("CreateAlert(%s): %v", other, err)
+    }
+    es := []models.IncidentEntity{{Kind: models.EntityHostname, Value: "host-1", LastSeen: b2.Timestamp}}
+    incident, created, err := r.Incidents.AttachAlert(ctx, b2, es, b2.Timestamp.Add(-time.Hour), "incident-b2")
+    if err != nil || !created || incident.TenantID != other {
+        t.Fatalf("AttachAlert in another tenant: got %+v, %v, %v, want a new incident", incident, created, err)
+    }
+    if got := mustGetIncident(t, r, "incident-a1"); got.AlertCount != 1 || got.TenantID != tenant {
+        t.Errorf("incident-a1: got %d alerts, tenant %q", got.AlertCount, got.TenantID)
+    }
+    if got, err := r.Incidents.GetIncidentByID(ctx, other, "incident-a1"); got != nil || err != nil {
+        t.Errorf("GetIncidentByID of another tenant's incident: got %v, %v, want nil, nil", got, err)
+    }
+    incidents, err := r.Incidents.GetAllIncidents(ctx, other, repository.IncidentFilter{})
+    if err != nil || len(incidents) != 1 || incidents[0].ID != "incident-b2" {
+        t.Errorf("GetAllIncidents(%s): got %+v, %v", other, incidents, err)
+    }
+    if err := r.Incidents.UpdateIncidentStatus(ctx, other, "incident-a1", models.IncidentResolved); !errors.Is(err, repository.ErrIncidentNotFound) {
+        t.Errorf("UpdateIncidentStatus of another tenant's incident: got %v, want ErrIncidentNotFound", err)
+    }
+}
+
+// testSameIDInTwoTenants checks that alert IDs, which clients may choose, are scoped to
+// the tenant: another tenant's alert with the same ID neither conflicts nor shows.
+func testSameIDInTwoTenants(t *testing.T, r Repos) {
+    ctx := context.Background()
+    const other = "globex"
+
+    attach(t, r, newAlert("a1", 0), time.Hour, models.EntityHostname, "host-1")
+    theirs := newAlert("a1", time.Minute)
+    theirs.Title = "Their alert"
+    if err := r.Alerts.CreateAlert(ctx, other, &theirs); err != nil {
+        t.Fatalf("CreateAlert(%s) with an ID used by %s: %v", other, tenant, err)
+    }
+    ids, err := r.Alerts.CreateAlerts(ctx, other, []models.SecurityAlert{newAlert("a1", 0), newAlert("a2", 0)})
+    if err != nil || !reflect.DeepEqual(ids, []string{"a2"}) {
+        t.Errorf("CreateAlerts(%s): got %v, %v, want only a2 inserted", other, ids, err)
+    }
+    ids, err = r.Alerts.CreateAlerts(ctx, tenant, []models.SecurityAlert{newAlert("a2", 0)})
+    if err != nil || !reflect.DeepEqual(ids, []string{"a2"}) {
+        t.Errorf("CreateAlerts(%s) with an ID used by %s: got %v, %v, want a2 inserted", tenant, other, ids, err)
+    }
+
+    analyzed := theirs
+    analyzed.RiskScore = 0.9
+    if err := r.Alerts.UpdateAlertWithAIResults(ctx, other, &analyzed); err != nil {
+        t.Fatalf("UpdateAlertWithAIResults(%s): %v", other, err)
+    }
+    if err := r.Alerts.UpdateAlertStatus(ctx, other, "a1", models.StatusTriaged); err != nil {
+        t.Fatalf("UpdateAlertStatus(%s): %v", other, err)
+    }
+    if got := mustGet(t, r, "a1"); got.Title != "Test alert a1" || got.RiskScore != 0 || got.Status != models.StatusNew {
+        t.Errorf("%s's a1 was changed through %s's: %+v", tenant, other, *got)
+    }
+    if got, err := r.Alerts.GetAlertByID(ctx, other, "a1"); err != nil || got == nil || got.Title != "Their alert" || got.RiskScore != 0.9 {
+        t.Errorf("GetAlertByID(%s, a1): got %+v, %v", other, got, err)
+    }
+
+    // Each a1 belongs to an incident of its own tenant
+    es := []models.IncidentEntity{{Kind: models.EntityHostname, Value: "host-1", LastSeen: theirs.Timestamp}}
+    incident, created, err := r.Incidents.AttachAlert(ctx, analyzed, es, theirs.Timestamp.Add(-time.Hour), "incident-b1")
+    if err != nil || !created {
+        t.Fatalf("AttachAlert(%s, a1): got %+v, %v, %v, want a new incident", other, incident, created, err)
+    }
+    got, err := r.Incidents.GetIncidentByID(ctx, other, "incident-b1")
+    if err != nil || got == nil || len(got.Alerts) != 1 || got.Alerts[0].Title != "Their alert" || got.RiskScore != 0.9 {
+        t.Errorf("GetIncidentByID(%s, incident-b1): got %+v, %v", other, got, err)
+    }
+    if mine := mustGetIncident(t, r, "incident-a1"); len(mine.Alerts) != 1 || mine.Alerts[0].Title != "Test alert a1" || mine.RiskScore != 0 {
+        t.Errorf("incident-a1: got %+v", *mine)
+    }
+}
@@ -0,0 +1,202 @@
+package sigma
+
+import (
+    "fmt"
+    "path"
+    "sort"
+    "strings"
+    "unicode"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// parseCondition compiles a Sigma condition over the named searches. Supported:
+// and, or, not, parentheses, "1 of <pattern>", "all of <pattern>", "1 of them" and
+// "all of them". Aggregations ("| count() > 5") need state across alerts and are rejected.
+func parseCondition(condition string, searches map[string]matcher) (matcher, error) {
+    if strings.Contains(condition, "|") {
+        return nil, fmt.Errorf("aggregations are not supported")
+    }
+    p := &conditionParser{tokens: tokenize(condition), searches: searches}
+    m, err := p.parseOr()
+    if err != nil {
+        return nil, err
+    }
+    if p.pos < len(p.tokens) {
+        return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+    }
+    return m, nil
+}
+
+// tokenize splits a condition into words and parentheses.
+func tokenize(condition string) []string {
+    var tokens []string
+    var word strings.Builder
+    flush := func() {
+        if word.Len() > 0 {
+            tokens = append(tokens, word.String())
+            word.Reset()
+        }
+    }
+    for _, r := range condition {
+        switch {
+        case r == '(' || r == ')':
+            flush()
+            tokens = append(tokens, string(r))
+        case unicode.IsSpace(r):
+            flush()
+        default:
+            word.WriteRune(r)
+        }
+    }
+    flush()
+    return tokens
+}
+
+// conditionParser is a recursive descent parser; "not" binds tighter than "and",
+// which binds tighter than "or".
+type conditionParser struct {
+    tokens   []string
+    pos      int
+    searches map[string]matcher
+}
+
+func (p *conditionParser) peek() string {
+    if p.pos < len(p.tokens) {
+        return strings.ToLower(p.tokens[p.pos])
+    }
+    return ""
+}
+
+func (p *conditionParser) next() string {
+    t := p.peek()
+    p.pos++
+    return t
+}
+
+func (p *conditionParser) parseOr() (matcher, error) {
+    first, err := p.parseAnd()
+    if err != nil {
+        return nil, err
+    }
+    terms := []matcher{first}
+    for p.peek() == "or" {
+        p.next()
+        m, err := p.parseAnd()
+        if err != nil {
+            return nil, err
+        }
+        terms = append(terms, m)
+    }
+    if len(terms) == 1 {
+        return first, nil
+    }
+    return anyOf(terms), nil
+}
+
+func (p *conditionParser) parseAnd() (matcher, error) {
+    first, err := p.parseNot()
+    if err != nil {
+        return nil, err
+    }
+    terms := []matcher{first}
+    for p.peek() == "and" {
+        p.next()
+        m, err := p.parseNot()
+        if err != nil {
+            return nil, err
+        }
+        terms = append(terms, m)
+    }
+    if len(terms) == 1 {
+        return first, nil
+    }
+    return allOf(terms), nil
+}
+
+func (p *conditionParser) parseNot() (matcher, error) {
+    if p.peek() != "not" {
+        return p.parsePrimary()
+    }
+    p.next()
+    m, err := p.parseNot()
+    if err != nil {
+        return nil, err
+    }
+    return func(a models.SecurityAlert) bool { return !m(a) }, nil
+}
+
+func (p *conditionParser) parsePrimary() (matcher, error) {
+    if p.pos >= len(p.tokens) {
+        return nil, fmt.Errorf("unexpected end of condition")
+    }
+    raw := p.tokens[p.pos]
+    switch tok := p.next(); tok {
+    case "(":
+        m, err := p.parseOr()
+        if err != nil {
+            return nil, err
+        }
+        if p.next() != ")" {
+            return nil, fmt.Errorf("missing closing parenthesis")
+        }
+        return m, nil
+    case ")", "and", "or":
+        return nil, fmt.Errorf("unexpected %q", raw)
+    case "1", "any", "all":
+        if p.peek() != "of" {
+            break
+        }
+        p.next()
+        if p.pos >= len(p.tokens) {
+            return nil, fmt.Errorf("%q needs a search name pattern or \"them\"", raw+" of")
+        }
+        pattern := p.tokens[p.pos]
+        p.next()
+        matched, err := p.searchesMatching(pattern)
+        if err != nil {
+            return nil, err
+        }
+        if tok == "all" {
+            return allOf(matched), nil
+        }
+        return anyOf(matched), nil
+    }
+
+    search, ok := p.searches[raw]
+    if !ok {
+        return nil, fmt.Errorf("unknown search %q", raw)
+    }
+    return search, nil
+}
+
+// searchesMatching returns the searches whose names match a glob pattern, or all of
+// them for "them". Searches whose names start with an underscore are not included
+// in "them", as in the Sigma specification.
+func (p *conditionParser) searchesMatching(pattern string) ([]matcher, error) {
+    var names []string
+    for name := range p.searches {
+        if strings.EqualFold(pattern, "them") {
+            if !strings.HasPrefix(name, "_") {
+                names = append(names, name)
+            }
+            continue
+        }
+        ok, err := path.Match(pattern, name)
+        if err != nil {
+            return nil, fmt.Errorf("invalid search pattern %q: %w", pattern, err)
+        }
+        if ok {
+            names = append(names, name)
+        }
+    }
+    if len(names) == 0 {
+        return nil, fmt.Errorf("no search matches %q", pattern)
+    }
+    sort.Strings(names) // Deterministic evaluation order
+    matched := make([]matcher, len(names))
+    for i, name := range names {
+        matched[i] = p.searches[name]
+    }
+    return matched, nil
+}
@@ -0,0 +1,182 @@
+package sigma
+
+import (
+    "context"
+    "fmt"
+    "io/fs"
+    "log"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync/atomic"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// RuleSet is an immutable set of compiled rules.
+type RuleSet struct {
+    rules []*Rule
+}
+
+// Len returns the number of rules in the set.
+func (s *RuleSet) Len() int {
+    return len(s.rules)
+}
+
+// Evaluate returns the rules the alert matches, in rule ID order.
+func (s *RuleSet) Evaluate(alert models.SecurityAlert) []models.SigmaMatch {
+    var matches []models.SigmaMatch
+    for _, r := range s.rules {
+        if r.Matches(alert) {
+            matches = append(matches, models.SigmaMatch{RuleID: r.ID, Title: r.Title, Level: r.Level})
+        }
+    }
+    return matches
+}
+
+// isRuleFile reports whether a file in the rules directory should be loaded.
+func isRuleFile(name string) bool {
+    ext := strings.ToLower(filepath.Ext(name))
+    return (ext == ".yml" || ext == ".yaml") && !strings.HasPrefix(filepath.Base(name), ".")
+}
+
+// LoadRules compiles every .yml and .yaml file below dir. Files that fail to parse
+// are logged and skipped so one broken rule cannot disable the others; only an
+// unreadable directory is an error.
+func LoadRules(dir string, mapping FieldMapping) (*RuleSet, error) {
+    set := &RuleSet{}
+    seen := make(map[string]string) // Rule ID -> file
+    err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+        if err != nil {
+            return err
+        }
+        if d.IsDir() || !isRuleFile(path) {
+            return nil
+        }
+        data, err := os.ReadFile(path)
+        if err != nil {
+            log.Printf("ERROR Sigma: Skipping %s: %v", path, err)
+            return nil
+        }
+        rules, err := ParseRules(data, mapping)
+        if err != nil {
+            log.Printf("ERROR Sigma: Skipping %s: %v", path, err)
+            return nil
+        }
+        for _, r := range rules {
+            if other, dup := seen[r.ID]; dup {
+                log.Printf("ERROR Sigma: Skipping rule %s in %s: already defined in %s", r.ID, path, other)
+                continue
+            }
+            seen[r.ID] = path
+            set.rules = append(set.rules, r)
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("failed to load Sigma rules from %s: %w", dir, err)
+    }
+    sort.Slice(set.rules, func(i, j int) bool { return set.rules[i].ID < set.rules[j].ID })
+    return set, nil
+}
+
+// Engine evaluates alerts against the rules in a directory and reloads them when
+// the directory changes.
+type Engine struct {
+    dir     string
+    mapping FieldMapping
+    rules   atomic.Pointer[RuleSet]
+    state   string // Directory state of the loaded rules, see dirState; only used by Watch
+}
+
+// NewEngine loads the rules in dir.
+func NewEngine(dir string, mapping FieldMapping) (*Engine, error) {
+    e := &Engine{dir: dir, mapping: mapping}
+    state, err := dirState(dir)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read Sigma rules directory: %w", err)
+    }
+    set, err := LoadRules(dir, mapping)
+    if err != nil {
+        return nil, err
+    }
+    e.rules.Store(set)
+    e.state = state
+    return e, nil
+}
+
+// Rules returns the current rule set.
+func (e *Engine) Rules() *RuleSet {
+    return e.rules.Load()
+}
+
+// Evaluate returns the rules the alert matches.
+func (e *Engine) Evaluate(alert models.SecurityAlert) []models.SigmaMatch {
+    return e.rules.Load().Evaluate(alert)
+}
+
+// Watch polls the rules directory every interval and reloads the rules when a rule
+// file was added, removed or modified, until ctx is cancelled. Evaluations running
+// during a reload finish with the previous rule set. If the directory cannot be
+// read, the current rules stay active.
+func (e *Engine) Watch(ctx context.Context, interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+
+        state, err := dirState(e.dir)
+        if err != nil {
+            log.Printf("ERROR Sigma: Failed to check rules directory %s: %v", e.dir, err)
+            continue
+        }
+        if state == e.state {
+            continue
+        }
+        set, err := LoadRules(e.dir, e.mapping)
+        if err != nil {
+            log.Printf("ERROR Sigma: Keeping the current rules: %v", err)
+            continue
+        }
+        e.rules.Store(set)
+        e.state = state
+        log.Printf("Sigma: Reloaded %d rules from %s", set.Len(), e.dir)
+    }
+}
+
+// dirState summarises the names, sizes and modification times of the rule files
+// below dir, so changes can be detected without reading the files.
+func dirState(dir string) (string, error) {
+    var b strings.Builder
+    err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+        if err != nil {
+            return err
+        }
+        if d.IsDir() || !isRuleFile(path) {
+            return nil
+        }
+        info, err := d.Info()
+        if err != nil {
+            return err
+        }
+        fmt.Fprintf(&b, "%s\x00%d\x00%d\n", path, info.Size(), info.ModTime().UnixNano())
+        return nil
+    })
+    return b.String(), err
+}
+
+// Escalate raises the alert's predicted severity to the highest level among its
+// Sigma matches. A matching rule never lowers the analyzer's verdict.
+func Escalate(alert *models.SecurityAlert) {
+    for _, m := range alert.SigmaMatches {
+        if models.SeverityRank(m.Level) > models.SeverityRank(alert.PredictedSeverity) {
+            alert.PredictedSeverity = m.Level
+        }
+    }
+}
@@ -0,0 +1,113 @@
+package sigma
+
+import (
+    "fmt"
+    "os"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// alertFields maps the alert field names rules can be mapped to onto the values they read.
+var alertFields = map[string]func(a models.SecurityAlert) string{
+    "source":      func(a models.SecurityAlert) string { return a.Source },
+    "severity":    func(a models.SecurityAlert) string { return a.Severity },
+    "category":    func(a models.SecurityAlert) string { return a.Category },
+    "title":       func(a models.SecurityAlert) string { return a.Title },
+    "description": func(a models.SecurityAlert) string { return a.Description },
+    "source_ip":   func(a models.SecurityAlert) string { return a.SourceIP },
+    "target_ip":   func(a models.SecurityAlert) string { return a.TargetIP },
+    "hostname":    func(a models.SecurityAlert) string { return a.Hostname },
+    "username":    func(a models.SecurityAlert) string { return a.Username },
+    "file_hash":   func(a models.SecurityAlert) string { return a.FileHash },
+}
+
+// keywordFields are the alert fields searched by keyword (field-less) detections.
+var keywordFields = []string{"title", "description"}
+
+// defaultMapping translates the Sigma field names most commonly found in public rule
+// sets. Every alert field is also reachable under its own name.
+var defaultMapping = map[string]string{
+    "computer":           "hostname",
+    "computername":       "hostname",
+    "hostname":           "hostname",
+    "workstationname":    "hostname",
+    "user":               "username",
+    "username":           "username",
+    "targetusername":     "username",
+    "subjectusername":    "username",
+    "src_ip":             "source_ip",
+    "sourceip":           "source_ip",
+    "sourceaddress":      "source_ip",
+    "ipaddress":          "source_ip",
+    "dst_ip":             "target_ip",
+    "destinationip":      "target_ip",
+    "destinationaddress": "target_ip",
+    "hashes":             "file_hash",
+    "hash":               "file_hash",
+    "md5":                "file_hash",
+    "sha1":               "file_hash",
+    "sha256":             "file_hash",
+    "message":            "description",
+}
+
+// FieldMapping translates the field names used in Sigma rules to SecurityAlert fields.
+// Lookups are case-insensitive.
+type FieldMapping map[string]string
+
+// DefaultFieldMapping returns the built-in mapping.
+func DefaultFieldMapping() FieldMapping {
+    m := make(FieldMapping, len(defaultMapping)+len(alertFields))
+    for name := range alertFields {
+        m[name] = name
+    }
+    for sigmaField, field := range defaultMapping {
+        m[sigmaField] = field
+    }
+    return m
+}
+
+// fieldMappingFile is the format of the mapping file:
+//
+//	fields:
+//	  TargetFilename: description
+//	  DeviceName: hostname
+type fieldMappingFile struct {
+    Fields map[string]string `yaml:"fields"`
+}
+
+// LoadFieldMapping returns the default mapping extended, and overridden, by the
+// mappings in the YAML file at path. An empty path returns the default mapping.
+func LoadFieldMapping(path string) (FieldMapping, error) {
+    m := DefaultFieldMapping()
+    if path == "" {
+        return m, nil
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read Sigma field mapping: %w", err)
+    }
+    var file fieldMappingFile
+    if err := yaml.Unmarshal(data, &file); err != nil {
+        return nil, fmt.Errorf("failed to parse Sigma field mapping %s: %w", path, err)
+    }
+    for sigmaField, field := range file.Fields {
+        field = strings.ToLower(strings.TrimSpace(field))
+        if _, ok := alertFields[field]; !ok {
+            return nil, fmt.Errorf("field %q is mapped to unknown alert field %q", sigmaField, field)
+        }
+        m[strings.ToLower(sigmaField)] = field
+    }
+    return m, nil
+}
+
+// resolve returns the accessor for a Sigma field name.
+func (m FieldMapping) resolve(sigmaField string) (func(a models.SecurityAlert) string, error) {
+    field, ok := m[strings.ToLower(sigmaField)]
+    if !ok {
+        return nil, fmt.Errorf("field %q is not mapped to an alert field", sigmaField)
+    }
+    return alertFields[field], nil
+}
@@ -0,0 +1,376 @@
+package sigma
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "io"
+    "net/netip"
+    "regexp"
+    "strconv"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// Levels are the Sigma rule levels, lowest first. They match the alert severities.
+var levels = []string{"informational", "low", "medium", "high", "critical"}
+
+// ruleFile is the subset of the Sigma rule format the engine understands.
+type ruleFile struct {
+    Title     string               `yaml:"title"`
+    ID        string               `yaml:"id"`
+    Status    string               `yaml:"status"`
+    Level     string               `yaml:"level"`
+    Action    string               `yaml:"action"` // Set on rule collection headers, which are not rules themselves
+    Detection map[string]yaml.Node `yaml:"detection"`
+}
+
+// Rule is a compiled Sigma rule.
+type Rule struct {
+    ID    string
+    Title string
+    Level string
+    match func(a models.SecurityAlert) bool
+}
+
+// Matches reports whether the alert satisfies the rule's detection.
+func (r *Rule) Matches(alert models.SecurityAlert) bool {
+    return r.match(alert)
+}
+
+// errSkipDocument marks YAML documents that are valid but not rules.
+var errSkipDocument = errors.New("not a rule")
+
+// ParseRules compiles every rule in a (possibly multi-document) YAML file.
+func ParseRules(data []byte, mapping FieldMapping) ([]*Rule, error) {
+    dec := yaml.NewDecoder(bytes.NewReader(data))
+    var rules []*Rule
+    for {
+        var file ruleFile
+        err := dec.Decode(&file)
+        if err == io.EOF {
+            return rules, nil
+        }
+        if err != nil {
+            return nil, fmt.Errorf("invalid YAML: %w", err)
+        }
+        rule, err := compileRule(file, mapping)
+        if err == errSkipDocument {
+            continue
+        }
+        if err != nil {
+            if file.ID != "" {
+                return nil, fmt.Errorf("rule %s: %w", file.ID, err)
+            }
+            return nil, err
+        }
+        rules = append(rules, rule)
+    }
+}
+
+// compileRule checks a parsed rule and compiles its detection.
+func compileRule(file ruleFile, mapping FieldMapping) (*Rule, error) {
+    if file.Action != "" || (file.Title == "" && file.Detection == nil) {
+        return nil, errSkipDocument
+    }
+    if file.Title == "" {
+        return nil, fmt.Errorf("rule has no title")
+    }
+    rule := &Rule{ID: file.ID, Title: file.Title, Level: strings.ToLower(file.Level)}
+    if rule.ID == "" {
+        rule.ID = file.Title // IDs are optional in Sigma, titles are not
+    }
+    if rule.Level == "" {
+        rule.Level = "medium"
+    } else if LevelRank(rule.Level) == 0 {
+        return nil, fmt.Errorf("unknown level %q", file.Level)
+    }
+
+    conditionNode, ok := file.Detection["condition"]
+    if !ok {
+        return nil, fmt.Errorf("detection has no condition")
+    }
+    searches := make(map[string]matcher)
+    for name, node := range file.Detection {
+        if name == "condition" || name == "timeframe" {
+            continue
+        }
+        search, err := compileSearch(&node, mapping)
+        if err != nil {
+            return nil, fmt.Errorf("search %q: %w", name, err)
+        }
+        searches[name] = search
+    }
+
+    // Several conditions are alternatives
+    var conditions []string
+    switch conditionNode.Kind {
+    case yaml.ScalarNode:
+        conditions = []string{conditionNode.Value}
+    case yaml.SequenceNode:
+        if err := conditionNode.Decode(&conditions); err != nil {
+            return nil, fmt.Errorf("invalid condition: %w", err)
+        }
+    default:
+        return nil, fmt.Errorf("condition must be a string or a list of strings")
+    }
+    var alternatives []matcher
+    for _, c := range conditions {
+        m, err := parseCondition(c, searches)
+        if err != nil {
+            return nil, fmt.Errorf("condition %q: %w", c, err)
+        }
+        alternatives = append(alternatives, m)
+    }
+    rule.match = anyOf(alternatives)
+    return rule, nil
+}
+
+// LevelRank orders Sigma levels from 1 (informational) to 5 (critical), 0 for unknown ones.
+func LevelRank(level string) int {
+    for i, l := range levels {
+        if strings.EqualFold(l, level) {
+            return i + 1
+        }
+    }
+    return 0
+}
+
+// matcher evaluates part of a detection against an alert.
+type matcher func(a models.SecurityAlert) bool
+
+func anyOf(ms []matcher) matcher {
+    return func(a models.SecurityAlert) bool {
+        for _, m := range ms {
+            if m(a) {
+                return true
+            }
+        }
+        return false
+    }
+}
+
+func allOf(ms []matcher) matcher {
+    return func(a models.SecurityAlert) bool {
+        for _, m := range ms {
+            if !m(a) {
+                return false
+            }
+        }
+        return true
+    }
+}
+
+// compileSearch compiles one named search of the detection section: a map of field
+// conditions (all must hold), a list of such maps (any must hold), or a list of
+// keywords searched in the alert's text fields.
+func compileSearch(node *yaml.Node, mapping FieldMapping) (matcher, error) {
+    switch node.Kind {
+    case yaml.MappingNode:
+        return compileFieldMap(node, mapping)
+    case yaml.SequenceNode:
+        var alternatives []matcher
+        for _, item := range node.Content {
+            var m matcher
+            var err error
+            switch item.Kind {
+            case yaml.MappingNode:
+                m, err = compileFieldMap(item, mapping)
+            case yaml.ScalarNode:
+                m, err = compileKeyword(item.Value)
+            default:
+                err = fmt.Errorf("list items must be field maps or keywords")
+            }
+            if err != nil {
+                return nil, err
+            }
+            alternatives = append(alternatives, m)
+        }
+        return anyOf(alternatives), nil
+    case yaml.ScalarNode:
+        return compileKeyword(node.Value)
+    }
+    return nil, fmt.Errorf("unsupported search definition")
+}
+
+// compileKeyword matches a value anywhere in the keyword fields.
+func compileKeyword(keyword string) (matcher, error) {
+    match, err := compileValue("*"+keyword+"*", nil)
+    if err != nil {
+        return nil, err
+    }
+    return func(a models.SecurityAlert) bool {
+        for _, f := range keywordFields {
+            if match(alertFields[f](a)) {
+                return true
+            }
+        }
+        return false
+    }, nil
+}
+
+// compileFieldMap compiles "field|modifier: value(s)" pairs, all of which must hold.
+func compileFieldMap(node *yaml.Node, mapping FieldMapping) (matcher, error) {
+    var conditions []matcher
+    for i := 0; i+1 < len(node.Content); i += 2 {
+        key, valueNode := node.Content[i].Value, node.Content[i+1]
+        parts := strings.Split(key, "|")
+        field, modifiers := parts[0], parts[1:]
+
+        get, err := mapping.resolve(field)
+        if err != nil {
+            return nil, err
+        }
+
+        var values []*yaml.Node
+        switch valueNode.Kind {
+        case yaml.SequenceNode:
+            values = valueNode.Content
+        case yaml.ScalarNode:
+            values = []*yaml.Node{valueNode}
+        default:
+            return nil, fmt.Errorf("field %q: values must be scalars", field)
+        }
+
+        matchAll := false
+        var valueModifiers []string
+        for _, mod := range modifiers {
+            if mod == "all" {
+                matchAll = true
+            } else {
+                valueModifiers = append(valueModifiers, mod)
+            }
+        }
+
+        var valueMatchers []func(string) bool
+        for _, v := range values {
+            if v.Kind != yaml.ScalarNode {
+                return nil, fmt.Errorf("field %q: values must be scalars", field)
+            }
+            var m func(string) bool
+            if v.Tag == "!!null" {
+                m = func(s string) bool { return s == "" }
+            } else if m, err = compileValue(v.Value, valueModifiers); err != nil {
+                return nil, fmt.Errorf("field %q: %w", field, err)
+            }
+            valueMatchers = append(valueMatchers, m)
+        }
+        if len(valueMatchers) == 0 {
+            // An empty list matches an empty field
+            valueMatchers = append(valueMatchers, func(s string) bool { return s == "" })
+        }
+
+        conditions = append(conditions, func(a models.SecurityAlert) bool {
+            value := get(a)
+            for _, m := range valueMatchers {
+                if m(value) != matchAll {
+                    return !matchAll
+                }
+            }
+            return matchAll
+        })
+    }
+    return allOf(conditions), nil
+}
+
+// compileValue builds the comparison for one value and its modifiers. Plain values
+// are compared case-insensitively and may contain the wildcards * and ?.
+func compileValue(value string, modifiers []string) (func(string) bool, error) {
+    pattern := value
+    for _, mod := range modifiers {
+        switch mod {
+        case "contains":
+            pattern = "*" + pattern + "*"
+        case "startswith":
+            pattern = pattern + "*"
+        case "endswith":
+            pattern = "*" + pattern
+        case "re":
+            if len(modifiers) != 1 {
+                return nil, fmt.Errorf("modifier re cannot be combined")
+            }
+            re, err := regexp.Compile(value)
+            if err != nil {
+                return nil, fmt.Errorf("invalid regular expression: %w", err)
+            }
+            return re.MatchString, nil
+        case "cidr":
+            if len(modifiers) != 1 {
+                return nil, fmt.Errorf("modifier cidr cannot be combined")
+            }
+            prefix, err := netip.ParsePrefix(value)
+            if err != nil {
+                return nil, fmt.Errorf("invalid CIDR block: %w", err)
+            }
+            return func(s string) bool {
+                addr, err := netip.ParseAddr(s)
+                return err == nil && prefix.Contains(addr.Unmap())
+            }, nil
+        case "lt", "lte", "gt", "gte":
+            if len(modifiers) != 1 {
+                return nil, fmt.Errorf("modifier %s cannot be combined", mod)
+            }
+            return compileComparison(mod, value)
+        default:
+            return nil, fmt.Errorf("unsupported modifier %q", mod)
+        }
+    }
+
+    if !strings.ContainsAny(pattern, "*?") {
+        return func(s string) bool { return strings.EqualFold(s, value) }, nil
+    }
+    re, err := regexp.Compile(wildcardToRegexp(pattern))
+    if err != nil {
+        return nil, err
+    }
+    return re.MatchString, nil
+}
+
+// wildcardToRegexp translates a Sigma wildcard pattern, where * and ? can be escaped
+// with a backslash, into an anchored case-insensitive regular expression.
+func wildcardToRegexp(pattern string) string {
+    var b strings.Builder
+    b.WriteString("(?is)^")
+    for i := 0; i < len(pattern); i++ {
+        c := pattern[i]
+        switch {
+        case c == '\\' && i+1 < len(pattern) && strings.IndexByte(`*?\`, pattern[i+1]) >= 0:
+            i++
+            b.WriteString(regexp.QuoteMeta(string(pattern[i])))
+        case c == '*':
+            b.WriteString(".*")
+        case c == '?':
+            b.WriteString(".")
+        default:
+            b.WriteString(regexp.QuoteMeta(string(c)))
+        }
+    }
+    b.WriteString("$")
+    return b.String()
+}
+
+// compileComparison compares numeric field values against value.
+func compileComparison(op, value string) (func(string) bool, error) {
+    limit, err := strconv.ParseFloat(value, 64)
+    if err != nil {
+        return nil, fmt.Errorf("modifier %s needs a number, got %q", op, value)
+    }
+    return func(s string) bool {
+        n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+        if err != nil {
+            return false
+        }
+        switch op {
+        case "lt":
+            return n < limit
+        case "lte":
+            return n <= limit
+        case "gt":
+            return n > limit
+        }
+        return n >= limit
+    }, nil
+}
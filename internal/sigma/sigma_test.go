@@ -0,0 +1,162 @@
+package sigma
+
+import (
+    "context"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+const testRule = `
+title: Suspicious admin logon
+id: 11111111-0000-0000-0000-000000000001
+level: high
+detection:
+  selection:
+    User|startswith: adm
+    src_ip|cidr: 10.0.0.0/8
+  hosts:
+    - ComputerName: dc-*
+    - ComputerName: '*-srv'
+  filter:
+    title|contains:
+      - test
+      - scheduled
+  condition: selection and 1 of hosts and not filter
+`
+
+func mustParse(t *testing.T, rule string) *Rule {
+    t.Helper()
+    rules, err := ParseRules([]byte(rule), DefaultFieldMapping())
+    if err != nil {
+        t.Fatalf("ParseRules: %v", err)
+    }
+    if len(rules) != 1 {
+        t.Fatalf("ParseRules: got %d rules, want 1", len(rules))
+    }
+    return rules[0]
+}
+
+func TestRuleMatches(t *testing.T) {
+    rule := mustParse(t, testRule)
+    if rule.ID != "11111111-0000-0000-0000-000000000001" || rule.Level != "high" {
+        t.Errorf("rule: got ID %q, level %q", rule.ID, rule.Level)
+    }
+
+    base := models.SecurityAlert{Title: "Logon", Username: "Administrator", SourceIP: "10.1.2.3", Hostname: "DC-01"}
+    tests := []struct {
+        name   string
+        modify func(a *models.SecurityAlert)
+        want   bool
+    }{
+        {"match", func(a *models.SecurityAlert) {}, true},
+        {"second host pattern", func(a *models.SecurityAlert) { a.Hostname = "files-srv" }, true},
+        {"other host", func(a *models.SecurityAlert) { a.Hostname = "ws-01" }, false},
+        {"other user", func(a *models.SecurityAlert) { a.Username = "alice" }, false},
+        {"outside the block", func(a *models.SecurityAlert) { a.SourceIP = "192.168.1.1" }, false},
+        {"filtered", func(a *models.SecurityAlert) { a.Title = "Scheduled logon" }, false},
+    }
+    for _, tc := range tests {
+        alert := base
+        tc.modify(&alert)
+        if got := rule.Matches(alert); got != tc.want {
+            t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+        }
+    }
+}
+
+func TestParseRulesErrors(t *testing.T) {
+    for name, rule := range map[string]string{
+        "unknown field":     "title: t\ndetection:\n  sel:\n    Image: x\n  condition: sel",
+        "unknown modifier":  "title: t\ndetection:\n  sel:\n    User|base64: x\n  condition: sel",
+        "unknown search":    "title: t\ndetection:\n  sel:\n    User: x\n  condition: sel and other",
+        "aggregation":       "title: t\ndetection:\n  sel:\n    User: x\n  condition: sel | count() > 5",
+        "unbalanced":        "title: t\ndetection:\n  sel:\n    User: x\n  condition: (sel",
+        "missing condition": "title: t\ndetection:\n  sel:\n    User: x",
+        "unknown level":     "title: t\nlevel: urgent\ndetection:\n  sel:\n    User: x\n  condition: sel",
+    } {
+        if _, err := ParseRules([]byte(rule), DefaultFieldMapping()); err == nil {
+            t.Errorf("%s: expected an error", name)
+        }
+    }
+}
+
+func TestKeywordsAndAllModifier(t *testing.T) {
+    rule := mustParse(t, `
+title: Mimikatz
+detection:
+  keywords:
+    - mimikatz
+    - sekurlsa
+  both:
+    description|contains|all:
+      - lsass
+      - dump
+  condition: keywords or both
+`)
+    for _, tc := range []struct {
+        alert models.SecurityAlert
+        want  bool
+    }{
+        {models.SecurityAlert{Title: "MIMIKATZ detected"}, true},
+        {models.SecurityAlert{Description: "lsass memory dump"}, true},
+        {models.SecurityAlert{Description: "lsass started"}, false},
+    } {
+        if got := rule.Matches(tc.alert); got != tc.want {
+            t.Errorf("Matches(%+v): got %v, want %v", tc.alert, got, tc.want)
+        }
+    }
+}
+
+func TestEscalate(t *testing.T) {
+    alert := models.SecurityAlert{PredictedSeverity: "medium", SigmaMatches: []models.SigmaMatch{{Level: "low"}, {Level: "critical"}}}
+    Escalate(&alert)
+    if alert.PredictedSeverity != "critical" {
+        t.Errorf("got %q, want critical", alert.PredictedSeverity)
+    }
+    alert = models.SecurityAlert{PredictedSeverity: "high", SigmaMatches: []models.SigmaMatch{{Level: "low"}}}
+    Escalate(&alert)
+    if alert.PredictedSeverity != "high" {
+        t.Errorf("a low rule lowered the severity to %q", alert.PredictedSeverity)
+    }
+}
+
+func TestEngineReload(t *testing.T) {
+    dir := t.TempDir()
+    write := func(name, content string) {
+        t.Helper()
+        if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    write("admin.yml", testRule)
+    write("broken.yml", "title: [")
+
+    e, err := NewEngine(dir, DefaultFieldMapping())
+    if err != nil {
+        t.Fatalf("NewEngine: %v", err)
+    }
+    if n := e.Rules().Len(); n != 1 {
+        t.Fatalf("loaded %d rules, want 1 (the broken file is skipped)", n)
+    }
+
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    go e.Watch(ctx, 10*time.Millisecond)
+
+    write("malware.yaml", "title: Malware\nid: malware\nlevel: critical\ndetection:\n  sel:\n    category: malware\n  condition: sel")
+    deadline := time.Now().Add(5 * time.Second)
+    for e.Rules().Len() != 2 {
+        if time.Now().After(deadline) {
+            t.Fatal("the new rule file was not loaded")
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+    matches := e.Evaluate(models.SecurityAlert{Category: "Malware"})
+    if len(matches) != 1 || matches[0].RuleID != "malware" || matches[0].Level != "critical" {
+        t.Errorf("Evaluate: got %+v", matches)
+    }
+}
@@ -0,0 +1,210 @@
+package stream
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "sort"
+    "sync"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+    "github.com/Kelvinkhyd/GuardianAI/internal/repository"
+)
+
+const (
+    // defaultBufferSize is the number of recent events kept in memory. Subscribers
+    // that fall further behind read the missing events from the repository.
+    defaultBufferSize = 1000
+    fetchBatchSize    = 500
+
+    // eventRetention is how long events are kept for clients resuming a stream.
+    eventRetention = 24 * time.Hour
+    purgeInterval  = time.Hour
+)
+
+// Hub follows the alert event log and hands new events to the streams opened by
+// GET /alerts/stream. It is woken by the repository's notifications and also polls
+// every pollInterval, in case a notification was lost.
+//
+// Subscribers pull events at their own pace instead of having them pushed, so a slow
+// client never holds up the hub or the other clients.
+type Hub struct {
+    repo          repository.AlertEventRepository
+    notifications <-chan struct{}
+    pollInterval  time.Duration
+    bufferSize    int
+
+    mu         sync.Mutex
+    recent     []models.AlertEvent // Newest events, in ID order
+    bufferFrom int64               // recent holds every event with an ID above this one
+    lastID     int64               // Newest event seen
+    changed    chan struct{}       // Closed and replaced whenever events arrive
+}
+
+// NewHub creates a hub that starts following the event log at its current end.
+// notifications may be nil, in which case the hub only polls.
+func NewHub(ctx context.Context, repo repository.AlertEventRepository, notifications <-chan struct{}, pollInterval time.Duration) (*Hub, error) {
+    lastID, err := repo.LatestAlertEventID(ctx)
+    if err != nil {
+        return nil, fmt.Errorf("failed to start alert stream: %w", err)
+    }
+    return &Hub{
+        repo:          repo,
+        notifications: notifications,
+        pollInterval:  pollInterval,
+        bufferSize:    defaultBufferSize,
+        bufferFrom:    lastID,
+        lastID:        lastID,
+        changed:       make(chan struct{}),
+    }, nil
+}
+
+// Run follows the event log until ctx is cancelled. It is meant to be started in its own goroutine.
+func (h *Hub) Run(ctx context.Context) {
+    log.Printf("Alert stream started (poll interval %s)", h.pollInterval)
+
+    ticker := time.NewTicker(h.pollInterval)
+    defer ticker.Stop()
+    lastPurge := time.Now()
+
+    for {
+        select {
+        case <-ctx.Done():
+            log.Println("Alert stream stopped.")
+            return
+        case <-h.notifications:
+        case <-ticker.C:
+        }
+
+        if err := h.poll(ctx); err != nil && ctx.Err() == nil {
+            log.Printf("ERROR Stream: Failed to read alert events: %v", err)
+        }
+
+        if time.Since(lastPurge) >= purgeInterval {
+            lastPurge = time.Now()
+            purged, err := h.repo.PurgeAlertEvents(ctx, time.Now().Add(-eventRetention))
+            if err != nil {
+                log.Printf("ERROR Stream: Failed to purge alert events: %v", err)
+            } else if purged > 0 {
+                log.Printf("Stream: Purged %d alert events older than %s", purged, eventRetention)
+            }
+        }
+    }
+}
+
+// poll reads every event recorded since the last call into the buffer.
+func (h *Hub) poll(ctx context.Context) error {
+    for {
+        h.mu.Lock()
+        after := h.lastID
+        h.mu.Unlock()
+
+        events, err := h.repo.GetAlertEventsAfter(ctx, after, fetchBatchSize)
+        if err != nil {
+            return err
+        }
+        if len(events) == 0 {
+            return nil
+        }
+
+        h.mu.Lock()
+        h.recent = append(h.recent, events...)
+        if excess := len(h.recent) - h.bufferSize; excess > 0 {
+            h.bufferFrom = h.recent[excess-1].ID
+            h.recent = append([]models.AlertEvent(nil), h.recent[excess:]...)
+        }
+        h.lastID = events[len(events)-1].ID
+        close(h.changed) // Wake up the waiting subscribers
+        h.changed = make(chan struct{})
+        h.mu.Unlock()
+
+        if len(events) < fetchBatchSize {
+            return nil
+        }
+    }
+}
+
+// LastEventID returns the ID of the newest event the hub has seen.
+func (h *Hub) LastEventID() int64 {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    return h.lastID
+}
+
+// Subscription is one client's position in the event stream.
+type Subscription struct {
+    hub    *Hub
+    tenant string // Only events of this tenant's alerts are handed out
+    filter repository.AlertFilter
+    last   int64 // ID of the last event handed out or skipped
+}
+
+// Subscribe opens a stream of the events whose alert belongs to tenant and matches
+// filter. It resumes right after the event with ID after, as sent in an SSE
+// Last-Event-ID header, or starts with the next new event if after is negative.
+// Sorting and pagination settings of the filter are ignored.
+func (h *Hub) Subscribe(tenant string, filter repository.AlertFilter, after int64) *Subscription {
+    filter.After = nil
+    last := h.LastEventID()
+    if after >= 0 && after < last {
+        last = after // An ID from the future, e.g. after the database was reset, starts at the end
+    }
+    return &Subscription{hub: h, tenant: tenant, filter: filter, last: last}
+}
+
+// Next blocks until events matching the subscription's filter are available and
+// returns them, oldest first. It returns ctx.Err() if ctx is done first.
+func (s *Subscription) Next(ctx context.Context) ([]models.AlertEvent, error) {
+    for {
+        events, err := s.fetch(ctx)
+        if err != nil {
+            return nil, err
+        }
+        var matched []models.AlertEvent
+        for _, e := range events {
+            s.last = e.ID
+            if e.Alert.TenantID == s.tenant && s.filter.Matches(e.Alert) {
+                matched = append(matched, e)
+            }
+        }
+        if len(matched) > 0 {
+            return matched, nil
+        }
+    }
+}
+
+// fetch returns the next events after s.last, waiting for new ones if there are none.
+func (s *Subscription) fetch(ctx context.Context) ([]models.AlertEvent, error) {
+    h := s.hub
+    for {
+        h.mu.Lock()
+        if bufferFrom := h.bufferFrom; s.last < bufferFrom {
+            // Fell behind the buffer, e.g. resuming an old stream: catch up from the repository.
+            h.mu.Unlock()
+            events, err := h.repo.GetAlertEventsAfter(ctx, s.last, fetchBatchSize)
+            if err != nil {
+                return nil, err
+            }
+            if len(events) == 0 || events[0].ID > bufferFrom {
+                s.last = bufferFrom // The missing events were purged, go on with the buffer
+                continue
+            }
+            return events, nil
+        }
+        if s.last < h.lastID {
+            start := sort.Search(len(h.recent), func(i int) bool { return h.recent[i].ID > s.last })
+            events := append([]models.AlertEvent(nil), h.recent[start:]...)
+            h.mu.Unlock()
+            return events, nil
+        }
+        changed := h.changed
+        h.mu.Unlock()
+
+        select {
+        case <-ctx.Done():
+            return nil, ctx.Err()
+        case <-changed:
+        }
+    }
+}
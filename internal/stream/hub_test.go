@@ -0,0 +1,133 @@
+package stream
+
+import (
+    "context"
+    "fmt"
+    "testing"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+    "github.com/Kelvinkhyd/GuardianAI/internal/repository"
+)
+
+func newAlert(id, severity string) *models.SecurityAlert {
+    return &models.SecurityAlert{
+        ID:        id,
+        Source:    "test",
+        Timestamp: time.Now(),
+        Severity:  severity,
+        Title:     "Test alert " + id,
+        Status:    models.StatusNew,
+    }
+}
+
+func create(t *testing.T, repo *repository.MemoryRepository, ids ...string) {
+    t.Helper()
+    for _, id := range ids {
+        if err := repo.CreateAlert(context.Background(), models.DefaultTenant, newAlert(id, "high")); err != nil {
+            t.Fatalf("CreateAlert(%s): %v", id, err)
+        }
+    }
+}
+
+// collect reads events from sub until it has n of them, and describes each as "type id".
+func collect(t *testing.T, sub *Subscription, n int) []string {
+    t.Helper()
+    ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+    defer cancel()
+    var got []string
+    for len(got) < n {
+        events, err := sub.Next(ctx)
+        if err != nil {
+            t.Fatalf("Next: %v (got %v so far)", err, got)
+        }
+        for _, e := range events {
+            got = append(got, fmt.Sprintf("%s %s", e.Type, e.Alert.ID))
+        }
+    }
+    return got
+}
+
+func expect(t *testing.T, got []string, want ...string) {
+    t.Helper()
+    if fmt.Sprint(got) != fmt.Sprint(want) {
+        t.Errorf("got %v, want %v", got, want)
+    }
+}
+
+func TestLiveStream(t *testing.T) {
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    repo := repository.NewMemoryRepository()
+    create(t, repo, "before") // Recorded before the hub started, not streamed
+
+    hub, err := NewHub(ctx, repo, repo.AlertEventNotifications(), time.Hour)
+    if err != nil {
+        t.Fatalf("NewHub: %v", err)
+    }
+    go hub.Run(ctx)
+    sub := hub.Subscribe(models.DefaultTenant, repository.AlertFilter{Severity: "high"}, -1)
+
+    if err := repo.CreateAlert(ctx, models.DefaultTenant, newAlert("low", "low")); err != nil {
+        t.Fatal(err)
+    }
+    if err := repo.CreateAlert(ctx, "acme", newAlert("other-tenant", "high")); err != nil {
+        t.Fatal(err)
+    }
+    create(t, repo, "a1")
+    expect(t, collect(t, sub, 1), "alert.created a1")
+
+    analyzed := newAlert("a1", "high")
+    analyzed.Status = models.StatusAnalyzed
+    analyzed.RiskScore = 0.8
+    if err := repo.UpdateAlertWithAIResults(ctx, models.DefaultTenant, analyzed); err != nil {
+        t.Fatal(err)
+    }
+    expect(t, collect(t, sub, 1), "alert.analyzed a1")
+
+    // Nothing else matches, so Next waits until its context is done.
+    wait, stop := context.WithTimeout(ctx, 20*time.Millisecond)
+    defer stop()
+    if events, err := sub.Next(wait); err != context.DeadlineExceeded {
+        t.Errorf("Next without new events: got %v, %v, want DeadlineExceeded", events, err)
+    }
+}
+
+func TestResume(t *testing.T) {
+    ctx := context.Background()
+    repo := repository.NewMemoryRepository()
+    create(t, repo, "a1", "a2", "a3") // Events 1 to 3
+
+    hub, err := NewHub(ctx, repo, nil, time.Hour)
+    if err != nil {
+        t.Fatalf("NewHub: %v", err)
+    }
+    hub.bufferSize = 2
+
+    // Events from before the hub started are read from the repository.
+    expect(t, collect(t, hub.Subscribe(models.DefaultTenant, repository.AlertFilter{}, 1), 2), "alert.created a2", "alert.created a3")
+
+    // Only events 6 and 7 stay buffered, 4 and 5 come from the repository.
+    create(t, repo, "a4", "a5", "a6", "a7")
+    if err := hub.poll(ctx); err != nil {
+        t.Fatalf("poll: %v", err)
+    }
+    if hub.bufferFrom != 5 || len(hub.recent) != 2 {
+        t.Fatalf("buffer: got %d events after %d, want 2 after 5", len(hub.recent), hub.bufferFrom)
+    }
+    expect(t, collect(t, hub.Subscribe(models.DefaultTenant, repository.AlertFilter{}, 3), 4),
+        "alert.created a4", "alert.created a5", "alert.created a6", "alert.created a7")
+
+    // Purged events are skipped, and IDs beyond the end start at the end.
+    if _, err := repo.PurgeAlertEvents(ctx, time.Now().Add(time.Hour)); err != nil {
+        t.Fatal(err)
+    }
+    old := hub.Subscribe(models.DefaultTenant, repository.AlertFilter{}, 0)
+    future := hub.Subscribe(models.DefaultTenant, repository.AlertFilter{}, 100)
+    create(t, repo, "a8")
+    if err := hub.poll(ctx); err != nil {
+        t.Fatalf("poll: %v", err)
+    }
+    expect(t, collect(t, old, 1), "alert.created a7", "alert.created a8") // Still buffered
+    expect(t, collect(t, future, 1), "alert.created a8")
+}
@@ -0,0 +1,140 @@
+package threatintel
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "log"
+    "math"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync/atomic"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// LoadFeeds builds an index from the feed files directly in dir. The format is
+// chosen by extension: .txt and .list are plain text, .csv is CSV and .json is a
+// STIX 2.1 bundle; other files are ignored. A feed that fails to load is logged and
+// skipped so one bad download cannot disable the others.
+func LoadFeeds(dir string) (*Index, error) {
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read threat intel directory %s: %w", dir, err)
+    }
+    names := make([]string, 0, len(entries))
+    for _, e := range entries {
+        if !e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+            names = append(names, e.Name())
+        }
+    }
+    sort.Strings(names) // Earlier files win for duplicate indicators
+
+    index := newIndex()
+    for _, name := range names {
+        indicators, skipped, err := loadFeed(filepath.Join(dir, name))
+        if err != nil {
+            log.Printf("ERROR Threat intel: Skipping feed %s: %v", name, err)
+            continue
+        }
+        if indicators == nil && skipped == 0 {
+            continue // Not a feed
+        }
+        for _, ind := range indicators {
+            index.add(ind)
+        }
+        if skipped > 0 {
+            log.Printf("Threat intel: Feed %s: ignored %d entries that are not IPs, CIDR blocks, domains or hashes", name, skipped)
+        }
+    }
+    return index, nil
+}
+
+// loadFeed parses one feed file according to its extension.
+func loadFeed(path string) ([]Indicator, int, error) {
+    var parse func(r io.Reader, feed string) ([]Indicator, int, error)
+    switch strings.ToLower(filepath.Ext(path)) {
+    case ".txt", ".list":
+        parse = parseText
+    case ".csv":
+        parse = parseCSV
+    case ".json":
+        parse = func(r io.Reader, feed string) ([]Indicator, int, error) { return parseSTIX(r, feed, time.Now()) }
+    default:
+        return nil, 0, nil
+    }
+
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, 0, err
+    }
+    defer f.Close()
+    return parse(f, filepath.Base(path))
+}
+
+// Engine matches alerts against the indicators of the feeds in a directory.
+type Engine struct {
+    dir       string
+    riskBoost float64
+    index     atomic.Pointer[Index]
+}
+
+// NewEngine loads the feeds in dir. Alerts matching an indicator get riskBoost added
+// to their risk score.
+func NewEngine(dir string, riskBoost float64) (*Engine, error) {
+    index, err := LoadFeeds(dir)
+    if err != nil {
+        return nil, err
+    }
+    e := &Engine{dir: dir, riskBoost: riskBoost}
+    e.index.Store(index)
+    return e, nil
+}
+
+// Index returns the current index.
+func (e *Engine) Index() *Index {
+    return e.index.Load()
+}
+
+// Enrich records the indicators found in the alert in its enrichment section and,
+// if there are any, raises its risk score by the engine's boost. It returns the
+// number of matches.
+func (e *Engine) Enrich(alert *models.SecurityAlert) int {
+    matches := e.index.Load().Match(*alert)
+    if alert.Enrichment == nil {
+        if len(matches) == 0 {
+            return 0
+        }
+        alert.Enrichment = &models.Enrichment{}
+    }
+    alert.Enrichment.ThreatIntel = matches // Replaces the matches of an earlier analysis
+    if len(matches) > 0 {
+        alert.RiskScore = math.Round(math.Min(1, alert.RiskScore+e.riskBoost)*1000) / 1000
+    }
+    return len(matches)
+}
+
+// Run reloads the feeds every interval until ctx is cancelled, so updated feed files
+// take effect without a restart. If the directory cannot be read, the current
+// indicators stay active.
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+        index, err := LoadFeeds(e.dir)
+        if err != nil {
+            log.Printf("ERROR Threat intel: Keeping the current indicators: %v", err)
+            continue
+        }
+        e.index.Store(index)
+        log.Printf("Threat intel: Reloaded %d indicators from %s", index.Len(), e.dir)
+    }
+}
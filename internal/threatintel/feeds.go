@@ -0,0 +1,169 @@
+package threatintel
+
+import (
+    "bufio"
+    "encoding/csv"
+    "encoding/json"
+    "fmt"
+    "io"
+    "regexp"
+    "strings"
+    "time"
+)
+
+// parseText reads one indicator per line. Blank lines and lines starting with # are
+// ignored; anything after a # on an indicator line becomes its description.
+func parseText(r io.Reader, feed string) ([]Indicator, int, error) {
+    var indicators []Indicator
+    skipped := 0
+    scanner := bufio.NewScanner(r)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        value, description, _ := strings.Cut(line, "#")
+        fields := strings.Fields(value)
+        if len(fields) == 0 {
+            continue
+        }
+        ind, ok := ParseIndicator(fields[0], "")
+        if !ok {
+            skipped++
+            continue
+        }
+        ind.Feed, ind.Description = feed, strings.TrimSpace(description)
+        indicators = append(indicators, ind)
+    }
+    return indicators, skipped, scanner.Err()
+}
+
+// parseCSV reads a CSV file with a header row. The indicator is taken from the
+// column named indicator, ioc or value; optional type and description (or comment)
+// columns are used when present.
+func parseCSV(r io.Reader, feed string) ([]Indicator, int, error) {
+    reader := csv.NewReader(r)
+    reader.Comment = '#'
+    reader.FieldsPerRecord = -1
+    reader.TrimLeadingSpace = true
+
+    header, err := reader.Read()
+    if err != nil {
+        return nil, 0, fmt.Errorf("failed to read CSV header: %w", err)
+    }
+    valueCol, typeCol, descCol := -1, -1, -1
+    for i, name := range header {
+        switch strings.ToLower(strings.TrimSpace(name)) {
+        case "indicator", "ioc", "value":
+            valueCol = i
+        case "type", "indicator_type":
+            typeCol = i
+        case "description", "comment":
+            descCol = i
+        }
+    }
+    if valueCol < 0 {
+        return nil, 0, fmt.Errorf("CSV header has no indicator, ioc or value column")
+    }
+
+    var indicators []Indicator
+    skipped := 0
+    for {
+        record, err := reader.Read()
+        if err == io.EOF {
+            return indicators, skipped, nil
+        }
+        if err != nil {
+            return nil, 0, fmt.Errorf("failed to read CSV: %w", err)
+        }
+        column := func(i int) string {
+            if i < 0 || i >= len(record) {
+                return ""
+            }
+            return record[i]
+        }
+        ind, ok := ParseIndicator(column(valueCol), column(typeCol))
+        if !ok {
+            skipped++
+            continue
+        }
+        ind.Feed, ind.Description = feed, strings.TrimSpace(column(descCol))
+        indicators = append(indicators, ind)
+    }
+}
+
+// stixBundle is the part of a STIX 2.1 bundle the loader reads.
+type stixBundle struct {
+    Type    string       `json:"type"`
+    Objects []stixObject `json:"objects"`
+}
+
+type stixObject struct {
+    Type        string    `json:"type"`
+    Name        string    `json:"name"`
+    Description string    `json:"description"`
+    Pattern     string    `json:"pattern"`
+    PatternType string    `json:"pattern_type"`
+    ValidUntil  time.Time `json:"valid_until"`
+    Revoked     bool      `json:"revoked"`
+}
+
+// stixComparison matches the comparisons of a STIX pattern the loader understands,
+// e.g. [ipv4-addr:value = '198.51.100.1'] or [file:hashes.'SHA-256' = '...'].
+// Comparisons joined by OR each yield an indicator.
+var stixComparison = regexp.MustCompile(`(ipv4-addr|ipv6-addr|domain-name|file):(value|hashes\.(?:'[^']+'|[A-Za-z0-9-]+))\s*=\s*'((?:[^'\\]|\\.)*)'`)
+
+// parseSTIX reads the indicator objects of a STIX 2.1 bundle. Revoked and expired
+// indicators and patterns in other languages are skipped.
+func parseSTIX(r io.Reader, feed string, now time.Time) ([]Indicator, int, error) {
+    var bundle stixBundle
+    if err := json.NewDecoder(r).Decode(&bundle); err != nil {
+        return nil, 0, fmt.Errorf("invalid STIX bundle: %w", err)
+    }
+    if bundle.Type != "bundle" {
+        return nil, 0, fmt.Errorf("not a STIX bundle (type %q)", bundle.Type)
+    }
+
+    var indicators []Indicator
+    skipped := 0
+    for _, obj := range bundle.Objects {
+        if obj.Type != "indicator" {
+            continue
+        }
+        if obj.Revoked || (!obj.ValidUntil.IsZero() && obj.ValidUntil.Before(now)) ||
+            (obj.PatternType != "" && obj.PatternType != "stix") {
+            skipped++
+            continue
+        }
+        description := obj.Name
+        if description == "" {
+            description = obj.Description
+        }
+
+        comparisons := stixComparison.FindAllStringSubmatch(obj.Pattern, -1)
+        if len(comparisons) == 0 {
+            skipped++
+            continue
+        }
+        for _, c := range comparisons {
+            typ := ""
+            switch c[1] {
+            case "ipv4-addr", "ipv6-addr":
+                typ = "ip"
+            case "domain-name":
+                typ = "domain"
+            case "file":
+                typ = "hash"
+            }
+            value := strings.ReplaceAll(c[3], `\'`, `'`)
+            ind, ok := ParseIndicator(value, typ)
+            if !ok {
+                skipped++
+                continue
+            }
+            ind.Feed, ind.Description = feed, description
+            indicators = append(indicators, ind)
+        }
+    }
+    return indicators, skipped, nil
+}
@@ -0,0 +1,202 @@
+package threatintel
+
+import (
+    "net/netip"
+    "regexp"
+    "strings"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+// Indicator is one indicator of compromise from a feed.
+type Indicator struct {
+    Type        string // models.IndicatorIP, IndicatorCIDR, IndicatorDomain or IndicatorHash
+    Value       string // Normalised: lower-case, addresses and prefixes in canonical form
+    Feed        string
+    Description string
+}
+
+var (
+    hexPattern    = regexp.MustCompile(`^[0-9a-f]+$`)
+    domainPattern = regexp.MustCompile(`^(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z][a-z0-9-]{0,61}[a-z0-9]$`)
+    // domainInText finds host names in free text such as alert descriptions.
+    domainInText = regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z][a-z0-9-]{0,61}[a-z0-9]\b`)
+)
+
+// hashLengths are the hex lengths of MD5, SHA-1, SHA-256 and SHA-512 digests.
+var hashLengths = map[int]bool{32: true, 40: true, 64: true, 128: true}
+
+// ParseIndicator normalises value and works out its type. typ may be empty to detect
+// the type, or one of ip, cidr, domain and hash (also accepted: ipv4, ipv6, md5,
+// sha1, sha256, sha512). ok is false for values that are not a usable indicator.
+func ParseIndicator(value, typ string) (ind Indicator, ok bool) {
+    value = strings.ToLower(strings.TrimSpace(value))
+    value = strings.TrimSuffix(value, ".") // Fully qualified domain names
+    typ = strings.ToLower(strings.TrimSpace(typ))
+
+    if typ == "" || typ == "ip" || typ == "ipv4" || typ == "ipv6" || typ == "cidr" {
+        if addr, err := netip.ParseAddr(value); err == nil {
+            return Indicator{Type: models.IndicatorIP, Value: addr.Unmap().String()}, true
+        }
+        if prefix, err := netip.ParsePrefix(value); err == nil {
+            prefix = prefix.Masked()
+            if prefix.IsSingleIP() {
+                return Indicator{Type: models.IndicatorIP, Value: prefix.Addr().Unmap().String()}, true
+            }
+            return Indicator{Type: models.IndicatorCIDR, Value: prefix.String()}, true
+        }
+        if typ != "" {
+            return Indicator{}, false
+        }
+    }
+    if typ == "" || typ == "hash" || typ == "md5" || typ == "sha1" || typ == "sha256" || typ == "sha512" {
+        if hashLengths[len(value)] && hexPattern.MatchString(value) {
+            return Indicator{Type: models.IndicatorHash, Value: value}, true
+        }
+        if typ != "" {
+            return Indicator{}, false
+        }
+    }
+    if typ == "" || typ == "domain" || typ == "hostname" {
+        if domainPattern.MatchString(value) {
+            return Indicator{Type: models.IndicatorDomain, Value: value}, true
+        }
+    }
+    return Indicator{}, false
+}
+
+// Index looks up the indicators of all loaded feeds. It is immutable once built.
+type Index struct {
+    ips      map[netip.Addr]Indicator
+    prefixes []indexedPrefix
+    domains  map[string]Indicator
+    hashes   map[string]Indicator
+    size     int
+}
+
+type indexedPrefix struct {
+    prefix    netip.Prefix
+    indicator Indicator
+}
+
+func newIndex() *Index {
+    return &Index{
+        ips:     make(map[netip.Addr]Indicator),
+        domains: make(map[string]Indicator),
+        hashes:  make(map[string]Indicator),
+    }
+}
+
+// Len returns the number of distinct indicators.
+func (x *Index) Len() int {
+    return x.size
+}
+
+// add stores an indicator. The first feed listing an indicator wins.
+func (x *Index) add(ind Indicator) {
+    switch ind.Type {
+    case models.IndicatorIP:
+        addr := netip.MustParseAddr(ind.Value)
+        if _, dup := x.ips[addr]; dup {
+            return
+        }
+        x.ips[addr] = ind
+    case models.IndicatorCIDR:
+        prefix := netip.MustParsePrefix(ind.Value)
+        for _, p := range x.prefixes {
+            if p.prefix == prefix {
+                return
+            }
+        }
+        x.prefixes = append(x.prefixes, indexedPrefix{prefix, ind})
+    case models.IndicatorDomain:
+        if _, dup := x.domains[ind.Value]; dup {
+            return
+        }
+        x.domains[ind.Value] = ind
+    case models.IndicatorHash:
+        if _, dup := x.hashes[ind.Value]; dup {
+            return
+        }
+        x.hashes[ind.Value] = ind
+    default:
+        return
+    }
+    x.size++
+}
+
+// lookupIP finds an indicator for an address: an exact match first, then the most
+// specific CIDR block containing it.
+func (x *Index) lookupIP(value string) (Indicator, bool) {
+    addr, err := netip.ParseAddr(strings.TrimSpace(value))
+    if err != nil {
+        return Indicator{}, false
+    }
+    addr = addr.Unmap()
+    if ind, ok := x.ips[addr]; ok {
+        return ind, true
+    }
+    best := -1
+    var found Indicator
+    for _, p := range x.prefixes {
+        if p.prefix.Bits() > best && p.prefix.Contains(addr) {
+            best, found = p.prefix.Bits(), p.indicator
+        }
+    }
+    return found, best >= 0
+}
+
+// lookupDomain finds an indicator for a host name or any of its parent domains, so
+// listing evil.example also flags cdn.evil.example. Top-level domains are never matched.
+func (x *Index) lookupDomain(host string) (Indicator, bool) {
+    host = strings.TrimSuffix(strings.ToLower(host), ".")
+    for strings.Contains(host, ".") {
+        if ind, ok := x.domains[host]; ok {
+            return ind, true
+        }
+        host = host[strings.Index(host, ".")+1:]
+    }
+    return Indicator{}, false
+}
+
+// Match returns the indicators found in the alert's addresses, file hash and in the
+// host names mentioned in its description.
+func (x *Index) Match(alert models.SecurityAlert) []models.ThreatIntelMatch {
+    var matches []models.ThreatIntelMatch
+    found := func(field, value string, ind Indicator) {
+        matches = append(matches, models.ThreatIntelMatch{
+            Field:       field,
+            Value:       value,
+            Indicator:   ind.Value,
+            Type:        ind.Type,
+            Feed:        ind.Feed,
+            Description: ind.Description,
+        })
+    }
+
+    if ind, ok := x.lookupIP(alert.SourceIP); ok {
+        found("source_ip", alert.SourceIP, ind)
+    }
+    if ind, ok := x.lookupIP(alert.TargetIP); ok {
+        found("target_ip", alert.TargetIP, ind)
+    }
+    if hash := strings.ToLower(strings.TrimSpace(alert.FileHash)); hash != "" {
+        if ind, ok := x.hashes[hash]; ok {
+            found("file_hash", alert.FileHash, ind)
+        }
+    }
+    if len(x.domains) > 0 {
+        seen := make(map[string]bool)
+        for _, host := range domainInText.FindAllString(alert.Description, -1) {
+            host = strings.ToLower(host)
+            if seen[host] {
+                continue
+            }
+            seen[host] = true
+            if ind, ok := x.lookupDomain(host); ok {
+                found("description", host, ind)
+            }
+        }
+    }
+    return matches
+}
@@ -0,0 +1,127 @@
+package threatintel
+
+import (
+    "context"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/Kelvinkhyd/GuardianAI/internal/models"
+)
+
+const (
+    textFeed = `# Known C2 servers
+198.51.100.7   # c2 server
+203.0.113.0/24
+not an indicator
+evil.example
+`
+    csvFeed = `indicator,type,description
+44d88612fea8a8f36de82e1278abb02f,md5,EICAR test file
+10.9.8.7,ip,
+`
+    stixFeed = `{
+  "type": "bundle",
+  "id": "bundle--1",
+  "objects": [
+    {"type": "indicator", "name": "Phishing domain", "pattern_type": "stix",
+     "pattern": "[domain-name:value = 'phish.example'] OR [ipv4-addr:value = '192.0.2.1']"},
+    {"type": "indicator", "name": "Ransomware", "pattern_type": "stix",
+     "pattern": "[file:hashes.'SHA-256' = 'E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855']"},
+    {"type": "indicator", "name": "Old", "pattern_type": "stix", "valid_until": "2000-01-01T00:00:00Z",
+     "pattern": "[ipv4-addr:value = '192.0.2.2']"},
+    {"type": "malware", "name": "Not an indicator"}
+  ]
+}`
+)
+
+func writeFeeds(t *testing.T, files map[string]string) string {
+    t.Helper()
+    dir := t.TempDir()
+    for name, content := range files {
+        if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    return dir
+}
+
+func TestLoadFeeds(t *testing.T) {
+    dir := writeFeeds(t, map[string]string{
+        "c2.txt":      textFeed,
+        "hashes.csv":  csvFeed,
+        "bundle.json": stixFeed,
+        "broken.json": "{",
+        "README.md":   "not a feed",
+    })
+    index, err := LoadFeeds(dir)
+    if err != nil {
+        t.Fatalf("LoadFeeds: %v", err)
+    }
+    // 3 from the text feed, 2 from the CSV and 3 from the bundle (the expired one is skipped)
+    if index.Len() != 8 {
+        t.Errorf("Len: got %d, want 8", index.Len())
+    }
+
+    tests := []struct {
+        name  string
+        alert models.SecurityAlert
+        want  []string // field=indicator
+    }{
+        {"exact IP", models.SecurityAlert{SourceIP: "198.51.100.7"}, []string{"source_ip=198.51.100.7"}},
+        {"CIDR", models.SecurityAlert{TargetIP: "203.0.113.99"}, []string{"target_ip=203.0.113.0/24"}},
+        {"mapped IPv6", models.SecurityAlert{SourceIP: "::ffff:10.9.8.7"}, []string{"source_ip=10.9.8.7"}},
+        {"hash", models.SecurityAlert{FileHash: "44D88612FEA8A8F36DE82E1278ABB02F"}, []string{"file_hash=44d88612fea8a8f36de82e1278abb02f"}},
+        {"STIX hash", models.SecurityAlert{FileHash: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+            []string{"file_hash=e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"}},
+        {"domains in the description", models.SecurityAlert{Description: "Beacon to cdn.Evil.Example and phish.example."},
+            []string{"description=evil.example", "description=phish.example"}},
+        {"expired", models.SecurityAlert{SourceIP: "192.0.2.2"}, nil},
+        {"clean", models.SecurityAlert{SourceIP: "10.0.0.1", Description: "example.com"}, nil},
+    }
+    for _, tc := range tests {
+        var got []string
+        for _, m := range index.Match(tc.alert) {
+            got = append(got, m.Field+"="+m.Indicator)
+        }
+        if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+            t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+        }
+    }
+}
+
+func TestEnrichAndReload(t *testing.T) {
+    dir := writeFeeds(t, map[string]string{"c2.txt": textFeed})
+    e, err := NewEngine(dir, 0.2)
+    if err != nil {
+        t.Fatalf("NewEngine: %v", err)
+    }
+
+    alert := models.SecurityAlert{SourceIP: "198.51.100.7", RiskScore: 0.7}
+    if n := e.Enrich(&alert); n != 1 || alert.RiskScore != 0.9 || len(alert.Enrichment.ThreatIntel) != 1 {
+        t.Errorf("Enrich: got %d matches, risk %v, enrichment %+v", n, alert.RiskScore, alert.Enrichment)
+    }
+    if m := alert.Enrichment.ThreatIntel[0]; m.Feed != "c2.txt" || m.Description != "c2 server" || m.Type != models.IndicatorIP {
+        t.Errorf("match: got %+v", m)
+    }
+    clean := models.SecurityAlert{SourceIP: "10.0.0.1", RiskScore: 0.5}
+    if n := e.Enrich(&clean); n != 0 || clean.RiskScore != 0.5 || clean.Enrichment != nil {
+        t.Errorf("Enrich(clean): got %d matches, risk %v, enrichment %+v", n, clean.RiskScore, clean.Enrichment)
+    }
+
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    go e.Run(ctx, 10*time.Millisecond)
+    if err := os.WriteFile(filepath.Join(dir, "more.txt"), []byte("10.0.0.1\n"), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    deadline := time.Now().Add(5 * time.Second)
+    for len(e.Index().Match(clean)) == 0 {
+        if time.Now().After(deadline) {
+            t.Fatal("the new feed was not loaded")
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+}
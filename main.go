@@ -1,45 +1,219 @@
 package main
 
 import (
+    "context"
     "log"
     "net/http"
 
     "github.com/gorilla/mux"
 
     "github.com/Kelvinkhyd/GuardianAI/internal/api"
+    "github.com/Kelvinkhyd/GuardianAI/internal/auth"
     "github.com/Kelvinkhyd/GuardianAI/internal/config"
     "github.com/Kelvinkhyd/GuardianAI/internal/database"
+    "github.com/Kelvinkhyd/GuardianAI/internal/dedup"
+    "github.com/Kelvinkhyd/GuardianAI/internal/ingest"
     "github.com/Kelvinkhyd/GuardianAI/internal/kafka" // Import kafka package
+    "github.com/Kelvinkhyd/GuardianAI/internal/outbox"
+    "github.com/Kelvinkhyd/GuardianAI/internal/processor"
     "github.com/Kelvinkhyd/GuardianAI/internal/repository"
+    "github.com/Kelvinkhyd/GuardianAI/internal/stream"
 )
 
 func main() {
     cfg := config.LoadConfig() // Load configuration
 
-    // Establish database connection
-    dbConn, err := database.NewDBConnection(cfg.DatabaseURL)
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+
+    // Initialize repositories
+    var alertRepo repository.AlertRepository
+    var outboxRepo repository.OutboxRepository
+    var incidentRepo repository.IncidentRepository
+    var eventRepo repository.AlertEventRepository
+    var apiKeyRepo repository.APIKeyRepository
+    var eventNotifications <-chan struct{} // Wakes the alert stream when events are recorded
+    if cfg.RepositoryBackend == "memory" {
+        log.Println("Using the in-memory repository, alerts will be lost on restart.")
+        memoryRepo := repository.NewMemoryRepository()
+        alertRepo, outboxRepo, incidentRepo, eventRepo, apiKeyRepo = memoryRepo, memoryRepo, memoryRepo, memoryRepo, memoryRepo
+        eventNotifications = memoryRepo.AlertEventNotifications()
+    } else {
+        // Establish database connection
+        dbConn, err := database.NewDBConnection(cfg.DatabaseURL)
+        if err != nil {
+            log.Fatalf("Failed to connect to database: %v", err)
+        }
+        defer dbConn.Close() // Ensure database connection is closed when main exits
+
+        // Bring the schema up to date
+        if err := dbConn.Migrate(context.Background(), cfg.AutoMigrate); err != nil {
+            log.Fatalf("Failed to migrate database: %v", err)
+        }
+
+        alertRepo = repository.NewPgAlertRepository(dbConn.DB)
+        outboxRepo = repository.NewPgOutboxRepository(dbConn.DB)
+        incidentRepo = repository.NewPgIncidentRepository(dbConn.DB)
+        eventRepo = repository.NewPgAlertEventRepository(dbConn.DB)
+        apiKeyRepo = repository.NewPgAPIKeyRepository(dbConn.DB)
+
+        // Alert events are also recorded by the processor, so listen for them in Postgres
+        eventNotifications, err = repository.ListenAlertEvents(ctx, cfg.DatabaseURL)
+        if err != nil {
+            log.Fatalf("Failed to listen for alert events: %v", err)
+        }
+    }
+
+    // Initialize the message bus
+    var publisher kafka.Publisher
+    if cfg.MessageBus == "memory" {
+        // No broker: run the processor in this process, on an in-memory bus
+        log.Println("Using the in-memory message bus, the alert processor runs inside the API server.")
+        bus := kafka.NewMemoryBus(cfg.ProcessorWorkers)
+        defer bus.Close()
+        publisher = bus.NewProducer(cfg.KafkaTopic)
+
+        consumer := bus.NewConsumer(cfg.KafkaTopic, processor.ConsumerGroupID, processor.RetryPolicy(cfg), cfg.ProcessorWorkers)
+        defer consumer.Close()
+        alertProcessor := processor.NewFromConfig(cfg, alertRepo)
+        correlator, err := processor.CorrelatorFromConfig(cfg, incidentRepo)
+        if err != nil {
+            log.Fatalf("Failed to set up the alert processor: %v", err)
+        }
+        if correlator != nil {
+            alertProcessor.SetCorrelator(correlator)
+        }
+        sigmaEngine, err := processor.SigmaFromConfig(cfg)
+        if err != nil {
+            log.Fatalf("Failed to set up the alert processor: %v", err)
+        }
+        if sigmaEngine != nil {
+            alertProcessor.SetSigma(sigmaEngine, cfg.SigmaReloadInterval)
+        }
+        tenantSigma, err := processor.TenantSigmaFromConfig(cfg)
+        if err != nil {
+            log.Fatalf("Failed to set up the alert processor: %v", err)
+        }
+        for tenant, engine := range tenantSigma {
+            alertProcessor.SetTenantSigma(tenant, engine, cfg.SigmaReloadInterval)
+        }
+        threatIntel, err := processor.ThreatIntelFromConfig(cfg)
+        if err != nil {
+            log.Fatalf("Failed to set up the alert processor: %v", err)
+        }
+        if threatIntel != nil {
+            alertProcessor.SetThreatIntel(threatIntel, cfg.ThreatIntelReloadInterval)
+        }
+        geoIP, err := processor.GeoIPFromConfig(cfg)
+        if err != nil {
+            log.Fatalf("Failed to set up the alert processor: %v", err)
+        }
+        if geoIP != nil {
+            defer geoIP.Close()
+            alertProcessor.SetGeoIP(geoIP)
+        }
+        go alertProcessor.Run(ctx, consumer)
+    } else {
+        // Initialize Kafka Producer
+        kafkaProducer := kafka.NewProducer(cfg.KafkaBrokers, cfg.KafkaTopic)
+        defer kafkaProducer.Close() // Ensure Kafka producer is closed
+        publisher = kafkaProducer
+    }
+
+    // Start the outbox relay, which publishes stored alerts to the message bus
+    relay := outbox.NewRelay(outboxRepo, publisher, cfg.OutboxPollInterval, cfg.OutboxBatchSize)
+    go relay.Run(ctx)
+
+    // Start the hub behind the alert stream
+    streamHub, err := stream.NewHub(ctx, eventRepo, eventNotifications, cfg.StreamPollInterval)
     if err != nil {
-        log.Fatalf("Failed to connect to database: %v", err)
+        log.Fatalf("Failed to set up the alert stream: %v", err)
     }
-    defer dbConn.Close() // Ensure database connection is closed when main exits
+    go streamHub.Run(ctx)
 
-    // Initialize Kafka Producer
-    kafkaProducer := kafka.NewProducer(cfg.KafkaBrokers, cfg.KafkaTopic)
-    defer kafkaProducer.Close() // Ensure Kafka producer is closed
+    // Set up deduplication of incoming alerts
+    var dedupPolicy *dedup.Policy
+    if cfg.DedupEnabled {
+        dedupPolicy, err = dedup.NewPolicy(cfg.DedupFields, cfg.DedupWindow, cfg.DedupEscalateAt)
+        if err != nil {
+            log.Fatalf("Invalid deduplication settings: %v", err)
+        }
+        log.Printf("Deduplicating alerts on %v within %s, escalating at %v occurrences", dedupPolicy.Fields(), dedupPolicy.Window, dedupPolicy.EscalateAt)
+    }
 
-    // Initialize repository
-    alertRepo := repository.NewPgAlertRepository(dbConn.DB)
+    // Every ingestion path stores alerts through the same service
+    ingestService := ingest.NewService(alertRepo, dedupPolicy)
 
-    // Initialize API handlers with the repository and Kafka producer
-    apiHandler := api.NewHandler(alertRepo, kafkaProducer) // Pass kafkaProducer
+    // Start the syslog listeners, if configured
+    syslogServer, err := ingest.SyslogFromConfig(cfg, ingestService)
+    if err != nil {
+        log.Fatalf("Failed to set up syslog ingestion: %v", err)
+    }
+    if syslogServer != nil {
+        go syslogServer.Run(ctx)
+    }
+
+    // Set up authentication of API requests
+    var authn *auth.Authenticator // nil leaves every route open
+    if cfg.AuthEnabled {
+        var jwtVerifier *auth.JWTVerifier
+        if cfg.AuthJWKSFile != "" {
+            jwtVerifier, err = auth.NewJWTVerifier(cfg.AuthJWKSFile, cfg.AuthJWTIssuer, cfg.AuthJWTAudience, cfg.AuthJWTRolesClaim, cfg.AuthJWTTenantClaim)
+            if err != nil {
+                log.Fatalf("Failed to set up JWT authentication: %v", err)
+            }
+        }
+        if cfg.AuthAdminKey == "" && jwtVerifier == nil {
+            log.Println("Neither AUTH_ADMIN_KEY nor AUTH_JWKS_FILE is set, only existing API keys can access the API.")
+        }
+        authn = auth.NewAuthenticator(apiKeyRepo, cfg.AuthAdminKey, jwtVerifier)
+    } else {
+        log.Println("WARNING: Authentication is disabled, anyone who can reach the API can read and submit alerts.")
+    }
+
+    // Initialize API handlers with the repository
+    apiHandler := api.NewHandler(alertRepo, ingestService)
+    incidentHandler := api.NewIncidentHandler(incidentRepo)
+    streamHandler := api.NewStreamHandler(streamHub, cfg.StreamAllowedOrigins)
+    apiKeyHandler := api.NewAPIKeyHandler(apiKeyRepo)
+    var hecHandler *api.HECHandler
+    if cfg.HECEnabled {
+        hecMapping, err := ingest.LoadHECMapping(cfg.HECFieldMapping)
+        if err != nil {
+            log.Fatalf("Failed to set up the HEC endpoints: %v", err)
+        }
+        hecHandler = api.NewHECHandler(ingestService, hecMapping, cfg.HECAckEnabled)
+    }
 
     // Create a new Gorilla Mux router
     router := mux.NewRouter()
 
-    // Define routes using the Mux router
-    router.HandleFunc("/alerts", apiHandler.HandleAlerts).Methods("POST")
-    router.HandleFunc("/alerts", apiHandler.GetAlerts).Methods("GET")
-    router.HandleFunc("/alerts/{id}", apiHandler.GetAlertByID).Methods("GET")
+    // Define routes using the Mux router. Every route declares the role it requires.
+    router.HandleFunc("/alerts", authn.Require(auth.RoleIngest, apiHandler.HandleAlerts)).Methods("POST")
+    router.HandleFunc("/alerts/batch", authn.Require(auth.RoleIngest, apiHandler.HandleAlertsBatch)).Methods("POST")
+    router.HandleFunc("/alerts", authn.Require(auth.RoleAnalyst, apiHandler.GetAlerts)).Methods("GET")
+    // Registered before /alerts/{id}; WebSocket upgrades and SSE share the path
+    router.HandleFunc("/alerts/stream", authn.RequireStream(auth.RoleAnalyst, streamHandler.StreamAlertsWS)).Methods("GET").MatcherFunc(api.IsWebSocketRequest)
+    router.HandleFunc("/alerts/stream", authn.RequireStream(auth.RoleAnalyst, streamHandler.StreamAlerts)).Methods("GET")
+    router.HandleFunc("/alerts/{id}", authn.Require(auth.RoleAnalyst, apiHandler.GetAlertByID)).Methods("GET")
+    router.HandleFunc("/alerts/{id}", authn.Require(auth.RoleAnalyst, apiHandler.UpdateAlert)).Methods("PATCH")
+    router.HandleFunc("/incidents", authn.Require(auth.RoleAnalyst, incidentHandler.GetIncidents)).Methods("GET")
+    router.HandleFunc("/incidents/{id}", authn.Require(auth.RoleAnalyst, incidentHandler.GetIncidentByID)).Methods("GET")
+    router.HandleFunc("/incidents/{id}", authn.Require(auth.RoleAnalyst, incidentHandler.UpdateIncident)).Methods("PATCH")
+    router.HandleFunc("/admin/api-keys", authn.Require(auth.RoleAdmin, apiKeyHandler.CreateAPIKey)).Methods("POST")
+    router.HandleFunc("/admin/api-keys", authn.Require(auth.RoleAdmin, apiKeyHandler.GetAPIKeys)).Methods("GET")
+    router.HandleFunc("/admin/api-keys/{id}", authn.Require(auth.RoleAdmin, apiKeyHandler.RevokeAPIKey)).Methods("DELETE")
+    if hecHandler != nil {
+        // Splunk HEC paths, so agents configured for Splunk work unchanged
+        for _, path := range []string{"/services/collector", "/services/collector/event", "/services/collector/event/1.0"} {
+            router.HandleFunc(path, authn.Require(auth.RoleIngest, hecHandler.HandleEvent)).Methods("POST")
+        }
+        for _, path := range []string{"/services/collector/raw", "/services/collector/raw/1.0"} {
+            router.HandleFunc(path, authn.Require(auth.RoleIngest, hecHandler.HandleRaw)).Methods("POST")
+        }
+        router.HandleFunc("/services/collector/ack", authn.Require(auth.RoleIngest, hecHandler.HandleAck)).Methods("POST")
+        router.HandleFunc("/services/collector/health", hecHandler.HandleHealth).Methods("GET")
+    }
 
     // Attach the Mux router to the HTTP server
     log.Printf("GuardianAI API server starting on port %s", cfg.ServerPort)